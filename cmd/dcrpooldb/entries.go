@@ -0,0 +1,426 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/decred/dcrpool/pool"
+)
+
+const (
+	textFormat = "text"
+	jsonFormat = "json"
+	csvFormat  = "csv"
+)
+
+// entry is a listed database entry.
+type entry struct {
+	// value is the decoded database value, exported as is in json.
+	value interface{}
+
+	// row is the value rendered as text and csv columns.
+	row []string
+
+	// The filterable attributes of the entry, an empty account or status
+	// and a zero creation time are not filtered on.
+	account   string
+	height    uint32
+	hasHeight bool
+	createdOn time.Time
+	status    string
+}
+
+// kind describes how entries of a kind are fetched and rendered.
+type kind struct {
+	columns []string
+	fetch   func(db *pool.BoltDB) ([]*entry, error)
+}
+
+// formatTime renders the provided time in RFC3339, zero times render empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// unixTime converts the provided unix time in seconds, zero converts to
+// the zero time.
+func unixTime(secs int64) time.Time {
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// nanoTime converts the provided unix time in nanoseconds, zero converts to
+// the zero time.
+func nanoTime(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// ratString renders the provided rational, nil renders empty.
+func ratString(r *big.Rat) string {
+	if r == nil {
+		return ""
+	}
+	return r.FloatString(8)
+}
+
+// paymentEntries converts the provided payments to entries.
+func paymentEntries(payments []*pool.Payment) []*entry {
+	entries := make([]*entry, 0, len(payments))
+	for _, p := range payments {
+		createdOn := nanoTime(p.CreatedOn)
+		id := pool.GeneratePaymentID(p.CreatedOn, p.Height, p.Account)
+		entries = append(entries, &entry{
+			value: p,
+			row: []string{string(id), p.Account,
+				strconv.FormatUint(uint64(p.Height), 10),
+				strconv.FormatUint(uint64(p.EstimatedMaturity), 10),
+				p.Amount.String(), formatTime(createdOn),
+				strconv.FormatUint(uint64(p.PaidOnHeight), 10),
+				p.TransactionID},
+			account:   p.Account,
+			height:    p.Height,
+			hasHeight: true,
+			createdOn: createdOn,
+		})
+	}
+	return entries
+}
+
+var paymentColumns = []string{"id", "account", "height", "estimatedmaturity",
+	"amount", "createdon", "paidonheight", "transactionid"}
+
+// kinds are the listable entry kinds, keyed by name.
+var kinds = map[string]kind{
+	"accounts": {
+		columns: []string{"id", "address", "createdon"},
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			accounts, err := db.ListAccounts()
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]*entry, 0, len(accounts))
+			for _, a := range accounts {
+				createdOn := unixTime(int64(a.CreatedOn))
+				entries = append(entries, &entry{
+					value:     a,
+					row:       []string{a.UUID, a.Address, formatTime(createdOn)},
+					account:   a.UUID,
+					createdOn: createdOn,
+				})
+			}
+			return entries, nil
+		},
+	},
+	"shares": {
+		columns: []string{"id", "account", "weight", "difficulty",
+			"createdon"},
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			shares, err := db.FetchShares(0, math.MaxInt64)
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]*entry, 0, len(shares))
+			for _, s := range shares {
+				createdOn := nanoTime(s.CreatedOn)
+				entries = append(entries, &entry{
+					value: s,
+					row: []string{strconv.FormatInt(s.CreatedOn, 10),
+						s.Account, ratString(s.Weight),
+						ratString(s.Difficulty),
+						formatTime(createdOn)},
+					account:   s.Account,
+					createdOn: createdOn,
+				})
+			}
+			return entries, nil
+		},
+	},
+	"jobs": {
+		columns: []string{"id", "height", "header"},
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			jobs, err := db.ListJobs()
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]*entry, 0, len(jobs))
+			for _, j := range jobs {
+				entries = append(entries, &entry{
+					value: j,
+					row: []string{j.UUID,
+						strconv.FormatUint(uint64(j.Height), 10), j.Header},
+					height:    j.Height,
+					hasHeight: true,
+				})
+			}
+			return entries, nil
+		},
+	},
+	"work": {
+		columns: []string{"id", "blockhash", "height", "minedby", "miner",
+			"status", "createdon"},
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			work, err := db.ListAcceptedWork()
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]*entry, 0, len(work))
+			for _, w := range work {
+				createdOn := unixTime(w.CreatedOn)
+				entries = append(entries, &entry{
+					value: w,
+					row: []string{w.UUID, w.BlockHash,
+						strconv.FormatUint(uint64(w.Height), 10), w.MinedBy,
+						w.Miner, string(w.Status), formatTime(createdOn)},
+					account:   w.MinedBy,
+					height:    w.Height,
+					hasHeight: true,
+					createdOn: createdOn,
+					status:    string(w.Status),
+				})
+			}
+			return entries, nil
+		},
+	},
+	"payments": {
+		columns: paymentColumns,
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			payments, err := db.FetchPayments()
+			if err != nil {
+				return nil, err
+			}
+			return paymentEntries(payments), nil
+		},
+	},
+	"archivedpayments": {
+		columns: paymentColumns,
+		fetch: func(db *pool.BoltDB) ([]*entry, error) {
+			payments, err := db.FetchArchivedPayments()
+			if err != nil {
+				return nil, err
+			}
+			return paymentEntries(payments), nil
+		},
+	},
+}
+
+// kindNames returns the sorted names of the listable entry kinds.
+func kindNames() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fetchEntries fetches all entries of the provided kind.
+func fetchEntries(db *pool.BoltDB, name string) (*listing, error) {
+	k, ok := kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown entry kind %q, expected one of %s",
+			name, strings.Join(kindNames(), ", "))
+	}
+	entries, err := k.fetch(db)
+	if err != nil {
+		return nil, err
+	}
+	return &listing{columns: k.columns, entries: entries}, nil
+}
+
+// listing is a set of entries of the same kind.
+type listing struct {
+	columns []string
+	entries []*entry
+}
+
+// filter selects the entries matching all of its set criteria.
+type filter struct {
+	account   string
+	minHeight uint32
+	maxHeight uint32
+	since     time.Time
+	until     time.Time
+	status    string
+}
+
+// match returns whether the provided entry matches the filter. Criteria
+// not applicable to the entry do not match when set.
+func (f *filter) match(e *entry) bool {
+	if f.account != "" && e.account != f.account {
+		return false
+	}
+	if f.minHeight != 0 && (!e.hasHeight || e.height < f.minHeight) {
+		return false
+	}
+	if f.maxHeight != 0 && (!e.hasHeight || e.height > f.maxHeight) {
+		return false
+	}
+	if !f.since.IsZero() && (e.createdOn.IsZero() ||
+		e.createdOn.Before(f.since)) {
+		return false
+	}
+	if !f.until.IsZero() && (e.createdOn.IsZero() ||
+		!e.createdOn.Before(f.until)) {
+		return false
+	}
+	if f.status != "" && e.status != f.status {
+		return false
+	}
+	return true
+}
+
+// apply returns the listing of the entries matching the filter.
+func (f *filter) apply(l *listing) *listing {
+	entries := make([]*entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return &listing{columns: l.columns, entries: entries}
+}
+
+// writeEntries writes the provided listing in the provided format.
+func writeEntries(w io.Writer, format string, l *listing) error {
+	switch format {
+	case jsonFormat:
+		values := make([]interface{}, 0, len(l.entries))
+		for _, e := range l.entries {
+			values = append(values, e.value)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+
+	case csvFormat:
+		cw := csv.NewWriter(w)
+		err := cw.Write(l.columns)
+		if err != nil {
+			return err
+		}
+		for _, e := range l.entries {
+			err := cw.Write(e.row)
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(l.columns, "\t")))
+		for _, e := range l.entries {
+			fmt.Fprintln(tw, strings.Join(e.row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// metadata is the pool metadata of the database.
+type metadata struct {
+	Version              uint32 `json:"version"`
+	PoolMode             string `json:"poolmode"`
+	LastPaymentHeight    uint32 `json:"lastpaymentheight"`
+	LastPaymentPaidOn    string `json:"lastpaymentpaidon"`
+	LastPaymentCreatedOn string `json:"lastpaymentcreatedon"`
+	TxFeeReserve         string `json:"txfeereserve"`
+	PoolBalance          string `json:"poolbalance"`
+	RoundWork            string `json:"roundwork"`
+}
+
+// fetchMetadata fetches the pool metadata of the provided database.
+func fetchMetadata(db *pool.BoltDB) (*metadata, error) {
+	version, err := db.Version()
+	if err != nil {
+		return nil, err
+	}
+	poolMode := "unset"
+	mode, err := db.FetchPoolMode()
+	if err != nil && !pool.IsError(err, pool.ErrValueNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		poolMode = "pool"
+		if mode == 1 {
+			poolMode = "solo"
+		}
+	}
+	state, err := db.FetchPaymentState()
+	if err != nil {
+		return nil, err
+	}
+	work, err := db.FetchRoundWork()
+	if err != nil {
+		return nil, err
+	}
+	return &metadata{
+		Version:              version,
+		PoolMode:             poolMode,
+		LastPaymentHeight:    state.LastPaymentHeight,
+		LastPaymentPaidOn:    formatTime(nanoTime(int64(state.LastPaymentPaidOn))),
+		LastPaymentCreatedOn: formatTime(nanoTime(int64(state.LastPaymentCreatedOn))),
+		TxFeeReserve:         state.TxFeeReserve.String(),
+		PoolBalance:          state.PoolBalance.String(),
+		RoundWork:            work.FloatString(8),
+	}, nil
+}
+
+// writeMetadata writes the provided pool metadata in the provided format.
+func writeMetadata(w io.Writer, format string, m *metadata) error {
+	fields := [][]string{
+		{"version", strconv.FormatUint(uint64(m.Version), 10)},
+		{"poolmode", m.PoolMode},
+		{"lastpaymentheight", strconv.FormatUint(uint64(m.LastPaymentHeight), 10)},
+		{"lastpaymentpaidon", m.LastPaymentPaidOn},
+		{"lastpaymentcreatedon", m.LastPaymentCreatedOn},
+		{"txfeereserve", m.TxFeeReserve},
+		{"poolbalance", m.PoolBalance},
+		{"roundwork", m.RoundWork},
+	}
+	switch format {
+	case jsonFormat:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+
+	case csvFormat:
+		cw := csv.NewWriter(w)
+		err := cw.Write([]string{"key", "value"})
+		if err != nil {
+			return err
+		}
+		err = cw.WriteAll(fields)
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, f := range fields {
+			fmt.Fprintf(tw, "%s\t%s\n", f[0], f[1])
+		}
+		return tw.Flush()
+	}
+}
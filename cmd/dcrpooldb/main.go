@@ -0,0 +1,232 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// dcrpooldb inspects and repairs an offline dcrpool bolt database. It lists,
+// filters and exports pool data, shows pool metadata, deletes individual
+// entries and checks the consistency of the database.
+//
+// Usage:
+//
+//	dcrpooldb [options] list <accounts|shares|jobs|work|payments|archivedpayments>
+//	dcrpooldb [options] metadata
+//	dcrpooldb [options] --readwrite delete <account|share|job|work|payment> <id>
+//	dcrpooldb [options] check
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/decred/dcrpool/pool"
+)
+
+var (
+	defaultDBFile = filepath.Join(dcrutil.AppDataDir("dcrpool", false),
+		"data", "dcrpool.kv")
+)
+
+// config defines the configuration options of the tool.
+type config struct {
+	DBFile    string `long:"dbfile" description:"Path to the database file."`
+	ReadWrite bool   `long:"readwrite" description:"Open the database read-write, required to delete entries. The pool must not be running."`
+	Format    string `long:"format" description:"The output format. {text, json, csv}"`
+	Output    string `long:"output" description:"Path to the file to export the output to. Defaults to standard output."`
+	Account   string `long:"account" description:"Only list entries of the provided account id."`
+	MinHeight uint32 `long:"minheight" description:"Only list entries at or above the provided height."`
+	MaxHeight uint32 `long:"maxheight" description:"Only list entries at or below the provided height."`
+	Since     string `long:"since" description:"Only list entries created at or after the provided RFC3339 time."`
+	Until     string `long:"until" description:"Only list entries created before the provided RFC3339 time."`
+	Status    string `long:"status" description:"Only list accepted work with the provided status. {submitted, accepted, confirmed, orphaned, matured}"`
+}
+
+const usage = "[options] list <accounts|shares|jobs|work|payments|" +
+	"archivedpayments> | metadata | delete <account|share|job|work|payment> " +
+	"<id> | check"
+
+// parseTime parses the provided RFC3339 time, the zero time is returned
+// for an empty string.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %v", s, err)
+	}
+	return t, nil
+}
+
+// newFilter creates the entry filter of the provided config.
+func newFilter(cfg *config) (*filter, error) {
+	since, err := parseTime(cfg.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseTime(cfg.Until)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{
+		account:   cfg.Account,
+		minHeight: cfg.MinHeight,
+		maxHeight: cfg.MaxHeight,
+		since:     since,
+		until:     until,
+		status:    cfg.Status,
+	}, nil
+}
+
+// deleteEntry removes the entry of the provided kind referenced by the
+// provided id. An error is returned if the entry does not exist.
+func deleteEntry(db *pool.BoltDB, kind string, id string) error {
+	switch kind {
+	case "account":
+		_, err := db.FetchAccount(id)
+		if err != nil {
+			return err
+		}
+		return db.DeleteAccount(id)
+	case "share":
+		createdOn, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid share creation time %q: %v", id, err)
+		}
+		return db.DeleteShare(createdOn)
+	case "job":
+		_, err := db.FetchJob(id)
+		if err != nil {
+			return err
+		}
+		return db.DeleteJob(id)
+	case "work":
+		_, err := db.FetchAcceptedWork(id)
+		if err != nil {
+			return err
+		}
+		return db.DeleteAcceptedWork(id)
+	case "payment":
+		_, err := db.FetchPayment(id)
+		if err != nil {
+			return err
+		}
+		return db.DeletePayment(id)
+	default:
+		return fmt.Errorf("unknown entry kind %q", kind)
+	}
+}
+
+func run() error {
+	cfg := config{
+		DBFile: defaultDBFile,
+		Format: textFormat,
+	}
+	parser := flags.NewParser(&cfg, flags.Default)
+	parser.Usage = usage
+	args, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no command provided, usage: dcrpooldb %s", usage)
+	}
+	switch cfg.Format {
+	case textFormat, jsonFormat, csvFormat:
+	default:
+		return fmt.Errorf("format must be one of %s, %s, %s", textFormat,
+			jsonFormat, csvFormat)
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.Output != "" {
+		f, err := os.Create(cfg.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	db, err := pool.OpenBoltDB(cfg.DBFile, !cfg.ReadWrite)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: dcrpooldb [options] list <%s>",
+				strings.Join(kindNames(), "|"))
+		}
+		f, err := newFilter(&cfg)
+		if err != nil {
+			return err
+		}
+		entries, err := fetchEntries(db, args[0])
+		if err != nil {
+			return err
+		}
+		return writeEntries(out, cfg.Format, f.apply(entries))
+
+	case "metadata":
+		meta, err := fetchMetadata(db)
+		if err != nil {
+			return err
+		}
+		return writeMetadata(out, cfg.Format, meta)
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: dcrpooldb [options] --readwrite " +
+				"delete <account|share|job|work|payment> <id>")
+		}
+		if !cfg.ReadWrite {
+			return fmt.Errorf("deleting entries requires --readwrite")
+		}
+		err := deleteEntry(db, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Deleted %s %s.\n", args[0], args[1])
+		return nil
+
+	case "check":
+		issues, err := db.CheckConsistency()
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			fmt.Fprintln(out, issue)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("%d consistency issues found", len(issues))
+		}
+		fmt.Fprintln(os.Stderr, "No consistency issues found.")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q, usage: dcrpooldb %s", cmd,
+			usage)
+	}
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
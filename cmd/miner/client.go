@@ -7,6 +7,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"math/big"
@@ -122,7 +123,15 @@ func (m *Miner) keepAlive(ctx context.Context) {
 			m.connectedMtx.RUnlock()
 
 			poolAddr := strings.Replace(m.config.Pool, "stratum+tcp", "", 1)
-			conn, err := net.Dial("tcp", poolAddr)
+			poolAddr = strings.Replace(poolAddr, "stratum+ssl", "", 1)
+			poolAddr = strings.TrimPrefix(poolAddr, "://")
+			var conn net.Conn
+			var err error
+			if m.config.tlsConfig != nil {
+				conn, err = tls.Dial("tcp", poolAddr, m.config.tlsConfig)
+			} else {
+				conn, err = net.Dial("tcp", poolAddr)
+			}
 			if err != nil {
 				log.Errorf("unable connect to %s, %v", poolAddr, err)
 				time.Sleep(time.Second * 5)
@@ -5,7 +5,10 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	_ "net/http/pprof"
 	"os"
@@ -53,8 +56,11 @@ type config struct {
 	MaxProcs   int    `long:"maxprocs" ini-name:"maxprocs" description:"Number of CPU cores to use. Default is all cores."`
 	Profile    string `long:"profile" ini-name:"profile" description:"Enable HTTP profiling on given [addr:]port -- NOTE port must be between 1024 and 65536"`
 	Stall      bool   `long:"stall" ini-name:"stall" description:"Do not generate work submissions"`
+	TLS        bool   `long:"tls" ini-name:"tls" description:"Connect to the mining pool over TLS"`
+	TLSCert    string `long:"tlscert" ini-name:"tlscert" description:"Path to the TLS certificate of the mining pool, required for self-signed certificates"`
 
-	net *chaincfg.Params
+	net       *chaincfg.Params
+	tlsConfig *tls.Config
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -424,6 +430,35 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Stratum over TLS is implied by the pool url scheme.
+	if strings.HasPrefix(cfg.Pool, "stratum+ssl") {
+		cfg.TLS = true
+	}
+
+	// Set the TLS configuration if the pool is to be connected to over TLS.
+	if cfg.TLS || cfg.TLSCert != "" {
+		cfg.tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+		if cfg.TLSCert != "" {
+			pem, err := ioutil.ReadFile(cleanAndExpandPath(cfg.TLSCert))
+			if err != nil {
+				str := "%s: unable to read TLS certificate: %v"
+				err := fmt.Errorf(str, funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				return nil, nil, err
+			}
+			certPool := x509.NewCertPool()
+			if !certPool.AppendCertsFromPEM(pem) {
+				str := "%s: invalid TLS certificate: %s"
+				err := fmt.Errorf(str, funcName, cfg.TLSCert)
+				fmt.Fprintln(os.Stderr, err)
+				return nil, nil, err
+			}
+			cfg.tlsConfig.RootCAs = certPool
+		}
+	}
+
 	availableCPUs := runtime.NumCPU()
 	if cfg.MaxProcs < 1 || cfg.MaxProcs > availableCPUs {
 		log.Warnf("%d is not a valid value for MaxProcs. Defaulting to %d.", cfg.MaxProcs, availableCPUs)
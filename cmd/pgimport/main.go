@@ -0,0 +1,81 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// pgimport is a one-shot tool migrating the data of an existing bolt pool
+// database into an empty postgres pool database.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/decred/slog"
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/decred/dcrpool/pool"
+)
+
+// config defines the configuration options of the import.
+type config struct {
+	DBFile    string `long:"dbfile" description:"Path to the bolt database file to import." required:"true"`
+	PGHost    string `long:"pghost" description:"The host of the postgres server."`
+	PGPort    uint32 `long:"pgport" description:"The port of the postgres server."`
+	PGUser    string `long:"pguser" description:"The user of the postgres database."`
+	PGPass    string `long:"pgpass" default-mask:"-" description:"The password of the postgres database user."`
+	PGDBName  string `long:"pgdbname" description:"The name of the postgres database."`
+	PGSSLMode string `long:"pgsslmode" description:"The SSL mode of the postgres connection. {disable, require, verify-ca, verify-full}"`
+}
+
+func run() error {
+	cfg := config{
+		PGHost:    "127.0.0.1",
+		PGPort:    5432,
+		PGUser:    "dcrpool",
+		PGDBName:  "dcrpool",
+		PGSSLMode: "disable",
+	}
+	_, err := flags.Parse(&cfg)
+	if err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	backend := slog.NewBackend(os.Stdout)
+	pool.UseLogger(backend.Logger("POOL"))
+
+	pgCfg := &pool.PostgresConfig{
+		Host:    cfg.PGHost,
+		Port:    cfg.PGPort,
+		User:    cfg.PGUser,
+		Pass:    cfg.PGPass,
+		DBName:  cfg.PGDBName,
+		SSLMode: cfg.PGSSLMode,
+	}
+
+	// The pool mode of the imported data is retained, the postgres database
+	// is only migrated to the latest schema.
+	db, err := pool.OpenPostgresDB(pgCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.ImportBoltDB(cfg.DBFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s into postgres database %s.\n", cfg.DBFile,
+		cfg.PGDBName)
+	return nil
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -34,13 +35,17 @@ const (
 	defaultLogDirname            = "log"
 	defaultLogFilename           = "dcrpool.log"
 	defaultDBFilename            = "dcrpool.kv"
+	defaultSnapshotDirname       = "snapshots"
 	defaultTLSCertFilename       = "dcrpool.cert"
 	defaultTLSKeyFilename        = "dcrpool.key"
 	defaultDcrdRPCHost           = "127.0.0.1"
 	defaultWalletGRPCHost        = "127.0.0.1"
 	defaultMaxGenTime            = time.Second * 15
+	defaultStaleJobGrace         = time.Second * 2
 	defaultPoolFee               = 0.01
 	defaultLastNPeriod           = time.Hour * 24
+	defaultLastNShares           = 100000
+	defaultLastNWork             = 2.0
 	defaultMaxTxFeeReserve       = 0.1
 	defaultSoloPool              = false
 	defaultGUIPort               = 8080
@@ -52,13 +57,35 @@ const (
 	defaultDR3Port               = 5553
 	defaultDR5Port               = 5554
 	defaultD1Port                = 5555
+	defaultMiner                 = pool.CPU
 	defaultDesignation           = "YourPoolNameHere"
 	defaultMaxConnectionsPerHost = 100 // 100 connected clients per host
+	defaultWebhookRetries        = 5
+	defaultWebhookRetryBackoff   = time.Second * 2
+	defaultWorkerOfflineTimeout  = time.Minute * 10
+	defaultHashRateDrop          = 50.0
+	defaultDBDriver              = boltDriver
+	defaultPGHost                = "127.0.0.1"
+	defaultPGPort                = 5432
+	defaultPGUser                = "dcrpool"
+	defaultPGDBName              = "dcrpool"
+	defaultPGSSLMode             = "disable"
+	defaultSnapshotInterval      = time.Hour
+	defaultSnapshotRetention     = 24
+)
+
+const (
+	// boltDriver is the bolt database backend, storing all pool data in
+	// the database file.
+	boltDriver = "bolt"
+	// postgresDriver is the postgres database backend.
+	postgresDriver = "postgres"
 )
 
 var (
 	defaultActiveNet     = chaincfg.SimNetParams().Name
 	defaultPaymentMethod = pool.PPLNS
+	defaultPPLNSWindow   = pool.PeriodWindow
 	defaultMinPayment    = 0.2
 	dcrpoolHomeDir       = dcrutil.AppDataDir("dcrpool", false)
 	defaultConfigFile    = filepath.Join(dcrpoolHomeDir, defaultConfigFilename)
@@ -83,18 +110,30 @@ type config struct {
 	DebugLevel            string        `long:"debuglevel" ini-name:"debuglevel" description:"Logging level for all subsystems. {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	LogDir                string        `long:"logdir" ini-name:"logdir" description:"Directory to log output."`
 	DBFile                string        `long:"dbfile" ini-name:"dbfile" description:"Path to the database file."`
+	DBDriver              string        `long:"dbdriver" ini-name:"dbdriver" description:"The database backend of the pool. {bolt, postgres}"`
+	PGHost                string        `long:"pghost" ini-name:"pghost" description:"The host of the postgres server, used with the postgres database backend."`
+	PGPort                uint32        `long:"pgport" ini-name:"pgport" description:"The port of the postgres server."`
+	PGUser                string        `long:"pguser" ini-name:"pguser" description:"The user of the postgres database."`
+	PGPass                string        `long:"pgpass" ini-name:"pgpass" default-mask:"-" description:"The password of the postgres database user."`
+	PGDBName              string        `long:"pgdbname" ini-name:"pgdbname" description:"The name of the postgres database."`
+	PGSSLMode             string        `long:"pgsslmode" ini-name:"pgsslmode" description:"The SSL mode of the postgres connection. {disable, require, verify-ca, verify-full}"`
 	DcrdRPCHost           string        `long:"dcrdrpchost" ini-name:"dcrdrpchost" description:"The ip:port to establish an RPC connection for dcrd."`
 	DcrdRPCCert           string        `long:"dcrdrpccert" ini-name:"dcrdrpccert" description:"The dcrd RPC certificate."`
+	DcrdRPCFailoverHosts  []string      `long:"dcrdrpcfailoverhosts" ini-name:"dcrdrpcfailoverhosts" description:"The ip:port of additional dcrd RPC connections to fail over to. Failover nodes use the RPC credentials and certificate of the primary node."`
 	WalletGRPCHost        string        `long:"walletgrpchost" ini-name:"walletgrpchost" description:"The ip:port to establish a GRPC connection for the wallet."`
 	WalletRPCCert         string        `long:"walletrpccert" ini-name:"walletrpccert" description:"The wallet RPC certificate."`
 	RPCUser               string        `long:"rpcuser" ini-name:"rpcuser" description:"Username for RPC connections."`
 	RPCPass               string        `long:"rpcpass" ini-name:"rpcpass" default-mask:"-" description:"Password for RPC connections."`
 	PoolFeeAddrs          []string      `long:"poolfeeaddrs" ini-name:"poolfeeaddrs" description:"Payment addresses to use for pool fee transactions. These addresses should be generated from a dedicated wallet account for pool fees."`
+	MiningAddrs           []string      `long:"miningaddrs" ini-name:"miningaddrs" description:"The mining addresses of the mining node. Coinbase outputs paying to these addresses are the mining rewards distributed by the pool."`
 	PoolFee               float64       `long:"poolfee" ini-name:"poolfee" description:"The fee charged for pool participation. eg. 0.01 (1%), 0.05 (5%)."`
 	MaxTxFeeReserve       float64       `long:"maxtxfeereserve" ini-name:"maxtxfeereserve" description:"The maximum amount reserved for transaction fees, in DCR."`
 	MaxGenTime            time.Duration `long:"maxgentime" ini-name:"maxgentime" description:"The share creation target time for the pool. Valid time units are {s,m,h}. Minimum 2 seconds. This currently should be below 30 seconds to increase the likelihood a work submission for clients between new work distributions by the pool."`
-	PaymentMethod         string        `long:"paymentmethod" ini-name:"paymentmethod" description:"The payment method of the pool. {pps, pplns}"`
-	LastNPeriod           time.Duration `long:"lastnperiod" ini-name:"lastnperiod" description:"The time period of interest when using PPLNS payment scheme. Valid time units are {s,m,h}. Minimum 60 seconds."`
+	PaymentMethod         string        `long:"paymentmethod" ini-name:"paymentmethod" description:"The payment method of the pool. {prop, pps, fpps, pplns}"`
+	LastNPeriod           time.Duration `long:"lastnperiod" ini-name:"lastnperiod" description:"The time period of interest when using PPLNS payment scheme with the period window. Valid time units are {s,m,h}. Minimum 60 seconds."`
+	PPLNSWindow           string        `long:"pplnswindow" ini-name:"pplnswindow" description:"The window of shares of the PPLNS payment scheme, the last n period, the last n shares or the most recent shares worth n times the network difficulty. {period, shares, work}"`
+	LastNShares           uint32        `long:"lastnshares" ini-name:"lastnshares" description:"The number of most recent shares of interest when using PPLNS payment scheme with the shares window."`
+	LastNWork             float64       `long:"lastnwork" ini-name:"lastnwork" description:"The multiple of the network difficulty the most recent shares of interest are worth when using PPLNS payment scheme with the work window."`
 	WalletPass            string        `long:"walletpass" ini-name:"walletpass" description:"The wallet passphrase."`
 	MinPayment            float64       `long:"minpayment" ini-name:"minpayment" description:"The minimum payment to process for an account."`
 	SoloPool              bool          `long:"solopool" ini-name:"solopool" description:"Solo pool mode. This disables payment processing when enabled."`
@@ -104,8 +143,10 @@ type config struct {
 	UseLEHTTPS            bool          `long:"uselehttps" ini-name:"uselehttps" description:"This enables HTTPS using a Letsencrypt certificate. By default the pool uses a self-signed certificate for HTTPS."`
 	TLSCert               string        `long:"tlscert" ini-name:"tlscert" description:"Path to the TLS cert file."`
 	TLSKey                string        `long:"tlskey" ini-name:"tlskey" description:"Path to the TLS key file."`
+	EnableMetrics         bool          `long:"enablemetrics" ini-name:"enablemetrics" description:"Expose pool metrics in the prometheus text format via the unauthenticated /metrics endpoint of the user interface."`
 	Designation           string        `long:"designation" ini-name:"designation" description:"The designated codename for this pool. Customises the logo in the top toolbar."`
 	MaxConnectionsPerHost uint32        `long:"maxconnperhost" ini-name:"maxconnperhost" description:"The maximum number of connections allowed per host."`
+	StaleJobGrace         time.Duration `long:"stalejobgrace" ini-name:"stalejobgrace" description:"The period after a new block is found within which work submissions for jobs of the previous block are still accepted. Valid time units are {s,m,h}."`
 	Profile               string        `long:"profile" ini-name:"profile" description:"Enable HTTP profiling on given [addr:]port -- NOTE port must be between 1024 and 65536"`
 	CPUPort               uint32        `long:"cpuport" ini-name:"cpuport" description:"CPU miner connection port."`
 	D9Port                uint32        `long:"d9port" ini-name:"d9port" description:"Innosilicon D9 connection port."`
@@ -113,7 +154,28 @@ type config struct {
 	DR5Port               uint32        `long:"dr5port" ini-name:"dr5port" description:"Antminer DR5 connection port."`
 	D1Port                uint32        `long:"d1port" ini-name:"d1port" description:"Whatsminer D1 connection port."`
 	DCR1Port              uint32        `long:"dcr1port" ini-name:"dcr1port" description:"Obelisk DCR1 connection port."`
+	UnifiedPort           uint32        `long:"unifiedport" ini-name:"unifiedport" description:"Connection port for all supported miners, the miner type of clients is identified from their user agent. Disabled by default."`
+	CPUTLSPort            uint32        `long:"cputlsport" ini-name:"cputlsport" description:"CPU miner TLS connection port. Disabled by default."`
+	D9TLSPort             uint32        `long:"d9tlsport" ini-name:"d9tlsport" description:"Innosilicon D9 TLS connection port. Disabled by default."`
+	DR3TLSPort            uint32        `long:"dr3tlsport" ini-name:"dr3tlsport" description:"Antminer DR3 TLS connection port. Disabled by default."`
+	DR5TLSPort            uint32        `long:"dr5tlsport" ini-name:"dr5tlsport" description:"Antminer DR5 TLS connection port. Disabled by default."`
+	D1TLSPort             uint32        `long:"d1tlsport" ini-name:"d1tlsport" description:"Whatsminer D1 TLS connection port. Disabled by default."`
+	DCR1TLSPort           uint32        `long:"dcr1tlsport" ini-name:"dcr1tlsport" description:"Obelisk DCR1 TLS connection port. Disabled by default."`
+	UnifiedTLSPort        uint32        `long:"unifiedtlsport" ini-name:"unifiedtlsport" description:"TLS connection port for all supported miners. Disabled by default."`
+	DefaultMiner          string        `long:"defaultminer" ini-name:"defaultminer" description:"The miner type assumed for clients connected to the unified port with unidentified user agents. {cpu, innosilicond9, antminerdr3, antminerdr5, whatsminerd1, obeliskdcr1}"`
+	WebhookURLs           []string      `long:"webhookurls" ini-name:"webhookurls" description:"Operator webhook urls receiving all pool notification events as JSON POST requests."`
+	WebhookSecret         string        `long:"webhooksecret" ini-name:"webhooksecret" default-mask:"-" description:"The secret notification deliveries to the operator webhook urls are signed with."`
+	WebhookRetries        uint32        `long:"webhookretries" ini-name:"webhookretries" description:"The number of times a failed webhook delivery is retried."`
+	WebhookRetryBackoff   time.Duration `long:"webhookretrybackoff" ini-name:"webhookretrybackoff" description:"The delay before retrying a failed webhook delivery, doubled with each retry. Valid time units are {s,m,h}."`
+	WorkerOfflineTimeout  time.Duration `long:"workerofflinetimeout" ini-name:"workerofflinetimeout" description:"The period a worker has to be offline before a worker offline event is sent. Disabled when set to 0. Valid time units are {s,m,h}."`
+	HashRateDrop          float64       `long:"hashratedrop" ini-name:"hashratedrop" description:"The percentage an account's hash rate has to drop below its hourly average before a hash rate drop event is sent. Disabled when set to 0."`
+	SnapshotInterval      time.Duration `long:"snapshotinterval" ini-name:"snapshotinterval" description:"The interval between online snapshots of the bolt database. Disabled when set to 0. Valid time units are {m,h}."`
+	SnapshotDir           string        `long:"snapshotdir" ini-name:"snapshotdir" description:"The directory database snapshots are written to. Defaults to the snapshots directory within the data directory."`
+	SnapshotRetention     uint32        `long:"snapshotretention" ini-name:"snapshotretention" description:"The number of database snapshots retained, older snapshots are removed. All snapshots are retained when set to 0."`
+	SnapshotGzip          bool          `long:"snapshotgzip" ini-name:"snapshotgzip" description:"Gzip compress database snapshots."`
+	Restore               string        `long:"restore" no-ini:"true" description:"Path to a database snapshot or backup replacing the bolt database before the pool starts. The snapshot is upgraded if older and its pool mode must match the configured pool mode."`
 	poolFeeAddrs          []dcrutil.Address
+	miningAddrs           []dcrutil.Address
 	dcrdRPCCerts          []byte
 	net                   *params
 }
@@ -330,6 +392,9 @@ func loadConfig() (*config, []string, error) {
 		ActiveNet:             defaultActiveNet,
 		PaymentMethod:         defaultPaymentMethod,
 		LastNPeriod:           defaultLastNPeriod,
+		PPLNSWindow:           defaultPPLNSWindow,
+		LastNShares:           defaultLastNShares,
+		LastNWork:             defaultLastNWork,
 		MinPayment:            defaultMinPayment,
 		SoloPool:              defaultSoloPool,
 		GUIPort:               defaultGUIPort,
@@ -339,12 +404,26 @@ func loadConfig() (*config, []string, error) {
 		TLSKey:                defaultTLSKeyFile,
 		Designation:           defaultDesignation,
 		MaxConnectionsPerHost: defaultMaxConnectionsPerHost,
+		StaleJobGrace:         defaultStaleJobGrace,
 		CPUPort:               defaultCPUPort,
 		D9Port:                defaultD9Port,
 		DR3Port:               defaultDR3Port,
 		DR5Port:               defaultDR5Port,
 		D1Port:                defaultD1Port,
 		DCR1Port:              defaultDCR1Port,
+		DefaultMiner:          defaultMiner,
+		WebhookRetries:        defaultWebhookRetries,
+		WebhookRetryBackoff:   defaultWebhookRetryBackoff,
+		WorkerOfflineTimeout:  defaultWorkerOfflineTimeout,
+		HashRateDrop:          defaultHashRateDrop,
+		DBDriver:              defaultDBDriver,
+		PGHost:                defaultPGHost,
+		PGPort:                defaultPGPort,
+		PGUser:                defaultPGUser,
+		PGDBName:              defaultPGDBName,
+		PGSSLMode:             defaultPGSSLMode,
+		SnapshotInterval:      defaultSnapshotInterval,
+		SnapshotRetention:     defaultSnapshotRetention,
 	}
 
 	// Service options which are only added on Windows.
@@ -490,6 +569,13 @@ func loadConfig() (*config, []string, error) {
 
 	cfg.DataDir = cleanAndExpandPath(cfg.DataDir)
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
+	if cfg.SnapshotDir == "" {
+		cfg.SnapshotDir = filepath.Join(cfg.DataDir, defaultSnapshotDirname)
+	}
+	cfg.SnapshotDir = cleanAndExpandPath(cfg.SnapshotDir)
+	if cfg.Restore != "" {
+		cfg.Restore = cleanAndExpandPath(cfg.Restore)
+	}
 	logRotator = nil
 
 	// Initialize log rotation.  After log rotation has been initialized, the
@@ -554,16 +640,63 @@ func loadConfig() (*config, []string, error) {
 
 	// Add default ports for the active network if there are no ports specified.
 	cfg.DcrdRPCHost = normalizeAddress(cfg.DcrdRPCHost, cfg.net.DcrdRPCServerPort)
+	for idx, host := range cfg.DcrdRPCFailoverHosts {
+		host = normalizeAddress(host, cfg.net.DcrdRPCServerPort)
+		if host == cfg.DcrdRPCHost {
+			str := "%s: dcrd RPC failover hosts must differ from the " +
+				"primary dcrd RPC host -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, host)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.DcrdRPCFailoverHosts[idx] = host
+	}
 	cfg.WalletGRPCHost = normalizeAddress(cfg.WalletGRPCHost, cfg.net.WalletGRPCServerPort)
 
 	if !cfg.SoloPool {
 		// Ensure a valid payment method is set.
-		if cfg.PaymentMethod != pool.PPS && cfg.PaymentMethod != pool.PPLNS {
-			str := "%s: paymentmethod must be either %s or %s"
-			err := fmt.Errorf(str, funcName, pool.PPS, pool.PPLNS)
+		var validMethod bool
+		for _, method := range pool.PaymentMethods {
+			if cfg.PaymentMethod == method {
+				validMethod = true
+				break
+			}
+		}
+		if !validMethod {
+			str := "%s: paymentmethod must be one of %s"
+			err := fmt.Errorf(str, funcName,
+				strings.Join(pool.PaymentMethods, ", "))
 			return nil, nil, err
 		}
 
+		// Ensure a valid PPLNS window is set.
+		if cfg.PaymentMethod == pool.PPLNS {
+			var validWindow bool
+			for _, window := range pool.PPLNSWindows {
+				if cfg.PPLNSWindow == window {
+					validWindow = true
+					break
+				}
+			}
+			if !validWindow {
+				str := "%s: pplnswindow must be one of %s"
+				err := fmt.Errorf(str, funcName,
+					strings.Join(pool.PPLNSWindows, ", "))
+				return nil, nil, err
+			}
+			if cfg.PPLNSWindow == pool.SharesWindow && cfg.LastNShares == 0 {
+				str := "%s: lastnshares must be greater than 0"
+				err := fmt.Errorf(str, funcName)
+				return nil, nil, err
+			}
+			if cfg.PPLNSWindow == pool.WorkWindow && cfg.LastNWork <= 0 {
+				str := "%s: lastnwork must be greater than 0"
+				err := fmt.Errorf(str, funcName)
+				return nil, nil, err
+			}
+		}
+
 		// Ensure pool fee is valid.
 		if cfg.PoolFee < 0 || cfg.PoolFee > 1 {
 			str := "%s: poolfee should be between 0 and 1"
@@ -603,6 +736,30 @@ func loadConfig() (*config, []string, error) {
 
 			cfg.poolFeeAddrs = append(cfg.poolFeeAddrs, addr)
 		}
+
+		// Ensure the mining addresses of the mining node are provided.
+		// Mining rewards are identified by the coinbase outputs paying
+		// to them.
+		if len(cfg.MiningAddrs) == 0 || len(cfg.MiningAddrs[0]) == 0 {
+			str := "%s: the miningaddrs option is not set"
+			err := fmt.Errorf(str, funcName)
+			return nil, nil, err
+		}
+
+		// Split the string into an array, and parse mining addresses.
+		cfg.MiningAddrs = strings.Split(cfg.MiningAddrs[0], ",")
+		for _, mAddr := range cfg.MiningAddrs {
+			addr, err := dcrutil.DecodeAddress(mAddr, cfg.net)
+			if err != nil {
+				str := "%s: mining address '%v' failed to decode: %v"
+				err := fmt.Errorf(str, funcName, mAddr, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+
+			cfg.miningAddrs = append(cfg.miningAddrs, addr)
+		}
 	}
 
 	// Do not allow maxgentime durations that are too short.
@@ -615,6 +772,29 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the default miner is supported.
+	switch cfg.DefaultMiner {
+	case pool.CPU, pool.InnosiliconD9, pool.AntminerDR3, pool.AntminerDR5,
+		pool.WhatsminerD1, pool.ObeliskDCR1:
+	default:
+		str := "%s: the defaultminer option must be a supported " +
+			"miner -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.DefaultMiner)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Do not allow negative stalejobgrace durations.
+	if cfg.StaleJobGrace < 0 {
+		str := "%s: the stalejobgrace option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StaleJobGrace)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Do not allow lastnperiod durations that are too short.
 	if cfg.LastNPeriod < time.Second*60 {
 		str := "%s: the lastnperiod option may not be less " +
@@ -625,6 +805,71 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure operator webhook urls are absolute http or https urls.
+	for _, webhookURL := range cfg.WebhookURLs {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+			str := "%s: invalid webhook url -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, webhookURL)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Do not allow negative notification durations.
+	if cfg.WebhookRetryBackoff < 0 || cfg.WorkerOfflineTimeout < 0 {
+		str := "%s: the webhookretrybackoff and workerofflinetimeout " +
+			"options may not be negative -- parsed [%v, %v]"
+		err := fmt.Errorf(str, funcName, cfg.WebhookRetryBackoff,
+			cfg.WorkerOfflineTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure a valid database backend is set.
+	if cfg.DBDriver != boltDriver && cfg.DBDriver != postgresDriver {
+		str := "%s: dbdriver must be one of %s, %s -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, boltDriver, postgresDriver,
+			cfg.DBDriver)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Restoring is only supported by the bolt database backend.
+	if cfg.Restore != "" && cfg.DBDriver != boltDriver {
+		str := "%s: the restore option is only supported by the %s " +
+			"dbdriver -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, boltDriver, cfg.DBDriver)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure snapshots are taken at most once a minute, snapshot file names
+	// have a resolution of one second.
+	if cfg.SnapshotInterval != 0 && cfg.SnapshotInterval < time.Minute {
+		str := "%s: the snapshotinterval option should be either 0 or at " +
+			"least a minute -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.SnapshotInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the hash rate drop percentage is valid.
+	if cfg.HashRateDrop < 0 || cfg.HashRateDrop > 100 {
+		str := "%s: the hashratedrop option should be between 0 and " +
+			"100 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.HashRateDrop)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Warn about missing config file only after all other configuration is
 	// done. This prevents the warning on help messages and invalid
 	// options. Note this should go directly before the return.
@@ -639,7 +884,13 @@ func loadConfig() (*config, []string, error) {
 	}
 
 	// Generate self-signed TLS cert and key if they do not already exist.
-	if !cfg.UseLEHTTPS && (!fileExists(cfg.TLSCert) || !fileExists(cfg.TLSKey)) {
+	// Stratum TLS endpoints require them regardless of how the GUI is
+	// served.
+	stratumTLS := cfg.CPUTLSPort != 0 || cfg.D9TLSPort != 0 ||
+		cfg.DR3TLSPort != 0 || cfg.DR5TLSPort != 0 || cfg.D1TLSPort != 0 ||
+		cfg.DCR1TLSPort != 0 || cfg.UnifiedTLSPort != 0
+	if (!cfg.UseLEHTTPS || stratumTLS) &&
+		(!fileExists(cfg.TLSCert) || !fileExists(cfg.TLSKey)) {
 		err := genCertPair(cfg.TLSCert, cfg.TLSKey)
 		if err != nil {
 			return nil, nil,
@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math"
 	"math/big"
@@ -98,7 +99,79 @@ func newPool(cfg *config) (*miningPool, error) {
 		return nil, err
 	}
 
-	db, err := pool.InitDB(cfg.DBFile, cfg.SoloPool)
+	// The unified and TLS ports are checked against the miner ports without
+	// being recorded as them.
+	ports := make(map[string]uint32, len(minerPorts))
+	for miner, port := range minerPorts {
+		ports[miner] = port
+	}
+	if cfg.UnifiedPort != 0 {
+		err = addPort(ports, "unified", cfg.UnifiedPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.UnifiedTLSPort != 0 {
+		err = addPort(ports, "unified (tls)", cfg.UnifiedTLSPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+	minerTLSPorts := make(map[string]uint32)
+	tlsPorts := map[string]uint32{
+		pool.CPU:           cfg.CPUTLSPort,
+		pool.InnosiliconD9: cfg.D9TLSPort,
+		pool.AntminerDR3:   cfg.DR3TLSPort,
+		pool.AntminerDR5:   cfg.DR5TLSPort,
+		pool.WhatsminerD1:  cfg.D1TLSPort,
+		pool.ObeliskDCR1:   cfg.DCR1TLSPort,
+	}
+	for miner, port := range tlsPorts {
+		if port == 0 {
+			continue
+		}
+		err = addPort(ports, miner+" (tls)", port)
+		if err != nil {
+			return nil, err
+		}
+		minerTLSPorts[miner] = port
+	}
+
+	// Load the pool's TLS certificate for stratum TLS endpoints if any
+	// are configured.
+	var tlsConfig *tls.Config
+	if len(minerTLSPorts) > 0 || cfg.UnifiedTLSPort != 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS key pair: %v", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	var db pool.Database
+	switch cfg.DBDriver {
+	case postgresDriver:
+		pgCfg := &pool.PostgresConfig{
+			Host:    cfg.PGHost,
+			Port:    cfg.PGPort,
+			User:    cfg.PGUser,
+			Pass:    cfg.PGPass,
+			DBName:  cfg.PGDBName,
+			SSLMode: cfg.PGSSLMode,
+		}
+		db, err = pool.InitPostgresDB(pgCfg, cfg.SoloPool)
+	default:
+		if cfg.Restore != "" {
+			err = pool.RestoreDB(cfg.DBFile, cfg.Restore, cfg.SoloPool)
+			if err != nil {
+				return nil, err
+			}
+		}
+		db, err = pool.InitDB(cfg.DBFile, cfg.SoloPool)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -111,13 +184,33 @@ func newPool(cfg *config) (*miningPool, error) {
 		MaxGenTime:            cfg.MaxGenTime,
 		PaymentMethod:         cfg.PaymentMethod,
 		LastNPeriod:           cfg.LastNPeriod,
+		PPLNSWindow:           cfg.PPLNSWindow,
+		LastNShares:           cfg.LastNShares,
+		LastNWork:             cfg.LastNWork,
 		WalletPass:            cfg.WalletPass,
 		MinPayment:            minPmt,
 		PoolFeeAddrs:          cfg.poolFeeAddrs,
+		MiningAddrs:           cfg.miningAddrs,
 		SoloPool:              cfg.SoloPool,
 		NonceIterations:       iterations,
 		MinerPorts:            minerPorts,
 		MaxConnectionsPerHost: cfg.MaxConnectionsPerHost,
+		StaleJobGrace:         cfg.StaleJobGrace,
+		UnifiedPort:           cfg.UnifiedPort,
+		DefaultMiner:          cfg.DefaultMiner,
+		MinerTLSPorts:         minerTLSPorts,
+		UnifiedTLSPort:        cfg.UnifiedTLSPort,
+		TLSConfig:             tlsConfig,
+		WebhookURLs:           cfg.WebhookURLs,
+		WebhookSecret:         cfg.WebhookSecret,
+		WebhookRetries:        cfg.WebhookRetries,
+		WebhookRetryBackoff:   cfg.WebhookRetryBackoff,
+		WorkerOfflineTimeout:  cfg.WorkerOfflineTimeout,
+		HashRateDropPercent:   cfg.HashRateDrop,
+		SnapshotInterval:      cfg.SnapshotInterval,
+		SnapshotDir:           cfg.SnapshotDir,
+		SnapshotRetention:     cfg.SnapshotRetention,
+		SnapshotCompress:      cfg.SnapshotGzip,
 	}
 	p.hub, err = pool.NewHub(p.cancel, hcfg)
 	if err != nil {
@@ -125,12 +218,14 @@ func newPool(cfg *config) (*miningPool, error) {
 	}
 
 	// Establish a connection to the mining node.
-	ntfnHandlers := p.hub.CreateNotificationHandlers()
+	ntfnHandlers := p.hub.CreateNodeNotificationHandlers(cfg.DcrdRPCHost)
 	nodeConn, err := rpcclient.New(dcrdRPCCfg, ntfnHandlers)
 	if err != nil {
 		return nil, err
 	}
 
+	p.hub.AddNodeConnection(cfg.DcrdRPCHost, nodeConn)
+
 	if err := nodeConn.NotifyWork(); err != nil {
 		nodeConn.Shutdown()
 		return nil, err
@@ -140,31 +235,54 @@ func newPool(cfg *config) (*miningPool, error) {
 		return nil, err
 	}
 
-	p.hub.SetNodeConnection(nodeConn)
+	// Establish connections to the failover mining nodes. Notifications
+	// are only subscribed to when a failover node becomes the active node.
+	for _, host := range cfg.DcrdRPCFailoverHosts {
+		failoverRPCCfg := *dcrdRPCCfg
+		failoverRPCCfg.Host = host
+		ntfnHandlers := p.hub.CreateNodeNotificationHandlers(host)
+		conn, err := rpcclient.New(&failoverRPCCfg, ntfnHandlers)
+		if err != nil {
+			mpLog.Errorf("unable to connect to failover node %s: %v",
+				host, err)
+			continue
+		}
+		p.hub.AddNodeConnection(host, conn)
+	}
 
 	// Establish a connection to the wallet if the pool is mining as a
 	// publicly available mining pool.
 	if !cfg.SoloPool {
-		creds, err := credentials.
-			NewClientTLSFromFile(cfg.WalletRPCCert, "localhost")
-		if err != nil {
-			return nil, err
-		}
+		dialWallet := func() (pool.WalletConnection, func() error, error) {
+			creds, err := credentials.
+				NewClientTLSFromFile(cfg.WalletRPCCert, "localhost")
+			if err != nil {
+				return nil, nil, err
+			}
 
-		grpc, err := grpc.Dial(cfg.WalletGRPCHost,
-			grpc.WithTransportCredentials(creds))
-		if err != nil {
-			return nil, err
+			grpc, err := grpc.Dial(cfg.WalletGRPCHost,
+				grpc.WithTransportCredentials(creds))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			walletConn := walletrpc.NewWalletServiceClient(grpc)
+			req := &walletrpc.BalanceRequest{RequiredConfirmations: 1}
+			_, err = walletConn.Balance(context.TODO(), req)
+			if err != nil {
+				grpc.Close()
+				return nil, nil, err
+			}
+			return walletConn, grpc.Close, nil
 		}
 
-		walletConn := walletrpc.NewWalletServiceClient(grpc)
-		req := &walletrpc.BalanceRequest{RequiredConfirmations: 1}
-		_, err = walletConn.Balance(context.TODO(), req)
+		walletConn, walletClose, err := dialWallet()
 		if err != nil {
 			return nil, err
 		}
 
-		p.hub.SetWalletConnection(walletConn, grpc.Close)
+		p.hub.SetWalletConnection(walletConn, walletClose)
+		p.hub.SetWalletDialer(dialWallet)
 	}
 
 	err = p.hub.FetchWork()
@@ -182,31 +300,44 @@ func newPool(cfg *config) (*miningPool, error) {
 	}
 
 	gcfg := &gui.Config{
-		SoloPool:               cfg.SoloPool,
-		GUIDir:                 cfg.GUIDir,
-		AdminPass:              cfg.AdminPass,
-		GUIPort:                cfg.GUIPort,
-		UseLEHTTPS:             cfg.UseLEHTTPS,
-		Domain:                 cfg.Domain,
-		TLSCertFile:            cfg.TLSCert,
-		TLSKeyFile:             cfg.TLSKey,
-		ActiveNet:              cfg.net.Params,
-		PaymentMethod:          cfg.PaymentMethod,
-		Designation:            cfg.Designation,
-		PoolFee:                cfg.PoolFee,
-		CSRFSecret:             csrfSecret,
-		MinerPorts:             minerPorts,
-		WithinLimit:            p.hub.WithinLimit,
-		FetchLastWorkHeight:    p.hub.FetchLastWorkHeight,
-		FetchLastPaymentHeight: p.hub.FetchLastPaymentHeight,
-		AddPaymentRequest:      p.hub.AddPaymentRequest,
-		FetchMinedWork:         p.hub.FetchMinedWork,
-		FetchWorkQuotas:        p.hub.FetchWorkQuotas,
-		BackupDB:               p.hub.BackupDB,
-		FetchClients:           p.hub.FetchClients,
-		AccountExists:          p.hub.AccountExists,
-		FetchArchivedPayments:  p.hub.FetchArchivedPayments,
-		FetchPendingPayments:   p.hub.FetchPendingPayments,
+		SoloPool:                  cfg.SoloPool,
+		GUIDir:                    cfg.GUIDir,
+		AdminPass:                 cfg.AdminPass,
+		GUIPort:                   cfg.GUIPort,
+		UseLEHTTPS:                cfg.UseLEHTTPS,
+		Domain:                    cfg.Domain,
+		TLSCertFile:               cfg.TLSCert,
+		TLSKeyFile:                cfg.TLSKey,
+		EnableMetrics:             cfg.EnableMetrics,
+		ActiveNet:                 cfg.net.Params,
+		PaymentMethod:             cfg.PaymentMethod,
+		Designation:               cfg.Designation,
+		PoolFee:                   cfg.PoolFee,
+		CSRFSecret:                csrfSecret,
+		MinerPorts:                minerPorts,
+		UnifiedPort:               cfg.UnifiedPort,
+		MinerTLSPorts:             minerTLSPorts,
+		UnifiedTLSPort:            cfg.UnifiedTLSPort,
+		WithinLimit:               p.hub.WithinLimit,
+		FetchLastWorkHeight:       p.hub.FetchLastWorkHeight,
+		FetchLastPaymentHeight:    p.hub.FetchLastPaymentHeight,
+		AddPaymentRequest:         p.hub.AddPaymentRequest,
+		FetchMinedWork:            p.hub.FetchMinedWork,
+		FetchWorkQuotas:           p.hub.FetchWorkQuotas,
+		BackupDB:                  p.hub.BackupDB,
+		RestoreDB:                 p.hub.RestoreDB,
+		FetchClients:              p.hub.FetchClients,
+		AccountExists:             p.hub.AccountExists,
+		FetchArchivedPayments:     p.hub.FetchArchivedPayments,
+		FetchPendingPayments:      p.hub.FetchPendingPayments,
+		FetchFailedPayoutAttempts: p.hub.FetchFailedPayoutAttempts,
+		ReconcilePayments:         p.hub.ReconcilePayments,
+		WriteMetrics:              p.hub.WriteMetrics,
+		FetchHubState:             p.hub.FetchHubState,
+		FetchHistory:              p.hub.FetchHistory,
+		FetchWorkers:              p.hub.FetchWorkers,
+		AddWebhook:                p.hub.AddWebhook,
+		RemoveWebhook:             p.hub.RemoveWebhook,
 	}
 	p.gui, err = gui.NewGUI(gcfg)
 	if err != nil {
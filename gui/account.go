@@ -5,7 +5,9 @@
 package gui
 
 import (
+	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/decred/dcrpool/pool"
 	"github.com/gorilla/csrf"
@@ -19,11 +21,32 @@ type accountPageData struct {
 	ArchivedPayments []archivedPayment
 	PendingPayments  []pendingPayment
 	ConnectedClients []client
+	HashRateHistory  []historySample
+	Workers          []worker
 	AccountID        string
 	Address          string
 	BlockExplorerURL string
 }
 
+// historySample represents the hash rate and share counts of an account over
+// a sampling interval, formatted for display.
+type historySample struct {
+	Time     string
+	HashRate string
+	Accepted uint64
+	Rejected uint64
+}
+
+// worker represents a named worker of an account, formatted for display.
+type worker struct {
+	Name      string
+	Online    bool
+	HashRate  string
+	Accepted  uint64
+	Rejected  uint64
+	LastShare string
+}
+
 // account is the handler for "GET /account". Renders the account template if
 // a valid address with associated account information is provided,
 // otherwise renders the index template with an appropriate error message.
@@ -77,6 +100,43 @@ func (ui *GUI) account(w http.ResponseWriter, r *http.Request) {
 		clients = clients[0:10]
 	}
 
+	// Get this accounts hourly hash rate history for the past day, most
+	// recent first.
+	samples, err := ui.cfg.FetchHistory(pool.HourResolution,
+		pool.AccountScope, accountID)
+	if err != nil {
+		log.Errorf("unable to fetch hash rate history: %v", err)
+	}
+	history := make([]historySample, 0, 24)
+	for i := len(samples) - 1; i >= 0 && len(history) < 24; i-- {
+		sample := samples[i]
+		history = append(history, historySample{
+			Time:     formatUnixTime(sample.Time * int64(time.Second)),
+			HashRate: hashString(new(big.Rat).SetFloat64(sample.HashRate)),
+			Accepted: sample.Accepted,
+			Rejected: sample.Rejected,
+		})
+	}
+
+	// Get this accounts workers, online workers first.
+	accountWorkers := ui.cfg.FetchWorkers(accountID)
+	workers := make([]worker, 0, len(accountWorkers))
+	for i := len(accountWorkers) - 1; i >= 0; i-- {
+		w := accountWorkers[i]
+		lastShare := "-"
+		if w.LastShare != 0 {
+			lastShare = formatUnixTime(w.LastShare)
+		}
+		workers = append(workers, worker{
+			Name:      w.Name,
+			Online:    w.Online,
+			HashRate:  hashString(new(big.Rat).SetFloat64(w.HashRate)),
+			Accepted:  w.Accepted,
+			Rejected:  w.Rejected,
+			LastShare: lastShare,
+		})
+	}
+
 	data := &accountPageData{
 		HeaderData: headerData{
 			CSRF:        csrf.TemplateField(r),
@@ -87,6 +147,8 @@ func (ui *GUI) account(w http.ResponseWriter, r *http.Request) {
 		PendingPayments:  pendingPmts,
 		ArchivedPayments: archivedPmts,
 		ConnectedClients: clients,
+		HashRateHistory:  history,
+		Workers:          workers,
 		AccountID:        accountID,
 		Address:          address,
 		BlockExplorerURL: ui.cfg.BlockExplorerURL,
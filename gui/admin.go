@@ -5,6 +5,7 @@
 package gui
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/csrf"
@@ -17,6 +18,17 @@ type adminPageData struct {
 	HeaderData       headerData
 	PoolStatsData    poolStatsData
 	ConnectedClients map[string][]client
+	FailedPayouts    []failedPayout
+}
+
+// failedPayout represents a failed attempt at paying out mature payments.
+type failedPayout struct {
+	Height            string
+	Amount            string
+	Failures          string
+	NextAttemptHeight string
+	Error             string
+	CreatedOn         string
 }
 
 // adminPage is the handler for "GET /admin". If the current session is
@@ -32,6 +44,24 @@ func (ui *GUI) adminPage(w http.ResponseWriter, r *http.Request) {
 
 	clients := ui.cache.getClients()
 
+	attempts, err := ui.cfg.FetchFailedPayoutAttempts()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	failedPayouts := make([]failedPayout, 0, len(attempts))
+	for _, attempt := range attempts {
+		failedPayouts = append(failedPayouts, failedPayout{
+			Height:            fmt.Sprint(attempt.Height),
+			Amount:            amount(attempt.Amount),
+			Failures:          fmt.Sprint(attempt.Failures),
+			NextAttemptHeight: fmt.Sprint(attempt.NextAttemptHeight),
+			Error:             attempt.Error,
+			CreatedOn:         formatUnixTime(attempt.CreatedOn),
+		})
+	}
+
 	pageData := adminPageData{
 		HeaderData: headerData{
 			CSRF:        csrf.TemplateField(r),
@@ -48,6 +78,7 @@ func (ui *GUI) adminPage(w http.ResponseWriter, r *http.Request) {
 			SoloPool:          ui.cfg.SoloPool,
 		},
 		ConnectedClients: clients,
+		FailedPayouts:    failedPayouts,
 	}
 
 	ui.renderTemplate(w, "admin", pageData)
@@ -112,3 +143,58 @@ func (ui *GUI) downloadDatabaseBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// restoreDatabase is the handler for "POST /restore". If the current session
+// is authenticated as an admin, the uploaded database snapshot or backup is
+// validated and the pool is stopped to swap it in. The pool has to be
+// restarted afterwards.
+func (ui *GUI) restoreDatabase(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(sessionKey).(*sessions.Session)
+
+	if session.Values["IsAdmin"] != true {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	file, _, err := r.FormFile("snapshot")
+	if err != nil {
+		http.Error(w, "Missing database snapshot: "+err.Error(),
+			http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	err = ui.cfg.RestoreDB(file)
+	if err != nil {
+		log.Errorf("Error restoring database: %v", err)
+		http.Error(w, "Error restoring database: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "Database restore staged, the pool is stopping. "+
+		"Restart the pool to resume mining with the restored database.")
+}
+
+// reconcilePayments is the handler for "POST /reconcile". If the current
+// session is authenticated as an admin, all archived payments are reconciled
+// against the wallet's transaction history and the report is returned to the
+// client.
+func (ui *GUI) reconcilePayments(w http.ResponseWriter, r *http.Request) {
+	session := r.Context().Value(sessionKey).(*sessions.Session)
+
+	if session.Values["IsAdmin"] != true {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	recs, err := ui.cfg.ReconcilePayments()
+	if err != nil {
+		log.Errorf("Error reconciling payments: %v", err)
+		http.Error(w, "Error reconciling payments: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, recs)
+}
@@ -0,0 +1,455 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/decred/dcrpool/pool"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultAPIPageSize is the page size used when an api request does not
+	// specify one.
+	defaultAPIPageSize = 25
+	// maxAPIPageSize is the maximum page size of api requests.
+	maxAPIPageSize = 100
+	// maxAPIRequestSize is the maximum size of api request bodies.
+	maxAPIRequestSize = 4096
+	// webhookSecretHeader is the header carrying the webhook secret
+	// required to remove a webhook.
+	webhookSecretHeader = "X-Dcrpool-Webhook-Secret"
+)
+
+// apiError represents an error returned by the api.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiErrorPayload wraps an api error.
+type apiErrorPayload struct {
+	Error apiError `json:"error"`
+}
+
+// apiPage represents a page of api results.
+type apiPage struct {
+	Data     interface{} `json:"data"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pagesize"`
+	Count    int         `json:"count"`
+}
+
+// apiPoolStats represents the pool statistics returned by the api.
+type apiPoolStats struct {
+	Network           string            `json:"network"`
+	SoloPool          bool              `json:"solopool"`
+	PaymentMethod     string            `json:"paymentmethod"`
+	PoolFee           float64           `json:"poolfee"`
+	LastWorkHeight    uint32            `json:"lastworkheight"`
+	LastPaymentHeight uint32            `json:"lastpaymentheight"`
+	HashRate          float64           `json:"hashrate"`
+	Clients           int               `json:"clients"`
+	MinerPorts        map[string]uint32 `json:"minerports"`
+	UnifiedPort       uint32            `json:"unifiedport"`
+	MinerTLSPorts     map[string]uint32 `json:"minertlsports"`
+	UnifiedTLSPort    uint32            `json:"unifiedtlsport"`
+}
+
+// apiRewardQuota represents the share of the pool reward due an account,
+// as a fraction of one.
+type apiRewardQuota struct {
+	AccountID  string  `json:"accountid"`
+	Percentage float64 `json:"percentage"`
+}
+
+// apiClient represents a connected mining client of an account.
+type apiClient struct {
+	Worker   string  `json:"worker"`
+	Miner    string  `json:"miner"`
+	IP       string  `json:"ip"`
+	HashRate float64 `json:"hashrate"`
+}
+
+// ratToFloat returns the float64 value of the provided rational number.
+func ratToFloat(r *big.Rat) float64 {
+	if r == nil {
+		return 0
+	}
+	f, _ := r.Float64()
+	return f
+}
+
+// sendAPIError writes an api error with the provided status code and
+// message.
+func sendAPIError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(apiErrorPayload{
+		Error: apiError{
+			Code:    code,
+			Message: message,
+		},
+	})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// getAPIPageParams parses the optional page and pagesize request parameters
+// of paginated api requests.
+func getAPIPageParams(r *http.Request) (page, pageSize int, err error) {
+	page, pageSize = 1, defaultAPIPageSize
+	if v := r.FormValue("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page parameter")
+		}
+	}
+	if v := r.FormValue("pagesize"); v != "" {
+		pageSize, err = strconv.Atoi(v)
+		if err != nil || pageSize < 1 || pageSize > maxAPIPageSize {
+			return 0, 0, fmt.Errorf("invalid pagesize parameter, "+
+				"expected a value between 1 and %d", maxAPIPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// sendAPIPage writes the requested page of a result set with the provided
+// item count. The provided function returns the items of the page.
+func sendAPIPage(w http.ResponseWriter, r *http.Request, count int, slice func(first, last int) interface{}) {
+	page, pageSize, err := getAPIPageParams(r)
+	if err != nil {
+		sendAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	first := min((page-1)*pageSize, count)
+	last := min(first+pageSize, count)
+	sendJSONResponse(w, apiPage{
+		Data:     slice(first, last),
+		Page:     page,
+		PageSize: pageSize,
+		Count:    count,
+	})
+}
+
+// apiMiddleware applies rate limiting per remote host and allows cross
+// origin requests to the api.
+func (ui *GUI) apiMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !ui.cfg.WithinLimit(host, pool.APIClient) {
+			sendAPIError(w, http.StatusTooManyRequests,
+				"request limit exceeded")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiAccountMiddleware ensures the account referenced by api requests exists.
+func (ui *GUI) apiAccountMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID := mux.Vars(r)["accountID"]
+		if !ui.cfg.AccountExists(accountID) {
+			sendAPIError(w, http.StatusNotFound, "account not found")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeAPI configures the routes of the versioned json api.
+func (ui *GUI) routeAPI() {
+	apiRouter := ui.router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.Use(ui.apiMiddleware)
+
+	apiRouter.HandleFunc("/pool", ui.apiPoolStats).Methods("GET")
+	apiRouter.HandleFunc("/hub", ui.apiHubState).Methods("GET")
+	apiRouter.HandleFunc("/blocks", ui.apiBlocks).Methods("GET")
+	apiRouter.HandleFunc("/rewardquotas", ui.apiRewardQuotas).Methods("GET")
+	apiRouter.HandleFunc("/history", ui.apiPoolHistory).Methods("GET")
+
+	accountRouter := apiRouter.PathPrefix("/accounts/{accountID}").Subrouter()
+	accountRouter.Use(ui.apiAccountMiddleware)
+	accountRouter.HandleFunc("/blocks", ui.apiAccountBlocks).Methods("GET")
+	accountRouter.HandleFunc("/clients", ui.apiAccountClients).Methods("GET")
+	accountRouter.HandleFunc("/payments/pending", ui.apiAccountPendingPayments).Methods("GET")
+	accountRouter.HandleFunc("/payments/archived", ui.apiAccountArchivedPayments).Methods("GET")
+	accountRouter.HandleFunc("/history", ui.apiAccountHistory).Methods("GET")
+	accountRouter.HandleFunc("/workers", ui.apiAccountWorkers).Methods("GET")
+	accountRouter.HandleFunc("/workers/{worker}/history", ui.apiWorkerHistory).Methods("GET")
+	accountRouter.HandleFunc("/webhooks", ui.apiAddWebhook).Methods("POST")
+	accountRouter.HandleFunc("/webhooks/{webhookID}", ui.apiRemoveWebhook).Methods("DELETE")
+
+	apiRouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendAPIError(w, http.StatusNotFound, "not found")
+	})
+}
+
+// apiPoolStats is the handler for "GET /api/v1/pool". It returns the pool
+// statistics.
+func (ui *GUI) apiPoolStats(w http.ResponseWriter, r *http.Request) {
+	clients := ui.cfg.FetchClients()
+	hashRate := new(big.Rat)
+	for _, c := range clients {
+		hashRate.Add(hashRate, c.FetchHashRate())
+	}
+	sendJSONResponse(w, apiPoolStats{
+		Network:           ui.cfg.ActiveNet.Name,
+		SoloPool:          ui.cfg.SoloPool,
+		PaymentMethod:     ui.cfg.PaymentMethod,
+		PoolFee:           ui.cfg.PoolFee,
+		LastWorkHeight:    ui.cfg.FetchLastWorkHeight(),
+		LastPaymentHeight: ui.cfg.FetchLastPaymentHeight(),
+		HashRate:          ratToFloat(hashRate),
+		Clients:           len(clients),
+		MinerPorts:        ui.cfg.MinerPorts,
+		UnifiedPort:       ui.cfg.UnifiedPort,
+		MinerTLSPorts:     ui.cfg.MinerTLSPorts,
+		UnifiedTLSPort:    ui.cfg.UnifiedTLSPort,
+	})
+}
+
+// apiHubState is the handler for "GET /api/v1/hub". It returns a snapshot of
+// the state of the pool hub.
+func (ui *GUI) apiHubState(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, ui.cfg.FetchHubState())
+}
+
+// apiBlocks is the handler for "GET /api/v1/blocks". It returns a page of
+// blocks mined by the pool, most recent first.
+func (ui *GUI) apiBlocks(w http.ResponseWriter, r *http.Request) {
+	work, err := ui.cfg.FetchMinedWork()
+	if err != nil {
+		log.Errorf("unable to fetch mined work: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to fetch mined blocks")
+		return
+	}
+	sendAPIPage(w, r, len(work), func(first, last int) interface{} {
+		return work[first:last]
+	})
+}
+
+// apiRewardQuotas is the handler for "GET /api/v1/rewardquotas". It returns a
+// page of the reward distribution to pool accounts.
+func (ui *GUI) apiRewardQuotas(w http.ResponseWriter, r *http.Request) {
+	quotas, err := ui.cfg.FetchWorkQuotas()
+	if err != nil {
+		log.Errorf("unable to fetch work quotas: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to fetch reward quotas")
+		return
+	}
+	rewardQuotas := make([]apiRewardQuota, 0, len(quotas))
+	for _, quota := range quotas {
+		rewardQuotas = append(rewardQuotas, apiRewardQuota{
+			AccountID:  quota.AccountID,
+			Percentage: ratToFloat(quota.Percentage),
+		})
+	}
+	sendAPIPage(w, r, len(rewardQuotas), func(first, last int) interface{} {
+		return rewardQuotas[first:last]
+	})
+}
+
+// apiAccountBlocks is the handler for "GET
+// /api/v1/accounts/{accountID}/blocks". It returns a page of blocks mined by
+// the account, most recent first.
+func (ui *GUI) apiAccountBlocks(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["accountID"]
+	allWork, err := ui.cfg.FetchMinedWork()
+	if err != nil {
+		log.Errorf("unable to fetch mined work: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to fetch mined blocks")
+		return
+	}
+	work := make([]*pool.AcceptedWork, 0)
+	for _, v := range allWork {
+		if v.MinedBy == accountID {
+			work = append(work, v)
+		}
+	}
+	sendAPIPage(w, r, len(work), func(first, last int) interface{} {
+		return work[first:last]
+	})
+}
+
+// apiAccountClients is the handler for "GET
+// /api/v1/accounts/{accountID}/clients". It returns a page of connected
+// mining clients of the account.
+func (ui *GUI) apiAccountClients(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["accountID"]
+	clients := make([]apiClient, 0)
+	for _, c := range ui.cfg.FetchClients() {
+		if c.FetchAccountID() != accountID {
+			continue
+		}
+		clients = append(clients, apiClient{
+			Worker:   c.FetchWorkerName(),
+			Miner:    c.FetchMinerType(),
+			IP:       c.FetchIPAddr(),
+			HashRate: ratToFloat(c.FetchHashRate()),
+		})
+	}
+	sendAPIPage(w, r, len(clients), func(first, last int) interface{} {
+		return clients[first:last]
+	})
+}
+
+// apiAccountPayments sends a page of the provided payments belonging to the
+// account referenced by the request.
+func (ui *GUI) apiAccountPayments(w http.ResponseWriter, r *http.Request, fetch func() ([]*pool.Payment, error)) {
+	accountID := mux.Vars(r)["accountID"]
+	allPayments, err := fetch()
+	if err != nil {
+		log.Errorf("unable to fetch payments: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to fetch payments")
+		return
+	}
+	payments := make([]*pool.Payment, 0)
+	for _, pmt := range allPayments {
+		if pmt.Account == accountID {
+			payments = append(payments, pmt)
+		}
+	}
+	sendAPIPage(w, r, len(payments), func(first, last int) interface{} {
+		return payments[first:last]
+	})
+}
+
+// apiAccountPendingPayments is the handler for "GET
+// /api/v1/accounts/{accountID}/payments/pending". It returns a page of unpaid
+// payments due to the account.
+func (ui *GUI) apiAccountPendingPayments(w http.ResponseWriter, r *http.Request) {
+	ui.apiAccountPayments(w, r, ui.cfg.FetchPendingPayments)
+}
+
+// apiAccountArchivedPayments is the handler for "GET
+// /api/v1/accounts/{accountID}/payments/archived". It returns a page of
+// payments made to the account, most recent first.
+func (ui *GUI) apiAccountArchivedPayments(w http.ResponseWriter, r *http.Request) {
+	ui.apiAccountPayments(w, r, ui.cfg.FetchArchivedPayments)
+}
+
+// apiHistory sends the hash rate and share history of the provided scope and
+// id at the requested resolution, hourly by default.
+func (ui *GUI) apiHistory(w http.ResponseWriter, r *http.Request, scope string, id string) {
+	resolution := r.FormValue("resolution")
+	if resolution == "" {
+		resolution = pool.HourResolution
+	}
+	if resolution != pool.HourResolution &&
+		resolution != pool.MinuteResolution {
+		sendAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid "+
+			"resolution parameter, expected %s or %s",
+			pool.MinuteResolution, pool.HourResolution))
+		return
+	}
+	samples, err := ui.cfg.FetchHistory(resolution, scope, id)
+	if err != nil {
+		log.Errorf("unable to fetch history: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to fetch history")
+		return
+	}
+	sendJSONResponse(w, samples)
+}
+
+// apiPoolHistory is the handler for "GET /api/v1/history". It returns the
+// hash rate and share history of the pool, oldest first.
+func (ui *GUI) apiPoolHistory(w http.ResponseWriter, r *http.Request) {
+	ui.apiHistory(w, r, pool.PoolScope, "")
+}
+
+// apiAccountHistory is the handler for "GET
+// /api/v1/accounts/{accountID}/history". It returns the hash rate and share
+// history of the account, oldest first.
+func (ui *GUI) apiAccountHistory(w http.ResponseWriter, r *http.Request) {
+	ui.apiHistory(w, r, pool.AccountScope, mux.Vars(r)["accountID"])
+}
+
+// apiAccountWorkers is the handler for "GET
+// /api/v1/accounts/{accountID}/workers". It returns a page of named workers
+// of the account along with their share statistics.
+func (ui *GUI) apiAccountWorkers(w http.ResponseWriter, r *http.Request) {
+	workers := ui.cfg.FetchWorkers(mux.Vars(r)["accountID"])
+	sendAPIPage(w, r, len(workers), func(first, last int) interface{} {
+		return workers[first:last]
+	})
+}
+
+// apiWorkerHistory is the handler for "GET
+// /api/v1/accounts/{accountID}/workers/{worker}/history". It returns the hash
+// rate and share history of a worker of the account, oldest first.
+func (ui *GUI) apiWorkerHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ui.apiHistory(w, r, pool.WorkerScope,
+		pool.WorkerID(vars["accountID"], vars["worker"]))
+}
+
+// apiAddWebhook is the handler for "POST
+// /api/v1/accounts/{accountID}/webhooks". It registers the url provided in
+// the json request body to receive notification events of the account. The
+// request must carry the signature of the message "dcrpool webhook <url>"
+// by the address of the account. The returned webhook secret signs
+// deliveries and is required to remove the webhook.
+func (ui *GUI) apiAddWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL       string `json:"url"`
+		Signature string `json:"signature"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body,
+		maxAPIRequestSize)).Decode(&req)
+	if err != nil {
+		sendAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	webhook, err := ui.cfg.AddWebhook(mux.Vars(r)["accountID"], req.URL,
+		req.Signature)
+	if err != nil {
+		if pool.IsError(err, pool.ErrInvalidSignature) {
+			sendAPIError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		sendAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sendJSONResponse(w, webhook)
+}
+
+// apiRemoveWebhook is the handler for "DELETE
+// /api/v1/accounts/{accountID}/webhooks/{webhookID}". The webhook secret
+// must be provided in the X-Dcrpool-Webhook-Secret header.
+func (ui *GUI) apiRemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := ui.cfg.RemoveWebhook(vars["accountID"], vars["webhookID"],
+		r.Header.Get(webhookSecretHeader))
+	if err != nil {
+		if pool.IsError(err, pool.ErrValueNotFound) {
+			sendAPIError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		log.Errorf("unable to remove webhook: %v", err)
+		sendAPIError(w, http.StatusInternalServerError,
+			"unable to remove webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
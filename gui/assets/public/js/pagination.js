@@ -11,7 +11,7 @@ if ( $('#blocks-page-select').length ) {
             var html = '';
                 if (data.length > 0) {
                 $.each(data, function(_, item){
-                    html += '<tr><td><a href="' + item.blockurl + '" rel="noopener noreferrer">' + item.blockheight + '</a></td><td>' + item.miner + '</td><td><span class="dcr-label">' + item.minedby + '</span></td></tr>';
+                    html += '<tr><td><a href="' + item.blockurl + '" rel="noopener noreferrer">' + item.blockheight + '</a></td><td>' + item.status + '</td><td>' + item.reward + '</td><td>' + item.effort + '</td><td>' + item.miner + '</td><td><span class="dcr-label">' + item.minedby + '</span></td></tr>';
                 });
             } else {
                 html += '<tr><td colspan="100%"><span class="no-data">No mined blocks</span></td></tr>';
@@ -28,7 +28,7 @@ if ( $('#blocks-by-account-page-select').length ) {
             var html = '';
             if (data.length > 0) {
                 $.each(data, function(_, item){
-                    html += '<tr><td><a href="' + item.blockurl + '" rel="noopener noreferrer">' + item.blockheight + '</a></td><td>' + item.confirmed + '</td><td>' + item.miner + '</td></tr>';
+                    html += '<tr><td><a href="' + item.blockurl + '" rel="noopener noreferrer">' + item.blockheight + '</a></td><td>' + item.status + '</td><td>' + item.reward + '</td><td>' + item.effort + '</td><td>' + item.miner + '</td></tr>';
                 });
             } else {
                 html += '<tr><td colspan="100%"><span class="no-data">No mined blocks</span></td></tr>';
@@ -45,7 +45,7 @@ if ( $('#account-clients-page-select').length ) {
             var html = '';
             if (data.length > 0) {
                 $.each(data, function(_, item){
-                    html += '<tr><td>' + item.miner + '</td><td>' + item.hashrate + '</td></tr>';
+                    html += '<tr><td>' + item.worker + '</td><td>' + item.miner + '</td><td>' + item.hashrate + '</td></tr>';
                 });
             } else {
                 html += '<tr><td colspan="100%"><span class="no-data">No connected clients</span></td></tr>';
@@ -16,6 +16,7 @@ import (
 // client represents a mining client. It is json annotated so it can easily be
 // encoded and sent over a websocket or pagination request.
 type client struct {
+	Worker   string `json:"worker"`
 	Miner    string `json:"miner"`
 	IP       string `json:"ip"`
 	HashRate string `json:"hashrate"`
@@ -28,6 +29,9 @@ type minedWork struct {
 	BlockURL    string `json:"blockurl"`
 	MinedBy     string `json:"minedby"`
 	Miner       string `json:"miner"`
+	Status      string `json:"status"`
+	Reward      string `json:"reward"`
+	Effort      string `json:"effort"`
 	Confirmed   bool   `json:"confirmed"`
 	// AccountID holds the full ID (not truncated) and so should not be json encoded
 	AccountID string `json:"-"`
@@ -106,7 +110,10 @@ func (c *Cache) updateMinedWork(work []*pool.AcceptedWork) {
 			MinedBy:     truncateAccountID(w.MinedBy),
 			Miner:       w.Miner,
 			AccountID:   w.MinedBy,
-			Confirmed:   w.Confirmed,
+			Status:      string(w.Status),
+			Reward:      reward(w.Reward),
+			Effort:      effort(w.Effort),
+			Confirmed:   w.IsMined(),
 		})
 	}
 
@@ -167,6 +174,7 @@ func (c *Cache) updateClients(clients []*pool.Client) {
 		clientHashRate := c.FetchHashRate()
 		accountID := c.FetchAccountID()
 		clientInfo[accountID] = append(clientInfo[accountID], client{
+			Worker:   c.FetchWorkerName(),
 			Miner:    c.FetchMinerType(),
 			IP:       c.FetchIPAddr(),
 			HashRate: hashString(clientHashRate),
@@ -81,6 +81,24 @@ func amount(amt dcrutil.Amount) string {
 	return fmt.Sprintf("%.3f DCR", amt.ToCoin())
 }
 
+// reward formats the provided block reward, unknown rewards are formatted as
+// a dash.
+func reward(amt dcrutil.Amount) string {
+	if amt == 0 {
+		return "-"
+	}
+	return amount(amt)
+}
+
+// effort formats the provided block effort as a percentage, unknown efforts
+// are formatted as a dash.
+func effort(effort float64) string {
+	if effort <= 0 {
+		return "-"
+	}
+	return floatToPercent(effort)
+}
+
 // formatUnixTime formats the provided integer as a UTC time string,
 func formatUnixTime(unix int64) string {
 	return time.Unix(0, unix).Format("2-Jan-2006 15:04:05 MST")
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -46,6 +47,8 @@ type Config struct {
 	TLSCertFile string
 	// TLSKeyFile represents the TLS key file path.
 	TLSKeyFile string
+	// EnableMetrics represents whether the pool metrics are exposed.
+	EnableMetrics bool
 	// UseLEHTTPS represents Letsencrypt HTTPS mode.
 	UseLEHTTPS bool
 	// Domain represents the domain name of the pool.
@@ -60,6 +63,13 @@ type Config struct {
 	PoolFee float64
 	// MinerPorts represents the configured ports for supported miners.
 	MinerPorts map[string]uint32
+	// UnifiedPort represents the configured port for all supported miners.
+	UnifiedPort uint32
+	// MinerTLSPorts represents the configured TLS ports for supported miners.
+	MinerTLSPorts map[string]uint32
+	// UnifiedTLSPort represents the configured TLS port for all supported
+	// miners.
+	UnifiedTLSPort uint32
 	// WithinLimit returns if a client is within its request limits.
 	WithinLimit func(string, int) bool
 	// FetchLastWorkHeight returns the last work height of the pool.
@@ -76,6 +86,9 @@ type Config struct {
 	FetchWorkQuotas func() ([]*pool.Quota, error)
 	// BackupDB streams a backup of the database over an http response.
 	BackupDB func(w http.ResponseWriter) error
+	// RestoreDB validates the provided database snapshot and stops the pool
+	// to swap it in.
+	RestoreDB func(r io.Reader) error
 	// FetchClients returns all connected pool clients.
 	FetchClients func() []*pool.Client
 	// AccountExists checks if the provided account id references a pool account.
@@ -84,6 +97,26 @@ type Config struct {
 	FetchArchivedPayments func() ([]*pool.Payment, error)
 	// FetchPendingPayments fetches all unpaid payments.
 	FetchPendingPayments func() ([]*pool.Payment, error)
+	// FetchFailedPayoutAttempts fetches all failed payout attempts.
+	FetchFailedPayoutAttempts func() ([]*pool.PayoutAttempt, error)
+	// ReconcilePayments compares all archived payments against the wallet's
+	// transaction history.
+	ReconcilePayments func() ([]*pool.PayoutReconciliation, error)
+	// WriteMetrics writes the pool metrics in the prometheus text exposition
+	// format.
+	WriteMetrics func(w io.Writer) error
+	// FetchHubState returns a snapshot of the state of the pool hub.
+	FetchHubState func() *pool.HubState
+	// FetchHistory fetches the hash rate and share history of the provided
+	// scope and id at the provided resolution.
+	FetchHistory func(resolution string, scope string, id string) ([]*pool.HistorySample, error)
+	// FetchWorkers returns all named workers of the provided account.
+	FetchWorkers func(accountID string) []*pool.Worker
+	// AddWebhook registers a webhook receiving notification events of the
+	// provided account.
+	AddWebhook func(accountID string, url string, signature string) (*pool.Webhook, error)
+	// RemoveWebhook removes a webhook of the provided account.
+	RemoveWebhook func(accountID string, id string, secret string) error
 }
 
 // GUI represents the the mining pool user interface.
@@ -130,6 +163,9 @@ func (ui *GUI) route() {
 	assetsRouter.PathPrefix("/").Handler(http.StripPrefix("/assets",
 		http.FileServer(assetsDir)))
 
+	// The json api has its own rate limiting and does not use sessions.
+	ui.routeAPI()
+
 	// All other routes have rate limiting and CSRF protection applied.
 	guiRouter := ui.router.PathPrefix("/").Subrouter()
 
@@ -144,6 +180,8 @@ func (ui *GUI) route() {
 	guiRouter.HandleFunc("/admin", ui.adminPage).Methods("GET")
 	guiRouter.HandleFunc("/admin", ui.adminLogin).Methods("POST")
 	guiRouter.HandleFunc("/backup", ui.downloadDatabaseBackup).Methods("POST")
+	guiRouter.HandleFunc("/restore", ui.restoreDatabase).Methods("POST")
+	guiRouter.HandleFunc("/reconcile", ui.reconcilePayments).Methods("POST")
 	guiRouter.HandleFunc("/logout", ui.adminLogout).Methods("POST")
 
 	// Paginated endpoints allow the GUI to request pages of data.
@@ -156,6 +194,12 @@ func (ui *GUI) route() {
 
 	// Websocket endpoint allows the GUI to receive updated values.
 	guiRouter.HandleFunc("/ws", ui.registerWebSocket).Methods("GET")
+
+	// Metrics endpoint allows prometheus to scrape pool telemetry. It is
+	// unauthenticated so it is only exposed when enabled.
+	if ui.cfg.EnableMetrics {
+		guiRouter.HandleFunc("/metrics", ui.metrics).Methods("GET")
+	}
 }
 
 // renderTemplate executes the provided template.
@@ -13,13 +13,16 @@ import (
 // indexPageData contains all of the necessary information to render the index
 // template.
 type indexPageData struct {
-	HeaderData    headerData
-	PoolStatsData poolStatsData
-	MinerPorts    map[string]uint32
-	MinedWork     []minedWork
-	RewardQuotas  []rewardQuota
-	Address       string
-	ModalError    string
+	HeaderData     headerData
+	PoolStatsData  poolStatsData
+	MinerPorts     map[string]uint32
+	UnifiedPort    uint32
+	MinerTLSPorts  map[string]uint32
+	UnifiedTLSPort uint32
+	MinedWork      []minedWork
+	RewardQuotas   []rewardQuota
+	Address        string
+	ModalError     string
 }
 
 // renderIndex renders the index template. It accepts an optional modalError
@@ -62,10 +65,13 @@ func (ui *GUI) renderIndex(w http.ResponseWriter, r *http.Request, modalError st
 			PoolFee:           ui.cfg.PoolFee,
 			SoloPool:          ui.cfg.SoloPool,
 		},
-		RewardQuotas: rewardQuotas,
-		MinedWork:    recentWork,
-		MinerPorts:   ui.cfg.MinerPorts,
-		ModalError:   modalError,
+		RewardQuotas:   rewardQuotas,
+		MinedWork:      recentWork,
+		MinerPorts:     ui.cfg.MinerPorts,
+		UnifiedPort:    ui.cfg.UnifiedPort,
+		MinerTLSPorts:  ui.cfg.MinerTLSPorts,
+		UnifiedTLSPort: ui.cfg.UnifiedTLSPort,
+		ModalError:     modalError,
 	}
 
 	ui.renderTemplate(w, "index", data)
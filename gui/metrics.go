@@ -0,0 +1,22 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"net/http"
+)
+
+// metrics is the handler for "GET /metrics". It writes the pool metrics in
+// the prometheus text exposition format.
+func (ui *GUI) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := ui.cfg.WriteMetrics(w)
+	if err != nil {
+		log.Errorf("Error writing metrics: %v", err)
+		http.Error(w, "Error writing metrics: "+err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+}
@@ -100,6 +100,7 @@ solopool=${SOLO_POOL}
 activenet=simnet
 walletpass=${WALLET_PASS}
 poolfeeaddrs=${PFEE_ADDR}
+miningaddrs=${POOL_MINING_ADDR}
 paymentmethod=${PAYMENT_METHOD}
 lastnperiod=${LAST_N_PERIOD}
 adminpass=${ADMIN_PASS}
@@ -7,11 +7,29 @@ package pool
 import (
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"time"
 
-	bolt "go.etcd.io/bbolt"
+	"github.com/decred/dcrd/dcrutil/v2"
+)
+
+// WorkStatus represents the lifecycle status of work submitted to the
+// network by the pool.
+type WorkStatus string
+
+const (
+	// WorkSubmitted indicates the work was submitted to the network but the
+	// outcome of the submission is unknown.
+	WorkSubmitted WorkStatus = "submitted"
+	// WorkAccepted indicates the work was accepted by the mining node.
+	WorkAccepted WorkStatus = "accepted"
+	// WorkConfirmed indicates the work was confirmed as mined work by
+	// incoming work built on it.
+	WorkConfirmed WorkStatus = "confirmed"
+	// WorkOrphaned indicates the mined work was disconnected from the chain.
+	WorkOrphaned WorkStatus = "orphaned"
+	// WorkMatured indicates the coinbase of the mined work has matured.
+	WorkMatured WorkStatus = "matured"
 )
 
 // AcceptedWork represents an accepted work submission to the network.
@@ -25,8 +43,22 @@ type AcceptedWork struct {
 	CreatedOn int64  `json:"createdon"`
 
 	// An accepted work becomes mined work once it is confirmed by incoming
-	// work as the parent block it was built on.
-	Confirmed bool `json:"confirmed"`
+	// work as the parent block it was built on. The time of each status
+	// transition is recorded.
+	Status      WorkStatus `json:"status"`
+	SubmittedOn int64      `json:"submittedon"`
+	AcceptedOn  int64      `json:"acceptedon,omitempty"`
+	ConfirmedOn int64      `json:"confirmedon,omitempty"`
+	OrphanedOn  int64      `json:"orphanedon,omitempty"`
+	MaturedOn   int64      `json:"maturedon,omitempty"`
+
+	// Reward is the coinbase reward of the mined work paid to the pool.
+	Reward dcrutil.Amount `json:"reward"`
+	// NetworkDifficulty is the network difficulty the work was found at.
+	NetworkDifficulty float64 `json:"networkdifficulty"`
+	// Effort is the work contributed to the pool since the previous block
+	// found by the pool relative to the network difficulty.
+	Effort float64 `json:"effort"`
 }
 
 // heightToBigEndianBytes returns a 4-byte big endian representation of
@@ -50,191 +82,114 @@ func AcceptedWorkID(blockHash string, blockHeight uint32) []byte {
 	return []byte(id)
 }
 
-// NewAcceptedWork creates an accepted work.
+// NewAcceptedWork creates an accepted work. The work is considered submitted
+// until it is accepted by the network.
 func NewAcceptedWork(blockHash string, prevHash string, height uint32,
 	minedBy string, miner string) *AcceptedWork {
+	now := time.Now().Unix()
 	return &AcceptedWork{
-		UUID:      string(AcceptedWorkID(blockHash, height)),
-		BlockHash: blockHash,
-		PrevHash:  prevHash,
-		Height:    height,
-		MinedBy:   minedBy,
-		Miner:     miner,
-		CreatedOn: time.Now().Unix(),
+		UUID:        string(AcceptedWorkID(blockHash, height)),
+		BlockHash:   blockHash,
+		PrevHash:    prevHash,
+		Height:      height,
+		MinedBy:     minedBy,
+		Miner:       miner,
+		CreatedOn:   now,
+		Status:      WorkSubmitted,
+		SubmittedOn: now,
 	}
 }
 
-// fetchWorkBucket is a helper function for getting the work bucket.
-func fetchWorkBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(workBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(workBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
+// setStatus transitions the work to the provided status, recording the
+// time of the transition.
+func (work *AcceptedWork) setStatus(status WorkStatus) {
+	now := time.Now().Unix()
+	work.Status = status
+	switch status {
+	case WorkAccepted:
+		// Work reverted to accepted is no longer confirmed.
+		work.ConfirmedOn = 0
+		if work.AcceptedOn == 0 {
+			work.AcceptedOn = now
+		}
+	case WorkConfirmed:
+		work.ConfirmedOn = now
+		work.OrphanedOn = 0
+	case WorkOrphaned:
+		work.OrphanedOn = now
+	case WorkMatured:
+		work.MaturedOn = now
 	}
-	return bkt, nil
 }
 
-// FetchAcceptedWork fetches the accepted work referenced by the provided id.
-func FetchAcceptedWork(db *bolt.DB, id []byte) (*AcceptedWork, error) {
-	var work AcceptedWork
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
+// IsMined returns whether the work is confirmed as mined work.
+func (work *AcceptedWork) IsMined() bool {
+	return work.Status == WorkConfirmed || work.Status == WorkMatured
+}
 
-		v := bkt.Get(id)
-		if v == nil {
-			desc := fmt.Sprintf("no value for key %s", string(id))
-			return MakeError(ErrValueNotFound, desc, nil)
-		}
+// isPrunable returns whether the work can be pruned. Only work never
+// confirmed as mined work is prunable, orphaned work is kept for auditing
+// purposes.
+func (work *AcceptedWork) isPrunable() bool {
+	return work.Status == WorkSubmitted || work.Status == WorkAccepted
+}
 
-		err = json.Unmarshal(v, &work)
-		return err
-	})
-	if err != nil {
-		return nil, err
+// acceptOver carries over the submission details of the provided existing
+// work being accepted. An ErrWorkExists error is returned if the existing
+// work is past submitted.
+func (work *AcceptedWork) acceptOver(existing *AcceptedWork) error {
+	if existing.Status != WorkSubmitted {
+		desc := fmt.Sprintf("work %s already exists", work.UUID)
+		return MakeError(ErrWorkExists, desc, nil)
 	}
+	work.CreatedOn = existing.CreatedOn
+	work.SubmittedOn = existing.SubmittedOn
+	return nil
+}
 
-	return &work, err
+// FetchAcceptedWork fetches the accepted work referenced by the provided id.
+func FetchAcceptedWork(db Database, id []byte) (*AcceptedWork, error) {
+	return db.FetchAcceptedWork(string(id))
 }
 
 // Create persists the accepted work to the database.
-func (work *AcceptedWork) Create(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		// Do not persist already existing accepted work.
-		id := []byte(work.UUID)
-		v := bkt.Get(id)
-		if v != nil {
-			desc := fmt.Sprintf("work %s already exists", work.UUID)
-			return MakeError(ErrWorkExists, desc, nil)
-		}
-
-		workBytes, err := json.Marshal(work)
-		if err != nil {
-			return err
-		}
-
-		return bkt.Put(id, workBytes)
-	})
-	return err
+func (work *AcceptedWork) Create(db Database) error {
+	return db.PersistAcceptedWork(work)
 }
 
 // Update persists modifications to an existing work.
-func (work *AcceptedWork) Update(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		// Assert the work provided exists before updating.
-		id := []byte(work.UUID)
-		v := bkt.Get(id)
-		if v == nil {
-			desc := fmt.Sprintf("work %s not found", work.UUID)
-			return MakeError(ErrWorkNotFound, desc, nil)
-		}
-
-		workBytes, err := json.Marshal(work)
-		if err != nil {
-			return err
-		}
+func (work *AcceptedWork) Update(db Database) error {
+	return db.UpdateAcceptedWork(work)
+}
 
-		return bkt.Put(id, workBytes)
-	})
-	return err
+// Accept transitions the work to accepted and persists it to the database.
+// Work already persisted as submitted is updated, work already accepted is
+// not persisted again.
+func (work *AcceptedWork) Accept(db Database) error {
+	return db.AcceptWork(work)
 }
 
 // Delete removes the associated accepted work from the database.
-func (work *AcceptedWork) Delete(db *bolt.DB) error {
-	return deleteEntry(db, workBkt, []byte(work.UUID))
+func (work *AcceptedWork) Delete(db Database) error {
+	return db.DeleteAcceptedWork(work.UUID)
 }
 
 // ListMinedWork returns work data associated with all blocks mined by the pool
 // regardless of whether they are confirmed or not.
 //
 // List is ordered, most recent comes first.
-func ListMinedWork(db *bolt.DB) ([]*AcceptedWork, error) {
-	minedWork := make([]*AcceptedWork, 0)
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		cursor := bkt.Cursor()
-		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
-			var work AcceptedWork
-			err := json.Unmarshal(v, &work)
-			if err != nil {
-				return err
-			}
-
-			minedWork = append(minedWork, &work)
-		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return minedWork, nil
+func ListMinedWork(db Database) ([]*AcceptedWork, error) {
+	return db.ListAcceptedWork()
 }
 
-// pruneAcceptedWork removes all accepted work not confirmed as mined work with
-// heights less than the provided height.
-func pruneAcceptedWork(db *bolt.DB, height uint32) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		toDelete := [][]byte{}
-		cursor := bkt.Cursor()
-		workHeightB := make([]byte, 8)
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			_, err := hex.Decode(workHeightB, k[:8])
-			if err != nil {
-				return err
-			}
-
-			workHeight := bigEndianBytesToHeight(workHeightB)
-			if workHeight < height {
-				var work AcceptedWork
-				err := json.Unmarshal(v, &work)
-				if err != nil {
-					return err
-				}
-
-				// Only prune unconfirmed accepted work.
-				if !work.Confirmed {
-					toDelete = append(toDelete, k)
-				}
-			}
-		}
-
-		for _, entry := range toDelete {
-			err := bkt.Delete(entry)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
+// pruneAcceptedWork removes all submitted and accepted work not confirmed as
+// mined work with heights less than the provided height.
+func pruneAcceptedWork(db Database, height uint32) error {
+	return db.PruneAcceptedWork(height)
+}
 
-	return err
+// matureAcceptedWork transitions all confirmed mined work with heights less
+// than or equal to the provided height to matured.
+func matureAcceptedWork(db Database, height uint32) error {
+	return db.MatureAcceptedWork(height)
 }
@@ -1,15 +1,12 @@
 package pool
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
-
-	bolt "go.etcd.io/bbolt"
 )
 
-func persistAcceptedWork(db *bolt.DB, blockHash string, prevHash string,
+func persistAcceptedWork(db Database, blockHash string, prevHash string,
 	height uint32, minedBy string, miner string) (*AcceptedWork, error) {
 	acceptedWork := NewAcceptedWork(blockHash, prevHash, height, minedBy, miner)
 	err := acceptedWork.Create(db)
@@ -19,38 +16,22 @@ func persistAcceptedWork(db *bolt.DB, blockHash string, prevHash string,
 	return acceptedWork, nil
 }
 
-func listMinedWorkByAccount(db *bolt.DB, accountID string) ([]*AcceptedWork, error) {
-	minedWork := make([]*AcceptedWork, 0)
-
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchWorkBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		cursor := bkt.Cursor()
-		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
-			var work AcceptedWork
-			err := json.Unmarshal(v, &work)
-			if err != nil {
-				return err
-			}
-
-			if strings.Compare(work.MinedBy, accountID) == 0 && work.Confirmed {
-				minedWork = append(minedWork, &work)
-			}
-		}
-
-		return nil
-	})
+func listMinedWorkByAccount(db Database, accountID string) ([]*AcceptedWork, error) {
+	work, err := ListMinedWork(db)
 	if err != nil {
 		return nil, err
 	}
 
+	minedWork := make([]*AcceptedWork, 0)
+	for _, w := range work {
+		if strings.Compare(w.MinedBy, accountID) == 0 && w.IsMined() {
+			minedWork = append(minedWork, w)
+		}
+	}
 	return minedWork, nil
 }
 
-func testAcceptedWork(t *testing.T, db *bolt.DB) {
+func testAcceptedWork(t *testing.T, db Database) {
 	workA, err := persistAcceptedWork(db,
 		"00000000000000001e2065a7248a9b4d3886fe3ca3128eebedddaf35fb26e58c",
 		"000000000000000007301a21efa98033e06f7eba836990394fff9f765f1556b1",
@@ -100,6 +81,38 @@ func testAcceptedWork(t *testing.T, db *bolt.DB) {
 		t.Fatal("Create: expected a duplicate accepted work error")
 	}
 
+	// Ensure submitted work is promoted when accepted.
+	if workE.Status != WorkSubmitted || workE.SubmittedOn == 0 {
+		t.Fatalf("expected new work to be submitted, got %v", workE.Status)
+	}
+	err = workE.Create(db)
+	if err != nil {
+		t.Fatalf("create workE error: %v", err)
+	}
+	err = workE.Accept(db)
+	if err != nil {
+		t.Fatalf("accept workE error: %v", err)
+	}
+	acceptedWork, err := FetchAcceptedWork(db, []byte(workE.UUID))
+	if err != nil {
+		t.Fatalf("FetchAcceptedWork error: %v", err)
+	}
+	if acceptedWork.Status != WorkAccepted || acceptedWork.AcceptedOn == 0 {
+		t.Fatalf("expected accepted work status, got %v",
+			acceptedWork.Status)
+	}
+
+	// Ensure accepting already accepted work returns an error.
+	err = workE.Accept(db)
+	if !IsError(err, ErrWorkExists) {
+		t.Fatalf("Accept: expected a duplicate accepted work error, got %v",
+			err)
+	}
+	err = workE.Delete(db)
+	if err != nil {
+		t.Fatalf("delete workE error: %v", err)
+	}
+
 	// Ensure fetching a non existent accepted work returns an error.
 	id := AcceptedWorkID(workC.BlockHash, workD.Height)
 
@@ -137,25 +150,25 @@ func testAcceptedWork(t *testing.T, db *bolt.DB) {
 	}
 
 	// Confirm all accepted work a mined work.
-	workA.Confirmed = true
+	workA.setStatus(WorkConfirmed)
 	err = workA.Update(db)
 	if err != nil {
 		t.Fatalf("confirm workA error: %v ", err)
 	}
 
-	workB.Confirmed = true
+	workB.setStatus(WorkConfirmed)
 	err = workB.Update(db)
 	if err != nil {
 		t.Fatalf("confirm workB error: %v ", err)
 	}
 
-	workC.Confirmed = true
+	workC.setStatus(WorkConfirmed)
 	err = workC.Update(db)
 	if err != nil {
 		t.Fatalf("confirm workC error: %v ", err)
 	}
 
-	workD.Confirmed = true
+	workD.setStatus(WorkConfirmed)
 	err = workD.Update(db)
 	if err != nil {
 		t.Fatalf("confirm workD error: %v ", err)
@@ -198,13 +211,13 @@ func testAcceptedWork(t *testing.T, db *bolt.DB) {
 	}
 
 	// Update work A and B as unconfirmed
-	workA.Confirmed = false
+	workA.setStatus(WorkAccepted)
 	err = workA.Update(db)
 	if err != nil {
 		t.Fatalf("unconfirm workA error: %v ", err)
 	}
 
-	workB.Confirmed = false
+	workB.setStatus(WorkAccepted)
 	err = workB.Update(db)
 	if err != nil {
 		t.Fatalf("unconfirm workB error: %v ", err)
@@ -221,6 +234,61 @@ func testAcceptedWork(t *testing.T, db *bolt.DB) {
 		t.Fatal("expected a work not found error")
 	}
 
+	// Ensure orphaned work is not pruned.
+	workC.setStatus(WorkOrphaned)
+	err = workC.Update(db)
+	if err != nil {
+		t.Fatalf("orphan workC error: %v ", err)
+	}
+	err = pruneAcceptedWork(db, workD.Height+1)
+	if err != nil {
+		t.Fatalf("PruneAcceptedWork error: %v", err)
+	}
+	orphanedWork, err := FetchAcceptedWork(db, []byte(workC.UUID))
+	if err != nil {
+		t.Fatalf("expected orphaned work to be kept: %v", err)
+	}
+	if orphanedWork.Status != WorkOrphaned || orphanedWork.OrphanedOn == 0 {
+		t.Fatalf("expected orphaned work status, got %v",
+			orphanedWork.Status)
+	}
+
+	// Ensure only confirmed mined work at or below the provided height
+	// matures.
+	err = matureAcceptedWork(db, workD.Height-1)
+	if err != nil {
+		t.Fatalf("matureAcceptedWork error: %v", err)
+	}
+	confirmedWork, err := FetchAcceptedWork(db, []byte(workD.UUID))
+	if err != nil {
+		t.Fatalf("FetchAcceptedWork error: %v", err)
+	}
+	if confirmedWork.Status != WorkConfirmed {
+		t.Fatalf("expected confirmed work status, got %v",
+			confirmedWork.Status)
+	}
+	err = matureAcceptedWork(db, workD.Height)
+	if err != nil {
+		t.Fatalf("matureAcceptedWork error: %v", err)
+	}
+	maturedWork, err := FetchAcceptedWork(db, []byte(workD.UUID))
+	if err != nil {
+		t.Fatalf("FetchAcceptedWork error: %v", err)
+	}
+	if maturedWork.Status != WorkMatured || maturedWork.MaturedOn == 0 ||
+		!maturedWork.IsMined() {
+		t.Fatalf("expected matured work status, got %v",
+			maturedWork.Status)
+	}
+	orphanedWork, err = FetchAcceptedWork(db, []byte(workC.UUID))
+	if err != nil {
+		t.Fatalf("FetchAcceptedWork error: %v", err)
+	}
+	if orphanedWork.Status != WorkOrphaned {
+		t.Fatalf("expected orphaned work to not mature, got %v",
+			orphanedWork.Status)
+	}
+
 	// Delete work C and D.
 	err = workC.Delete(db)
 	if err != nil {
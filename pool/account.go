@@ -6,14 +6,11 @@ package pool
 
 import (
 	"encoding/hex"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/crypto/blake256"
 	"github.com/decred/dcrd/dcrutil/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 // Account represents a mining pool account.
@@ -58,72 +55,23 @@ func NewAccount(address string, activeNet *chaincfg.Params) (*Account, error) {
 	return account, nil
 }
 
-// fetchAccountBucket is a helper function for getting the account bucket.
-func fetchAccountBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(accountBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(accountBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-
-	return bkt, nil
-}
-
 // FetchAccount fetches the account referenced by the provided id.
-func FetchAccount(db *bolt.DB, id []byte) (*Account, error) {
-	var account Account
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchAccountBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		v := bkt.Get(id)
-		if v == nil {
-			desc := fmt.Sprintf("no account found for id %s", string(id))
-			return MakeError(ErrValueNotFound, desc, nil)
-		}
-
-		err = json.Unmarshal(v, &account)
-		return err
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &account, err
+func FetchAccount(db Database, id []byte) (*Account, error) {
+	return db.FetchAccount(string(id))
 }
 
 // Create persists the account to the database.
-func (acc *Account) Create(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchAccountBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		accBytes, err := json.Marshal(acc)
-		if err != nil {
-			return err
-		}
-		err = bkt.Put([]byte(acc.UUID), accBytes)
-		return err
-	})
-	return err
+func (acc *Account) Create(db Database) error {
+	return db.PersistAccount(acc)
 }
 
 // Update is not supported for accounts.
-func (acc *Account) Update(db *bolt.DB) error {
+func (acc *Account) Update(db Database) error {
 	desc := "account update not supported"
 	return MakeError(ErrNotSupported, desc, nil)
 }
 
 // Delete purges the referenced account from the database.
-func (acc *Account) Delete(db *bolt.DB) error {
-	return deleteEntry(db, accountBkt, []byte(acc.UUID))
+func (acc *Account) Delete(db Database) error {
+	return db.DeleteAccount(acc.UUID)
 }
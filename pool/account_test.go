@@ -5,10 +5,9 @@ import (
 	"testing"
 
 	"github.com/decred/dcrd/chaincfg/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
-func persistAccount(db *bolt.DB, address string, activeNet *chaincfg.Params) (*Account, error) {
+func persistAccount(db Database, address string, activeNet *chaincfg.Params) (*Account, error) {
 	acc, err := NewAccount(address, activeNet)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create account: %v", err)
@@ -20,7 +19,7 @@ func persistAccount(db *bolt.DB, address string, activeNet *chaincfg.Params) (*A
 	return acc, nil
 }
 
-func testAccount(t *testing.T, db *bolt.DB) {
+func testAccount(t *testing.T, db Database) {
 	accountA, err := persistAccount(db, "Ssj6Sd54j11JM8qpenCwfwnKD73dsjm68ru",
 		chaincfg.SimNetParams())
 	if err != nil {
@@ -2,13 +2,14 @@ package pool
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrutil/v2"
 	"github.com/decred/dcrd/wire"
-	bolt "go.etcd.io/bbolt"
 )
 
 var (
@@ -18,24 +19,50 @@ var (
 
 type ChainStateConfig struct {
 	// DB represents the pool database.
-	DB *bolt.DB
+	DB Database
 	// SoloPool represents the solo pool mining mode.
 	SoloPool bool
 	// PayDividends pays mature mining rewards to participating accounts.
 	PayDividends func(uint32) error
+	// ConfirmPayouts confirms published payouts mined by the block
+	// associated with the provided block hash and height.
+	ConfirmPayouts func(*chainhash.Hash, uint32) error
+	// UnconfirmPayouts reverts payouts confirmed by the block at the
+	// provided height.
+	UnconfirmPayouts func(uint32) error
+	// CreditShares credits work performed towards the provided connected
+	// block based on the configured payment scheme.
+	CreditShares func(*wire.BlockHeader) error
+	// RecordBlock records a status update of a block mined by the pool.
+	RecordBlock func(string)
+	// Notify sends the provided notification event.
+	Notify func(*Event)
 	// GeneratePayments creates payments for participating accounts in pool
 	// mining mode based on the configured payment scheme.
 	GeneratePayments func(uint32, dcrutil.Amount) error
+	// CoinbaseReward returns the reward paid to the pool by the coinbase
+	// of the provided block.
+	CoinbaseReward func(*wire.MsgBlock) (dcrutil.Amount, error)
 	// GetBlock fetches the block associated with the provided block hash.
 	GetBlock func(*chainhash.Hash) (*wire.MsgBlock, error)
 	// PruneJobs removes all jobs with heights less than the provided height.
-	PruneJobs func(*bolt.DB, uint32) error
+	PruneJobs func(Database, uint32) error
 	// PruneAcceptedWork removes all accepted work not confirmed as mined
 	// work with heights less than the provided height.
-	PruneAcceptedWork func(*bolt.DB, uint32) error
-	// PendingPaymentsAtHeight fetches all pending payments at
-	// the provided height.
-	PendingPaymentsAtHeight func(*bolt.DB, uint32) ([]*Payment, error)
+	PruneAcceptedWork func(Database, uint32) error
+	// MatureAcceptedWork marks confirmed mined work with coinbases matured
+	// as of the provided connected block height as matured.
+	MatureAcceptedWork func(Database, uint32) error
+	// RevertRound reverts the payments generated for the invalidated block
+	// mined by the pool at the provided height.
+	RevertRound func(uint32) (*RoundReversal, error)
+	// PruneRounds removes archived rounds that can no longer be invalidated
+	// as of the provided connected block height.
+	PruneRounds func(uint32) error
+	// StaleJobGrace represents the period after a new parent notification
+	// within which work submissions for jobs built on the superseded parent
+	// are still accepted.
+	StaleJobGrace time.Duration
 	// Cancel represents the pool's context cancellation function.
 	Cancel context.CancelFunc
 	// HubWg represents the hub's waitgroup.
@@ -48,6 +75,63 @@ type blockNotification struct {
 	Done   chan bool
 }
 
+// ReorgReport summarizes the effects of a chain reorganization on the
+// accepted work and payments of the pool.
+type ReorgReport struct {
+	OldTip          string
+	OldTipHeight    uint32
+	ForkHeight      uint32
+	Depth           uint32
+	UnconfirmedWork []string
+	OrphanedWork    []string
+	RemovedPayments int
+	RemovedAmount   dcrutil.Amount
+	PaidPayments    int
+	PaidAmount      dcrutil.Amount
+	RestoredShares  int
+}
+
+// newReorgReport creates a report of the chain reorganization disconnecting
+// the provided chain tip.
+func newReorgReport(tip *wire.BlockHeader) *ReorgReport {
+	return &ReorgReport{
+		OldTip:       tip.BlockHash().String(),
+		OldTipHeight: tip.Height,
+		ForkHeight:   tip.Height,
+	}
+}
+
+// disconnect records the provided disconnected block.
+func (r *ReorgReport) disconnect(header *wire.BlockHeader) {
+	r.Depth++
+	if header.Height > 0 && header.Height-1 < r.ForkHeight {
+		r.ForkHeight = header.Height - 1
+	}
+}
+
+// addReversal records the provided reverted round.
+func (r *ReorgReport) addReversal(reversal *RoundReversal) {
+	for _, pmt := range reversal.RemovedPayments {
+		r.RemovedPayments++
+		r.RemovedAmount += pmt.Amount
+	}
+	for _, pmt := range reversal.PaidPayments {
+		r.PaidPayments++
+		r.PaidAmount += pmt.Amount
+	}
+	r.RestoredShares += reversal.RestoredShares
+}
+
+// String returns a human-readable summary of the report.
+func (r *ReorgReport) String() string {
+	return fmt.Sprintf("depth %d from block #%d (%s) to fork height #%d, "+
+		"%d mined work unconfirmed, %d mined work orphaned, %d pending "+
+		"payments (%v) removed, %d shares restored", r.Depth,
+		r.OldTipHeight, r.OldTip, r.ForkHeight, len(r.UnconfirmedWork),
+		len(r.OrphanedWork), r.RemovedPayments, r.RemovedAmount,
+		r.RestoredShares)
+}
+
 // ChainState represents the current state of the chain.
 type ChainState struct {
 	lastWorkHeight uint32 // update atomically.
@@ -57,6 +141,16 @@ type ChainState struct {
 	discCh         chan *blockNotification
 	currentWork    string
 	currentWorkMtx sync.RWMutex
+
+	// prevParent is the parent superseded by the parent of the current
+	// work at lastParentChange. Both are protected by the current work
+	// mutex.
+	prevParent       string
+	lastParentChange int64
+
+	// reorg is the report of the chain reorganization in progress. It is
+	// only accessed by the chain updates handler.
+	reorg *ReorgReport
 }
 
 // NewChainState creates a a chain state.
@@ -93,6 +187,67 @@ func (cs *ChainState) fetchCurrentWork() string {
 	return work
 }
 
+// setParentWork updates the current work built on a new parent. The parent
+// of the superseded work is recorded along with the provided time of the
+// change.
+func (cs *ChainState) setParentWork(headerE string, t time.Time) {
+	cs.currentWorkMtx.Lock()
+	if cs.currentWork != "" && cs.currentWork[8:72] != headerE[8:72] {
+		cs.prevParent = cs.currentWork[8:72]
+		cs.lastParentChange = t.UnixNano()
+	}
+	cs.currentWork = headerE
+	cs.currentWorkMtx.Unlock()
+}
+
+// isStaleJob returns whether the provided job is built on a parent other
+// than the current work's parent. Jobs built on the parent superseded by
+// the current work's parent are only stale after the stale job grace period.
+func (cs *ChainState) isStaleJob(job *Job) bool {
+	cs.currentWorkMtx.RLock()
+	currWork := cs.currentWork
+	prevParent := cs.prevParent
+	lastParentChange := cs.lastParentChange
+	cs.currentWorkMtx.RUnlock()
+	if currWork == "" {
+		return false
+	}
+	parent := job.Header[8:72]
+	if parent == currWork[8:72] {
+		return false
+	}
+	if parent != prevParent {
+		return true
+	}
+	return time.Since(time.Unix(0, lastParentChange)) > cs.cfg.StaleJobGrace
+}
+
+// revertRound reverts the payments generated for the invalidated block mined
+// by the pool at the provided height and records the reversal.
+func (cs *ChainState) revertRound(height uint32) error {
+	reversal, err := cs.cfg.RevertRound(height)
+	if err != nil {
+		return err
+	}
+	cs.reorg.addReversal(reversal)
+	return nil
+}
+
+// completeReorg logs the report of the chain reorganization in progress, if
+// any, and resets it.
+func (cs *ChainState) completeReorg() {
+	if cs.reorg == nil {
+		return
+	}
+	log.Infof("Chain reorganization of %s", cs.reorg)
+	if cs.reorg.PaidPayments > 0 {
+		log.Warnf("%d payments (%v) of invalidated mined work were already "+
+			"paid and cannot be reverted", cs.reorg.PaidPayments,
+			cs.reorg.PaidAmount)
+	}
+	cs.reorg = nil
+}
+
 // handleChainUpdates processes connected and disconnected block
 // notifications from the consensus daemon.
 func (cs *ChainState) handleChainUpdates(ctx context.Context) {
@@ -114,12 +269,39 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 				close(msg.Done)
 				continue
 			}
+
+			// A connected block completes the chain reorganization in
+			// progress.
+			cs.completeReorg()
+
 			if !cs.cfg.SoloPool {
+				blockHash := header.BlockHash()
+				err = cs.cfg.ConfirmPayouts(&blockHash, header.Height)
+				if err != nil {
+					// Errors generated tracking payout confirmations should
+					// not terminate the chainstate process.
+					log.Errorf("unable to confirm payouts: %v", err)
+				}
+				err = cs.cfg.CreditShares(&header)
+				if err != nil {
+					// Errors generated crediting shares should not
+					// terminate the chainstate process, uncredited shares
+					// are credited with the next connected block.
+					log.Errorf("unable to credit shares: %v", err)
+				}
+				err = cs.cfg.PruneRounds(header.Height)
+				if err != nil {
+					// Errors generated pruning archived rounds should not
+					// terminate the chainstate process.
+					log.Errorf("unable to prune rounds: %v", err)
+				}
 				err = cs.cfg.PayDividends(header.Height)
 				if err != nil {
+					// Errors generated processing payments should not
+					// prevent the remaining handling of the connected
+					// block, pending payments are retried with the next
+					// connected block.
 					log.Errorf("unable to process payments: %v", err)
-					close(msg.Done)
-					continue
 				}
 			}
 			if header.Height > MaxReorgLimit {
@@ -136,6 +318,17 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 					continue
 				}
 			}
+			err = cs.cfg.MatureAcceptedWork(cs.cfg.DB, header.Height)
+			if err != nil {
+				// Errors generated maturing mined work indicate an
+				// underlying issue accessing the database. The chainstate
+				// process will be terminated as a result.
+				log.Errorf("unable to mature mined work as of height "+
+					"#%d: %v", header.Height, err)
+				close(msg.Done)
+				cs.cfg.Cancel()
+				continue
+			}
 
 			// If the parent of the connected block is an accepted work of the
 			// pool, confirm it as mined.
@@ -162,7 +355,7 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 			}
 
 			// Update accepted work as confirmed mined.
-			work.Confirmed = true
+			work.setStatus(WorkConfirmed)
 			err = work.Update(cs.cfg.DB)
 			if err != nil {
 				// Errors generated updating work state indicate an underlying
@@ -174,6 +367,7 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 				cs.cfg.Cancel()
 				continue
 			}
+			cs.cfg.RecordBlock(blockConfirmed)
 			log.Tracef("Mined work %s confirmed by connected block #%d",
 				header.PrevBlock.String(), header.Height)
 			if header.Height > MaxReorgLimit {
@@ -207,7 +401,30 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 					cs.cfg.Cancel()
 					continue
 				}
-				coinbase := dcrutil.Amount(block.Transactions[0].TxOut[2].Value)
+				coinbase, err := cs.cfg.CoinbaseReward(block)
+				if err != nil {
+					// Errors generated extracting the pool reward of
+					// confirmed mined work are fatal since payments are
+					// sourced from it. The chainstate process will be
+					// terminated as a result.
+					log.Errorf("unable to extract coinbase reward of "+
+						"block #%d: %v", block.Header.Height, err)
+					close(msg.Done)
+					cs.cfg.Cancel()
+					continue
+				}
+				work.Reward = coinbase
+				err = work.Update(cs.cfg.DB)
+				if err != nil {
+					// Errors generated updating work state indicate an
+					// underlying issue accessing the database. The
+					// chainstate process will be terminated as a result.
+					log.Errorf("unable to record reward of mined work "+
+						"%s: %v", header.PrevBlock.String(), err)
+					close(msg.Done)
+					cs.cfg.Cancel()
+					continue
+				}
 				err = cs.cfg.GeneratePayments(block.Header.Height, coinbase)
 				if err != nil {
 					// Errors generated creating payments are fatal since it is
@@ -232,6 +449,23 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 				continue
 			}
 
+			// Disconnected blocks are part of a chain reorganization which
+			// completes with the next connected block. Reorganizations of
+			// any depth are handled one disconnected block at a time.
+			if cs.reorg == nil {
+				cs.reorg = newReorgReport(&header)
+			}
+			cs.reorg.disconnect(&header)
+
+			if !cs.cfg.SoloPool {
+				err = cs.cfg.UnconfirmPayouts(header.Height)
+				if err != nil {
+					// Errors generated reverting payout confirmations should
+					// not terminate the chainstate process.
+					log.Errorf("unable to unconfirm payouts: %v", err)
+				}
+			}
+
 			// Check if the disconnected block confirms a mined block, if it
 			// does unconfirm it.
 			id := AcceptedWorkID(header.PrevBlock.String(), header.Height-1)
@@ -255,7 +489,7 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 			}
 
 			if confWork != nil {
-				confWork.Confirmed = false
+				confWork.setStatus(WorkAccepted)
 				err = confWork.Update(cs.cfg.DB)
 				if err != nil {
 					// Errors generated updating work state indicate an underlying
@@ -270,6 +504,24 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 
 				log.Tracef("Mined work unconfirmed %s via disconnected "+
 					"block #%d", header.PrevBlock.String(), header.Height)
+				cs.reorg.UnconfirmedWork = append(cs.reorg.UnconfirmedWork,
+					string(id))
+
+				if !cs.cfg.SoloPool {
+					// Payments of mined work are generated when it is
+					// confirmed, revert them along with the confirmation.
+					err = cs.revertRound(header.Height - 1)
+					if err != nil {
+						// Errors generated reverting payments indicate an
+						// underlying issue accessing the database. The
+						// chainstate process will be terminated as a result.
+						log.Errorf("unable to revert payments at height "+
+							"#%d: %v", header.Height-1, err)
+						close(msg.Done)
+						cs.cfg.Cancel()
+						continue
+					}
+				}
 			}
 
 			// If the disconnected block is an accepted work of the pool
@@ -294,7 +546,7 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 				cs.cfg.Cancel()
 				continue
 			}
-			work.Confirmed = false
+			work.setStatus(WorkOrphaned)
 			err = work.Update(cs.cfg.DB)
 			if err != nil {
 				// Errors generated updating work state indicate an underlying
@@ -306,36 +558,26 @@ func (cs *ChainState) handleChainUpdates(ctx context.Context) {
 				cs.cfg.Cancel()
 				continue
 			}
+			cs.cfg.RecordBlock(blockOrphaned)
+			cs.cfg.Notify(NewEvent(EventBlockOrphaned, work.MinedBy, work))
 			log.Tracef("Disconnected mined work %s at height #%d",
 				header.BlockHash().String(), header.Height)
+			cs.reorg.OrphanedWork = append(cs.reorg.OrphanedWork, string(id))
 
 			if !cs.cfg.SoloPool {
 				// If the disconnected block is an accepted work from the pool,
-				// delete all associated payments.
-				payments, err := cs.cfg.PendingPaymentsAtHeight(cs.cfg.DB,
-					header.Height)
+				// revert all associated payments.
+				err = cs.revertRound(header.Height)
 				if err != nil {
-					// Errors generated looking up pending payments
-					// indicates an underlying issue accessing the database.
-					// The chainstate process will be terminated as a result.
-					log.Errorf("failed to fetch pending payments "+
-						"at height #%d: %v", header.Height, err)
+					// Errors generated reverting payments indicate an
+					// underlying issue accessing the database. The
+					// chainstate process will be terminated as a result.
+					log.Errorf("unable to revert payments at height "+
+						"#%d: %v", header.Height, err)
 					close(msg.Done)
 					cs.cfg.Cancel()
 					continue
 				}
-				for _, pmt := range payments {
-					err = pmt.Delete(cs.cfg.DB)
-					if err != nil {
-						// Errors generated updating work state indicate
-						// an underlying issue accessing the database. The
-						// chainstate process will be terminated as a result.
-						log.Errorf("unable to delete pending payment", err)
-						close(msg.Done)
-						cs.cfg.Cancel()
-						break
-					}
-				}
 			}
 			close(msg.Done)
 		}
@@ -4,16 +4,17 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrutil/v2"
 	"github.com/decred/dcrd/wire"
-	bolt "go.etcd.io/bbolt"
 )
 
-func testChainState(t *testing.T, db *bolt.DB) {
+func testChainState(t *testing.T, db Database) {
 	var minedHeader wire.BlockHeader
 
 	// Create mined work header.
@@ -43,6 +44,9 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	generatePayments := func(uint32, dcrutil.Amount) error {
 		return nil
 	}
+	coinbaseReward := func(*wire.MsgBlock) (dcrutil.Amount, error) {
+		return dcrutil.Amount(100), nil
+	}
 	getBlock := func(*chainhash.Hash) (*wire.MsgBlock, error) {
 		// Return a fake block.
 		coinbase := wire.NewMsgTx()
@@ -57,31 +61,53 @@ func testChainState(t *testing.T, db *bolt.DB) {
 		}
 		return block, nil
 	}
-	pruneJobs := func(*bolt.DB, uint32) error {
+	pruneJobs := func(Database, uint32) error {
+		return nil
+	}
+	pruneAcceptedWork := func(Database, uint32) error {
 		return nil
 	}
-	pruneAcceptedWork := func(*bolt.DB, uint32) error {
+	confirmPayouts := func(*chainhash.Hash, uint32) error {
 		return nil
 	}
-	pendingPaymentsAtHeight := func(*bolt.DB, uint32) ([]*Payment, error) {
-		return []*Payment{
-			{Account: xID, Amount: dcrutil.Amount(100)},
+	unconfirmPayouts := func(uint32) error {
+		return nil
+	}
+	revertRound := func(height uint32) (*RoundReversal, error) {
+		return &RoundReversal{
+			Height: height,
+			RemovedPayments: []*Payment{
+				{Account: xID, Amount: dcrutil.Amount(100)},
+			},
+			RestoredShares: 1,
 		}, nil
 	}
+	pruneRounds := func(uint32) error {
+		return nil
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	var confHeader wire.BlockHeader
 	cCfg := &ChainStateConfig{
-		DB:                      db,
-		SoloPool:                false,
-		PayDividends:            payDividends,
-		GeneratePayments:        generatePayments,
-		GetBlock:                getBlock,
-		PruneJobs:               pruneJobs,
-		PruneAcceptedWork:       pruneAcceptedWork,
-		PendingPaymentsAtHeight: pendingPaymentsAtHeight,
-		Cancel:                  cancel,
-		HubWg:                   new(sync.WaitGroup),
+		DB:                 db,
+		SoloPool:           false,
+		PayDividends:       payDividends,
+		ConfirmPayouts:     confirmPayouts,
+		UnconfirmPayouts:   unconfirmPayouts,
+		CreditShares:       func(*wire.BlockHeader) error { return nil },
+		RecordBlock:        func(string) {},
+		Notify:             func(*Event) {},
+		GeneratePayments:   generatePayments,
+		CoinbaseReward:     coinbaseReward,
+		GetBlock:           getBlock,
+		PruneJobs:          pruneJobs,
+		PruneAcceptedWork:  pruneAcceptedWork,
+		MatureAcceptedWork: matureAcceptedWork,
+		RevertRound:        revertRound,
+		PruneRounds:        pruneRounds,
+		StaleJobGrace:      time.Second,
+		Cancel:             cancel,
+		HubWg:              new(sync.WaitGroup),
 	}
 
 	cs := NewChainState(cCfg)
@@ -176,10 +202,14 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	if err != nil {
 		t.Fatalf("unable to confirm accepted work: %v", err)
 	}
-	if !confirmedWork.Confirmed {
+	if confirmedWork.Status != WorkConfirmed || confirmedWork.ConfirmedOn == 0 {
 		t.Fatalf("expected accepted work to be confirmed " +
 			"after chain notifications")
 	}
+	if confirmedWork.Reward != dcrutil.Amount(100) {
+		t.Fatalf("expected a mined work reward of %v, got %v",
+			dcrutil.Amount(100), confirmedWork.Reward)
+	}
 
 	discConfMsg := &blockNotification{
 		Header: confHeaderB,
@@ -199,9 +229,40 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	if err != nil {
 		t.Fatalf("unexpected error %v", err)
 	}
-	if discMinedWork.Confirmed {
+	if discMinedWork.Status != WorkOrphaned {
 		t.Fatalf("disconnected mined work a height #%d should "+
-			"be unconfirmed", discMinedWork.Height)
+			"be orphaned", discMinedWork.Height)
+	}
+
+	// Ensure the disconnected blocks are tracked as a single chain
+	// reorganization reverting the payments of the invalidated mined work.
+	report := cs.reorg
+	if report == nil {
+		t.Fatal("expected a chain reorganization in progress")
+	}
+	if report.Depth != 2 {
+		t.Fatalf("expected a reorg depth of 2, got %d", report.Depth)
+	}
+	if report.OldTipHeight != confHeader.Height {
+		t.Fatalf("expected an old tip height of %d, got %d",
+			confHeader.Height, report.OldTipHeight)
+	}
+	if report.ForkHeight != minedHeader.Height-1 {
+		t.Fatalf("expected a fork height of %d, got %d",
+			minedHeader.Height-1, report.ForkHeight)
+	}
+	if len(report.UnconfirmedWork) != 1 || len(report.OrphanedWork) != 1 {
+		t.Fatalf("expected one unconfirmed and one orphaned mined work, "+
+			"got %d and %d", len(report.UnconfirmedWork),
+			len(report.OrphanedWork))
+	}
+	if report.RemovedPayments != 2 || report.RemovedAmount != 200 {
+		t.Fatalf("expected 2 removed payments worth 200 atoms, got %d "+
+			"worth %v", report.RemovedPayments, report.RemovedAmount)
+	}
+	if report.RestoredShares != 2 {
+		t.Fatalf("expected 2 restored shares, got %d",
+			report.RestoredShares)
 	}
 
 	// Ensure a malformed disconnected block does not terminate the chain state
@@ -220,6 +281,10 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	}
 	cs.connCh <- minedMsg
 	<-minedMsg.Done
+	if cs.reorg != nil {
+		t.Fatal("expected the chain reorganization to be completed by " +
+			"a connected block")
+	}
 	cs.cfg.PayDividends = func(uint32) error {
 		return fmt.Errorf("unable to publish dividend transaction")
 	}
@@ -230,6 +295,17 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	}
 	cs.connCh <- confMsg
 	<-confMsg.Done
+
+	// Ensure the remaining handling of the connected block proceeds
+	// regardless of the dividend payment error.
+	confirmedWork, err = FetchAcceptedWork(cs.cfg.DB, []byte(work.UUID))
+	if err != nil {
+		t.Fatalf("unable to confirm accepted work: %v", err)
+	}
+	if confirmedWork.Status != WorkConfirmed {
+		t.Fatalf("expected accepted work to be confirmed despite the " +
+			"dividend payment error")
+	}
 	discConfMsg = &blockNotification{
 		Header: confHeaderB,
 		Done:   make(chan bool),
@@ -258,6 +334,45 @@ func testChainState(t *testing.T, db *bolt.DB) {
 			updatedCurrentWork, currentWork)
 	}
 
+	// Ensure jobs built on the current parent are not stale.
+	currJob, err := NewJob(workE, 42)
+	if err != nil {
+		t.Fatalf("[NewJob] unexpected error: %v", err)
+	}
+	staleWorkE := workE[:8] + strings.Repeat("0", 64) + workE[72:]
+	cs.setCurrentWork(staleWorkE)
+	cs.setParentWork(headerE, time.Now().Add(-cs.cfg.StaleJobGrace*2))
+	if cs.isStaleJob(currJob) {
+		t.Fatal("expected job built on the current parent to not be stale")
+	}
+
+	// Ensure jobs built on the superseded parent are only stale after
+	// the stale job grace period.
+	staleJob, err := NewJob(staleWorkE, 42)
+	if err != nil {
+		t.Fatalf("[NewJob] unexpected error: %v", err)
+	}
+	if !cs.isStaleJob(staleJob) {
+		t.Fatal("expected job built on a superseded parent to be stale")
+	}
+	cs.setCurrentWork(staleWorkE)
+	cs.setParentWork(headerE, time.Now())
+	if cs.isStaleJob(staleJob) {
+		t.Fatal("expected job within the stale job grace period to " +
+			"not be stale")
+	}
+
+	// Ensure jobs built on an earlier parent than the superseded parent
+	// are stale within the stale job grace period.
+	olderWorkE := workE[:8] + strings.Repeat("1", 64) + workE[72:]
+	olderJob, err := NewJob(olderWorkE, 42)
+	if err != nil {
+		t.Fatalf("[NewJob] unexpected error: %v", err)
+	}
+	if !cs.isStaleJob(olderJob) {
+		t.Fatal("expected job built on an earlier parent to be stale")
+	}
+
 	cancel()
 	cs.cfg.HubWg.Wait()
 
@@ -279,7 +394,7 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	}
 	cs.connCh <- minedMsg
 	<-minedMsg.Done
-	cs.cfg.PruneJobs = func(*bolt.DB, uint32) error {
+	cs.cfg.PruneJobs = func(Database, uint32) error {
 		return fmt.Errorf("unable to prune jobs")
 	}
 
@@ -316,6 +431,28 @@ func testChainState(t *testing.T, db *bolt.DB) {
 
 	runChainState()
 
+	// Ensure a coinbase reward error terminates the chain state process.
+	minedMsg = &blockNotification{
+		Header: minedHeaderB,
+		Done:   make(chan bool),
+	}
+	cs.connCh <- minedMsg
+	<-minedMsg.Done
+	cs.cfg.CoinbaseReward = func(*wire.MsgBlock) (dcrutil.Amount, error) {
+		return 0, fmt.Errorf("unable to extract coinbase reward")
+	}
+
+	confMsg = &blockNotification{
+		Header: confHeaderB,
+		Done:   make(chan bool),
+	}
+	cs.connCh <- confMsg
+	<-confMsg.Done
+	cs.cfg.CoinbaseReward = coinbaseReward
+	cs.cfg.HubWg.Wait()
+
+	runChainState()
+
 	// Ensure a prune accepted work error terminates the chain state process.
 	minedMsg = &blockNotification{
 		Header: minedHeaderB,
@@ -323,7 +460,7 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	}
 	cs.connCh <- minedMsg
 	<-minedMsg.Done
-	cs.cfg.PruneAcceptedWork = func(*bolt.DB, uint32) error {
+	cs.cfg.PruneAcceptedWork = func(Database, uint32) error {
 		return fmt.Errorf("unable to prune accepted work")
 	}
 
@@ -338,16 +475,15 @@ func testChainState(t *testing.T, db *bolt.DB) {
 
 	runChainState()
 
-	// Ensure a pending payments at height error terminates the chain
-	// state process.
+	// Ensure a revert round error terminates the chain state process.
 	minedMsg = &blockNotification{
 		Header: minedHeaderB,
 		Done:   make(chan bool),
 	}
 	cs.connCh <- minedMsg
 	<-minedMsg.Done
-	cs.cfg.PendingPaymentsAtHeight = func(*bolt.DB, uint32) ([]*Payment, error) {
-		return nil, fmt.Errorf("unable to fetch pending payments")
+	cs.cfg.RevertRound = func(uint32) (*RoundReversal, error) {
+		return nil, fmt.Errorf("unable to revert round")
 	}
 
 	discMinedMsg = &blockNotification{
@@ -356,7 +492,7 @@ func testChainState(t *testing.T, db *bolt.DB) {
 	}
 	cs.discCh <- discMinedMsg
 	<-discMinedMsg.Done
-	cs.cfg.PendingPaymentsAtHeight = pendingPaymentsAtHeight
+	cs.cfg.RevertRound = revertRound
 	cs.cfg.HubWg.Wait()
 
 	runChainState()
@@ -24,7 +24,6 @@ import (
 	"github.com/decred/dcrd/blockchain/standalone"
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/wire"
-	bolt "go.etcd.io/bbolt"
 )
 
 const (
@@ -38,6 +37,11 @@ const (
 
 	// clientTimeout represents the read/write timeout for the client.
 	clientTimeout = time.Minute * 4
+
+	// maxTimeOffset represents the maximum duration a submitted timestamp
+	// can be ahead of the pool's time. This matches the consensus daemon's
+	// limit for block timestamps.
+	maxTimeOffset = time.Hour * 2
 )
 
 var (
@@ -59,7 +63,7 @@ type ClientConfig struct {
 	// ActiveNet represents the active network being mined on.
 	ActiveNet *chaincfg.Params
 	// DB represents the pool database.
-	DB *bolt.DB
+	DB Database
 	// SoloPool represents the solo pool mining mode.
 	SoloPool bool
 	// Blake256Pad represents the extra padding needed for work
@@ -69,6 +73,13 @@ type ClientConfig struct {
 	NonceIterations float64
 	// Miner returns the endpoint miner type.
 	FetchMiner func() string
+	// DetectMiner enables identifying the client's miner type from its
+	// mining.subscribe user agent, the endpoint miner type is used for
+	// unidentified clients.
+	DetectMiner bool
+	// FetchMinerDifficulty returns the difficulty info of the provided
+	// miner type.
+	FetchMinerDifficulty func(string) (*DifficultyInfo, error)
 	// DifficultyInfo represents the difficulty info for the client.
 	DifficultyInfo *DifficultyInfo
 	// EndpointWg is the waitgroup of the client's endpoint.
@@ -79,6 +90,11 @@ type ClientConfig struct {
 	SubmitWork func(*string) (bool, error)
 	// FetchCurrentWork returns the current work of the pool.
 	FetchCurrentWork func() string
+	// IsStaleJob returns whether the provided job is stale.
+	IsStaleJob func(*Job) bool
+	// RecordSubmission records a share submission for the provided job,
+	// duplicate submissions are rejected.
+	RecordSubmission func(string, string, string, string, string) error
 	// WithinLimit returns if the client is still within its request limits.
 	WithinLimit func(string, int) bool
 	// HashCalcThreshold represents the minimum operating time in seconds
@@ -88,12 +104,36 @@ type ClientConfig struct {
 	MaxGenTime time.Duration
 	// ClientTimeout represents the connection read/write timeout.
 	ClientTimeout time.Duration
+	// RecordShare records a share submission of the provided miner type
+	// with the provided status and rejection reason.
+	RecordShare func(string, string, string)
+	// AddWorker records a new connection of the provided account worker.
+	AddWorker func(string, string, string) error
+	// RemoveWorker records a terminated connection of the provided account
+	// worker.
+	RemoveWorker func(string, string)
+	// RecordWorkerShare records a share submission of the provided account
+	// worker with the provided status and rejection reason.
+	RecordWorkerShare func(string, string, string, string)
+	// Notify sends the provided notification event.
+	Notify func(*Event)
+	// AddRoundWork adds the difficulty of an accepted share to the work
+	// contributed to the pool in the current round.
+	AddRoundWork func(*big.Rat)
+	// CompleteRound returns the effort of a block found by the pool at the
+	// provided network difficulty and starts a new round.
+	CompleteRound func(*big.Rat) float64
 }
 
 // Client represents a client connection.
 type Client struct {
-	submissions  int64 // update atomically.
-	lastWorkTime int64 // update atomically.
+	submissions    int64 // update atomically.
+	lastWorkTime   int64 // update atomically.
+	retargetShares int64 // update atomically.
+	lastRetarget   int64 // update atomically.
+	staleShares    int64 // update atomically.
+	acceptedShares int64 // update atomically.
+	rejectedShares int64 // update atomically.
 
 	id            string
 	addr          *net.TCPAddr
@@ -108,12 +148,18 @@ type Client struct {
 	ch            chan Message
 	readCh        chan readPayload
 	account       string
+	miner         string
+	minerMtx      sync.RWMutex
 	authorized    bool
 	authorizedMtx sync.Mutex
 	subscribed    bool
 	subscribedMtx sync.Mutex
 	hashRate      *big.Rat
 	hashRateMtx   sync.RWMutex
+	diffInfo      *DifficultyInfo
+	diffInfoMtx   sync.RWMutex
+	jobDiffs      map[string]*DifficultyInfo
+	jobDiffsMtx   sync.Mutex
 	wg            sync.WaitGroup
 }
 
@@ -133,16 +179,19 @@ func (c *Client) generateExtraNonce1() error {
 func NewClient(conn net.Conn, addr *net.TCPAddr, cCfg *ClientConfig) (*Client, error) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	c := &Client{
-		addr:     addr,
-		cfg:      cCfg,
-		conn:     conn,
-		ctx:      ctx,
-		cancel:   cancel,
-		ch:       make(chan Message),
-		readCh:   make(chan readPayload),
-		encoder:  json.NewEncoder(conn),
-		reader:   bufio.NewReaderSize(conn, maxMessageSize),
-		hashRate: ZeroRat,
+		addr:         addr,
+		cfg:          cCfg,
+		conn:         conn,
+		ctx:          ctx,
+		cancel:       cancel,
+		ch:           make(chan Message),
+		readCh:       make(chan readPayload),
+		encoder:      json.NewEncoder(conn),
+		reader:       bufio.NewReaderSize(conn, maxMessageSize),
+		hashRate:     ZeroRat,
+		diffInfo:     cCfg.DifficultyInfo,
+		jobDiffs:     make(map[string]*DifficultyInfo),
+		lastRetarget: time.Now().UnixNano(),
 	}
 	err := c.generateExtraNonce1()
 	if err != nil {
@@ -152,25 +201,70 @@ func NewClient(conn net.Conn, addr *net.TCPAddr, cCfg *ClientConfig) (*Client, e
 	return c, nil
 }
 
+// fetchMiner returns the miner type of the client. The endpoint miner type is
+// returned if the client's miner type has not been identified.
+func (c *Client) fetchMiner() string {
+	c.minerMtx.RLock()
+	miner := c.miner
+	c.minerMtx.RUnlock()
+	if miner == "" {
+		return c.cfg.FetchMiner()
+	}
+	return miner
+}
+
+// detectMiner identifies the client's miner type from the provided
+// user agent and updates its difficulty info accordingly. The endpoint
+// miner type is retained if the user agent is not recognised.
+func (c *Client) detectMiner(userAgent string) {
+	miner, ok := identifyMiner(userAgent)
+	if !ok {
+		log.Debugf("%s: unable to identify miner from user agent %q, "+
+			"defaulting to %s", c.id, userAgent, c.cfg.FetchMiner())
+		return
+	}
+	diffInfo, err := c.cfg.FetchMinerDifficulty(miner)
+	if err != nil {
+		log.Errorf("%s: unable to fetch %s difficulty: %v", c.id, miner, err)
+		return
+	}
+
+	c.minerMtx.Lock()
+	c.miner = miner
+	c.minerMtx.Unlock()
+	c.diffInfoMtx.Lock()
+	c.diffInfo = diffInfo
+	c.diffInfoMtx.Unlock()
+
+	log.Tracef("%s identified as %s from user agent %q", c.id, miner,
+		userAgent)
+}
+
 // shutdown terminates all client processes and established connections.
 func (c *Client) shutdown() {
 	c.cfg.RemoveClient(c)
+	c.authorizedMtx.Lock()
+	authorized := c.authorized
+	c.authorizedMtx.Unlock()
+	if authorized && c.account != "" {
+		c.cfg.RemoveWorker(c.account, c.name)
+	}
 	log.Tracef("%s connection terminated.", c.id)
 }
 
-// claimWeightedShare records a weighted share for the pool client. This
-// serves as proof of verifiable work contributed to the mining pool.
-func (c *Client) claimWeightedShare() error {
+// claimWeightedShare records a share for the pool client weighted by the
+// provided difficulty the share was checked against. This serves as proof
+// of verifiable work contributed to the mining pool.
+func (c *Client) claimWeightedShare(difficulty *big.Rat) error {
 	if c.cfg.SoloPool {
 		return fmt.Errorf("cannot claim shares in solo pool mode")
 	}
 	if c.cfg.ActiveNet.Name == chaincfg.MainNetParams().Name &&
-		c.cfg.FetchMiner() == CPU {
+		c.fetchMiner() == CPU {
 		return fmt.Errorf("cannot claim shares for cpu miners on mainnet, " +
 			"reserved for testing purposes only (simnet, testnet)")
 	}
-	weight := ShareWeights[c.cfg.FetchMiner()]
-	share := NewShare(c.account, weight)
+	share := NewShare(c.account, difficulty)
 	return share.Create(c.cfg.DB)
 }
 
@@ -210,6 +304,14 @@ func (c *Client) handleAuthorizeRequest(req *Request, allowed bool) error {
 
 		name := strings.TrimSpace(parts[1])
 		address := strings.TrimSpace(parts[0])
+		if len(name) > maxWorkerNameLength {
+			err := fmt.Errorf("worker name exceeds %d characters",
+				maxWorkerNameLength)
+			sErr := NewStratumError(Unknown, err)
+			resp := AuthorizeResponse(*req.ID, false, sErr)
+			c.ch <- resp
+			return err
+		}
 
 		// Fetch the account of the address provided.
 		id, err := AccountID(address, c.cfg.ActiveNet)
@@ -255,6 +357,19 @@ func (c *Client) handleAuthorizeRequest(req *Request, allowed bool) error {
 		c.name = username
 	}
 
+	c.authorizedMtx.Lock()
+	authorized := c.authorized
+	c.authorizedMtx.Unlock()
+	if !authorized && c.account != "" {
+		err := c.cfg.AddWorker(c.account, c.name, c.fetchMiner())
+		if err != nil {
+			err := fmt.Errorf("unable to add worker: %v", err)
+			sErr := NewStratumError(Unknown, err)
+			resp := AuthorizeResponse(*req.ID, false, sErr)
+			c.ch <- resp
+			return err
+		}
+	}
 	c.authorizedMtx.Lock()
 	c.authorized = true
 	c.authorizedMtx.Unlock()
@@ -274,7 +389,7 @@ func (c *Client) handleSubscribeRequest(req *Request, allowed bool) error {
 		return err
 	}
 
-	_, nid, err := ParseSubscribeRequest(req)
+	userAgent, nid, err := ParseSubscribeRequest(req)
 	if err != nil {
 		err := fmt.Errorf("unable to parse subscribe request: %v", err)
 		sErr := NewStratumError(Unknown, err)
@@ -283,13 +398,17 @@ func (c *Client) handleSubscribeRequest(req *Request, allowed bool) error {
 		return err
 	}
 
+	if c.cfg.DetectMiner {
+		c.detectMiner(userAgent)
+	}
+
 	// Generate a subscription id if none exists.
 	if nid == "" {
 		nid = fmt.Sprintf("mn%v", c.extraNonce1)
 	}
 
 	var resp *Response
-	switch c.cfg.FetchMiner() {
+	switch c.fetchMiner() {
 	case ObeliskDCR1:
 		// The DCR1 is not fully complaint with the stratum spec.
 		// It uses a 4-byte extraNonce2 regardless of the
@@ -336,16 +455,126 @@ func (c *Client) handleSubscribeRequest(req *Request, allowed bool) error {
 	return nil
 }
 
+// fetchDifficulty returns the current difficulty info of the client.
+func (c *Client) fetchDifficulty() *DifficultyInfo {
+	c.diffInfoMtx.RLock()
+	defer c.diffInfoMtx.RUnlock()
+	return c.diffInfo
+}
+
+// recordJobDifficulty associates the current difficulty info of the client
+// with the provided job. Records of jobs below the reorg limit of the
+// provided job are pruned.
+func (c *Client) recordJobDifficulty(jobID string) {
+	height, err := jobHeight(jobID)
+	if err != nil {
+		log.Errorf("unable to record job difficulty: %v", err)
+		return
+	}
+
+	diffInfo := c.fetchDifficulty()
+	c.jobDiffsMtx.Lock()
+	c.jobDiffs[jobID] = diffInfo
+	if height > MaxReorgLimit {
+		pruneLimit := height - MaxReorgLimit
+		for id := range c.jobDiffs {
+			// Recorded job ids are valid, the error can safely be ignored.
+			h, _ := jobHeight(id)
+			if h < pruneLimit {
+				delete(c.jobDiffs, id)
+			}
+		}
+	}
+	c.jobDiffsMtx.Unlock()
+}
+
+// fetchJobDifficulty returns the difficulty info the client was issued the
+// provided job at. The current difficulty info of the client is returned if
+// the job has no associated record.
+func (c *Client) fetchJobDifficulty(jobID string) *DifficultyInfo {
+	c.jobDiffsMtx.Lock()
+	diffInfo, ok := c.jobDiffs[jobID]
+	c.jobDiffsMtx.Unlock()
+	if !ok {
+		return c.fetchDifficulty()
+	}
+	return diffInfo
+}
+
 // setDifficulty sends the pool client's difficulty ratio.
 func (c *Client) setDifficulty() {
-	diff := new(big.Rat).Set(c.cfg.DifficultyInfo.difficulty)
+	diff := new(big.Rat).Set(c.fetchDifficulty().difficulty)
 	diffNotif := SetDifficultyNotification(diff)
-	c.ch <- diffNotif
+	select {
+	case c.ch <- diffNotif:
+	case <-c.ctx.Done():
+	}
+}
+
+// retarget adjusts the client's difficulty towards generating a share every
+// max gen time when its share submission rate deviates from it. The client
+// is notified of the updated difficulty and sent work to apply it to.
+func (c *Client) retarget() {
+	// Only retarget authorized and subscribed clients.
+	c.authorizedMtx.Lock()
+	authorized := c.authorized
+	c.authorizedMtx.Unlock()
+	c.subscribedMtx.Lock()
+	subscribed := c.subscribed
+	c.subscribedMtx.Unlock()
+
+	if !subscribed || !authorized {
+		return
+	}
+
+	shares := atomic.LoadInt64(&c.retargetShares)
+	lastRetarget := atomic.LoadInt64(&c.lastRetarget)
+	elapsed := time.Since(time.Unix(0, lastRetarget))
+	if shares < varDiffRetargetShares &&
+		elapsed < c.cfg.MaxGenTime*varDiffRetargetWindow {
+		return
+	}
+	atomic.StoreInt64(&c.retargetShares, 0)
+	atomic.StoreInt64(&c.lastRetarget, time.Now().UnixNano())
+
+	// The difficulty of the CPU miner, the least performant supported
+	// miner, is the minimum difficulty of the pool.
+	minDiff, err := c.cfg.FetchMinerDifficulty(CPU)
+	if err != nil {
+		log.Errorf("%s: unable to fetch minimum difficulty: %v", c.id, err)
+		return
+	}
+	current := c.fetchDifficulty()
+	diff := calculateVarDiff(current.difficulty, minDiff.difficulty, shares,
+		elapsed, c.cfg.MaxGenTime)
+	if diff == nil {
+		return
+	}
+	target, err := DifficultyToTarget(c.cfg.ActiveNet, diff)
+	if err != nil {
+		log.Errorf("%s: unable to calculate target: %v", c.id, err)
+		return
+	}
+
+	c.diffInfoMtx.Lock()
+	c.diffInfo = &DifficultyInfo{
+		target:     target,
+		difficulty: diff,
+		powLimit:   current.powLimit,
+	}
+	c.diffInfoMtx.Unlock()
+
+	log.Tracef("%s difficulty retargeted from %s to %s", c.id,
+		current.difficulty.FloatString(4), diff.FloatString(4))
+
+	c.setDifficulty()
+	c.updateWork()
 }
 
 // handleSubmitWorkRequest processes work submission request messages received.
 func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 	if !allowed {
+		c.recordShare(shareRejected, reasonLimitReached)
 		err := fmt.Errorf("unable to process submit work request, limit reached")
 		sErr := NewStratumError(Unknown, err)
 		resp := SubmitWorkResponse(*req.ID, false, sErr)
@@ -354,8 +583,9 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 	}
 
 	_, jobID, extraNonce2E, nTimeE, nonceE, err :=
-		ParseSubmitWorkRequest(req, c.cfg.FetchMiner())
+		ParseSubmitWorkRequest(req, c.fetchMiner())
 	if err != nil {
+		c.recordShare(shareRejected, reasonMalformed)
 		err := fmt.Errorf("unable to parse submit work request: %v", err)
 		sErr := NewStratumError(Unknown, err)
 		resp := SubmitWorkResponse(*req.ID, false, sErr)
@@ -364,26 +594,48 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 	}
 	job, err := FetchJob(c.cfg.DB, []byte(jobID))
 	if err != nil {
+		c.recordShare(shareRejected, reasonUnknownJob)
 		err := fmt.Errorf("unable to fetch job: %v", err)
 		sErr := NewStratumError(Unknown, err)
 		resp := SubmitWorkResponse(*req.ID, false, sErr)
 		c.ch <- resp
 		return err
 	}
+	if c.cfg.IsStaleJob(job) {
+		atomic.AddInt64(&c.staleShares, 1)
+		c.recordShare(shareStale, "")
+		err := fmt.Errorf("work submitted by %s references stale job %s",
+			c.id, jobID)
+		sErr := NewStratumError(StaleJob, err)
+		resp := SubmitWorkResponse(*req.ID, false, sErr)
+		c.ch <- resp
+		return err
+	}
 	header, err := GenerateSolvedBlockHeader(job.Header, c.extraNonce1,
-		extraNonce2E, nTimeE, nonceE, c.cfg.FetchMiner())
+		extraNonce2E, nTimeE, nonceE, c.fetchMiner())
 	if err != nil {
+		c.recordShare(shareRejected, reasonInvalidHeader)
 		err := fmt.Errorf("unable to generate solved block header: %v", err)
 		sErr := NewStratumError(Unknown, err)
 		resp := SubmitWorkResponse(*req.ID, false, sErr)
 		c.ch <- resp
 		return err
 	}
-	diffInfo := c.cfg.DifficultyInfo
+	err = validateTimestamp(header, job, time.Now())
+	if err != nil {
+		c.recordShare(shareRejected, reasonTimestamp)
+		err := fmt.Errorf("invalid timestamp submitted by %s: %v", c.id, err)
+		sErr := NewStratumError(Unknown, err)
+		resp := SubmitWorkResponse(*req.ID, false, sErr)
+		c.ch <- resp
+		return err
+	}
+	diffInfo := c.fetchJobDifficulty(jobID)
 	target := new(big.Rat).SetInt(standalone.CompactToBig(header.Bits))
 
 	// The target difficulty must be larger than zero.
 	if target.Sign() <= 0 {
+		c.recordShare(shareRejected, reasonInvalidHeader)
 		err := fmt.Errorf("block target difficulty of %064x is too "+
 			"low", target)
 		sErr := NewStratumError(Unknown, err)
@@ -402,6 +654,7 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 	// Only submit work to the network if the submitted blockhash is
 	// less than the pool target for the client.
 	if hashTarget.Cmp(diffInfo.target) > 0 {
+		c.recordShare(shareLowDifficulty, "")
 		err := fmt.Errorf("submitted work from %s is not less than its "+
 			"corresponding pool target", c.id)
 		sErr := NewStratumError(LowDifficultyShare, err)
@@ -409,13 +662,35 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 		c.ch <- resp
 		return err
 	}
+
+	// Reject share submissions that have already been recorded for the job.
+	err = c.cfg.RecordSubmission(jobID, c.extraNonce1, extraNonce2E, nTimeE,
+		nonceE)
+	if err != nil {
+		if IsError(err, ErrShareExists) {
+			c.recordShare(shareRejected, reasonDuplicate)
+			err := fmt.Errorf("duplicate share submitted by %s: %v", c.id, err)
+			sErr := NewStratumError(DuplicateShare, err)
+			resp := SubmitWorkResponse(*req.ID, false, sErr)
+			c.ch <- resp
+			return err
+		}
+		c.recordShare(shareRejected, reasonInternal)
+		err := fmt.Errorf("unable to record share submission: %v", err)
+		sErr := NewStratumError(Unknown, err)
+		resp := SubmitWorkResponse(*req.ID, false, sErr)
+		c.ch <- resp
+		return err
+	}
 	atomic.AddInt64(&c.submissions, 1)
+	atomic.AddInt64(&c.retargetShares, 1)
 
 	// Claim a weighted share for work contributed to the pool if not mining
 	// in solo mining mode.
 	if !c.cfg.SoloPool {
-		err := c.claimWeightedShare()
+		err := c.claimWeightedShare(diffInfo.difficulty)
 		if err != nil {
+			c.recordShare(shareRejected, reasonInternal)
 			err := fmt.Errorf("unable to claim weighted share for %v: %v",
 				c.id, err)
 			sErr := NewStratumError(Unknown, err)
@@ -424,6 +699,8 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 			return err
 		}
 	}
+	c.recordShare(shareAccepted, "")
+	c.cfg.AddRoundWork(diffInfo.difficulty)
 
 	// Only submit work to the network if the submitted blockhash is
 	// less than the network target difficulty.
@@ -448,8 +725,17 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 	copy(submissionB[wire.MaxBlockHeaderPayload:],
 		c.cfg.Blake256Pad)
 	submission := hex.EncodeToString(submissionB)
+	work := NewAcceptedWork(hash.String(), header.PrevBlock.String(),
+		header.Height, c.account, c.fetchMiner())
+	work.NetworkDifficulty, _ = netDiff.Float64()
 	accepted, err := c.cfg.SubmitWork(&submission)
 	if err != nil {
+		// Track the work as submitted since the outcome of the submission
+		// is unknown, it is confirmed if the network accepted it.
+		cErr := work.Create(c.cfg.DB)
+		if cErr != nil && !IsError(cErr, ErrWorkExists) {
+			log.Errorf("unable to persist submitted work: %v", cErr)
+		}
 		err := fmt.Errorf("unable to submit work request: %v", err)
 		sErr := NewStratumError(Unknown, err)
 		resp := SubmitWorkResponse(*req.ID, false, sErr)
@@ -462,11 +748,9 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 		return fmt.Errorf("work %s rejected by the network", hash.String())
 	}
 
-	// Create accepted work if the work submission is accepted
+	// Persist the work as accepted if the work submission is accepted
 	// by the mining node.
-	work := NewAcceptedWork(hash.String(), header.PrevBlock.String(),
-		header.Height, c.account, c.cfg.FetchMiner())
-	err = work.Create(c.cfg.DB)
+	err = work.Accept(c.cfg.DB)
 	if err != nil {
 		// If the submitted accepted work already exists, ignore the
 		// submission.
@@ -484,6 +768,16 @@ func (c *Client) handleSubmitWorkRequest(req *Request, allowed bool) error {
 		return err
 	}
 	log.Tracef("Work %s accepted by the network", hash.String())
+
+	// Record the effort of the pool for the accepted work and start a new
+	// round.
+	work.Effort = c.cfg.CompleteRound(netDiff)
+	err = work.Update(c.cfg.DB)
+	if err != nil {
+		log.Errorf("unable to record effort of accepted work %s: %v",
+			hash.String(), err)
+	}
+	c.cfg.Notify(NewEvent(EventBlockFound, c.account, work))
 	resp := SubmitWorkResponse(*req.ID, true, nil)
 	c.ch <- resp
 	return nil
@@ -661,6 +955,7 @@ func (c *Client) process(ctx context.Context) {
 					}
 					if allowed {
 						c.setDifficulty()
+						atomic.StoreInt64(&c.lastRetarget, time.Now().UnixNano())
 						time.Sleep(time.Second)
 						c.updateWork()
 					}
@@ -738,6 +1033,31 @@ func hexReversed(in string) (string, error) {
 	return buf.String(), nil
 }
 
+// validateTimestamp ensures the timestamp of the provided solved header is
+// not earlier than that of its job, which the consensus daemon generated
+// ahead of the median time of past blocks, and not further ahead of the
+// provided time than the consensus daemon allows.
+func validateTimestamp(header *wire.BlockHeader, job *Job, now time.Time) error {
+	nTimeD, err := hex.DecodeString(job.Header[272:280])
+	if err != nil {
+		desc := fmt.Sprintf("failed to decode job timestamp %s",
+			job.Header[272:280])
+		return MakeError(ErrDecode, desc, err)
+	}
+	jobTime := time.Unix(int64(binary.LittleEndian.Uint32(nTimeD)), 0)
+	if header.Timestamp.Before(jobTime) {
+		desc := fmt.Sprintf("timestamp %v is earlier than the job "+
+			"timestamp %v", header.Timestamp, jobTime)
+		return MakeError(ErrOther, desc, nil)
+	}
+	if header.Timestamp.After(now.Add(maxTimeOffset)) {
+		desc := fmt.Sprintf("timestamp %v is too far in the future",
+			header.Timestamp)
+		return MakeError(ErrOther, desc, nil)
+	}
+	return nil
+}
+
 // handleAntminerDR3 prepares work notifications for the Antminer DR3.
 func (c *Client) handleAntminerDR3Work(req *Request) {
 	jobID, prevBlock, genTx1, genTx2, blockVersion, nBits, nTime,
@@ -888,6 +1208,33 @@ func (c *Client) setHashRate(hash *big.Rat) {
 	c.hashRateMtx.Unlock()
 }
 
+// recordShare counts a share submission of the client with the provided
+// status and records it with the provided rejection reason.
+func (c *Client) recordShare(status string, reason string) {
+	if status == shareAccepted {
+		atomic.AddInt64(&c.acceptedShares, 1)
+	} else {
+		atomic.AddInt64(&c.rejectedShares, 1)
+	}
+	c.cfg.RecordShare(c.fetchMiner(), status, reason)
+	if c.account != "" {
+		c.cfg.RecordWorkerShare(c.account, c.name, status, reason)
+	}
+}
+
+// fetchShareCounts returns the accepted and rejected share counts of the
+// client since the last call.
+func (c *Client) fetchShareCounts() (uint64, uint64) {
+	accepted := atomic.SwapInt64(&c.acceptedShares, 0)
+	rejected := atomic.SwapInt64(&c.rejectedShares, 0)
+	return uint64(accepted), uint64(rejected)
+}
+
+// FetchWorkerName gets the client's worker name.
+func (c *Client) FetchWorkerName() string {
+	return c.name
+}
+
 // FetchHashRate gets the client's hash rate.
 func (c *Client) FetchHashRate() *big.Rat {
 	c.hashRateMtx.Lock()
@@ -902,7 +1249,7 @@ func (c *Client) FetchIPAddr() string {
 
 // FetchMinerType gets the client's miner type.
 func (c *Client) FetchMinerType() string {
-	return c.cfg.FetchMiner()
+	return c.fetchMiner()
 }
 
 // FetchAccountID gets the client's account ID.
@@ -910,6 +1257,11 @@ func (c *Client) FetchAccountID() string {
 	return c.account
 }
 
+// FetchStaleShares gets the number of stale shares submitted by the client.
+func (c *Client) FetchStaleShares() int64 {
+	return atomic.LoadInt64(&c.staleShares)
+}
+
 func (c *Client) hashMonitor(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * time.Duration(c.cfg.HashCalcThreshold))
 	defer ticker.Stop()
@@ -925,7 +1277,7 @@ func (c *Client) hashMonitor(ctx context.Context) {
 				continue
 			}
 			average := float64(c.cfg.HashCalcThreshold) / float64(submissions)
-			diffInfo := c.cfg.DifficultyInfo
+			diffInfo := c.fetchDifficulty()
 			num := new(big.Rat).Mul(diffInfo.difficulty,
 				new(big.Rat).SetFloat64(c.cfg.NonceIterations))
 			denom := new(big.Rat).SetFloat64(average)
@@ -936,6 +1288,23 @@ func (c *Client) hashMonitor(ctx context.Context) {
 	}
 }
 
+// varDiffMonitor periodically reevaluates the client's difficulty based on
+// its share submission rate. It must be run as a goroutine.
+func (c *Client) varDiffMonitor(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.wg.Done()
+			return
+
+		case <-ticker.C:
+			c.retarget()
+		}
+	}
+}
+
 // Send dispatches messages to a pool client. It must be run as a goroutine.
 func (c *Client) send(ctx context.Context) {
 	for {
@@ -971,7 +1340,16 @@ func (c *Client) send(ctx context.Context) {
 						continue
 					}
 
-					switch c.cfg.FetchMiner() {
+					// Associate the work with the client's current difficulty
+					// in order to validate submissions against it.
+					jobID, _, _, _, _, _, _, _, err := ParseWorkNotification(req)
+					if err != nil {
+						log.Errorf("unable to parse work message: %v", err)
+						continue
+					}
+					c.recordJobDifficulty(jobID)
+
+					switch c.fetchMiner() {
 					case CPU:
 						c.handleCPUWork(req)
 						log.Tracef("%s notified of new work", c.id)
@@ -993,7 +1371,7 @@ func (c *Client) send(ctx context.Context) {
 						log.Tracef("%s notified of new work", c.id)
 
 					default:
-						log.Errorf("unknown miner provided: %s", c.cfg.FetchMiner())
+						log.Errorf("unknown miner provided: %s", c.fetchMiner())
 						c.cancel()
 						continue
 					}
@@ -1017,11 +1395,12 @@ func (c *Client) run(ctx context.Context) {
 	endpointWg.Add(1)
 	go c.read()
 
-	c.wg.Add(4)
+	c.wg.Add(5)
 	go c.process(ctx)
 	go c.send(ctx)
 	go c.hashMonitor(ctx)
 	go c.rollWork(ctx)
+	go c.varDiffMonitor(ctx)
 	c.wg.Wait()
 
 	c.shutdown()
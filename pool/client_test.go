@@ -16,10 +16,9 @@ import (
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
-func testClient(t *testing.T, db *bolt.DB) {
+func testClient(t *testing.T, db Database) {
 	port := uint32(3030)
 	laddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", "127.0.0.1", port))
 	if err != nil {
@@ -94,6 +93,16 @@ func testClient(t *testing.T, db *bolt.DB) {
 		currentWork = work
 		currentWorkMtx.Unlock()
 	}
+	metrics := NewMetrics()
+	workers, err := newWorkerRegistry(db)
+	if err != nil {
+		t.Fatalf("[newWorkerRegistry] unexpected error: %v", err)
+	}
+	roundEffort, err := newRoundEffort(db)
+	if err != nil {
+		t.Fatalf("[newRoundEffort] unexpected error: %v", err)
+	}
+	submissions := newSubmissionSet()
 	cCfg := &ClientConfig{
 		ActiveNet:       chaincfg.SimNetParams(),
 		DB:              db,
@@ -104,10 +113,11 @@ func testClient(t *testing.T, db *bolt.DB) {
 			defer minerMtx.RUnlock()
 			return miner
 		},
-		SoloPool:       false,
-		DifficultyInfo: diffInfo,
-		EndpointWg:     new(sync.WaitGroup),
-		RemoveClient:   func(c *Client) {},
+		FetchMinerDifficulty: poolDiffs.fetchMinerDifficulty,
+		SoloPool:             false,
+		DifficultyInfo:       diffInfo,
+		EndpointWg:           new(sync.WaitGroup),
+		RemoveClient:         func(c *Client) {},
 		SubmitWork: func(submission *string) (bool, error) {
 			return false, nil
 		},
@@ -116,11 +126,25 @@ func testClient(t *testing.T, db *bolt.DB) {
 			defer currentWorkMtx.RUnlock()
 			return currentWork
 		},
+		IsStaleJob: func(*Job) bool {
+			return false
+		},
+		RecordSubmission: submissions.record,
 		WithinLimit: func(ip string, clientType int) bool {
 			return true
 		},
 		HashCalcThreshold: 1,
 		ClientTimeout:     time.Millisecond * 1300,
+		RecordShare: func(miner string, status string, reason string) {
+			metrics.add(sharesMetric, 1, "miner", miner, "status", status,
+				"reason", reason)
+		},
+		AddWorker:         workers.connect,
+		RemoveWorker:      workers.disconnect,
+		RecordWorkerShare: workers.recordShare,
+		Notify:            func(*Event) {},
+		AddRoundWork:      roundEffort.addWork,
+		CompleteRound:     roundEffort.complete,
 	}
 	client, err := NewClient(c, tcpAddr, cCfg)
 	if err != nil {
@@ -231,6 +255,28 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatal("expected an invalid username authorize error response")
 	}
 
+	// Ensure a CPU client receives an error response when the provided
+	// worker name is too long.
+	id++
+	r = AuthorizeRequest(&id, strings.Repeat("m", maxWorkerNameLength+1),
+		"SsiuwSRYvH7pqWmRxFJWR8Vmqc3AWsjmK2Y")
+	err = sE.Encode(r)
+	if err != nil {
+		t.Fatalf("[Encode] unexpected error: %v", err)
+	}
+	data = <-recvCh
+	msg, _, err = IdentifyMessage(data)
+	if err != nil {
+		t.Fatalf("[IdentifyMessage] unexpected error: %v", err)
+	}
+	resp, ok = msg.(*Response)
+	if !ok {
+		t.Fatalf("expected response with id %d, got %d", *r.ID, resp.ID)
+	}
+	if resp.Error == nil {
+		t.Fatal("expected a worker name length authorize error response")
+	}
+
 	// Ensure a CPU client receives an error response when it has
 	// exhausted its request limits.
 	client.cfg.WithinLimit = func(ip string, clientType int) bool {
@@ -285,6 +331,14 @@ func testClient(t *testing.T, db *bolt.DB) {
 	if resp.Error != nil {
 		t.Fatalf("expected non-error authorize response, got %v", resp.Error)
 	}
+
+	// Ensure the authorized worker is registered as online.
+	accountWorkers := workers.fetchWorkers(client.FetchAccountID())
+	if len(accountWorkers) != 1 || accountWorkers[0].Name != "mn" ||
+		!accountWorkers[0].Online {
+		t.Fatalf("expected online worker mn to be registered, got %v",
+			accountWorkers)
+	}
 	data = <-recvCh
 	msg, mType, err = IdentifyMessage(data)
 	if err != nil {
@@ -516,8 +570,16 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("expected %s message method, got %s", Notify, req.Method)
 	}
 
+	// Ensure the difficulty the work was issued at is recorded.
+	client.jobDiffsMtx.Lock()
+	_, ok = client.jobDiffs[job.UUID]
+	client.jobDiffsMtx.Unlock()
+	if !ok {
+		t.Fatalf("expected a difficulty record for job %s", job.UUID)
+	}
+
 	// Claim a weighted share for the CPU client.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (CPU)] unexpected error: %v", err)
 	}
@@ -525,13 +587,13 @@ func testClient(t *testing.T, db *bolt.DB) {
 	// Ensure a CPU client receives an error response when
 	// it triggers a weighted share error.
 	client.cfg.SoloPool = true
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err == nil {
 		t.Fatalf("[claimWeightedShare (CPU)] expected a solo pool mode error")
 	}
 	client.cfg.SoloPool = false
 	client.cfg.ActiveNet = chaincfg.MainNetParams()
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err == nil {
 		t.Fatalf("[claimWeightedShare (CPU)] expected an active " +
 			"network cpu share error")
@@ -549,7 +611,7 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 
 	// Claim a weighted share for the Innosilicon D9 client.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (D9)] unexpected error: %v", err)
 	}
@@ -566,11 +628,22 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 
 	// Claim a weighted share for the Whatsminer D1.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (D1)] unexpected error: %v", err)
 	}
 
+	// Ensure the share is credited at the difficulty it was checked against.
+	shares, err := PPLNSLastNShares(db, 1)
+	if err != nil {
+		t.Fatalf("[PPLNSLastNShares] unexpected error: %v", err)
+	}
+	if len(shares) != 1 ||
+		shares[0].Difficulty.Cmp(client.fetchDifficulty().difficulty) != 0 {
+		t.Fatalf("expected a share credited at difficulty %v",
+			client.fetchDifficulty().difficulty)
+	}
+
 	// Send a work notification to an Antminer DR3 client.
 	setMiner(AntminerDR3)
 	client.ch <- r
@@ -583,7 +656,7 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 
 	// Claim a weighted share for the Antminer DR3.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (DR3)] unexpected error: %v", err)
 	}
@@ -599,7 +672,7 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 
 	// Claim a weighted share for the Antminer DR5.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (DR5)] unexpected error: %v", err)
 	}
@@ -616,7 +689,7 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 
 	// Claim a weighted share for the Obelisk DCR1.
-	err = client.claimWeightedShare()
+	err = client.claimWeightedShare(client.fetchDifficulty().difficulty)
 	if err != nil {
 		t.Fatalf("[claimWeightedShare (DCR1)] unexpected error: %v", err)
 	}
@@ -729,6 +802,92 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("expected a job not found error")
 	}
 
+	// Ensure a CPU client receives an error response when
+	// submitting work referencing a stale job.
+	client.cfg.IsStaleJob = func(*Job) bool {
+		return true
+	}
+	id++
+	sub = SubmitWorkRequest(&id, "tcl", job.UUID, "00000000", "05ec705e", "116f0200")
+	err = sE.Encode(sub)
+	if err != nil {
+		t.Fatalf("[Encode] unexpected error: %v", err)
+	}
+	cpuSub = <-recvCh
+	msg, mType, err = IdentifyMessage(cpuSub)
+	if err != nil {
+		t.Fatalf("[IdentifyMessage] unexpected error: %v", err)
+	}
+	if mType != ResponseMessage {
+		t.Fatalf("expected a response message, got %v", mType)
+	}
+	resp, ok = msg.(*Response)
+	if !ok {
+		t.Fatalf("unable to cast message as response")
+	}
+	if resp.ID != *sub.ID {
+		t.Fatalf("expected a response with id %d, got %d", *sub.ID, resp.ID)
+	}
+	if resp.Error == nil {
+		t.Fatalf("expected a stale job error")
+	}
+	if resp.Error.Code != StaleJob {
+		t.Fatalf("expected a stale job error code, got %d", resp.Error.Code)
+	}
+	if client.FetchStaleShares() != 1 {
+		t.Fatalf("expected a stale share count of 1, got %d",
+			client.FetchStaleShares())
+	}
+	staleShares := metrics.fetch(sharesMetric, "miner", client.fetchMiner(),
+		"status", shareStale, "reason", "")
+	if staleShares != 1 {
+		t.Fatalf("expected a stale share metric of 1, got %v", staleShares)
+	}
+	client.cfg.IsStaleJob = func(*Job) bool {
+		return false
+	}
+
+	// Ensure a CPU client receives an error response when submitting
+	// work with an invalid extraNonce2 size or timestamp.
+	invalidSubs := []struct {
+		extraNonce2 string
+		nTime       string
+	}{
+		// An extraNonce2 of the wrong size.
+		{extraNonce2: "0000", nTime: "05ec705e"},
+		// A timestamp earlier than that of the job.
+		{extraNonce2: "00000000", nTime: "04ec705e"},
+		// A timestamp too far in the future.
+		{extraNonce2: "00000000", nTime: "ffffffff"},
+	}
+	for idx, tc := range invalidSubs {
+		id++
+		sub = SubmitWorkRequest(&id, "tcl", job.UUID, tc.extraNonce2,
+			tc.nTime, "116f0200")
+		err = sE.Encode(sub)
+		if err != nil {
+			t.Fatalf("[Encode] unexpected error: %v", err)
+		}
+		cpuSub = <-recvCh
+		msg, mType, err = IdentifyMessage(cpuSub)
+		if err != nil {
+			t.Fatalf("[IdentifyMessage] unexpected error: %v", err)
+		}
+		if mType != ResponseMessage {
+			t.Fatalf("expected a response message, got %v", mType)
+		}
+		resp, ok = msg.(*Response)
+		if !ok {
+			t.Fatalf("unable to cast message as response")
+		}
+		if resp.ID != *sub.ID {
+			t.Fatalf("expected a response with id %d, got %d", *sub.ID, resp.ID)
+		}
+		if resp.Error == nil {
+			t.Fatalf("#%d: expected an invalid work submission error", idx+1)
+		}
+	}
+
 	// Ensure a non-supported client receives an error response when
 	// submitting work.
 	setMiner("notaminer")
@@ -791,6 +950,9 @@ func testClient(t *testing.T, db *bolt.DB) {
 		return true, nil
 	}
 
+	// Clear recorded share submissions to allow resubmitting the work.
+	submissions.prune(math.MaxUint32)
+
 	// Ensure a CPU client receives a non-error response when
 	// submitting valid work.
 	id++
@@ -818,6 +980,35 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("expected a non-error work submission response, got %v", resp.Error)
 	}
 
+	// Ensure the work submitted with an unknown outcome is promoted to
+	// accepted along with its effort.
+	minedWork, err := ListMinedWork(db)
+	if err != nil {
+		t.Fatalf("[ListMinedWork] unexpected error: %v", err)
+	}
+	var acceptedWork *AcceptedWork
+	for _, work := range minedWork {
+		if work.Status == WorkAccepted && work.MinedBy == client.FetchAccountID() {
+			acceptedWork = work
+		}
+	}
+	if acceptedWork == nil {
+		t.Fatal("expected the submitted work to be accepted")
+	}
+	if acceptedWork.SubmittedOn == 0 || acceptedWork.AcceptedOn == 0 {
+		t.Fatalf("expected submission and acceptance times, got %d and %d",
+			acceptedWork.SubmittedOn, acceptedWork.AcceptedOn)
+	}
+	if acceptedWork.Effort <= 0 || acceptedWork.NetworkDifficulty <= 0 {
+		t.Fatalf("expected a positive effort and network difficulty, "+
+			"got %v and %v", acceptedWork.Effort,
+			acceptedWork.NetworkDifficulty)
+	}
+	if roundEffort.fetchWork().Sign() != 0 {
+		t.Fatalf("expected a new round after accepted work, got %v",
+			roundEffort.fetchWork())
+	}
+
 	// Ensure a CPU client receives an error response when
 	// submitting duplicate work.
 	id++
@@ -842,12 +1033,31 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("expected a response with id %d, got %d", *sub.ID, resp.ID)
 	}
 	if resp.Error == nil {
-		t.Fatal("expected a work exists work submission error")
+		t.Fatal("expected a duplicate share work submission error")
+	}
+	if resp.Error.Code != DuplicateShare {
+		t.Fatalf("expected a duplicate share error code, got %d",
+			resp.Error.Code)
+	}
+	duplicateShares := metrics.fetch(sharesMetric, "miner",
+		client.fetchMiner(), "status", shareRejected, "reason",
+		reasonDuplicate)
+	if duplicateShares != 1 {
+		t.Fatalf("expected a duplicate share metric of 1, got %v",
+			duplicateShares)
+	}
+	accountWorkers = workers.fetchWorkers(client.FetchAccountID())
+	if accountWorkers[0].Rejections[reasonDuplicate] != 1 {
+		t.Fatalf("expected a duplicate share rejection for worker %s, "+
+			"got %v", accountWorkers[0].Name, accountWorkers[0].Rejections)
 	}
 	client.cfg.SubmitWork = func(submission *string) (bool, error) {
 		return false, nil
 	}
 
+	// Clear recorded share submissions to allow resubmitting the work.
+	submissions.prune(math.MaxUint32)
+
 	// Ensure a CPU client receives an error response when
 	// submitting work intended for a different network.
 	client.cfg.ActiveNet = chaincfg.MainNetParams()
@@ -877,6 +1087,9 @@ func testClient(t *testing.T, db *bolt.DB) {
 	}
 	client.cfg.ActiveNet = chaincfg.SimNetParams()
 
+	// Clear recorded share submissions to allow resubmitting the work.
+	submissions.prune(math.MaxUint32)
+
 	// Ensure a CPU client receives an error response when
 	// submitting work that is rejected by the network.
 	id++
@@ -1038,6 +1251,55 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatal("expected a non-nil client hash rate")
 	}
 
+	// Fake a high share submission rate and ensure the difficulty of a
+	// client configured with a max gen time gets retargeted.
+	rc, rs, err := makeConn(ln, serverCh)
+	if err != nil {
+		t.Fatalf("[makeConn] unexpected error: %v", err)
+	}
+	raddr := rc.RemoteAddr()
+	rtcpAddr, err := net.ResolveTCPAddr(raddr.Network(), raddr.String())
+	if err != nil {
+		t.Fatalf("unable to parse tcp addresss: %v", err)
+	}
+	retargetCfg := *cCfg
+	retargetCfg.MaxGenTime = maxGenTime
+	retargetClient, err := NewClient(rc, rtcpAddr, &retargetCfg)
+	if err != nil {
+		t.Fatalf("[NewClient] unexpected error: %v", err)
+	}
+	retargetClient.authorized = true
+	retargetClient.subscribed = true
+	initialDiff := retargetClient.fetchDifficulty().difficulty
+	atomic.StoreInt64(&retargetClient.lastRetarget,
+		time.Now().Add(-maxGenTime).UnixNano())
+	atomic.StoreInt64(&retargetClient.retargetShares, varDiffRetargetShares*4)
+	go retargetClient.run(retargetClient.ctx)
+	data, err = bufio.NewReaderSize(rs, maxMessageSize).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("[ReadBytes] unexpected error: %v", err)
+	}
+	msg, mType, err = IdentifyMessage(data)
+	if err != nil {
+		t.Fatalf("[IdentifyMessage] unexpected error: %v", err)
+	}
+	if mType != NotificationMessage {
+		t.Fatalf("expected a notification message, got %v", mType)
+	}
+	req, ok = msg.(*Request)
+	if !ok {
+		t.Fatalf("unable to cast message as request")
+	}
+	if req.Method != SetDifficulty {
+		t.Fatalf("expected %s message method, got %s", SetDifficulty, req.Method)
+	}
+	if retargetClient.fetchDifficulty().difficulty.Cmp(initialDiff) <= 0 {
+		t.Fatalf("expected a retargeted difficulty greater than %v",
+			initialDiff.FloatString(4))
+	}
+	retargetClient.cancel()
+	rs.Close()
+
 	// Ensure the client gets terminated if it sends an unknown message type.
 	id++
 	r = &Request{
@@ -1202,6 +1464,26 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("expected %s message method, got %s", Notify, req.Method)
 	}
 
+	// Ensure the client's miner type is identified from its user agent.
+	client.detectMiner(AntminerDR3)
+	if client.FetchMinerType() != AntminerDR3 {
+		t.Fatalf("expected a miner type of %s, got %s", AntminerDR3,
+			client.FetchMinerType())
+	}
+
+	// Ensure an unidentified user agent does not change the miner type.
+	client.detectMiner("unknownminer/1.0.0")
+	if client.FetchMinerType() != AntminerDR3 {
+		t.Fatalf("expected a miner type of %s, got %s", AntminerDR3,
+			client.FetchMinerType())
+	}
+	client.minerMtx.Lock()
+	client.miner = ""
+	client.minerMtx.Unlock()
+	client.diffInfoMtx.Lock()
+	client.diffInfo = diffInfo
+	client.diffInfoMtx.Unlock()
+
 	// Trigger a client timeout by waiting.
 	time.Sleep(time.Millisecond * 1500)
 
@@ -1217,5 +1499,8 @@ func testClient(t *testing.T, db *bolt.DB) {
 		t.Fatalf("emptyBucket error: %v", err)
 	}
 
+	// Clear recorded share submissions.
+	submissions.prune(math.MaxUint32)
+
 	client.cfg.EndpointWg.Wait()
 }
@@ -0,0 +1,72 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+// payoutScripts returns the output scripts paying to the provided mining
+// addresses.
+func payoutScripts(addrs []dcrutil.Address) ([][]byte, error) {
+	scripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			desc := fmt.Sprintf("unable to create payout script for "+
+				"mining address %s", addr)
+			return nil, MakeError(ErrOther, desc, err)
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+// isPayoutScript checks if the provided output script pays to one of the
+// provided payout scripts.
+func isPayoutScript(pkScript []byte, scripts [][]byte) bool {
+	for _, script := range scripts {
+		if bytes.Equal(pkScript, script) {
+			return true
+		}
+	}
+	return false
+}
+
+// coinbaseReward returns the reward paid to the pool by the coinbase of the
+// provided block. The reward is the sum of the coinbase outputs paying to
+// the provided payout scripts, which includes the transaction fees of the
+// block. The coinbase layout is not assumed, outputs are identified by
+// their scripts only.
+func coinbaseReward(block *wire.MsgBlock, scripts [][]byte) (dcrutil.Amount, error) {
+	if len(block.Transactions) == 0 {
+		desc := fmt.Sprintf("block #%d has no coinbase transaction",
+			block.Header.Height)
+		return 0, MakeError(ErrCoinbaseReward, desc, nil)
+	}
+	var reward int64
+	var matched bool
+	for _, out := range block.Transactions[0].TxOut {
+		if out.Version != txscript.DefaultScriptVersion {
+			continue
+		}
+		if !isPayoutScript(out.PkScript, scripts) {
+			continue
+		}
+		reward += out.Value
+		matched = true
+	}
+	if !matched {
+		desc := fmt.Sprintf("no coinbase outputs of block #%d pay to "+
+			"the pool mining addresses", block.Header.Height)
+		return 0, MakeError(ErrCoinbaseReward, desc, nil)
+	}
+	return dcrutil.Amount(reward), nil
+}
@@ -0,0 +1,138 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+func testCoinbaseReward(t *testing.T) {
+	secondAddr, err := dcrutil.DecodeAddress(yAddr, chaincfg.SimNetParams())
+	if err != nil {
+		t.Fatalf("[DecodeAddress] unexpected error: %v", err)
+	}
+	scripts, err := payoutScripts([]dcrutil.Address{miningAddr, secondAddr})
+	if err != nil {
+		t.Fatalf("[payoutScripts] unexpected error: %v", err)
+	}
+	payoutScript := scripts[0]
+	secondScript := scripts[1]
+	otherScripts, err := payoutScripts([]dcrutil.Address{poolFeeAddrs})
+	if err != nil {
+		t.Fatalf("[payoutScripts] unexpected error: %v", err)
+	}
+	otherScript := otherScripts[0]
+	heightScript := []byte{0x6a, 0x0c, 0x14, 0x00, 0x00, 0x00}
+
+	newBlock := func(outs ...*wire.TxOut) *wire.MsgBlock {
+		coinbase := wire.NewMsgTx()
+		for _, out := range outs {
+			coinbase.AddTxOut(out)
+		}
+		return &wire.MsgBlock{
+			Header:       wire.BlockHeader{Height: 20},
+			Transactions: []*wire.MsgTx{coinbase},
+		}
+	}
+	versionedOut := wire.NewTxOut(500, payoutScript)
+	versionedOut.Version = 1
+
+	tests := []struct {
+		name   string
+		block  *wire.MsgBlock
+		reward dcrutil.Amount
+		err    bool
+	}{
+		{
+			name: "standard layout",
+			block: newBlock(
+				wire.NewTxOut(100, otherScript),
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(1000, payoutScript),
+			),
+			reward: 1000,
+		},
+		{
+			name: "multiple payout outputs",
+			block: newBlock(
+				wire.NewTxOut(100, otherScript),
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(600, payoutScript),
+				wire.NewTxOut(400, payoutScript),
+			),
+			reward: 1000,
+		},
+		{
+			name: "reordered layout",
+			block: newBlock(
+				wire.NewTxOut(1000, payoutScript),
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(100, otherScript),
+			),
+			reward: 1000,
+		},
+		{
+			name: "multiple mining addresses",
+			block: newBlock(
+				wire.NewTxOut(100, otherScript),
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(700, payoutScript),
+				wire.NewTxOut(300, secondScript),
+			),
+			reward: 1000,
+		},
+		{
+			name: "non-default script version",
+			block: newBlock(
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(1000, payoutScript),
+				versionedOut,
+			),
+			reward: 1000,
+		},
+		{
+			name: "no payout outputs",
+			block: newBlock(
+				wire.NewTxOut(100, otherScript),
+				wire.NewTxOut(0, heightScript),
+				wire.NewTxOut(1000, otherScript),
+			),
+			err: true,
+		},
+		{
+			name:  "empty coinbase",
+			block: newBlock(),
+			err:   true,
+		},
+		{
+			name: "no coinbase",
+			block: &wire.MsgBlock{
+				Header: wire.BlockHeader{Height: 20},
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		reward, err := coinbaseReward(test.block, scripts)
+		if test.err {
+			if err == nil {
+				t.Fatalf("%s: expected an error", test.name)
+			}
+			if !IsError(err, ErrCoinbaseReward) {
+				t.Fatalf("%s: expected a coinbase reward error, got %v",
+					test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if reward != test.reward {
+			t.Fatalf("%s: expected a reward of %v, got %v", test.name,
+				test.reward, reward)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"math/big"
+	"net/http"
+)
+
+// Database represents the persistence layer of the mining pool. All pool
+// state is read and written through it, allowing the storage backend to be
+// swapped or mocked.
+//
+// Fetch methods return an ErrValueNotFound error when the requested entry
+// does not exist. Lists returned are never nil.
+type Database interface {
+	// Close closes the database.
+	Close() error
+	// Backup saves a copy of the database to the provided file name, in
+	// the directory of the database.
+	Backup(fileName string) error
+	// HTTPBackup streams a copy of the database over the provided http
+	// response.
+	HTTPBackup(w http.ResponseWriter) error
+	// Snapshot writes a verified, timestamped copy of the database to the
+	// provided directory while the database remains online, gzip
+	// compressed if set. The path of the snapshot is returned.
+	Snapshot(dir string, compress bool) (string, error)
+	// Purge removes all existing data of the database.
+	Purge() error
+
+	// FetchPoolMode fetches the persisted pool mode, 1 for solo pool mode
+	// and 0 for pool mode.
+	FetchPoolMode() (uint32, error)
+	// PersistPoolMode saves the provided pool mode.
+	PersistPoolMode(mode uint32) error
+	// FetchCSRFSecret fetches the persisted CSRF secret.
+	FetchCSRFSecret() ([]byte, error)
+	// PersistCSRFSecret saves the provided CSRF secret.
+	PersistCSRFSecret(secret []byte) error
+	// FetchPaymentState fetches the persisted state of the payment manager,
+	// values never persisted default to zero.
+	FetchPaymentState() (*PaymentState, error)
+	// PersistPaymentState saves the provided payment manager state.
+	PersistPaymentState(state *PaymentState) error
+	// FetchRoundWork fetches the work contributed to the pool since the
+	// last block found by the pool, it defaults to zero.
+	FetchRoundWork() (*big.Rat, error)
+	// PersistRoundWork saves the provided round work.
+	PersistRoundWork(work *big.Rat) error
+
+	// FetchAccount fetches the account referenced by the provided id.
+	FetchAccount(id string) (*Account, error)
+	// PersistAccount saves the provided account.
+	PersistAccount(account *Account) error
+	// DeleteAccount removes the account referenced by the provided id.
+	DeleteAccount(id string) error
+
+	// FetchJob fetches the job referenced by the provided id.
+	FetchJob(id string) (*Job, error)
+	// PersistJob saves the provided job.
+	PersistJob(job *Job) error
+	// DeleteJob removes the job referenced by the provided id.
+	DeleteJob(id string) error
+	// PruneJobs removes all jobs with heights less than the provided height.
+	PruneJobs(height uint32) error
+
+	// PersistShare saves the provided share.
+	PersistShare(share *Share) error
+	// FetchShares fetches all shares created within the provided inclusive
+	// bounds. List is ordered, oldest comes first.
+	FetchShares(minNano int64, maxNano int64) ([]*Share, error)
+	// FetchLastShares fetches shares from the most recent until the
+	// provided function reports the window of shares is complete. List is
+	// ordered, most recent comes first.
+	FetchLastShares(complete func(*Share) bool) ([]*Share, error)
+	// PruneShares removes all shares created before the provided minimum
+	// and saves the provided payment manager state atomically.
+	PruneShares(minNano int64, state *PaymentState) error
+
+	// FetchRound fetches the archived round at the provided height.
+	FetchRound(height uint32) (*Round, error)
+	// ArchiveRound archives all shares created before the provided minimum
+	// as the shares of the provided round and removes them. The provided
+	// payment manager state is saved atomically. Shares of an existing
+	// round at the same height are retained.
+	ArchiveRound(round *Round, minNano int64, state *PaymentState) error
+	// RestoreRound restores the shares of the archived round at the
+	// provided height and removes the round. The persisted last payment
+	// created on time is rewound to that of the round if later. The
+	// restored round is returned.
+	RestoreRound(height uint32) (*Round, error)
+	// PruneRounds removes all archived rounds with heights less than the
+	// provided height.
+	PruneRounds(height uint32) error
+
+	// FetchAcceptedWork fetches the accepted work referenced by the
+	// provided id.
+	FetchAcceptedWork(id string) (*AcceptedWork, error)
+	// PersistAcceptedWork saves the provided work. An ErrWorkExists error
+	// is returned if the work already exists.
+	PersistAcceptedWork(work *AcceptedWork) error
+	// UpdateAcceptedWork saves modifications to the provided work. An
+	// ErrWorkNotFound error is returned if the work does not exist.
+	UpdateAcceptedWork(work *AcceptedWork) error
+	// AcceptWork transitions the provided work to accepted and saves it.
+	// Work already persisted as submitted is updated, an ErrWorkExists
+	// error is returned for work already past submitted.
+	AcceptWork(work *AcceptedWork) error
+	// DeleteAcceptedWork removes the accepted work referenced by the
+	// provided id.
+	DeleteAcceptedWork(id string) error
+	// ListAcceptedWork fetches all accepted work. List is ordered, most
+	// recent comes first.
+	ListAcceptedWork() ([]*AcceptedWork, error)
+	// PruneAcceptedWork removes all submitted and accepted work not
+	// confirmed as mined work with heights less than the provided height.
+	PruneAcceptedWork(height uint32) error
+	// MatureAcceptedWork transitions all confirmed mined work with heights
+	// less than or equal to the provided height to matured.
+	MatureAcceptedWork(height uint32) error
+
+	// FetchPayment fetches the pending payment referenced by the provided
+	// id.
+	FetchPayment(id string) (*Payment, error)
+	// PersistPayment saves the provided pending payment.
+	PersistPayment(payment *Payment) error
+	// DeletePayment removes the pending payment referenced by the provided
+	// id.
+	DeletePayment(id string) error
+	// FetchPayments fetches all pending payments. List is ordered, oldest
+	// comes first.
+	FetchPayments() ([]*Payment, error)
+	// ArchivePayments removes the provided payments from the pending
+	// payments and archives them atomically.
+	ArchivePayments(payments []*Payment) error
+	// FetchArchivedPayments fetches all archived payments. List is
+	// ordered, most recent comes first.
+	FetchArchivedPayments() ([]*Payment, error)
+
+	// PersistPayoutAttempt saves the provided payout attempt.
+	PersistPayoutAttempt(attempt *PayoutAttempt) error
+	// FetchPayoutAttempts fetches all payout attempts. List is ordered,
+	// most recent comes first.
+	FetchPayoutAttempts() ([]*PayoutAttempt, error)
+
+	// PersistHistorySamples saves the provided samples at the provided
+	// resolution.
+	PersistHistorySamples(resolution string, samples []*HistorySample) error
+	// FetchHistory fetches the samples of the provided scope and id at the
+	// provided resolution taken at or after the provided time. List is
+	// ordered, oldest comes first.
+	FetchHistory(resolution string, scope string, id string, since int64) ([]*HistorySample, error)
+	// FetchHistoryRange fetches the samples of all series at the provided
+	// resolution taken within the provided start inclusive and end
+	// exclusive times. List is ordered by series.
+	FetchHistoryRange(resolution string, start int64, end int64) ([]*HistorySample, error)
+	// PruneHistory removes all samples at the provided resolution taken
+	// before the provided time.
+	PruneHistory(resolution string, before int64) error
+
+	// FetchWorkers fetches all workers.
+	FetchWorkers() ([]*Worker, error)
+	// PersistWorkers saves the provided workers atomically.
+	PersistWorkers(workers []*Worker) error
+	// DeleteWorkers removes the workers referenced by the provided ids.
+	DeleteWorkers(ids []string) error
+
+	// FetchWebhook fetches the webhook referenced by the provided id.
+	FetchWebhook(id string) (*Webhook, error)
+	// PersistWebhook saves the provided webhook. An account can register
+	// at most MaxAccountWebhooks webhooks.
+	PersistWebhook(webhook *Webhook) error
+	// DeleteWebhook removes the webhook referenced by the provided id.
+	DeleteWebhook(id string) error
+	// FetchAccountWebhooks fetches all webhooks registered by the provided
+	// account.
+	FetchAccountWebhooks(accountID string) ([]*Webhook, error)
+}
@@ -5,11 +5,18 @@
 package pool
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"net/http"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/decred/dcrd/dcrutil/v2"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -35,6 +42,26 @@ var (
 	// Confirmed processed payements are sourced from the payment bucket and
 	// archived.
 	paymentArchiveBkt = []byte("paymentarchivebkt")
+	// payoutBkt stores all payout attempts and their states for auditing
+	// failed payouts.
+	payoutBkt = []byte("payoutbkt")
+	// workerBkt stores the statistics of all named workers of pool
+	// accounts.
+	workerBkt = []byte("workerbkt")
+	// minuteHistoryBkt stores per minute hash rate and share samples of the
+	// pool, accounts and workers. It is pruned by the minute history
+	// retention period.
+	minuteHistoryBkt = []byte("minutehistorybkt")
+	// hourHistoryBkt stores hourly hash rate and share samples downsampled
+	// from the minute history. It is pruned by the hour history retention
+	// period.
+	hourHistoryBkt = []byte("hourhistorybkt")
+	// webhookBkt stores the notification webhooks registered by accounts.
+	webhookBkt = []byte("webhookbkt")
+	// roundBkt stores the shares pruned when generating payments for blocks
+	// mined by the pool, it is pruned by the current chain tip adjusted by
+	// the coinbase maturity and the max reorg height.
+	roundBkt = []byte("roundbkt")
 	// versionK is the key of the current version of the database.
 	versionK = []byte("version")
 	// lastPaymentCreatedOn is the key of the last time a payment was
@@ -47,6 +74,15 @@ var (
 	lastPaymentHeight = []byte("lastpaymentheight")
 	// txFeeReserve is the key of the tx fee reserve.
 	txFeeReserve = []byte("txfeereserve")
+	// poolBalance is the key of the pool balance of the pay per share
+	// payment schemes.
+	poolBalance = []byte("poolbalance")
+	// minedRewards is the key of the rewards of recently mined blocks
+	// credited to the pool balance.
+	minedRewards = []byte("minedrewards")
+	// roundWork is the key of the work contributed to the pool since the
+	// last block found by the pool.
+	roundWork = []byte("roundwork")
 	// soloPool is the solo pool mode key.
 	soloPool = []byte("solopool")
 	// csrfSecret is the CSRF secret key.
@@ -57,15 +93,29 @@ var (
 	backupFile = "backup.kv"
 )
 
+// poolBuckets are all buckets nested within the pool bucket.
+var poolBuckets = [][]byte{accountBkt, shareBkt, workBkt, jobBkt,
+	paymentBkt, paymentArchiveBkt, payoutBkt, workerBkt, minuteHistoryBkt,
+	hourHistoryBkt, webhookBkt, roundBkt}
+
+// BoltDB is the bolt implementation of the pool database. All pool data is
+// stored in buckets nested within the pool bucket, values are json encoded.
+type BoltDB struct {
+	*bolt.DB
+}
+
+// Ensure BoltDB implements the Database interface.
+var _ Database = (*BoltDB)(nil)
+
 // openDB creates a connection to the provided bolt storage, the returned
 // connection storage should always be closed after use.
-func openDB(storage string) (*bolt.DB, error) {
+func openDB(storage string) (*BoltDB, error) {
 	db, err := bolt.Open(storage, 0600,
 		&bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
 		return nil, MakeError(ErrDBOpen, "", err)
 	}
-	return db, nil
+	return &BoltDB{db}, nil
 }
 
 // createNestedBucket creates a nested child bucket of the provided parent.
@@ -79,7 +129,7 @@ func createNestedBucket(parent *bolt.Bucket, child []byte) error {
 }
 
 // createBuckets creates all storage buckets of the mining pool.
-func createBuckets(db *bolt.DB) error {
+func createBuckets(db *BoltDB) error {
 	err := db.Update(func(tx *bolt.Tx) error {
 		var err error
 		pbkt := tx.Bucket(poolBkt)
@@ -117,22 +167,60 @@ func createBuckets(db *bolt.DB) error {
 		if err != nil {
 			return err
 		}
-		return createNestedBucket(pbkt, paymentArchiveBkt)
+		err = createNestedBucket(pbkt, paymentArchiveBkt)
+		if err != nil {
+			return err
+		}
+		err = createNestedBucket(pbkt, payoutBkt)
+		if err != nil {
+			return err
+		}
+		err = createNestedBucket(pbkt, workerBkt)
+		if err != nil {
+			return err
+		}
+		err = createNestedBucket(pbkt, minuteHistoryBkt)
+		if err != nil {
+			return err
+		}
+		err = createNestedBucket(pbkt, hourHistoryBkt)
+		if err != nil {
+			return err
+		}
+		err = createNestedBucket(pbkt, webhookBkt)
+		if err != nil {
+			return err
+		}
+		return createNestedBucket(pbkt, roundBkt)
+	})
+	return err
+}
+
+// Backup saves a copy of the db to the provided file name, in the directory
+// of the db file.
+func (db *BoltDB) Backup(fileName string) error {
+	backupPath := filepath.Join(filepath.Dir(db.Path()), fileName)
+	err := db.View(func(tx *bolt.Tx) error {
+		err := tx.CopyFile(backupPath, 0600)
+		return err
 	})
 	return err
 }
 
-// backup saves a copy of the db to file.
-func backup(db *bolt.DB, file string) error {
+// HTTPBackup streams a backup of the db over an http response.
+func (db *BoltDB) HTTPBackup(w http.ResponseWriter) error {
 	err := db.View(func(tx *bolt.Tx) error {
-		err := tx.CopyFile(file, 0600)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="backup.db"`)
+		w.Header().Set("Content-Length", strconv.Itoa(int(tx.Size())))
+		_, err := tx.WriteTo(w)
 		return err
 	})
 	return err
 }
 
-// purge removes all existing data and recreates the db.
-func purge(db *bolt.DB) error {
+// Purge removes all existing data and recreates the db.
+func (db *BoltDB) Purge() error {
 	err := db.Update(func(tx *bolt.Tx) error {
 		pbkt := tx.Bucket(poolBkt)
 		if pbkt == nil {
@@ -163,10 +251,46 @@ func purge(db *bolt.DB) error {
 		if err != nil {
 			return err
 		}
+		err = pbkt.DeleteBucket(payoutBkt)
+		if err != nil {
+			return err
+		}
+		err = pbkt.DeleteBucket(workerBkt)
+		if err != nil {
+			return err
+		}
+		err = pbkt.DeleteBucket(minuteHistoryBkt)
+		if err != nil {
+			return err
+		}
+		err = pbkt.DeleteBucket(hourHistoryBkt)
+		if err != nil {
+			return err
+		}
+		err = pbkt.DeleteBucket(webhookBkt)
+		if err != nil {
+			return err
+		}
+		err = pbkt.DeleteBucket(roundBkt)
+		if err != nil {
+			return err
+		}
 		err = pbkt.Delete(txFeeReserve)
 		if err != nil {
 			return err
 		}
+		err = pbkt.Delete(poolBalance)
+		if err != nil {
+			return err
+		}
+		err = pbkt.Delete(minedRewards)
+		if err != nil {
+			return err
+		}
+		err = pbkt.Delete(roundWork)
+		if err != nil {
+			return err
+		}
 		err = pbkt.Delete(lastPaymentHeight)
 		if err != nil {
 			return err
@@ -192,7 +316,7 @@ func purge(db *bolt.DB) error {
 }
 
 // InitDB handles the creation, upgrading and backup of the pool database.
-func InitDB(dbFile string, isSoloPool bool) (*bolt.DB, error) {
+func InitDB(dbFile string, isSoloPool bool) (*BoltDB, error) {
 	db, err := openDB(dbFile)
 	if err != nil {
 		return nil, MakeError(ErrDBOpen, "unable to open db file", err)
@@ -207,34 +331,23 @@ func InitDB(dbFile string, isSoloPool bool) (*bolt.DB, error) {
 	}
 
 	var switchMode bool
-	err = db.View(func(tx *bolt.Tx) error {
-		pbkt := tx.Bucket(poolBkt)
-		if pbkt == nil {
-			return err
-		}
-		v := pbkt.Get(soloPool)
-		if v == nil {
-			return nil
-		}
-		spMode := binary.LittleEndian.Uint32(v) == 1
-		if isSoloPool != spMode {
-			switchMode = true
-		}
-		return nil
-	})
+	mode, err := db.FetchPoolMode()
 	if err != nil {
-		return nil, err
+		if !IsError(err, ErrValueNotFound) {
+			return nil, err
+		}
+	} else {
+		switchMode = isSoloPool != (mode == 1)
 	}
 
 	if switchMode {
 		// Backup the current database and wipe it.
-		backupPath := filepath.Join(filepath.Dir(db.Path()), backupFile)
-		err := backup(db, backupPath)
+		err := db.Backup(backupFile)
 		if err != nil {
 			return nil, err
 		}
 		log.Infof("Pool mode changed, database backup created.")
-		err = purge(db)
+		err = db.Purge()
 		if err != nil {
 			return nil, err
 		}
@@ -243,30 +356,51 @@ func InitDB(dbFile string, isSoloPool bool) (*bolt.DB, error) {
 	return db, nil
 }
 
+// fetchPoolBucket is a helper function for getting the pool bucket.
+func fetchPoolBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return pbkt, nil
+}
+
+// fetchBucket is a helper function for getting the provided bucket nested
+// within the pool bucket.
+func fetchBucket(tx *bolt.Tx, bucket []byte) (*bolt.Bucket, error) {
+	pbkt, err := fetchPoolBucket(tx)
+	if err != nil {
+		return nil, err
+	}
+	bkt := pbkt.Bucket(bucket)
+	if bkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(bucket))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return bkt, nil
+}
+
 // deleteEntry removes the specified key and its associated value from
 // the provided bucket.
-func deleteEntry(db *bolt.DB, bucket, key []byte) error {
+func deleteEntry(db *BoltDB, bucket, key []byte) error {
 	err := db.Update(func(tx *bolt.Tx) error {
-		pbkt := tx.Bucket(poolBkt)
-		if pbkt == nil {
-			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-			return MakeError(ErrBucketNotFound, desc, nil)
+		b, err := fetchBucket(tx, bucket)
+		if err != nil {
+			return err
 		}
-		b := pbkt.Bucket(bucket)
 		return b.Delete(key)
 	})
 	return err
 }
 
 // emptyBucket deletes all k/v pairs in the provided bucket.
-func emptyBucket(db *bolt.DB, bucket []byte) error {
+func (db *BoltDB) emptyBucket(bucket []byte) error {
 	err := db.Update(func(tx *bolt.Tx) error {
-		pbkt := tx.Bucket(poolBkt)
-		if pbkt == nil {
-			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-			return MakeError(ErrBucketNotFound, desc, nil)
+		b, err := fetchBucket(tx, bucket)
+		if err != nil {
+			return err
 		}
-		b := pbkt.Bucket(bucket)
 		toDelete := [][]byte{}
 		c := b.Cursor()
 		for k, _ := c.First(); k != nil; k, _ = c.Next() {
@@ -282,3 +416,1172 @@ func emptyBucket(db *bolt.DB, bucket []byte) error {
 	})
 	return err
 }
+
+// fetchValue is a helper function for fetching the json encoded value of
+// the provided key in the provided bucket.
+func fetchValue(db *BoltDB, bucket []byte, key []byte, value interface{}) error {
+	return db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, bucket)
+		if err != nil {
+			return err
+		}
+		v := bkt.Get(key)
+		if v == nil {
+			desc := fmt.Sprintf("no value found for key %s in bucket %s",
+				string(key), string(bucket))
+			return MakeError(ErrValueNotFound, desc, nil)
+		}
+		return json.Unmarshal(v, value)
+	})
+}
+
+// putValue is a helper function for persisting the json encoding of the
+// provided value with the provided key in the provided bucket.
+func putValue(bkt *bolt.Bucket, key []byte, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bkt.Put(key, b)
+}
+
+// persistValue is a helper function for persisting the json encoding of the
+// provided value with the provided key in the provided bucket.
+func persistValue(db *BoltDB, bucket []byte, key []byte, value interface{}) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, bucket)
+		if err != nil {
+			return err
+		}
+		return putValue(bkt, key, value)
+	})
+}
+
+// FetchPoolMode fetches the persisted pool mode.
+func (db *BoltDB) FetchPoolMode() (uint32, error) {
+	var mode uint32
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(soloPool)
+		if v == nil {
+			return MakeError(ErrValueNotFound, "pool mode not set", nil)
+		}
+		mode = binary.LittleEndian.Uint32(v)
+		return nil
+	})
+	return mode, err
+}
+
+// PersistPoolMode saves the provided pool mode.
+func (db *BoltDB) PersistPoolMode(mode uint32) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, mode)
+		return pbkt.Put(soloPool, b)
+	})
+}
+
+// FetchCSRFSecret fetches the persisted CSRF secret.
+func (db *BoltDB) FetchCSRFSecret() ([]byte, error) {
+	var secret []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(csrfSecret)
+		if v == nil {
+			return MakeError(ErrValueNotFound, "csrf secret not set", nil)
+		}
+		secret = make([]byte, len(v))
+		copy(secret, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// PersistCSRFSecret saves the provided CSRF secret.
+func (db *BoltDB) PersistCSRFSecret(secret []byte) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		return pbkt.Put(csrfSecret, secret)
+	})
+}
+
+// bigEndianBytesToNano returns nanosecond time from the provided
+// big endian bytes.
+func bigEndianBytesToNano(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+// FetchPaymentState fetches the persisted state of the payment manager.
+func (db *BoltDB) FetchPaymentState() (*PaymentState, error) {
+	state := &PaymentState{
+		MinedRewards: make(map[uint32]dcrutil.Amount),
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(lastPaymentHeight)
+		if v != nil {
+			state.LastPaymentHeight = binary.LittleEndian.Uint32(v)
+		}
+		v = pbkt.Get(lastPaymentPaidOn)
+		if v != nil {
+			state.LastPaymentPaidOn = bigEndianBytesToNano(v)
+		}
+		v = pbkt.Get(lastPaymentCreatedOn)
+		if v != nil {
+			state.LastPaymentCreatedOn = bigEndianBytesToNano(v)
+		}
+		v = pbkt.Get(txFeeReserve)
+		if v != nil {
+			state.TxFeeReserve = dcrutil.Amount(binary.LittleEndian.Uint32(v))
+		}
+		v = pbkt.Get(poolBalance)
+		if v != nil {
+			state.PoolBalance = dcrutil.Amount(binary.LittleEndian.Uint64(v))
+		}
+		v = pbkt.Get(minedRewards)
+		if v != nil {
+			return json.Unmarshal(v, &state.MinedRewards)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if state.MinedRewards == nil {
+		state.MinedRewards = make(map[uint32]dcrutil.Amount)
+	}
+	return state, nil
+}
+
+// putPaymentState is a helper function for persisting the provided payment
+// manager state.
+func putPaymentState(tx *bolt.Tx, state *PaymentState) error {
+	pbkt, err := fetchPoolBucket(tx)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, state.LastPaymentHeight)
+	err = pbkt.Put(lastPaymentHeight, b)
+	if err != nil {
+		return err
+	}
+	err = pbkt.Put(lastPaymentPaidOn,
+		nanoToBigEndianBytes(int64(state.LastPaymentPaidOn)))
+	if err != nil {
+		return err
+	}
+	err = pbkt.Put(lastPaymentCreatedOn,
+		nanoToBigEndianBytes(int64(state.LastPaymentCreatedOn)))
+	if err != nil {
+		return err
+	}
+	b = make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(state.TxFeeReserve))
+	err = pbkt.Put(txFeeReserve, b)
+	if err != nil {
+		return err
+	}
+	b = make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(state.PoolBalance))
+	err = pbkt.Put(poolBalance, b)
+	if err != nil {
+		return err
+	}
+	rewards, err := json.Marshal(state.MinedRewards)
+	if err != nil {
+		return err
+	}
+	return pbkt.Put(minedRewards, rewards)
+}
+
+// PersistPaymentState saves the provided payment manager state.
+func (db *BoltDB) PersistPaymentState(state *PaymentState) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return putPaymentState(tx, state)
+	})
+}
+
+// FetchRoundWork fetches the work contributed to the pool since the last
+// block found by the pool.
+func (db *BoltDB) FetchRoundWork() (*big.Rat, error) {
+	work := new(big.Rat)
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(roundWork)
+		if v == nil {
+			return nil
+		}
+		_, ok := work.SetString(string(v))
+		if !ok {
+			desc := fmt.Sprintf("invalid round work %s", string(v))
+			return MakeError(ErrParse, desc, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return work, nil
+}
+
+// PersistRoundWork saves the provided round work.
+func (db *BoltDB) PersistRoundWork(work *big.Rat) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		return pbkt.Put(roundWork, []byte(work.String()))
+	})
+}
+
+// FetchAccount fetches the account referenced by the provided id.
+func (db *BoltDB) FetchAccount(id string) (*Account, error) {
+	var account Account
+	err := fetchValue(db, accountBkt, []byte(id), &account)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			desc := fmt.Sprintf("no account found for id %s", id)
+			return nil, MakeError(ErrValueNotFound, desc, nil)
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// PersistAccount saves the provided account.
+func (db *BoltDB) PersistAccount(account *Account) error {
+	return persistValue(db, accountBkt, []byte(account.UUID), account)
+}
+
+// DeleteAccount removes the account referenced by the provided id.
+func (db *BoltDB) DeleteAccount(id string) error {
+	return deleteEntry(db, accountBkt, []byte(id))
+}
+
+// FetchJob fetches the job referenced by the provided id.
+func (db *BoltDB) FetchJob(id string) (*Job, error) {
+	var job Job
+	err := fetchValue(db, jobBkt, []byte(id), &job)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			desc := fmt.Sprintf("no value found for job id %s", id)
+			return nil, MakeError(ErrValueNotFound, desc, nil)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PersistJob saves the provided job.
+func (db *BoltDB) PersistJob(job *Job) error {
+	return persistValue(db, jobBkt, []byte(job.UUID), job)
+}
+
+// DeleteJob removes the job referenced by the provided id.
+func (db *BoltDB) DeleteJob(id string) error {
+	return deleteEntry(db, jobBkt, []byte(id))
+}
+
+// pruneBucketByHeight removes all entries of the provided bucket with keys
+// prefixed by heights less than the provided height.
+func pruneBucketByHeight(bkt *bolt.Bucket, height uint32) error {
+	heightBE := heightToBigEndianBytes(height)
+	toDelete := [][]byte{}
+	c := bkt.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		height, err := hex.DecodeString(string(k[:8]))
+		if err != nil {
+			return err
+		}
+
+		if bytes.Compare(height, heightBE) < 0 {
+			toDelete = append(toDelete, k)
+		}
+	}
+
+	for _, entry := range toDelete {
+		err := bkt.Delete(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PruneJobs removes all jobs with heights less than the provided height.
+func (db *BoltDB) PruneJobs(height uint32) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, jobBkt)
+		if err != nil {
+			return err
+		}
+		return pruneBucketByHeight(bkt, height)
+	})
+}
+
+// PersistShare saves the provided share.
+func (db *BoltDB) PersistShare(share *Share) error {
+	return persistValue(db, shareBkt, nanoToBigEndianBytes(share.CreatedOn),
+		share)
+}
+
+// FetchShares fetches all shares created within the provided inclusive
+// bounds.
+func (db *BoltDB) FetchShares(minNano int64, maxNano int64) ([]*Share, error) {
+	minBytes := nanoToBigEndianBytes(minNano)
+	maxBytes := nanoToBigEndianBytes(maxNano)
+	shares := make([]*Share, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, shareBkt)
+		if err != nil {
+			return err
+		}
+		c := bkt.Cursor()
+		for k, v := c.Seek(minBytes); k != nil && bytes.Compare(k, maxBytes) <= 0; k, v = c.Next() {
+			var share Share
+			err := json.Unmarshal(v, &share)
+			if err != nil {
+				return err
+			}
+			shares = append(shares, &share)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// FetchLastShares fetches shares from the most recent until the provided
+// function reports the window of shares is complete.
+func (db *BoltDB) FetchLastShares(complete func(*Share) bool) ([]*Share, error) {
+	shares := make([]*Share, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, shareBkt)
+		if err != nil {
+			return err
+		}
+		c := bkt.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var share Share
+			err := json.Unmarshal(v, &share)
+			if err != nil {
+				return err
+			}
+			shares = append(shares, &share)
+			if complete(&share) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// fetchSharesBefore fetches all shares created before the provided minimum.
+func fetchSharesBefore(tx *bolt.Tx, minNano int64) ([]*Share, error) {
+	minBytes := nanoToBigEndianBytes(minNano)
+	bkt, err := fetchBucket(tx, shareBkt)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]*Share, 0)
+	cursor := bkt.Cursor()
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		if bytes.Compare(minBytes, k) <= 0 {
+			break
+		}
+		var share Share
+		err := json.Unmarshal(v, &share)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+	return shares, nil
+}
+
+// pruneShares removes all shares created before the provided minimum.
+func pruneShares(tx *bolt.Tx, minNano int64) error {
+	minBytes := nanoToBigEndianBytes(minNano)
+	bkt, err := fetchBucket(tx, shareBkt)
+	if err != nil {
+		return err
+	}
+	toDelete := [][]byte{}
+	cursor := bkt.Cursor()
+	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+		if bytes.Compare(minBytes, k) > 0 {
+			toDelete = append(toDelete, k)
+		}
+	}
+	for _, entry := range toDelete {
+		err := bkt.Delete(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneShares removes all shares created before the provided minimum and
+// saves the provided payment manager state.
+func (db *BoltDB) PruneShares(minNano int64, state *PaymentState) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		err := putPaymentState(tx, state)
+		if err != nil {
+			return err
+		}
+		return pruneShares(tx, minNano)
+	})
+}
+
+// fetchRound fetches the archived round at the provided height.
+func fetchRound(tx *bolt.Tx, height uint32) (*Round, error) {
+	bkt, err := fetchBucket(tx, roundBkt)
+	if err != nil {
+		return nil, err
+	}
+	v := bkt.Get(heightToBigEndianBytes(height))
+	if v == nil {
+		desc := fmt.Sprintf("no round found for height %d", height)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	var round Round
+	err = json.Unmarshal(v, &round)
+	if err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+// FetchRound fetches the archived round at the provided height.
+func (db *BoltDB) FetchRound(height uint32) (*Round, error) {
+	var round *Round
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		round, err = fetchRound(tx, height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// ArchiveRound archives all shares created before the provided minimum as
+// the shares of the provided round and removes them.
+func (db *BoltDB) ArchiveRound(round *Round, minNano int64, state *PaymentState) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		err := putPaymentState(tx, state)
+		if err != nil {
+			return err
+		}
+		round.Shares, err = fetchSharesBefore(tx, minNano)
+		if err != nil {
+			return err
+		}
+		existing, err := fetchRound(tx, round.Height)
+		if err != nil && !IsError(err, ErrValueNotFound) {
+			return err
+		}
+		if existing != nil {
+			round.Shares = append(existing.Shares, round.Shares...)
+			round.LastPaymentCreatedOn = existing.LastPaymentCreatedOn
+		}
+		bkt, err := fetchBucket(tx, roundBkt)
+		if err != nil {
+			return err
+		}
+		err = putValue(bkt, heightToBigEndianBytes(round.Height), round)
+		if err != nil {
+			return err
+		}
+		return pruneShares(tx, minNano)
+	})
+}
+
+// RestoreRound restores the shares of the archived round at the provided
+// height and removes the round.
+func (db *BoltDB) RestoreRound(height uint32) (*Round, error) {
+	var round *Round
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		round, err = fetchRound(tx, height)
+		if err != nil {
+			return err
+		}
+		sbkt, err := fetchBucket(tx, shareBkt)
+		if err != nil {
+			return err
+		}
+		for _, share := range round.Shares {
+			err := putValue(sbkt, nanoToBigEndianBytes(share.CreatedOn), share)
+			if err != nil {
+				return err
+			}
+		}
+		bkt, err := fetchBucket(tx, roundBkt)
+		if err != nil {
+			return err
+		}
+		err = bkt.Delete(heightToBigEndianBytes(height))
+		if err != nil {
+			return err
+		}
+
+		// Restored shares are credited with the next round.
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(lastPaymentCreatedOn)
+		if v != nil && bigEndianBytesToNano(v) > round.LastPaymentCreatedOn {
+			return pbkt.Put(lastPaymentCreatedOn,
+				nanoToBigEndianBytes(int64(round.LastPaymentCreatedOn)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// PruneRounds removes all archived rounds with heights less than the
+// provided height.
+func (db *BoltDB) PruneRounds(height uint32) error {
+	minBytes := heightToBigEndianBytes(height)
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, roundBkt)
+		if err != nil {
+			return err
+		}
+		toDelete := [][]byte{}
+		cursor := bkt.Cursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			if bytes.Compare(k, minBytes) >= 0 {
+				break
+			}
+			toDelete = append(toDelete, k)
+		}
+		for _, entry := range toDelete {
+			err := bkt.Delete(entry)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchAcceptedWork fetches the accepted work referenced by the provided id.
+func (db *BoltDB) FetchAcceptedWork(id string) (*AcceptedWork, error) {
+	var work AcceptedWork
+	err := fetchValue(db, workBkt, []byte(id), &work)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			desc := fmt.Sprintf("no value for key %s", id)
+			return nil, MakeError(ErrValueNotFound, desc, nil)
+		}
+		return nil, err
+	}
+	return &work, nil
+}
+
+// PersistAcceptedWork saves the provided work.
+func (db *BoltDB) PersistAcceptedWork(work *AcceptedWork) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		// Do not persist already existing accepted work.
+		id := []byte(work.UUID)
+		v := bkt.Get(id)
+		if v != nil {
+			desc := fmt.Sprintf("work %s already exists", work.UUID)
+			return MakeError(ErrWorkExists, desc, nil)
+		}
+		return putValue(bkt, id, work)
+	})
+}
+
+// UpdateAcceptedWork saves modifications to the provided work.
+func (db *BoltDB) UpdateAcceptedWork(work *AcceptedWork) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		// Assert the work provided exists before updating.
+		id := []byte(work.UUID)
+		v := bkt.Get(id)
+		if v == nil {
+			desc := fmt.Sprintf("work %s not found", work.UUID)
+			return MakeError(ErrWorkNotFound, desc, nil)
+		}
+		return putValue(bkt, id, work)
+	})
+}
+
+// AcceptWork transitions the provided work to accepted and saves it.
+func (db *BoltDB) AcceptWork(work *AcceptedWork) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		id := []byte(work.UUID)
+		v := bkt.Get(id)
+		if v != nil {
+			var existing AcceptedWork
+			err := json.Unmarshal(v, &existing)
+			if err != nil {
+				return err
+			}
+			err = work.acceptOver(&existing)
+			if err != nil {
+				return err
+			}
+		}
+
+		work.setStatus(WorkAccepted)
+		return putValue(bkt, id, work)
+	})
+}
+
+// DeleteAcceptedWork removes the accepted work referenced by the provided
+// id.
+func (db *BoltDB) DeleteAcceptedWork(id string) error {
+	return deleteEntry(db, workBkt, []byte(id))
+}
+
+// ListAcceptedWork fetches all accepted work.
+func (db *BoltDB) ListAcceptedWork() ([]*AcceptedWork, error) {
+	minedWork := make([]*AcceptedWork, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		cursor := bkt.Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var work AcceptedWork
+			err := json.Unmarshal(v, &work)
+			if err != nil {
+				return err
+			}
+			minedWork = append(minedWork, &work)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return minedWork, nil
+}
+
+// PruneAcceptedWork removes all submitted and accepted work not confirmed
+// as mined work with heights less than the provided height.
+func (db *BoltDB) PruneAcceptedWork(height uint32) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		toDelete := [][]byte{}
+		cursor := bkt.Cursor()
+		workHeightB := make([]byte, 8)
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			_, err := hex.Decode(workHeightB, k[:8])
+			if err != nil {
+				return err
+			}
+
+			workHeight := bigEndianBytesToHeight(workHeightB)
+			if workHeight < height {
+				var work AcceptedWork
+				err := json.Unmarshal(v, &work)
+				if err != nil {
+					return err
+				}
+				// Only prune work never confirmed as mined work,
+				// orphaned work is kept for auditing purposes.
+				if work.isPrunable() {
+					toDelete = append(toDelete, k)
+				}
+			}
+		}
+
+		for _, entry := range toDelete {
+			err := bkt.Delete(entry)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MatureAcceptedWork transitions all confirmed mined work with heights less
+// than or equal to the provided height to matured.
+func (db *BoltDB) MatureAcceptedWork(height uint32) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+
+		matured := make(map[string]*AcceptedWork)
+		cursor := bkt.Cursor()
+		workHeightB := make([]byte, 8)
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			_, err := hex.Decode(workHeightB, k[:8])
+			if err != nil {
+				return err
+			}
+
+			workHeight := bigEndianBytesToHeight(workHeightB)
+			if workHeight > height {
+				break
+			}
+
+			var work AcceptedWork
+			err = json.Unmarshal(v, &work)
+			if err != nil {
+				return err
+			}
+			if work.Status != WorkConfirmed {
+				continue
+			}
+			work.setStatus(WorkMatured)
+			matured[string(k)] = &work
+		}
+
+		for k, work := range matured {
+			err := putValue(bkt, []byte(k), work)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPayment fetches the pending payment referenced by the provided id.
+func (db *BoltDB) FetchPayment(id string) (*Payment, error) {
+	var payment Payment
+	err := fetchValue(db, paymentBkt, []byte(id), &payment)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			desc := fmt.Sprintf("no payment found for id %s", id)
+			return nil, MakeError(ErrValueNotFound, desc, nil)
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// PersistPayment saves the provided pending payment.
+func (db *BoltDB) PersistPayment(payment *Payment) error {
+	return persistValue(db, paymentBkt, payment.id(), payment)
+}
+
+// DeletePayment removes the pending payment referenced by the provided id.
+func (db *BoltDB) DeletePayment(id string) error {
+	return deleteEntry(db, paymentBkt, []byte(id))
+}
+
+// FetchPayments fetches all pending payments.
+func (db *BoltDB) FetchPayments() ([]*Payment, error) {
+	payments := make([]*Payment, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, paymentBkt)
+		if err != nil {
+			return err
+		}
+		cursor := bkt.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var payment Payment
+			err := json.Unmarshal(v, &payment)
+			if err != nil {
+				return err
+			}
+			payments = append(payments, &payment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// ArchivePayments removes the provided payments from the pending payments
+// and archives them.
+func (db *BoltDB) ArchivePayments(payments []*Payment) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchBucket(tx, paymentBkt)
+		if err != nil {
+			return err
+		}
+		abkt, err := fetchBucket(tx, paymentArchiveBkt)
+		if err != nil {
+			return err
+		}
+		for _, pmt := range payments {
+			err := pbkt.Delete(pmt.id())
+			if err != nil {
+				return err
+			}
+			pmt.CreatedOn = time.Now().UnixNano()
+			err = putValue(abkt, pmt.id(), pmt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchArchivedPayments fetches all archived payments.
+func (db *BoltDB) FetchArchivedPayments() ([]*Payment, error) {
+	pmts := make([]*Payment, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		abkt, err := fetchBucket(tx, paymentArchiveBkt)
+		if err != nil {
+			return err
+		}
+		c := abkt.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var payment Payment
+			err := json.Unmarshal(v, &payment)
+			if err != nil {
+				return err
+			}
+			pmts = append(pmts, &payment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pmts, nil
+}
+
+// PersistPayoutAttempt saves the provided payout attempt.
+func (db *BoltDB) PersistPayoutAttempt(attempt *PayoutAttempt) error {
+	return persistValue(db, payoutBkt, []byte(attempt.UUID), attempt)
+}
+
+// FetchPayoutAttempts fetches all payout attempts.
+func (db *BoltDB) FetchPayoutAttempts() ([]*PayoutAttempt, error) {
+	attempts := make([]*PayoutAttempt, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, payoutBkt)
+		if err != nil {
+			return err
+		}
+		c := bkt.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var attempt PayoutAttempt
+			err := json.Unmarshal(v, &attempt)
+			if err != nil {
+				return err
+			}
+			attempts = append(attempts, &attempt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// fetchHistoryBucket is a helper function for getting the history bucket of
+// the provided resolution.
+func fetchHistoryBucket(tx *bolt.Tx, resolution string) (*bolt.Bucket, error) {
+	key, err := historyBucket(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return fetchBucket(tx, key)
+}
+
+// PersistHistorySamples saves the provided samples at the provided
+// resolution.
+func (db *BoltDB) PersistHistorySamples(resolution string, samples []*HistorySample) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchHistoryBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+		for _, sample := range samples {
+			err := putValue(bkt, sample.historyKey(), sample)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchHistory fetches the samples of the provided scope and id at the
+// provided resolution taken at or after the provided time.
+func (db *BoltDB) FetchHistory(resolution string, scope string, id string, since int64) ([]*HistorySample, error) {
+	samples := make([]*HistorySample, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchHistoryBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+		prefix := historySeriesKey(scope, id)
+		c := bkt.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if historySampleTime(k) < since {
+				continue
+			}
+			var sample HistorySample
+			err := json.Unmarshal(v, &sample)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, &sample)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// FetchHistoryRange fetches the samples of all series at the provided
+// resolution taken within the provided start inclusive and end exclusive
+// times.
+func (db *BoltDB) FetchHistoryRange(resolution string, start int64, end int64) ([]*HistorySample, error) {
+	samples := make([]*HistorySample, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchHistoryBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			t := historySampleTime(k)
+			if t < start || t >= end {
+				return nil
+			}
+			var sample HistorySample
+			err := json.Unmarshal(v, &sample)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, &sample)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// PruneHistory removes all samples at the provided resolution taken before
+// the provided time.
+func (db *BoltDB) PruneHistory(resolution string, before int64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchHistoryBucket(tx, resolution)
+		if err != nil {
+			return err
+		}
+		toDelete := make([][]byte, 0)
+		err = bkt.ForEach(func(k, _ []byte) error {
+			if historySampleTime(k) < before {
+				toDelete = append(toDelete, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range toDelete {
+			err := bkt.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchWorkers fetches all workers.
+func (db *BoltDB) FetchWorkers() ([]*Worker, error) {
+	workers := make([]*Worker, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workerBkt)
+		if err != nil {
+			return err
+		}
+		return bkt.ForEach(func(_, v []byte) error {
+			var worker Worker
+			err := json.Unmarshal(v, &worker)
+			if err != nil {
+				return err
+			}
+			workers = append(workers, &worker)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workers, nil
+}
+
+// PersistWorkers saves the provided workers.
+func (db *BoltDB) PersistWorkers(workers []*Worker) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workerBkt)
+		if err != nil {
+			return err
+		}
+		for _, worker := range workers {
+			id := WorkerID(worker.AccountID, worker.Name)
+			err := putValue(bkt, []byte(id), worker)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteWorkers removes the workers referenced by the provided ids.
+func (db *BoltDB) DeleteWorkers(ids []string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workerBkt)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err := bkt.Delete([]byte(id))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchWebhook fetches the webhook referenced by the provided id.
+func (db *BoltDB) FetchWebhook(id string) (*Webhook, error) {
+	var webhook Webhook
+	err := fetchValue(db, webhookBkt, []byte(id), &webhook)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			desc := fmt.Sprintf("no webhook found for id %s", id)
+			return nil, MakeError(ErrValueNotFound, desc, nil)
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// filterAccountWebhooks fetches all webhooks of the provided bucket
+// registered by the provided account.
+func filterAccountWebhooks(bkt *bolt.Bucket, accountID string) ([]*Webhook, error) {
+	webhooks := make([]*Webhook, 0)
+	err := bkt.ForEach(func(_, v []byte) error {
+		var webhook Webhook
+		err := json.Unmarshal(v, &webhook)
+		if err != nil {
+			return err
+		}
+		if webhook.AccountID == accountID {
+			webhooks = append(webhooks, &webhook)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// PersistWebhook saves the provided webhook.
+func (db *BoltDB) PersistWebhook(webhook *Webhook) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, webhookBkt)
+		if err != nil {
+			return err
+		}
+		registered, err := filterAccountWebhooks(bkt, webhook.AccountID)
+		if err != nil {
+			return err
+		}
+		err = webhook.checkLimit(registered)
+		if err != nil {
+			return err
+		}
+		return putValue(bkt, []byte(webhook.UUID), webhook)
+	})
+}
+
+// DeleteWebhook removes the webhook referenced by the provided id.
+func (db *BoltDB) DeleteWebhook(id string) error {
+	return deleteEntry(db, webhookBkt, []byte(id))
+}
+
+// FetchAccountWebhooks fetches all webhooks registered by the provided
+// account.
+func (db *BoltDB) FetchAccountWebhooks(accountID string) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, webhookBkt)
+		if err != nil {
+			return err
+		}
+		webhooks, err = filterAccountWebhooks(bkt, accountID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
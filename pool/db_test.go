@@ -11,7 +11,7 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
-func initBlankDB(dbFile string) (*bolt.DB, error) {
+func initBlankDB(dbFile string) (*BoltDB, error) {
 	os.Remove(dbFile)
 	db, err := openDB(dbFile)
 	if err != nil {
@@ -38,80 +38,28 @@ func testFetchBucketHelpers(t *testing.T) {
 
 	expectedNotFoundErr := fmt.Errorf("expected main bucket not found error")
 
-	pmtMgr := &PaymentMgr{}
-
-	// Ensure payment manager helpers return an error when the
+	// Ensure the payment state helpers return an error when the
 	// pool bucket cannot be found.
-	err = db.View(func(tx *bolt.Tx) error {
-		err = pmtMgr.loadLastPaymentCreatedOn(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.loadLastPaymentHeight(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.loadLastPaymentPaidOn(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.loadTxFeeReserve(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.persistLastPaymentCreatedOn(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.persistLastPaymentHeight(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.persistLastPaymentPaidOn(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		err = pmtMgr.persistTxFeeReserve(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-
-		return nil
-	})
-	if err != nil {
-		t.Fatal(err)
+	_, err = db.FetchPaymentState()
+	if err == nil {
+		t.Fatal(expectedNotFoundErr)
+	}
+	err = db.PersistPaymentState(&PaymentState{})
+	if err == nil {
+		t.Fatal(expectedNotFoundErr)
 	}
 
+	nestedBkts := [][]byte{accountBkt, workBkt,
+		jobBkt, paymentBkt, paymentArchiveBkt, shareBkt, roundBkt}
+
 	// Ensure fetch bucket helpers return an error when the
 	// pool bucket cannot be found.
 	err = db.View(func(tx *bolt.Tx) error {
-		_, err := fetchWorkBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchAccountBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchWorkBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchJobBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchPaymentBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchPaymentArchiveBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
-		}
-		_, err = fetchShareBucket(tx)
-		if err == nil {
-			return expectedNotFoundErr
+		for _, bkt := range nestedBkts {
+			_, err := fetchBucket(tx, bkt)
+			if err == nil {
+				return expectedNotFoundErr
+			}
 		}
 		return nil
 	})
@@ -129,7 +77,7 @@ func testFetchBucketHelpers(t *testing.T) {
 		t.Fatal(expectedNotFoundErr)
 	}
 
-	err = purge(db)
+	err = db.Purge()
 	if err == nil {
 		t.Fatal(expectedNotFoundErr)
 	}
@@ -163,41 +111,17 @@ func testFetchBucketHelpers(t *testing.T) {
 	// Ensure fetch bucket helpers return an error if the
 	// required nested bucket cannot be found.
 	err = db.View(func(tx *bolt.Tx) error {
-		_, err := fetchWorkBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchAccountBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchWorkBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchJobBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchPaymentBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchPaymentArchiveBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
-		}
-		_, err = fetchShareBucket(tx)
-		if err == nil {
-			return expectedNestedNotFoundErr
+		for _, bkt := range nestedBkts {
+			_, err := fetchBucket(tx, bkt)
+			if err == nil {
+				return expectedNestedNotFoundErr
+			}
 		}
-
 		return nil
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
 }
 
 func testInitDB(t *testing.T) {
@@ -246,6 +170,30 @@ func testInitDB(t *testing.T) {
 		if err == nil {
 			return fmt.Errorf("expected paymentArchiveBkt to exist already")
 		}
+		_, err = pbkt.CreateBucket(payoutBkt)
+		if err == nil {
+			return fmt.Errorf("expected payoutBkt to exist already")
+		}
+		_, err = pbkt.CreateBucket(workerBkt)
+		if err == nil {
+			return fmt.Errorf("expected workerBkt to exist already")
+		}
+		_, err = pbkt.CreateBucket(minuteHistoryBkt)
+		if err == nil {
+			return fmt.Errorf("expected minuteHistoryBkt to exist already")
+		}
+		_, err = pbkt.CreateBucket(hourHistoryBkt)
+		if err == nil {
+			return fmt.Errorf("expected hourHistoryBkt to exist already")
+		}
+		_, err = pbkt.CreateBucket(webhookBkt)
+		if err == nil {
+			return fmt.Errorf("expected webhookBkt to exist already")
+		}
+		_, err = pbkt.CreateBucket(roundBkt)
+		if err == nil {
+			return fmt.Errorf("expected roundBkt to exist already")
+		}
 		return nil
 	})
 	if err != nil {
@@ -289,7 +237,7 @@ func testInitDB(t *testing.T) {
 	}
 }
 
-func testDatabase(t *testing.T, db *bolt.DB) {
+func testDatabase(t *testing.T, db *BoltDB) {
 	// Persist some accounts.
 	accountA, err := persistAccount(db, "Ssj6Sd54j11JM8qpenCwfwnKD73dsjm68ru",
 		chaincfg.SimNetParams())
@@ -316,7 +264,7 @@ func testDatabase(t *testing.T, db *bolt.DB) {
 	}
 
 	// purge the db.
-	err = purge(db)
+	err = db.Purge()
 	if err != nil {
 		t.Fatalf("backup error: %v", err)
 	}
@@ -337,7 +285,7 @@ func testDatabase(t *testing.T, db *bolt.DB) {
 		os.Remove(backupFile)
 	}()
 
-	err = backup(db, backupFile)
+	err = db.Backup(backupFile)
 	if err != nil {
 		t.Fatalf("backup error: %v", err)
 	}
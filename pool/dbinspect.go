@@ -0,0 +1,304 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// OpenBoltDB opens an existing bolt pool database file for offline
+// inspection and repair, read-only if set. Unlike InitDB the database is
+// neither created, upgraded nor purged. An ErrDBUpgrade error is returned
+// for databases newer than the latest version understood by the program.
+func OpenBoltDB(dbFile string, readOnly bool) (*BoltDB, error) {
+	if _, err := os.Stat(dbFile); err != nil {
+		desc := fmt.Sprintf("unable to access db file %s", dbFile)
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+	bdb, err := bolt.Open(dbFile, 0600,
+		&bolt.Options{Timeout: 1 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, MakeError(ErrDBOpen, "unable to open db file", err)
+	}
+	db := &BoltDB{bdb}
+	version, err := db.Version()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version > DBVersion {
+		db.Close()
+		desc := fmt.Sprintf("db version %d is newer than the supported "+
+			"version %d", version, DBVersion)
+		return nil, MakeError(ErrDBUpgrade, desc, nil)
+	}
+	return db, nil
+}
+
+// Version returns the version of the database.
+func (db *BoltDB) Version() (uint32, error) {
+	var version uint32
+	err := db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(poolBkt) == nil {
+			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+			return MakeError(ErrBucketNotFound, desc, nil)
+		}
+		var err error
+		version, err = fetchDBVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+// ListAccounts fetches all accounts. List is ordered by account id.
+func (db *BoltDB) ListAccounts() ([]*Account, error) {
+	accounts := make([]*Account, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, accountBkt)
+		if err != nil {
+			return err
+		}
+		return bkt.ForEach(func(_, v []byte) error {
+			var account Account
+			err := json.Unmarshal(v, &account)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, &account)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ListJobs fetches all jobs. List is ordered, oldest comes first.
+func (db *BoltDB) ListJobs() ([]*Job, error) {
+	jobs := make([]*Job, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, jobBkt)
+		if err != nil {
+			return err
+		}
+		return bkt.ForEach(func(_, v []byte) error {
+			var job Job
+			err := json.Unmarshal(v, &job)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, &job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeleteShare removes the share created at the provided time. An
+// ErrValueNotFound error is returned if no such share exists.
+func (db *BoltDB) DeleteShare(createdOn int64) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, shareBkt)
+		if err != nil {
+			return err
+		}
+		key := nanoToBigEndianBytes(createdOn)
+		if bkt.Get(key) == nil {
+			desc := fmt.Sprintf("no share found created on %d", createdOn)
+			return MakeError(ErrValueNotFound, desc, nil)
+		}
+		return bkt.Delete(key)
+	})
+}
+
+// checkEntries calls the provided check function for each entry of the
+// provided bucket nested within the pool bucket, entries failing the check
+// are reported as issues. Missing buckets are skipped.
+func checkEntries(pbkt *bolt.Bucket, bucket []byte, report func(string, ...interface{}), check func(k, v []byte) error) error {
+	bkt := pbkt.Bucket(bucket)
+	if bkt == nil {
+		return nil
+	}
+	return bkt.ForEach(func(k, v []byte) error {
+		err := check(k, v)
+		if err != nil {
+			report("%s entry %x: %v", string(bucket), k, err)
+		}
+		return nil
+	})
+}
+
+// CheckConsistency checks the database for missing buckets, undecodable
+// entries, entries stored under keys not matching their values and entries
+// referencing unknown accounts. The issues found are returned.
+func (db *BoltDB) CheckConsistency() ([]string, error) {
+	issues := make([]string, 0)
+	report := func(format string, args ...interface{}) {
+		issues = append(issues, fmt.Sprintf(format, args...))
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPoolBucket(tx)
+		if err != nil {
+			return err
+		}
+		version, err := fetchDBVersion(tx)
+		if err != nil {
+			report("db version not set")
+		} else if version != DBVersion {
+			report("db version %d does not match the latest version %d",
+				version, DBVersion)
+		}
+		for _, bucket := range poolBuckets {
+			if pbkt.Bucket(bucket) == nil {
+				report("bucket %s not found", string(bucket))
+			}
+		}
+
+		// Check nothing further when the account bucket is missing since
+		// all account references would be reported as unknown.
+		if pbkt.Bucket(accountBkt) == nil {
+			return nil
+		}
+		accounts := make(map[string]struct{})
+		err = pbkt.Bucket(accountBkt).ForEach(func(k, v []byte) error {
+			var account Account
+			err := json.Unmarshal(v, &account)
+			if err != nil {
+				report("%s entry %x: %v", string(accountBkt), k, err)
+				return nil
+			}
+			if account.UUID != string(k) {
+				report("account %s stored under key %s", account.UUID,
+					string(k))
+			}
+			accounts[account.UUID] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		knownAccount := func(id string) bool {
+			_, ok := accounts[id]
+			return ok
+		}
+
+		err = checkEntries(pbkt, shareBkt, report, func(k, v []byte) error {
+			var share Share
+			err := json.Unmarshal(v, &share)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(k, nanoToBigEndianBytes(share.CreatedOn)) {
+				return fmt.Errorf("share created on %d stored under "+
+					"a different time", share.CreatedOn)
+			}
+			if !knownAccount(share.Account) {
+				return fmt.Errorf("share references unknown account %s",
+					share.Account)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = checkEntries(pbkt, jobBkt, report, func(k, v []byte) error {
+			var job Job
+			err := json.Unmarshal(v, &job)
+			if err != nil {
+				return err
+			}
+			if job.UUID != string(k) {
+				return fmt.Errorf("job %s stored under a different id",
+					job.UUID)
+			}
+			height, err := jobHeight(job.UUID)
+			if err != nil {
+				return err
+			}
+			if height != job.Height {
+				return fmt.Errorf("job %s at height %d encodes height %d",
+					job.UUID, job.Height, height)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = checkEntries(pbkt, workBkt, report, func(k, v []byte) error {
+			var work AcceptedWork
+			err := json.Unmarshal(v, &work)
+			if err != nil {
+				return err
+			}
+			id := AcceptedWorkID(work.BlockHash, work.Height)
+			if !bytes.Equal(k, id) || work.UUID != string(id) {
+				return fmt.Errorf("work %s does not match its block "+
+					"hash and height", work.UUID)
+			}
+			switch work.Status {
+			case WorkSubmitted, WorkAccepted, WorkConfirmed,
+				WorkOrphaned, WorkMatured:
+			default:
+				return fmt.Errorf("work %s has unknown status %q",
+					work.UUID, work.Status)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		checkPayment := func(k, v []byte) error {
+			var payment Payment
+			err := json.Unmarshal(v, &payment)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(k, payment.id()) {
+				return fmt.Errorf("payment %s stored under a different id",
+					string(payment.id()))
+			}
+			if payment.Account != poolFeesK &&
+				!knownAccount(payment.Account) {
+				return fmt.Errorf("payment references unknown account %s",
+					payment.Account)
+			}
+			return nil
+		}
+		err = checkEntries(pbkt, paymentBkt, report, checkPayment)
+		if err != nil {
+			return err
+		}
+		err = checkEntries(pbkt, paymentArchiveBkt, report, checkPayment)
+		if err != nil {
+			return err
+		}
+		return checkEntries(pbkt, roundBkt, report, func(k, v []byte) error {
+			var round Round
+			err := json.Unmarshal(v, &round)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(k, heightToBigEndianBytes(round.Height)) {
+				return fmt.Errorf("round %d stored under a different "+
+					"height", round.Height)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
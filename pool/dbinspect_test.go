@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDBInspect(t *testing.T, db *BoltDB) {
+	// Ensure the database version can be fetched.
+	version, err := db.Version()
+	if err != nil {
+		t.Fatalf("Version error: %v", err)
+	}
+	if version != DBVersion {
+		t.Fatalf("expected db version %d, got %d", DBVersion, version)
+	}
+
+	// Ensure accounts X and Y are listed.
+	accounts, err := db.ListAccounts()
+	if err != nil {
+		t.Fatalf("ListAccounts error: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, account := range accounts {
+		ids[account.UUID] = true
+	}
+	if len(accounts) != 2 || !ids[xID] || !ids[yID] {
+		t.Fatalf("expected accounts %s and %s to be listed, got %d "+
+			"accounts", xID, yID, len(accounts))
+	}
+
+	// Ensure jobs are listed.
+	job, err := persistJob(db, "header", 56)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := db.ListJobs()
+	if err != nil {
+		t.Fatalf("ListJobs error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].UUID != job.UUID {
+		t.Fatalf("expected job %s to be listed, got %d jobs", job.UUID,
+			len(jobs))
+	}
+
+	// Ensure a consistent database reports no issues.
+	weight := new(big.Rat).SetInt64(1)
+	createdOn := time.Now().UnixNano()
+	err = persistShare(db, xID, weight, createdOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := db.CheckConsistency()
+	if err != nil {
+		t.Fatalf("CheckConsistency error: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+
+	// Ensure shares of unknown accounts are reported.
+	err = persistShare(db, "unknown", weight, createdOn+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err = db.CheckConsistency()
+	if err != nil {
+		t.Fatalf("CheckConsistency error: %v", err)
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0], "unknown account") {
+		t.Fatalf("expected an unknown account issue, got %v", issues)
+	}
+
+	// Ensure the reported share can be deleted.
+	err = db.DeleteShare(createdOn + 1)
+	if err != nil {
+		t.Fatalf("DeleteShare error: %v", err)
+	}
+	shares, err := db.FetchShares(0, createdOn+1)
+	if err != nil {
+		t.Fatalf("FetchShares error: %v", err)
+	}
+	if len(shares) != 1 || shares[0].CreatedOn != createdOn {
+		t.Fatalf("expected only the share created on %d to remain, got "+
+			"%d shares", createdOn, len(shares))
+	}
+
+	// Ensure deleting a non-existent share errors.
+	err = db.DeleteShare(createdOn + 1)
+	if !IsError(err, ErrValueNotFound) {
+		t.Fatalf("expected a value not found error, got %v", err)
+	}
+
+	// Empty the share and job buckets.
+	err = emptyBucket(db, shareBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, jobBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package pool
 
 import (
 	"fmt"
+	"math"
 	"math/big"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +21,26 @@ const (
 	ObeliskDCR1   = "obeliskdcr1"
 )
 
+const (
+	// varDiffRetargetShares is the number of shares a client is expected
+	// to submit before its difficulty is reevaluated.
+	varDiffRetargetShares = 10
+
+	// varDiffRetargetWindow is the multiple of the pool's share creation
+	// target time after which a client's difficulty is reevaluated,
+	// regardless of the number of shares it submitted.
+	varDiffRetargetWindow = 6
+
+	// varDiffVariance is the allowed deviation of a client's share rate
+	// from the pool's share creation target time before its difficulty
+	// is adjusted.
+	varDiffVariance = 0.3
+
+	// varDiffMaxAdjustment is the maximum factor a client's difficulty
+	// can be increased or decreased by per retarget.
+	varDiffMaxAdjustment = 4.0
+)
+
 var (
 	// minerHashes is a map of all known DCR miners and their corresponding
 	// hashrates.
@@ -30,8 +52,35 @@ var (
 		AntminerDR5:   new(big.Int).SetInt64(35e12),
 		WhatsminerD1:  new(big.Int).SetInt64(48e12),
 	}
+
+	// minerUserAgents is a map of the mining.subscribe user agent prefixes
+	// of known DCR miners and their corresponding miner types. The Antminer
+	// DR3 and DR5 report the same user agent and cannot be told apart, they
+	// are assigned the default miner of the connection endpoint.
+	minerUserAgents = map[string]string{
+		"cpuminer":       CPU,
+		"cgminer/4.10.0": ObeliskDCR1,
+		"sgminer/4.4.2":  InnosiliconD9,
+		"whatsminer":     WhatsminerD1,
+	}
 )
 
+// identifyMiner returns the miner type associated with the provided
+// mining.subscribe user agent. User agents naming a supported miner type
+// directly are also recognised.
+func identifyMiner(userAgent string) (string, bool) {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if _, ok := minerHashes[userAgent]; ok {
+		return userAgent, true
+	}
+	for prefix, miner := range minerUserAgents {
+		if strings.HasPrefix(userAgent, prefix) {
+			return miner, true
+		}
+	}
+	return "", false
+}
+
 // DifficultyInfo represents the difficulty related info for a mining client.
 type DifficultyInfo struct {
 	target     *big.Rat
@@ -79,3 +128,43 @@ func (d *DifficultySet) fetchMinerDifficulty(miner string) (*DifficultyInfo, err
 	}
 	return diffData, nil
 }
+
+// calculateVarDiff determines the difficulty a client should be mining at
+// based on the number of shares it submitted over the elapsed period. The
+// provided difficulty is adjusted towards generating a share every
+// max gen time, it is not adjusted below the provided minimum difficulty of
+// the pool. A nil difficulty is returned if no adjustment is required.
+func calculateVarDiff(difficulty *big.Rat, minDifficulty *big.Rat, shares int64, elapsed time.Duration, maxGenTime time.Duration) *big.Rat {
+	if elapsed <= 0 || maxGenTime <= 0 {
+		return nil
+	}
+
+	// The adjustment ratio is calculated as:
+	//
+	//    ratio = (max_gen_time * shares) / elapsed
+	//
+	// A client that did not submit any shares over the elapsed period
+	// has its difficulty reduced by the maximum adjustment.
+	ratio := 1 / varDiffMaxAdjustment
+	if shares > 0 {
+		ratio = (maxGenTime.Seconds() * float64(shares)) / elapsed.Seconds()
+	}
+	if math.Abs(ratio-1) <= varDiffVariance {
+		return nil
+	}
+
+	// Clamp the ratio to the maximum adjustment allowed.
+	ratio = math.Min(ratio, varDiffMaxAdjustment)
+	ratio = math.Max(ratio, 1/varDiffMaxAdjustment)
+
+	adjusted := new(big.Rat).Mul(difficulty, new(big.Rat).SetFloat64(ratio))
+
+	// Clamp the difficulty to the minimum difficulty of the pool if needed.
+	if adjusted.Cmp(minDifficulty) < 0 {
+		adjusted.Set(minDifficulty)
+	}
+	if adjusted.Cmp(difficulty) == 0 {
+		return nil
+	}
+	return adjusted
+}
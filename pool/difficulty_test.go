@@ -3,6 +3,7 @@ package pool
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 )
@@ -47,4 +48,117 @@ func testDifficulty(t *testing.T) {
 			}
 		}
 	}
+
+	// Ensure client difficulties are retargeted towards the max gen time.
+	maxGenTime := time.Second * 20
+	diff := new(big.Rat).SetInt64(100)
+	one := new(big.Rat).SetInt64(1)
+	varDiffs := []struct {
+		shares  int64
+		elapsed time.Duration
+		want    *big.Rat
+	}{
+		{
+			// Share rate within the allowed variance.
+			shares:  10,
+			elapsed: time.Second * 210,
+			want:    nil,
+		},
+		{
+			// Share rate twice the target rate.
+			shares:  10,
+			elapsed: time.Second * 100,
+			want:    new(big.Rat).SetInt64(200),
+		},
+		{
+			// Share rate capped at the maximum adjustment.
+			shares:  100,
+			elapsed: time.Second * 100,
+			want:    new(big.Rat).SetInt64(400),
+		},
+		{
+			// Share rate half the target rate.
+			shares:  5,
+			elapsed: time.Second * 200,
+			want:    new(big.Rat).SetInt64(50),
+		},
+		{
+			// No shares submitted over the elapsed period.
+			shares:  0,
+			elapsed: time.Second * 120,
+			want:    new(big.Rat).SetInt64(25),
+		},
+		{
+			// No elapsed period.
+			shares:  10,
+			elapsed: 0,
+			want:    nil,
+		},
+	}
+
+	for idx, tc := range varDiffs {
+		got := calculateVarDiff(diff, one, tc.shares, tc.elapsed, maxGenTime)
+		if tc.want == nil {
+			if got != nil {
+				t.Fatalf("[calculateVarDiff] #%d: expected no adjustment, "+
+					"got %v", idx+1, got.FloatString(4))
+			}
+			continue
+		}
+		if got == nil || got.Cmp(tc.want) != 0 {
+			t.Fatalf("[calculateVarDiff] #%d: expected difficulty %v, got %v",
+				idx+1, tc.want.FloatString(4), got)
+		}
+	}
+
+	// Ensure adjusted difficulties are clamped to the minimum difficulty.
+	got := calculateVarDiff(new(big.Rat).SetInt64(2), one, 0, time.Minute,
+		maxGenTime)
+	if got == nil || got.Cmp(one) != 0 {
+		t.Fatalf("[calculateVarDiff] expected a difficulty of 1, got %v", got)
+	}
+
+	// Ensure fractional difficulties keep their precision and are only
+	// clamped to the minimum difficulty.
+	minDiff := new(big.Rat).SetFrac64(1, 100000)
+	fracDiff := new(big.Rat).SetFrac64(1, 1000)
+	got = calculateVarDiff(fracDiff, minDiff, 5, time.Second*200, maxGenTime)
+	if got == nil || got.Cmp(new(big.Rat).SetFrac64(1, 2000)) != 0 {
+		t.Fatalf("[calculateVarDiff] expected a difficulty of 1/2000, got %v",
+			got)
+	}
+	got = calculateVarDiff(fracDiff, minDiff, 10, time.Second*100, maxGenTime)
+	if got == nil || got.Cmp(new(big.Rat).SetFrac64(1, 500)) != 0 {
+		t.Fatalf("[calculateVarDiff] expected a difficulty of 1/500, got %v",
+			got)
+	}
+	got = calculateVarDiff(new(big.Rat).SetFrac64(1, 50000), minDiff, 0,
+		time.Minute, maxGenTime)
+	if got == nil || got.Cmp(minDiff) != 0 {
+		t.Fatalf("[calculateVarDiff] expected the minimum difficulty, got %v",
+			got)
+	}
+
+	// Ensure miners are identified from their user agents.
+	agentTests := []struct {
+		userAgent string
+		miner     string
+		ok        bool
+	}{
+		{"cpuminer/1.0.0", CPU, true},
+		{"cgminer/4.10.0", ObeliskDCR1, true},
+		{"sgminer/4.4.2", InnosiliconD9, true},
+		{"cgminer/4.9.0", "", false},
+		{"Whatsminer/v1.0", WhatsminerD1, true},
+		{AntminerDR5, AntminerDR5, true},
+		{"bfgminer/5.5.0", "", false},
+		{"", "", false},
+	}
+	for idx, tc := range agentTests {
+		miner, ok := identifyMiner(tc.userAgent)
+		if ok != tc.ok || miner != tc.miner {
+			t.Fatalf("[identifyMiner] #%d: expected (%q, %v), got (%q, %v)",
+				idx+1, tc.miner, tc.ok, miner, ok)
+		}
+	}
 }
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"math/big"
+	"sync"
+)
+
+// roundEffort tracks the work contributed to the pool since the last block
+// found by the pool. The effort of a found block is the contributed work
+// relative to the network difficulty it was found at, its inverse is the
+// luck of the pool.
+type roundEffort struct {
+	db   Database
+	work *big.Rat
+	mtx  sync.Mutex
+}
+
+// newRoundEffort creates a round effort tracker loaded with the persisted
+// round work.
+func newRoundEffort(db Database) (*roundEffort, error) {
+	work, err := db.FetchRoundWork()
+	if err != nil {
+		return nil, err
+	}
+	return &roundEffort{
+		db:   db,
+		work: work,
+	}, nil
+}
+
+// addWork adds the difficulty of an accepted share to the round work.
+func (r *roundEffort) addWork(difficulty *big.Rat) {
+	r.mtx.Lock()
+	r.work.Add(r.work, difficulty)
+	r.mtx.Unlock()
+}
+
+// complete returns the effort of a block found at the provided network
+// difficulty and starts a new round.
+func (r *roundEffort) complete(netDiff *big.Rat) float64 {
+	r.mtx.Lock()
+	var effort float64
+	if netDiff.Sign() > 0 {
+		effort, _ = new(big.Rat).Quo(r.work, netDiff).Float64()
+	}
+	r.work.SetInt64(0)
+	r.mtx.Unlock()
+
+	err := r.persist()
+	if err != nil {
+		log.Errorf("unable to persist round work: %v", err)
+	}
+	return effort
+}
+
+// fetchWork returns the work contributed to the pool in the current round.
+func (r *roundEffort) fetchWork() *big.Rat {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return new(big.Rat).Set(r.work)
+}
+
+// persist saves the round work to the db.
+func (r *roundEffort) persist() error {
+	return r.db.PersistRoundWork(r.fetchWork())
+}
@@ -6,21 +6,22 @@ package pool
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"math/big"
 	"net"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 type EndpointConfig struct {
 	// ActiveNet represents the active network being mined on.
 	ActiveNet *chaincfg.Params
 	// DB represents the pool database.
-	DB *bolt.DB
+	DB Database
 	// SoloPool represents the solo pool mining mode.
 	SoloPool bool
 	// Blake256Pad represents the extra padding needed for work
@@ -33,12 +34,46 @@ type EndpointConfig struct {
 	MaxConnectionsPerHost uint32
 	// MaxGenTime represents the share creation target time for the pool.
 	MaxGenTime time.Duration
+	// TLSConfig represents the TLS configuration of the endpoint. Client
+	// connections are not encrypted if it is not set.
+	TLSConfig *tls.Config
+	// DetectMiner enables identifying the miner type of clients from their
+	// mining.subscribe user agents, the endpoint miner type is used for
+	// unidentified clients.
+	DetectMiner bool
+	// FetchMinerDifficulty returns the difficulty info of the provided
+	// miner type.
+	FetchMinerDifficulty func(string) (*DifficultyInfo, error)
+	// RecordShare records a share submission of the provided miner type
+	// with the provided status and rejection reason.
+	RecordShare func(string, string, string)
+	// AddWorker records a new connection of the provided account worker.
+	AddWorker func(string, string, string) error
+	// RemoveWorker records a terminated connection of the provided account
+	// worker.
+	RemoveWorker func(string, string)
+	// RecordWorkerShare records a share submission of the provided account
+	// worker with the provided status and rejection reason.
+	RecordWorkerShare func(string, string, string, string)
+	// Notify sends the provided notification event.
+	Notify func(*Event)
+	// AddRoundWork adds the difficulty of an accepted share to the work
+	// contributed to the pool in the current round.
+	AddRoundWork func(*big.Rat)
+	// CompleteRound returns the effort of a block found by the pool at the
+	// provided network difficulty and starts a new round.
+	CompleteRound func(*big.Rat) float64
 	// HubWg represents the hub's waitgroup.
 	HubWg *sync.WaitGroup
 	// SubmitWork sends solved block data to the consensus daemon.
 	SubmitWork func(*string) (bool, error)
 	// FetchCurrentWork returns the current work of the pool.
 	FetchCurrentWork func() string
+	// IsStaleJob returns whether the provided job is stale.
+	IsStaleJob func(*Job) bool
+	// RecordSubmission records a share submission for the provided job,
+	// duplicate submissions are rejected.
+	RecordSubmission func(string, string, string, string, string) error
 	// WithinLimit returns if a client is within its request limits.
 	WithinLimit func(string, int) bool
 	// AddConnection records a new client connection.
@@ -82,6 +117,9 @@ func NewEndpoint(eCfg *EndpointConfig, diffInfo *DifficultyInfo, port uint32, mi
 	if err != nil {
 		return nil, err
 	}
+	if eCfg.TLSConfig != nil {
+		listener = tls.NewListener(listener, eCfg.TLSConfig)
+	}
 	endpoint.listener = listener
 	return endpoint, nil
 }
@@ -97,7 +135,16 @@ func (e *Endpoint) removeClient(c *Client) {
 // listen accepts incoming client connections on the endpoint.
 // It must be run as a goroutine.
 func (e *Endpoint) listen() {
-	log.Infof("%s listening on :%d", e.miner, e.port)
+	var secure string
+	if e.cfg.TLSConfig != nil {
+		secure = " (tls)"
+	}
+	if e.cfg.DetectMiner {
+		log.Infof("Unified endpoint (default %s) listening on :%d%s",
+			e.miner, e.port, secure)
+	} else {
+		log.Infof("%s listening on :%d%s", e.miner, e.port, secure)
+	}
 	for {
 		conn, err := e.listener.Accept()
 		if err != nil {
@@ -160,15 +207,26 @@ func (e *Endpoint) connect(ctx context.Context) {
 				FetchMiner: func() string {
 					return e.miner
 				},
-				DifficultyInfo:    e.diffInfo,
-				EndpointWg:        &e.wg,
-				RemoveClient:      e.removeClient,
-				SubmitWork:        e.cfg.SubmitWork,
-				FetchCurrentWork:  e.cfg.FetchCurrentWork,
-				WithinLimit:       e.cfg.WithinLimit,
-				HashCalcThreshold: hashCalcThreshold,
-				MaxGenTime:        e.cfg.MaxGenTime,
-				ClientTimeout:     clientTimeout,
+				DetectMiner:          e.cfg.DetectMiner,
+				FetchMinerDifficulty: e.cfg.FetchMinerDifficulty,
+				DifficultyInfo:       e.diffInfo,
+				EndpointWg:           &e.wg,
+				RemoveClient:         e.removeClient,
+				SubmitWork:           e.cfg.SubmitWork,
+				FetchCurrentWork:     e.cfg.FetchCurrentWork,
+				IsStaleJob:           e.cfg.IsStaleJob,
+				RecordSubmission:     e.cfg.RecordSubmission,
+				WithinLimit:          e.cfg.WithinLimit,
+				HashCalcThreshold:    hashCalcThreshold,
+				MaxGenTime:           e.cfg.MaxGenTime,
+				ClientTimeout:        clientTimeout,
+				RecordShare:          e.cfg.RecordShare,
+				AddWorker:            e.cfg.AddWorker,
+				RemoveWorker:         e.cfg.RemoveWorker,
+				RecordWorkerShare:    e.cfg.RecordWorkerShare,
+				Notify:               e.cfg.Notify,
+				AddRoundWork:         e.cfg.AddRoundWork,
+				CompleteRound:        e.cfg.CompleteRound,
 			}
 			client, err := NewClient(msg.Conn, tcpAddr, cCfg)
 			if err != nil {
@@ -2,6 +2,9 @@ package pool
 
 import (
 	"context"
+	"crypto/elliptic"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"math"
 	"math/big"
@@ -11,8 +14,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/decred/dcrd/certgen"
 	"github.com/decred/dcrd/chaincfg/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 func makeConn(listener *net.TCPListener, serverCh chan net.Conn) (net.Conn, net.Conn, error) {
@@ -24,7 +27,7 @@ func makeConn(listener *net.TCPListener, serverCh chan net.Conn) (net.Conn, net.
 	return conn, server, nil
 }
 
-func testEndpoint(t *testing.T, db *bolt.DB) {
+func testEndpoint(t *testing.T, db Database) {
 	miner := CPU
 	powLimit := chaincfg.SimNetParams().PowLimit
 	powLimitF, _ := new(big.Float).SetInt(powLimit).Float64()
@@ -57,9 +60,20 @@ func testEndpoint(t *testing.T, db *bolt.DB) {
 		FetchCurrentWork: func() string {
 			return ""
 		},
+		IsStaleJob: func(*Job) bool {
+			return false
+		},
+		RecordSubmission: newSubmissionSet().record,
 		WithinLimit: func(ip string, clientType int) bool {
 			return true
 		},
+		RecordShare:       func(string, string, string) {},
+		AddWorker:         func(string, string, string) error { return nil },
+		RemoveWorker:      func(string, string) {},
+		RecordWorkerShare: func(string, string, string, string) {},
+		Notify:            func(*Event) {},
+		AddRoundWork:      func(*big.Rat) {},
+		CompleteRound:     func(*big.Rat) float64 { return 0 },
 		AddConnection: func(host string) {
 			connectionsMtx.Lock()
 			connections[host]++
@@ -233,4 +247,56 @@ func testEndpoint(t *testing.T, db *bolt.DB) {
 
 	cancel()
 	endpoint.cfg.HubWg.Wait()
+
+	// Ensure a TLS endpoint accepts encrypted client connections.
+	certPEM, keyPEM, err := certgen.NewTLSCertPair(elliptic.P256(),
+		"dcrpool test cert", time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("[NewTLSCertPair] unexpected error: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("[X509KeyPair] unexpected error: %v", err)
+	}
+	tlsCfg := *eCfg
+	tlsCfg.HubWg = new(sync.WaitGroup)
+	tlsCfg.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	tlsPort := port + 2
+	tlsEndpoint, err := NewEndpoint(&tlsCfg, diffInfo, tlsPort, miner)
+	if err != nil {
+		t.Fatalf("[NewEndpoint] unexpected error: %v", err)
+	}
+	tlsEndpoint.cfg.HubWg.Add(1)
+	ctx, cancel = context.WithCancel(context.Background())
+	go tlsEndpoint.run(ctx)
+	time.Sleep(time.Millisecond * 100)
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(certPEM) {
+		t.Fatal("unable to add the test certificate to the cert pool")
+	}
+	tlsConn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", "127.0.0.1",
+		tlsPort), &tls.Config{
+		RootCAs:    roots,
+		ServerName: "localhost",
+	})
+	if err != nil {
+		t.Fatalf("[tls.Dial] unexpected error: %v", err)
+	}
+	defer tlsConn.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	// Ensure the TLS client got created.
+	tlsEndpoint.clientsMtx.Lock()
+	tlsClients := len(tlsEndpoint.clients)
+	tlsEndpoint.clientsMtx.Unlock()
+	if tlsClients != 1 {
+		t.Fatalf("expected %d TLS endpoint client, got %d", 1, tlsClients)
+	}
+
+	cancel()
+	tlsEndpoint.cfg.HubWg.Wait()
 }
@@ -53,6 +53,17 @@ const (
 	// ErrDBUpgrade indicates a database upgrade error.
 	ErrDBUpgrade
 
+	// ErrShareExists indicates an already existing share submission.
+	ErrShareExists
+
+	// ErrCoinbaseReward indicates the pool reward of a coinbase could not
+	// be determined.
+	ErrCoinbaseReward
+
+	// ErrInvalidSignature indicates a message signature does not match the
+	// expected address.
+	ErrInvalidSignature
+
 	// ErrOther indicates a miscellenious error.
 	ErrOther
 )
@@ -73,6 +84,9 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrNotSupported:       "ErrNotSupported",
 	ErrDivideByZero:       "ErrDivideByZero",
 	ErrDBUpgrade:          "ErrDBUpgrade",
+	ErrShareExists:        "ErrShareExists",
+	ErrCoinbaseReward:     "ErrCoinbaseReward",
+	ErrInvalidSignature:   "ErrInvalidSignature",
 	ErrOther:              "ErrOther",
 }
 
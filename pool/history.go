@@ -0,0 +1,244 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// MinuteResolution represents per minute history samples.
+	MinuteResolution = "minute"
+	// HourResolution represents hourly history samples.
+	HourResolution = "hour"
+
+	// PoolScope represents history samples of the pool.
+	PoolScope = "pool"
+	// AccountScope represents history samples of an account.
+	AccountScope = "account"
+	// WorkerScope represents history samples of a worker of an account.
+	WorkerScope = "worker"
+
+	// historySampleInterval is the interval between minute history samples.
+	historySampleInterval = time.Minute
+	// minuteHistoryRetention is the period minute history samples are kept.
+	minuteHistoryRetention = time.Hour * 24
+	// hourHistoryRetention is the period hour history samples are kept.
+	hourHistoryRetention = time.Hour * 24 * 30
+)
+
+// HistorySample represents the hash rate and share counts of the pool, an
+// account or a worker over a sampling interval.
+type HistorySample struct {
+	Scope    string  `json:"scope"`
+	ID       string  `json:"id"`
+	Time     int64   `json:"time"`
+	HashRate float64 `json:"hashrate"`
+	Accepted uint64  `json:"accepted"`
+	Rejected uint64  `json:"rejected"`
+}
+
+// WorkerID returns the history id of the provided worker of an account.
+func WorkerID(accountID string, worker string) string {
+	return fmt.Sprintf("%s.%s", accountID, worker)
+}
+
+// historySeriesKey returns the key prefix of all samples of the provided
+// scope and id.
+func historySeriesKey(scope string, id string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/", scope, id))
+}
+
+// historyKey returns the key of the provided sample. Keys of a series are
+// ordered by the sample time.
+func (s *HistorySample) historyKey() []byte {
+	key := historySeriesKey(s.Scope, s.ID)
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(s.Time))
+	return append(key, b...)
+}
+
+// historySampleTime returns the sample time encoded in the provided key.
+func historySampleTime(key []byte) int64 {
+	if len(key) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(key[len(key)-8:]))
+}
+
+// historyBucket returns the history bucket key of the provided resolution.
+func historyBucket(resolution string) ([]byte, error) {
+	switch resolution {
+	case MinuteResolution:
+		return minuteHistoryBkt, nil
+	case HourResolution:
+		return hourHistoryBkt, nil
+	default:
+		desc := fmt.Sprintf("unknown history resolution %s", resolution)
+		return nil, MakeError(ErrOther, desc, nil)
+	}
+}
+
+// persistHistorySamples stores the provided samples at the provided
+// resolution.
+func persistHistorySamples(db Database, resolution string, samples []*HistorySample) error {
+	return db.PersistHistorySamples(resolution, samples)
+}
+
+// fetchHistory fetches the samples of the provided scope and id at the
+// provided resolution taken at or after the provided time. List is ordered,
+// oldest comes first.
+func fetchHistory(db Database, resolution string, scope string, id string, since int64) ([]*HistorySample, error) {
+	return db.FetchHistory(resolution, scope, id, since)
+}
+
+// downsampleHistory aggregates the minute samples taken within the hour
+// starting at the provided time into hourly samples. Hash rates are averaged
+// over the hour and share counts are summed.
+func downsampleHistory(db Database, hour int64) error {
+	end := hour + int64(time.Hour/time.Second)
+	minuteSamples, err := db.FetchHistoryRange(MinuteResolution, hour, end)
+	if err != nil {
+		return err
+	}
+	hourly := make(map[string]*HistorySample)
+	order := make([]string, 0)
+	for _, sample := range minuteSamples {
+		series := string(historySeriesKey(sample.Scope, sample.ID))
+		agg, ok := hourly[series]
+		if !ok {
+			agg = &HistorySample{
+				Scope: sample.Scope,
+				ID:    sample.ID,
+				Time:  hour,
+			}
+			hourly[series] = agg
+			order = append(order, series)
+		}
+		agg.HashRate += sample.HashRate
+		agg.Accepted += sample.Accepted
+		agg.Rejected += sample.Rejected
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	samplesPerHour := float64(time.Hour / historySampleInterval)
+	samples := make([]*HistorySample, 0, len(order))
+	for _, series := range order {
+		agg := hourly[series]
+		agg.HashRate /= samplesPerHour
+		samples = append(samples, agg)
+	}
+	return persistHistorySamples(db, HourResolution, samples)
+}
+
+// pruneHistory removes all samples at the provided resolution taken before
+// the provided time.
+func pruneHistory(db Database, resolution string, before int64) error {
+	return db.PruneHistory(resolution, before)
+}
+
+// collectHistorySamples generates minute samples of the pool and the
+// accounts and workers of the provided clients at the provided time. Clients
+// without an account, as in solo pool mining mode, only contribute to the
+// pool sample.
+func collectHistorySamples(clients []*Client, now int64) []*HistorySample {
+	poolSample := &HistorySample{Scope: PoolScope, Time: now}
+	series := make(map[string]*HistorySample)
+	samples := []*HistorySample{poolSample}
+	fetchSample := func(scope string, id string) *HistorySample {
+		key := string(historySeriesKey(scope, id))
+		sample, ok := series[key]
+		if !ok {
+			sample = &HistorySample{
+				Scope: scope,
+				ID:    id,
+				Time:  now,
+			}
+			series[key] = sample
+			samples = append(samples, sample)
+		}
+		return sample
+	}
+	for _, c := range clients {
+		hashRate, _ := new(big.Rat).Set(c.FetchHashRate()).Float64()
+		accepted, rejected := c.fetchShareCounts()
+		aggs := []*HistorySample{poolSample}
+		accountID := c.FetchAccountID()
+		if accountID != "" {
+			aggs = append(aggs, fetchSample(AccountScope, accountID),
+				fetchSample(WorkerScope, WorkerID(accountID,
+					c.FetchWorkerName())))
+		}
+		for _, agg := range aggs {
+			agg.HashRate += hashRate
+			agg.Accepted += accepted
+			agg.Rejected += rejected
+		}
+	}
+	return samples
+}
+
+// recordHistory persists minute samples of all connected clients at the
+// provided time. Completed hours are downsampled and samples past their
+// retention period are pruned.
+func (h *Hub) recordHistory(now time.Time) error {
+	sampleTime := now.Truncate(historySampleInterval).Unix()
+	samples := collectHistorySamples(h.FetchClients(), sampleTime)
+	err := persistHistorySamples(h.db, MinuteResolution, samples)
+	if err != nil {
+		return err
+	}
+
+	// Downsample the previous hour once the first sample of a new hour
+	// is taken.
+	hour := now.Truncate(time.Hour)
+	if now.Sub(hour) < historySampleInterval {
+		err := downsampleHistory(h.db, hour.Add(-time.Hour).Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = pruneHistory(h.db, MinuteResolution,
+		now.Add(-minuteHistoryRetention).Unix())
+	if err != nil {
+		return err
+	}
+	return pruneHistory(h.db, HourResolution,
+		now.Add(-hourHistoryRetention).Unix())
+}
+
+// historyMonitor periodically records hash rate and share history. It must
+// be run as a goroutine.
+func (h *Hub) historyMonitor(ctx context.Context) {
+	ticker := time.NewTicker(historySampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.wg.Done()
+			return
+
+		case now := <-ticker.C:
+			err := h.recordHistory(now)
+			if err != nil {
+				log.Errorf("unable to record history: %v", err)
+			}
+			h.notifyHashRateDrops()
+		}
+	}
+}
+
+// FetchHistory fetches all retained samples of the provided scope and id at
+// the provided resolution. List is ordered, oldest comes first.
+func (h *Hub) FetchHistory(resolution string, scope string, id string) ([]*HistorySample, error) {
+	return fetchHistory(h.db, resolution, scope, id, 0)
+}
@@ -0,0 +1,113 @@
+package pool
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testHistory(t *testing.T, db Database) {
+	// Ensure client samples are aggregated per worker, account and pool.
+	clients := []*Client{
+		{account: xID, name: "a", hashRate: big.NewRat(100, 1),
+			acceptedShares: 4, rejectedShares: 1},
+		{account: xID, name: "b", hashRate: big.NewRat(50, 1),
+			acceptedShares: 2},
+		{account: yID, name: "a", hashRate: big.NewRat(25, 1),
+			acceptedShares: 1, rejectedShares: 1},
+		{name: "solo", hashRate: big.NewRat(5, 1)},
+	}
+	hour := time.Now().Add(-time.Hour).Truncate(time.Hour).Unix()
+	samples := collectHistorySamples(clients, hour)
+	if len(samples) != 6 {
+		t.Fatalf("expected 6 history samples, got %d", len(samples))
+	}
+	poolSample := samples[0]
+	if poolSample.Scope != PoolScope || poolSample.HashRate != 180 ||
+		poolSample.Accepted != 7 || poolSample.Rejected != 2 {
+		t.Fatalf("unexpected pool history sample: %+v", poolSample)
+	}
+	for _, sample := range samples {
+		if sample.Scope == AccountScope && sample.ID == xID &&
+			(sample.HashRate != 150 || sample.Accepted != 6) {
+			t.Fatalf("unexpected account history sample: %+v", sample)
+		}
+	}
+	accepted, rejected := clients[0].fetchShareCounts()
+	if accepted != 0 || rejected != 0 {
+		t.Fatalf("expected share counts to be reset, got %d accepted "+
+			"and %d rejected", accepted, rejected)
+	}
+
+	// Persist an hour of minute samples for the pool and downsample them.
+	minuteSamples := make([]*HistorySample, 0)
+	for i := int64(0); i < 60; i++ {
+		minuteSamples = append(minuteSamples, &HistorySample{
+			Scope:    PoolScope,
+			Time:     hour + i*60,
+			HashRate: float64(i % 2 * 120),
+			Accepted: 1,
+		})
+	}
+	err := persistHistorySamples(db, MinuteResolution, minuteSamples)
+	if err != nil {
+		t.Fatalf("[persistHistorySamples] unexpected error: %v", err)
+	}
+	history, err := fetchHistory(db, MinuteResolution, PoolScope, "", hour+30*60)
+	if err != nil {
+		t.Fatalf("[fetchHistory] unexpected error: %v", err)
+	}
+	if len(history) != 30 {
+		t.Fatalf("expected 30 minute history samples, got %d", len(history))
+	}
+	if history[0].Time != hour+30*60 {
+		t.Fatalf("expected the oldest sample first, got time %d",
+			history[0].Time)
+	}
+
+	err = downsampleHistory(db, hour)
+	if err != nil {
+		t.Fatalf("[downsampleHistory] unexpected error: %v", err)
+	}
+	history, err = fetchHistory(db, HourResolution, PoolScope, "", 0)
+	if err != nil {
+		t.Fatalf("[fetchHistory] unexpected error: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 hour history sample, got %d", len(history))
+	}
+	if history[0].HashRate != 60 || history[0].Accepted != 60 {
+		t.Fatalf("expected an hourly hash rate of 60 and 60 accepted "+
+			"shares, got %+v", history[0])
+	}
+
+	// Ensure samples past their retention period are pruned.
+	err = pruneHistory(db, MinuteResolution, hour+30*60)
+	if err != nil {
+		t.Fatalf("[pruneHistory] unexpected error: %v", err)
+	}
+	history, err = fetchHistory(db, MinuteResolution, PoolScope, "", 0)
+	if err != nil {
+		t.Fatalf("[fetchHistory] unexpected error: %v", err)
+	}
+	if len(history) != 30 {
+		t.Fatalf("expected 30 minute history samples after pruning, "+
+			"got %d", len(history))
+	}
+
+	// Ensure an unknown resolution is rejected.
+	_, err = fetchHistory(db, "day", PoolScope, "", 0)
+	if err == nil {
+		t.Fatal("expected an unknown resolution error")
+	}
+
+	// Empty the history buckets.
+	err = emptyBucket(db, minuteHistoryBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, hourHistoryBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}
@@ -7,12 +7,13 @@ package pool
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,13 +22,15 @@ import (
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrjson/v3"
 	"github.com/decred/dcrd/dcrutil/v2"
 	chainjson "github.com/decred/dcrd/rpc/jsonrpc/types/v2"
 	"github.com/decred/dcrd/rpcclient/v5"
 	"github.com/decred/dcrd/wire"
 	"github.com/decred/dcrwallet/rpc/walletrpc"
-	bolt "go.etcd.io/bbolt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -53,6 +56,13 @@ const (
 	NewParent = "newparent"
 	NewVotes  = "newvotes"
 	NewTxns   = "newtxns"
+
+	// nodeHealthCheckInterval is the interval between mining node health
+	// checks.
+	nodeHealthCheckInterval = time.Second * 10
+
+	// nodeTimeout is the maximum duration to wait on a mining node request.
+	nodeTimeout = time.Second * 10
 )
 
 var (
@@ -70,6 +80,7 @@ type WalletConnection interface {
 	ConstructTransaction(context.Context, *walletrpc.ConstructTransactionRequest, ...grpc.CallOption) (*walletrpc.ConstructTransactionResponse, error)
 	SignTransaction(context.Context, *walletrpc.SignTransactionRequest, ...grpc.CallOption) (*walletrpc.SignTransactionResponse, error)
 	PublishTransaction(context.Context, *walletrpc.PublishTransactionRequest, ...grpc.CallOption) (*walletrpc.PublishTransactionResponse, error)
+	GetTransaction(context.Context, *walletrpc.GetTransactionRequest, ...grpc.CallOption) (*walletrpc.GetTransactionResponse, error)
 }
 
 // NodeConnection defines the functionality needed by a mining node
@@ -86,21 +97,41 @@ type NodeConnection interface {
 // HubConfig represents configuration details for the hub.
 type HubConfig struct {
 	ActiveNet             *chaincfg.Params
-	DB                    *bolt.DB
+	DB                    Database
 	PoolFee               float64
 	MaxTxFeeReserve       dcrutil.Amount
 	MaxGenTime            time.Duration
 	PaymentMethod         string
 	LastNPeriod           time.Duration
+	PPLNSWindow           string
+	LastNShares           uint32
+	LastNWork             float64
 	WalletPass            string
 	MinPayment            dcrutil.Amount
 	SoloPool              bool
 	PoolFeeAddrs          []dcrutil.Address
+	MiningAddrs           []dcrutil.Address
 	AdminPass             string
 	Secret                string
 	NonceIterations       float64
 	MinerPorts            map[string]uint32
 	MaxConnectionsPerHost uint32
+	StaleJobGrace         time.Duration
+	UnifiedPort           uint32
+	DefaultMiner          string
+	MinerTLSPorts         map[string]uint32
+	UnifiedTLSPort        uint32
+	TLSConfig             *tls.Config
+	WebhookURLs           []string
+	WebhookSecret         string
+	WebhookRetries        uint32
+	WebhookRetryBackoff   time.Duration
+	WorkerOfflineTimeout  time.Duration
+	HashRateDropPercent   float64
+	SnapshotInterval      time.Duration
+	SnapshotDir           string
+	SnapshotRetention     uint32
+	SnapshotCompress      bool
 }
 
 // Hub maintains the set of active clients and facilitates message broadcasting
@@ -108,13 +139,21 @@ type HubConfig struct {
 type Hub struct {
 	clients int32 // update atomically.
 
-	db             *bolt.DB
+	db             Database
 	cfg            *HubConfig
 	limiter        *RateLimiter
-	nodeConn       NodeConnection
-	nodeConnMtx    sync.Mutex
+	metrics        *Metrics
+	workers        *workerRegistry
+	notifier       *Notifier
+	hashRates      *hashRateMonitor
+	roundEffort    *roundEffort
+	nodes          []*node
+	activeNode     int
+	nodesMtx       sync.Mutex
 	walletClose    func() error
 	walletConn     WalletConnection
+	walletDial     func() (WalletConnection, func() error, error)
+	walletMtx      sync.Mutex
 	poolDiffs      *DifficultySet
 	paymentMgr     *PaymentMgr
 	chainState     *ChainState
@@ -123,45 +162,330 @@ type Hub struct {
 	cancel         context.CancelFunc
 	endpoints      []*Endpoint
 	blake256Pad    []byte
+	submissions    *submissionSet
 	wg             *sync.WaitGroup
+	restorePath    string
+	restoreDBFile  string
+	restoreMtx     sync.Mutex
+}
+
+// node represents a mining node connection of the hub.
+type node struct {
+	host    string
+	conn    NodeConnection
+	healthy bool
 }
 
-// SetNodeConnection sets the mining node connection.
+// SetNodeConnection sets the primary mining node connection, replacing
+// any previously added node connections.
 func (h *Hub) SetNodeConnection(conn NodeConnection) {
-	h.nodeConnMtx.Lock()
-	h.nodeConn = conn
-	h.nodeConnMtx.Unlock()
+	h.nodesMtx.Lock()
+	h.nodes = []*node{{conn: conn, healthy: true}}
+	h.activeNode = 0
+	h.nodesMtx.Unlock()
+}
+
+// AddNodeConnection adds a mining node connection for the provided host.
+// The first connection added is the active node, subsequent connections
+// are failover nodes. Only the active node is expected to have work and
+// block notifications enabled.
+func (h *Hub) AddNodeConnection(host string, conn NodeConnection) {
+	h.nodesMtx.Lock()
+	h.nodes = append(h.nodes, &node{host: host, conn: conn, healthy: true})
+	h.nodesMtx.Unlock()
+}
+
+// isActiveNode returns whether the provided host is the active mining node.
+func (h *Hub) isActiveNode(host string) bool {
+	h.nodesMtx.Lock()
+	defer h.nodesMtx.Unlock()
+	if len(h.nodes) == 0 {
+		return false
+	}
+	return h.nodes[h.activeNode].host == host
+}
+
+// nodeRequest is a mining node request. It returns a function which stores
+// the result of the request, since a timed out request keeps running and
+// must not store its result once the caller has given up on it.
+type nodeRequest func() (func(), error)
+
+// nodeResult is the outcome of a mining node request.
+type nodeResult struct {
+	store func()
+	err   error
+}
+
+// callNode runs the provided mining node request, giving up if it does not
+// complete within the node timeout. The result of a completed request is
+// stored from the calling goroutine.
+func callNode(req nodeRequest) error {
+	resultCh := make(chan nodeResult, 1)
+	go func() {
+		store, err := req()
+		resultCh <- nodeResult{store: store, err: err}
+	}()
+	select {
+	case res := <-resultCh:
+		if res.err != nil {
+			return res.err
+		}
+		if res.store != nil {
+			res.store()
+		}
+		return nil
+	case <-time.After(nodeTimeout):
+		return MakeError(ErrOther, "node request timed out", nil)
+	}
+}
+
+// nodeCall runs the provided mining node request which has no result,
+// giving up if it does not complete within the node timeout.
+func nodeCall(call func() error) error {
+	return callNode(func() (func(), error) {
+		return nil, call()
+	})
+}
+
+// isNodeFailure returns whether the provided mining node request error
+// indicates the node is unable to serve requests, as opposed to the node
+// rejecting the request itself.
+func isNodeFailure(err error) bool {
+	_, ok := err.(*dcrjson.RPCError)
+	return !ok
+}
+
+// withNode runs the provided request against the active mining node. If the
+// active node is unable to serve the request it is marked unhealthy and the
+// request is retried against the remaining healthy nodes, failing over to
+// the first node to complete it. Errors returned by a node for the request
+// itself are returned as is.
+func (h *Hub) withNode(method string, req func(NodeConnection) (func(), error)) error {
+	h.nodesMtx.Lock()
+	if len(h.nodes) == 0 {
+		h.nodesMtx.Unlock()
+		return MakeError(ErrOther, "node connection unset", nil)
+	}
+	candidates := make([]int, 0, len(h.nodes))
+	candidates = append(candidates, h.activeNode)
+	for idx, n := range h.nodes {
+		if idx != h.activeNode && n.healthy {
+			candidates = append(candidates, idx)
+		}
+	}
+	nodes := make([]*node, len(h.nodes))
+	copy(nodes, h.nodes)
+	h.nodesMtx.Unlock()
+
+	var err error
+	for _, idx := range candidates {
+		n := nodes[idx]
+		start := time.Now()
+		err = callNode(func() (func(), error) {
+			return req(n.conn)
+		})
+		h.observeRPC(dcrdService, method, start)
+		if err == nil {
+			if idx != candidates[0] {
+				h.switchNode(idx)
+			}
+			return nil
+		}
+		if !isNodeFailure(err) {
+			return err
+		}
+		log.Errorf("node %s request failed: %v", n.host, err)
+		h.setNodeHealth(idx, false)
+	}
+	return err
+}
+
+// setNodeHealth updates the health status of the node at the provided index.
+func (h *Hub) setNodeHealth(idx int, healthy bool) {
+	h.nodesMtx.Lock()
+	h.nodes[idx].healthy = healthy
+	h.nodesMtx.Unlock()
+}
+
+// switchNode makes the node at the provided index the active mining node and
+// resubscribes to work and block notifications from it.
+func (h *Hub) switchNode(idx int) {
+	h.nodesMtx.Lock()
+	if idx == h.activeNode {
+		h.nodesMtx.Unlock()
+		return
+	}
+	n := h.nodes[idx]
+	h.activeNode = idx
+	h.nodesMtx.Unlock()
+
+	log.Infof("Failing over to node %s", n.host)
+	err := nodeCall(n.conn.NotifyWork)
+	if err != nil {
+		log.Errorf("unable to subscribe to work notifications "+
+			"from node %s: %v", n.host, err)
+		h.setNodeHealth(idx, false)
+		return
+	}
+	err = nodeCall(n.conn.NotifyBlocks)
+	if err != nil {
+		log.Errorf("unable to subscribe to block notifications "+
+			"from node %s: %v", n.host, err)
+		h.setNodeHealth(idx, false)
+	}
+}
+
+// checkNodes updates the health status of all mining nodes and fails over
+// to a healthy node if the active node is unhealthy. Work from the new
+// active node is dispatched to connected clients on failover.
+func (h *Hub) checkNodes() {
+	h.nodesMtx.Lock()
+	nodes := make([]*node, len(h.nodes))
+	copy(nodes, h.nodes)
+	h.nodesMtx.Unlock()
+
+	for idx, n := range nodes {
+		err := nodeCall(func() error {
+			_, err := n.conn.GetWork()
+			return err
+		})
+		if err != nil {
+			log.Errorf("node %s health check failed: %v", n.host, err)
+		}
+		h.setNodeHealth(idx, err == nil)
+	}
+
+	h.nodesMtx.Lock()
+	if len(h.nodes) == 0 || h.nodes[h.activeNode].healthy {
+		h.nodesMtx.Unlock()
+		return
+	}
+	next := -1
+	for idx, n := range h.nodes {
+		if n.healthy {
+			next = idx
+			break
+		}
+	}
+	h.nodesMtx.Unlock()
+
+	if next == -1 {
+		log.Errorf("no healthy node connections available")
+		return
+	}
+	h.switchNode(next)
+
+	work, _, err := h.getWork()
+	if err != nil {
+		log.Errorf("unable to fetch work after failover: %v", err)
+		return
+	}
+	h.chainState.setCurrentWork(work)
+	h.processWork(work)
+}
+
+// monitorNodes periodically checks the health of the mining nodes.
+// It must be run as a goroutine.
+func (h *Hub) monitorNodes(ctx context.Context) {
+	ticker := time.NewTicker(nodeHealthCheckInterval)
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			h.wg.Done()
+			return
+
+		case <-ticker.C:
+			h.checkNodes()
+		}
+	}
 }
 
 // SetWalletConnection sets the wallet connection and it's associated close.
 func (h *Hub) SetWalletConnection(conn WalletConnection, close func() error) {
+	h.walletMtx.Lock()
+	h.walletConn = conn
+	h.walletClose = close
+	h.walletMtx.Unlock()
+}
+
+// SetWalletDialer sets the function used to re-establish the wallet
+// connection when it breaks.
+func (h *Hub) SetWalletDialer(dial func() (WalletConnection, func() error, error)) {
+	h.walletMtx.Lock()
+	h.walletDial = dial
+	h.walletMtx.Unlock()
+}
+
+// fetchWalletConnection returns the wallet connection.
+func (h *Hub) fetchWalletConnection() WalletConnection {
+	h.walletMtx.Lock()
+	defer h.walletMtx.Unlock()
+	return h.walletConn
+}
+
+// redialWallet replaces a broken wallet connection with a newly dialed one.
+func (h *Hub) redialWallet() error {
+	h.walletMtx.Lock()
+	defer h.walletMtx.Unlock()
+	if h.walletDial == nil {
+		return MakeError(ErrOther, "wallet dialer unset", nil)
+	}
+	if h.walletClose != nil {
+		h.walletClose()
+	}
+	conn, close, err := h.walletDial()
+	if err != nil {
+		h.walletConn = nil
+		h.walletClose = nil
+		return err
+	}
 	h.walletConn = conn
 	h.walletClose = close
+	log.Info("Wallet connection re-established")
+	return nil
+}
+
+// isWalletConnError returns whether the provided wallet request error
+// indicates a broken wallet connection.
+func isWalletConnError(err error) bool {
+	return status.Code(err) == codes.Unavailable
 }
 
-// persistPoolMode saves the pool mode to the db.
-func (h *Hub) persistPoolMode(tx *bolt.Tx, mode uint32) error {
-	pbkt := tx.Bucket(poolBkt)
-	b := make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, mode)
-	return pbkt.Put(soloPool, b)
+// handleWalletError re-dials the wallet connection if the provided wallet
+// request error indicates the connection is broken. The provided error is
+// returned unchanged.
+func (h *Hub) handleWalletError(err error) error {
+	if isWalletConnError(err) {
+		log.Errorf("wallet connection broken, re-dialing: %v", err)
+		if rErr := h.redialWallet(); rErr != nil {
+			log.Errorf("unable to re-dial wallet: %v", rErr)
+		}
+	}
+	return err
 }
 
-// PruneJobs removes all jobs with heights less than the provided height.
-func (h *Hub) pruneJobs(db *bolt.DB, height uint32) error {
+// PruneJobs removes all jobs and their share submissions with heights less
+// than the provided height.
+func (h *Hub) pruneJobs(db Database, height uint32) error {
+	h.submissions.prune(height)
 	return pruneJobs(db, height)
 }
 
 // PruneAcceptedWork removes all accepted work not confirmed as mined
 // work with heights less than the provided height.
-func (h *Hub) pruneAcceptedWork(db *bolt.DB, height uint32) error {
+func (h *Hub) pruneAcceptedWork(db Database, height uint32) error {
 	return pruneAcceptedWork(db, height)
 }
 
-// pendingPaymentsAtHeight fetches all pending payments at
-// the provided height.
-func (h *Hub) pendingPaymentsAtHeight(db *bolt.DB, height uint32) ([]*Payment, error) {
-	return fetchPendingPaymentsAtHeight(db, height)
+// matureAcceptedWork marks confirmed mined work with coinbases matured as of
+// the provided connected block height as matured.
+func (h *Hub) matureAcceptedWork(db Database, height uint32) error {
+	maturity := uint32(h.cfg.ActiveNet.CoinbaseMaturity)
+	if height < maturity {
+		return nil
+	}
+	return matureAcceptedWork(db, height-maturity)
 }
 
 // generateBlake256Pad creates the extra padding needed for work
@@ -181,8 +505,10 @@ func NewHub(cancel context.CancelFunc, hcfg *HubConfig) (*Hub, error) {
 		cfg:         hcfg,
 		db:          hcfg.DB,
 		limiter:     NewRateLimiter(),
+		metrics:     NewMetrics(),
 		wg:          new(sync.WaitGroup),
 		connections: make(map[string]uint32),
+		submissions: newSubmissionSet(),
 		cancel:      cancel,
 	}
 	h.blake256Pad = generateBlake256Pad()
@@ -201,17 +527,44 @@ func NewHub(cancel context.CancelFunc, hcfg *HubConfig) (*Hub, error) {
 		return nil, err
 	}
 
+	h.workers, err = newWorkerRegistry(h.db)
+	if err != nil {
+		return nil, err
+	}
+
+	h.roundEffort, err = newRoundEffort(h.db)
+	if err != nil {
+		return nil, err
+	}
+
+	h.notifier = NewNotifier(&NotifierConfig{
+		DB:           h.db,
+		URLs:         h.cfg.WebhookURLs,
+		Secret:       h.cfg.WebhookSecret,
+		MaxRetries:   h.cfg.WebhookRetries,
+		RetryBackoff: h.cfg.WebhookRetryBackoff,
+		HubWg:        h.wg,
+	})
+	h.hashRates = newHashRateMonitor()
+
 	pCfg := &PaymentMgrConfig{
 		DB:                 h.db,
 		ActiveNet:          h.cfg.ActiveNet,
 		PoolFee:            h.cfg.PoolFee,
 		LastNPeriod:        h.cfg.LastNPeriod,
+		PPLNSWindow:        h.cfg.PPLNSWindow,
+		LastNShares:        h.cfg.LastNShares,
+		LastNWork:          h.cfg.LastNWork,
 		SoloPool:           h.cfg.SoloPool,
 		PaymentMethod:      h.cfg.PaymentMethod,
 		MinPayment:         h.cfg.MinPayment,
 		PoolFeeAddrs:       h.cfg.PoolFeeAddrs,
+		MiningAddrs:        h.cfg.MiningAddrs,
 		MaxTxFeeReserve:    h.cfg.MaxTxFeeReserve,
 		PublishTransaction: h.PublishTransaction,
+		GetBlock:           h.getBlock,
+		RecordPaymentRun:   h.recordPaymentRun,
+		Notify:             h.notify,
 	}
 	h.paymentMgr, err = NewPaymentMgr(pCfg)
 	if err != nil {
@@ -219,16 +572,25 @@ func NewHub(cancel context.CancelFunc, hcfg *HubConfig) (*Hub, error) {
 	}
 
 	sCfg := &ChainStateConfig{
-		DB:                      h.db,
-		SoloPool:                h.cfg.SoloPool,
-		PayDividends:            h.paymentMgr.payDividends,
-		GeneratePayments:        h.paymentMgr.generatePayments,
-		GetBlock:                h.getBlock,
-		PruneJobs:               h.pruneJobs,
-		PruneAcceptedWork:       h.pruneAcceptedWork,
-		PendingPaymentsAtHeight: h.pendingPaymentsAtHeight,
-		Cancel:                  h.cancel,
-		HubWg:                   h.wg,
+		DB:                 h.db,
+		SoloPool:           h.cfg.SoloPool,
+		PayDividends:       h.paymentMgr.payDividends,
+		ConfirmPayouts:     h.paymentMgr.confirmPayouts,
+		UnconfirmPayouts:   h.paymentMgr.unconfirmPayouts,
+		CreditShares:       h.paymentMgr.creditShares,
+		RecordBlock:        h.recordBlock,
+		Notify:             h.notify,
+		GeneratePayments:   h.paymentMgr.generatePayments,
+		CoinbaseReward:     h.paymentMgr.coinbaseReward,
+		GetBlock:           h.getBlock,
+		PruneJobs:          h.pruneJobs,
+		PruneAcceptedWork:  h.pruneAcceptedWork,
+		MatureAcceptedWork: h.matureAcceptedWork,
+		RevertRound:        h.paymentMgr.revertRound,
+		PruneRounds:        h.paymentMgr.pruneRounds,
+		StaleJobGrace:      h.cfg.StaleJobGrace,
+		Cancel:             h.cancel,
+		HubWg:              h.wg,
 	}
 	h.chainState = NewChainState(sCfg)
 
@@ -238,45 +600,91 @@ func NewHub(cancel context.CancelFunc, hcfg *HubConfig) (*Hub, error) {
 		log.Infof("Solo pool mode active.")
 	}
 
-	err = h.db.Update(func(tx *bolt.Tx) error {
-		mode := uint32(0)
-		if h.cfg.SoloPool {
-			mode = 1
-		}
-		return h.persistPoolMode(tx, mode)
-	})
+	mode := uint32(0)
+	if h.cfg.SoloPool {
+		mode = 1
+	}
+	err = h.db.PersistPoolMode(mode)
 	if err != nil {
 		return nil, err
 	}
 	return h, nil
 }
 
-// submitWork sends solved block data to the consensus daemon for evaluation.
+// submitWork sends solved block data to all healthy consensus daemons for
+// evaluation to minimize the risk of the block being orphaned. The work is
+// considered accepted if any of the daemons accepts it.
 func (h *Hub) submitWork(data *string) (bool, error) {
-	h.nodeConnMtx.Lock()
-	defer h.nodeConnMtx.Unlock()
-
-	if h.nodeConn == nil {
+	h.nodesMtx.Lock()
+	if len(h.nodes) == 0 {
+		h.nodesMtx.Unlock()
 		return false, MakeError(ErrOther, "node connection unset", nil)
 	}
+	nodes := make([]*node, 0, len(h.nodes))
+	nodes = append(nodes, h.nodes[h.activeNode])
+	for idx, n := range h.nodes {
+		if idx != h.activeNode && n.healthy {
+			nodes = append(nodes, n)
+		}
+	}
+	h.nodesMtx.Unlock()
 
-	return h.nodeConn.GetWorkSubmit(*data)
+	type submission struct {
+		accepted bool
+		err      error
+	}
+	results := make(chan submission, len(nodes))
+	for _, n := range nodes {
+		go func(n *node) {
+			start := time.Now()
+			var accepted bool
+			err := callNode(func() (func(), error) {
+				res, err := n.conn.GetWorkSubmit(*data)
+				return func() { accepted = res }, err
+			})
+			h.observeRPC(dcrdService, "getworksubmit", start)
+			if err != nil {
+				log.Errorf("unable to submit work to node %s: %v",
+					n.host, err)
+				results <- submission{err: err}
+				return
+			}
+			results <- submission{accepted: accepted}
+		}(n)
+	}
+
+	var accepted bool
+	var err error
+	var submitted bool
+	for range nodes {
+		sub := <-results
+		if sub.err != nil {
+			err = sub.err
+			continue
+		}
+		submitted = true
+		accepted = accepted || sub.accepted
+	}
+	if submitted {
+		if accepted {
+			h.metrics.add(blocksMetric, 1, "status", blockFound)
+		}
+		return accepted, nil
+	}
+	return false, err
 }
 
 // getWork fetches available work from the consensus daemon.
 func (h *Hub) getWork() (string, string, error) {
-	h.nodeConnMtx.Lock()
-	defer h.nodeConnMtx.Unlock()
-
-	if h.nodeConn == nil {
-		return "", "", MakeError(ErrOther, "node connection unset", nil)
-	}
-
-	work, err := h.nodeConn.GetWork()
+	var work *chainjson.GetWorkResult
+	err := h.withNode("getwork", func(conn NodeConnection) (func(), error) {
+		res, err := conn.GetWork()
+		return func() { work = res }, err
+	})
 	if err != nil {
 		return "", "", err
 	}
-	return work.Data, work.Target, err
+	return work.Data, work.Target, nil
 }
 
 // WithinLimit returns if a client is within its request limits.
@@ -301,14 +709,15 @@ func (h *Hub) AddPaymentRequest(addr string) error {
 
 // getBlock fetches the blocks associated with the provided block hash.
 func (h *Hub) getBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
-	h.nodeConnMtx.Lock()
-	defer h.nodeConnMtx.Unlock()
-
-	if h.nodeConn == nil {
-		return nil, MakeError(ErrOther, "node connection unset", nil)
+	var block *wire.MsgBlock
+	err := h.withNode("getblock", func(conn NodeConnection) (func(), error) {
+		res, err := conn.GetBlock(blockHash)
+		return func() { block = res }, err
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return h.nodeConn.GetBlock(blockHash)
+	return block, nil
 }
 
 // fetchHostConnections returns the client connection count for the
@@ -383,36 +792,91 @@ func (h *Hub) processWork(headerE string) {
 // Listen creates listeners for all supported pool clients.
 func (h *Hub) Listen() error {
 	for miner, port := range h.cfg.MinerPorts {
-		diffInfo, err := h.poolDiffs.fetchMinerDifficulty(miner)
+		err := h.addEndpoint(miner, port, false, false)
 		if err != nil {
 			return err
 		}
-		eCfg := &EndpointConfig{
-			ActiveNet:             h.cfg.ActiveNet,
-			DB:                    h.db,
-			SoloPool:              h.cfg.SoloPool,
-			Blake256Pad:           h.blake256Pad,
-			NonceIterations:       h.cfg.NonceIterations,
-			MaxConnectionsPerHost: h.cfg.MaxConnectionsPerHost,
-			HubWg:                 h.wg,
-			SubmitWork:            h.submitWork,
-			FetchCurrentWork:      h.chainState.fetchCurrentWork,
-			WithinLimit:           h.limiter.withinLimit,
-			AddConnection:         h.addConnection,
-			RemoveConnection:      h.removeConnection,
-			FetchHostConnections:  h.fetchHostConnections,
-			MaxGenTime:            h.cfg.MaxGenTime,
+	}
+
+	// Create the unified endpoint which identifies the miner type of its
+	// clients if configured.
+	if h.cfg.UnifiedPort != 0 {
+		err := h.addEndpoint(h.cfg.DefaultMiner, h.cfg.UnifiedPort, true, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the configured TLS endpoints alongside the plain ones.
+	for miner, port := range h.cfg.MinerTLSPorts {
+		err := h.addEndpoint(miner, port, false, true)
+		if err != nil {
+			return err
 		}
-		endpoint, err := NewEndpoint(eCfg, diffInfo, port, miner)
+	}
+	if h.cfg.UnifiedTLSPort != 0 {
+		err := h.addEndpoint(h.cfg.DefaultMiner, h.cfg.UnifiedTLSPort,
+			true, true)
 		if err != nil {
-			desc := fmt.Sprintf("unable to create %s listener", miner)
-			return MakeError(ErrOther, desc, err)
+			return err
 		}
-		h.endpoints = append(h.endpoints, endpoint)
 	}
 	return nil
 }
 
+// addEndpoint creates a stratum endpoint for the provided miner on the
+// provided port. The miner serves as the default for unidentified clients
+// when miner detection is enabled. Client connections are accepted over TLS
+// when useTLS is set.
+func (h *Hub) addEndpoint(miner string, port uint32, detectMiner bool, useTLS bool) error {
+	if useTLS && h.cfg.TLSConfig == nil {
+		desc := fmt.Sprintf("no TLS configuration provided for %s "+
+			"TLS listener", miner)
+		return MakeError(ErrOther, desc, nil)
+	}
+	diffInfo, err := h.poolDiffs.fetchMinerDifficulty(miner)
+	if err != nil {
+		return err
+	}
+	eCfg := &EndpointConfig{
+		ActiveNet:             h.cfg.ActiveNet,
+		DB:                    h.db,
+		SoloPool:              h.cfg.SoloPool,
+		Blake256Pad:           h.blake256Pad,
+		NonceIterations:       h.cfg.NonceIterations,
+		MaxConnectionsPerHost: h.cfg.MaxConnectionsPerHost,
+		HubWg:                 h.wg,
+		SubmitWork:            h.submitWork,
+		FetchCurrentWork:      h.chainState.fetchCurrentWork,
+		IsStaleJob:            h.chainState.isStaleJob,
+		RecordSubmission:      h.submissions.record,
+		WithinLimit:           h.limiter.withinLimit,
+		AddConnection:         h.addConnection,
+		RemoveConnection:      h.removeConnection,
+		FetchHostConnections:  h.fetchHostConnections,
+		MaxGenTime:            h.cfg.MaxGenTime,
+		DetectMiner:           detectMiner,
+		FetchMinerDifficulty:  h.poolDiffs.fetchMinerDifficulty,
+		RecordShare:           h.recordShare,
+		AddWorker:             h.addWorker,
+		RemoveWorker:          h.removeWorker,
+		RecordWorkerShare:     h.recordWorkerShare,
+		Notify:                h.notify,
+		AddRoundWork:          h.roundEffort.addWork,
+		CompleteRound:         h.roundEffort.complete,
+	}
+	if useTLS {
+		eCfg.TLSConfig = h.cfg.TLSConfig
+	}
+	endpoint, err := NewEndpoint(eCfg, diffInfo, port, miner)
+	if err != nil {
+		desc := fmt.Sprintf("unable to create %s listener", miner)
+		return MakeError(ErrOther, desc, err)
+	}
+	h.endpoints = append(h.endpoints, endpoint)
+	return nil
+}
+
 // CloseListeners terminates listeners created by endpoints of the hub. This
 // should only be used in the pool's shutdown process the hub is not running.
 func (h *Hub) CloseListeners() {
@@ -423,26 +887,54 @@ func (h *Hub) CloseListeners() {
 
 // CreateNotificationHandlers returns handlers for block and work notifications.
 func (h *Hub) CreateNotificationHandlers() *rpcclient.NotificationHandlers {
+	return h.createNotificationHandlers(func() bool { return true })
+}
+
+// CreateNodeNotificationHandlers returns handlers for block and work
+// notifications from the mining node of the provided host. Notifications
+// are ignored unless the node is the active mining node.
+func (h *Hub) CreateNodeNotificationHandlers(host string) *rpcclient.NotificationHandlers {
+	return h.createNotificationHandlers(func() bool {
+		return h.isActiveNode(host)
+	})
+}
+
+// createNotificationHandlers returns handlers for block and work
+// notifications, notifications are only processed if active returns true.
+func (h *Hub) createNotificationHandlers(active func() bool) *rpcclient.NotificationHandlers {
 	return &rpcclient.NotificationHandlers{
 		OnBlockConnected: func(headerB []byte, transactions [][]byte) {
+			if !active() {
+				return
+			}
 			h.chainState.connCh <- &blockNotification{
 				Header: headerB,
 				Done:   make(chan bool),
 			}
 		},
 		OnBlockDisconnected: func(headerB []byte) {
+			if !active() {
+				return
+			}
 			h.chainState.discCh <- &blockNotification{
 				Header: headerB,
 				Done:   make(chan bool),
 			}
 		},
 		OnWork: func(headerB []byte, target []byte, reason string) {
+			if !active() {
+				return
+			}
 			currWork := hex.EncodeToString(headerB)
 			switch reason {
 			case NewTxns:
 				h.chainState.setCurrentWork(currWork)
 
-			case NewParent, NewVotes:
+			case NewParent:
+				h.chainState.setParentWork(currWork, time.Now())
+				h.processWork(currWork)
+
+			case NewVotes:
 				h.chainState.setCurrentWork(currWork)
 				h.processWork(currWork)
 			}
@@ -468,9 +960,17 @@ func (h *Hub) HasClients() bool {
 }
 
 // PublishTransaction creates a transaction paying pool accounts for work done.
-func (h *Hub) PublishTransaction(payouts map[dcrutil.Address]dcrutil.Amount, targetAmt dcrutil.Amount) (string, error) {
-	if h.walletConn == nil {
-		return "", fmt.Errorf("wallet connnection unset")
+// The provided status function is called once the transaction has been
+// constructed and signed. A broken wallet connection is re-dialed before
+// the error is returned.
+func (h *Hub) PublishTransaction(payouts map[dcrutil.Address]dcrutil.Amount, targetAmt dcrutil.Amount, setStatus func(PayoutStatus)) (string, error) {
+	walletConn := h.fetchWalletConnection()
+	if walletConn == nil {
+		err := fmt.Errorf("wallet connnection unset")
+		if rErr := h.redialWallet(); rErr != nil {
+			log.Errorf("unable to re-dial wallet: %v", rErr)
+		}
+		return "", err
 	}
 
 	var total dcrutil.Amount
@@ -483,9 +983,11 @@ func (h *Hub) PublishTransaction(payouts map[dcrutil.Address]dcrutil.Amount, tar
 		RequiredConfirmations: 1,
 	}
 
-	balanceResp, err := h.walletConn.Balance(context.TODO(), balanceReq)
+	start := time.Now()
+	balanceResp, err := walletConn.Balance(context.TODO(), balanceReq)
+	h.observeRPC(walletService, "balance", start)
 	if err != nil {
-		return "", err
+		return "", h.handleWalletError(err)
 	}
 	spendable := dcrutil.Amount(balanceResp.Spendable)
 
@@ -512,24 +1014,32 @@ func (h *Hub) PublishTransaction(payouts map[dcrutil.Address]dcrutil.Amount, tar
 		OutputSelectionAlgorithm: walletrpc.ConstructTransactionRequest_ALL,
 		NonChangeOutputs:         outs,
 	}
-	constructTxResp, err := h.walletConn.ConstructTransaction(context.TODO(), constructTxReq)
+	start = time.Now()
+	constructTxResp, err := walletConn.ConstructTransaction(context.TODO(), constructTxReq)
+	h.observeRPC(walletService, "constructtransaction", start)
 	if err != nil {
-		return "", err
+		return "", h.handleWalletError(err)
 	}
+	setStatus(PayoutConstructed)
 	signTxReq := &walletrpc.SignTransactionRequest{
 		SerializedTransaction: constructTxResp.UnsignedTransaction,
 		Passphrase:            []byte(h.cfg.WalletPass),
 	}
-	signedTxResp, err := h.walletConn.SignTransaction(context.TODO(), signTxReq)
+	start = time.Now()
+	signedTxResp, err := walletConn.SignTransaction(context.TODO(), signTxReq)
+	h.observeRPC(walletService, "signtransaction", start)
 	if err != nil {
-		return "", err
+		return "", h.handleWalletError(err)
 	}
+	setStatus(PayoutSigned)
 	pubTxReq := &walletrpc.PublishTransactionRequest{
 		SignedTransaction: signedTxResp.Transaction,
 	}
-	pubTxResp, err := h.walletConn.PublishTransaction(context.TODO(), pubTxReq)
+	start = time.Now()
+	pubTxResp, err := walletConn.PublishTransaction(context.TODO(), pubTxReq)
+	h.observeRPC(walletService, "publishtransaction", start)
 	if err != nil {
-		return "", err
+		return "", h.handleWalletError(err)
 	}
 	txid, err := chainhash.NewHash(pubTxResp.TransactionHash)
 	if err != nil {
@@ -542,9 +1052,8 @@ func (h *Hub) PublishTransaction(payouts map[dcrutil.Address]dcrutil.Amount, tar
 func (h *Hub) backup(ctx context.Context) {
 	<-ctx.Done()
 	log.Tracef("backing up db.")
-	backupPath := filepath.Join(filepath.Dir(h.db.Path()), backupFile)
-	err := backup(h.db, backupPath)
-	if err != nil {
+	err := h.db.Backup(backupFile)
+	if err != nil && !IsError(err, ErrNotSupported) {
 		log.Errorf("unable to backup db: %v", err)
 	}
 	h.wg.Done()
@@ -553,16 +1062,19 @@ func (h *Hub) backup(ctx context.Context) {
 // shutdown tears down the hub and releases resources used.
 func (h *Hub) shutdown() {
 	if !h.cfg.SoloPool {
+		h.walletMtx.Lock()
 		if h.walletClose != nil {
 			h.walletClose()
 		}
+		h.walletMtx.Unlock()
 	}
-	h.nodeConnMtx.Lock()
-	if h.nodeConn != nil {
-		h.nodeConn.Shutdown()
+	h.nodesMtx.Lock()
+	for _, n := range h.nodes {
+		n.conn.Shutdown()
 	}
-	h.nodeConnMtx.Unlock()
+	h.nodesMtx.Unlock()
 	h.db.Close()
+	h.applyRestore()
 }
 
 // Run handles the process lifecycles of the pool hub.
@@ -577,6 +1089,23 @@ func (h *Hub) Run(ctx context.Context) {
 	go h.backup(ctx)
 	h.wg.Add(1)
 
+	if h.cfg.SnapshotInterval > 0 {
+		go h.snapshotMonitor(ctx)
+		h.wg.Add(1)
+	}
+
+	go h.monitorNodes(ctx)
+	h.wg.Add(1)
+
+	go h.historyMonitor(ctx)
+	h.wg.Add(1)
+
+	go h.workerMonitor(ctx)
+	h.wg.Add(1)
+
+	go h.notifier.run(ctx)
+	h.wg.Add(1)
+
 	h.wg.Wait()
 	h.shutdown()
 }
@@ -615,14 +1144,7 @@ func (h *Hub) FetchWorkQuotas() ([]*Quota, error) {
 	if h.cfg.SoloPool {
 		return nil, nil
 	}
-	var percentages map[string]*big.Rat
-	var err error
-	if h.cfg.PaymentMethod == PPS {
-		percentages, err = h.paymentMgr.PPSSharePercentages()
-	}
-	if h.cfg.PaymentMethod == PPLNS {
-		percentages, err = h.paymentMgr.PPLNSSharePercentages()
-	}
+	percentages, err := h.paymentMgr.sharePercentages()
 	if err != nil {
 		return nil, err
 	}
@@ -649,32 +1171,20 @@ func (h *Hub) AccountExists(accountID string) bool {
 
 // CSRFSecret fetches a persisted secret or generates a new one.
 func (h *Hub) CSRFSecret() ([]byte, error) {
-	var secret []byte
-	err := h.db.Update(func(tx *bolt.Tx) error {
-		pbkt := tx.Bucket(poolBkt)
-		if pbkt == nil {
-			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-			return MakeError(ErrBucketNotFound, desc, nil)
-		}
-		v := pbkt.Get(csrfSecret)
-		if v != nil {
-			secret = make([]byte, len(v))
-			copy(secret, v)
-			return nil
-		}
+	secret, err := h.db.FetchCSRFSecret()
+	if err == nil {
+		return secret, nil
+	}
+	if !IsError(err, ErrValueNotFound) {
+		return nil, err
+	}
 
-		var err error
-		secret = make([]byte, 32)
-		_, err = rand.Read(secret)
-		if err != nil {
-			return err
-		}
-		err = pbkt.Put(csrfSecret, secret)
-		if err != nil {
-			return err
-		}
-		return nil
-	})
+	secret = make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.PersistCSRFSecret(secret)
 	if err != nil {
 		return nil, err
 	}
@@ -683,12 +1193,140 @@ func (h *Hub) CSRFSecret() ([]byte, error) {
 
 // BackupDB streams a backup of the database over an http response.
 func (h *Hub) BackupDB(w http.ResponseWriter) error {
-	err := h.db.View(func(tx *bolt.Tx) error {
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Disposition", `attachment; filename="backup.db"`)
-		w.Header().Set("Content-Length", strconv.Itoa(int(tx.Size())))
-		_, err := tx.WriteTo(w)
-		return err
-	})
-	return err
+	return h.db.HTTPBackup(w)
+}
+
+// observeRPC records the latency of an RPC call to the provided service
+// started at the provided time.
+func (h *Hub) observeRPC(service string, method string, start time.Time) {
+	h.metrics.observe(rpcDurationMetric, time.Since(start), "service",
+		service, "method", method)
+}
+
+// recordShare records a share submission of the provided miner type with
+// the provided status and rejection reason.
+func (h *Hub) recordShare(miner string, status string, reason string) {
+	h.metrics.add(sharesMetric, 1, "miner", miner, "status", status,
+		"reason", reason)
+}
+
+// recordBlock records a block status update.
+func (h *Hub) recordBlock(status string) {
+	h.metrics.add(blocksMetric, 1, "status", status)
+}
+
+// recordPaymentRun records the duration and outcome of a payout run.
+func (h *Hub) recordPaymentRun(duration time.Duration, err error) {
+	h.metrics.observe(paymentRunMetric, duration)
+	if err != nil {
+		h.metrics.add(paymentFailuresMetric, 1)
+	}
+}
+
+// WriteMetrics refreshes the pool's client, hash rate and tx fee reserve
+// metrics and writes all metrics in the prometheus text exposition format.
+// The client and hash rate series are computed per request and replace the
+// previous series at once, so concurrent requests never write partially
+// refreshed metrics.
+func (h *Hub) WriteMetrics(w io.Writer) error {
+	clients := make(map[string]float64)
+	hashRates := make(map[string]*big.Rat)
+	for _, endpoint := range h.endpoints {
+		endpoint.clientsMtx.Lock()
+		endpointClients := make([]*Client, 0, len(endpoint.clients))
+		for _, c := range endpoint.clients {
+			endpointClients = append(endpointClients, c)
+		}
+		endpoint.clientsMtx.Unlock()
+
+		key := metricLabels("miner", endpoint.miner, "port",
+			strconv.FormatUint(uint64(endpoint.port), 10))
+		clients[key] = float64(len(endpointClients))
+		for _, c := range endpointClients {
+			miner := c.FetchMinerType()
+			rate, ok := hashRates[miner]
+			if !ok {
+				rate = new(big.Rat)
+				hashRates[miner] = rate
+			}
+			rate.Add(rate, c.FetchHashRate())
+		}
+	}
+	rates := make(map[string]float64, len(hashRates))
+	for miner, rate := range hashRates {
+		rates[metricLabels("miner", miner)], _ = rate.Float64()
+	}
+	h.metrics.replace(clientsMetric, clients)
+	h.metrics.replace(hashRateMetric, rates)
+	if !h.cfg.SoloPool {
+		h.metrics.set(txFeeReserveMetric,
+			float64(h.paymentMgr.fetchTxFeeReserve()))
+		h.metrics.set(poolBalanceMetric,
+			float64(h.paymentMgr.fetchPoolBalance()))
+	}
+	return h.metrics.Write(w)
+}
+
+// NodeState represents the state of a mining node connection of the hub.
+type NodeState struct {
+	Host    string `json:"host"`
+	Healthy bool   `json:"healthy"`
+	Active  bool   `json:"active"`
+}
+
+// EndpointState represents the state of a miner endpoint of the hub.
+type EndpointState struct {
+	Miner   string `json:"miner"`
+	Port    uint32 `json:"port"`
+	TLS     bool   `json:"tls"`
+	Clients int    `json:"clients"`
+}
+
+// HubState represents a snapshot of the state of the hub.
+type HubState struct {
+	LastWorkHeight    uint32          `json:"lastworkheight"`
+	LastPaymentHeight uint32          `json:"lastpaymentheight"`
+	ConnectedClients  int32           `json:"connectedclients"`
+	TxFeeReserve      dcrutil.Amount  `json:"txfeereserve"`
+	PoolBalance       dcrutil.Amount  `json:"poolbalance"`
+	Nodes             []NodeState     `json:"nodes"`
+	Endpoints         []EndpointState `json:"endpoints"`
+}
+
+// FetchHubState returns a snapshot of the state of the hub.
+func (h *Hub) FetchHubState() *HubState {
+	state := &HubState{
+		LastWorkHeight:   h.chainState.fetchLastWorkHeight(),
+		ConnectedClients: atomic.LoadInt32(&h.clients),
+		Nodes:            make([]NodeState, 0),
+		Endpoints:        make([]EndpointState, 0, len(h.endpoints)),
+	}
+	if !h.cfg.SoloPool {
+		state.LastPaymentHeight = h.paymentMgr.fetchLastPaymentHeight()
+		state.TxFeeReserve = h.paymentMgr.fetchTxFeeReserve()
+		state.PoolBalance = h.paymentMgr.fetchPoolBalance()
+	}
+
+	h.nodesMtx.Lock()
+	for idx, n := range h.nodes {
+		state.Nodes = append(state.Nodes, NodeState{
+			Host:    n.host,
+			Healthy: n.healthy,
+			Active:  idx == h.activeNode,
+		})
+	}
+	h.nodesMtx.Unlock()
+
+	for _, endpoint := range h.endpoints {
+		endpoint.clientsMtx.Lock()
+		clients := len(endpoint.clients)
+		endpoint.clientsMtx.Unlock()
+		state.Endpoints = append(state.Endpoints, EndpointState{
+			Miner:   endpoint.miner,
+			Port:    endpoint.port,
+			TLS:     endpoint.cfg.TLSConfig != nil,
+			Clients: clients,
+		})
+	}
+	return state
 }
@@ -18,11 +18,11 @@ import (
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrjson/v3"
 	"github.com/decred/dcrd/dcrutil/v2"
 	chainjson "github.com/decred/dcrd/rpc/jsonrpc/types/v2"
 	"github.com/decred/dcrd/wire"
 	"github.com/decred/dcrwallet/rpc/walletrpc"
-	bolt "go.etcd.io/bbolt"
 	"google.golang.org/grpc"
 )
 
@@ -104,6 +104,10 @@ func (t *tWalletConnection) PublishTransaction(context.Context, *walletrpc.Publi
 	}, nil
 }
 
+func (t *tWalletConnection) GetTransaction(context.Context, *walletrpc.GetTransactionRequest, ...grpc.CallOption) (*walletrpc.GetTransactionResponse, error) {
+	return &walletrpc.GetTransactionResponse{}, nil
+}
+
 type tNodeConnection struct{}
 
 func (t *tNodeConnection) GetWorkSubmit(sub string) (bool, error) {
@@ -204,7 +208,35 @@ func (t *tNodeConnection) NotifyBlocks() error {
 
 func (t *tNodeConnection) Shutdown() {}
 
-func testHub(t *testing.T, db *bolt.DB) {
+// tFailingNodeConnection is a node connection that fails all requests.
+type tFailingNodeConnection struct {
+	tNodeConnection
+	submissions int32
+}
+
+func (t *tFailingNodeConnection) GetWorkSubmit(sub string) (bool, error) {
+	atomic.AddInt32(&t.submissions, 1)
+	return false, fmt.Errorf("node unavailable")
+}
+
+func (t *tFailingNodeConnection) GetWork() (*chainjson.GetWorkResult, error) {
+	return nil, fmt.Errorf("node unavailable")
+}
+
+func (t *tFailingNodeConnection) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return nil, fmt.Errorf("node unavailable")
+}
+
+// tRejectingNodeConnection is a node connection that rejects block requests.
+type tRejectingNodeConnection struct {
+	tNodeConnection
+}
+
+func (t *tRejectingNodeConnection) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return nil, &dcrjson.RPCError{Code: -5, Message: "block not found"}
+}
+
+func testHub(t *testing.T, db *BoltDB) {
 	minPayment, err := dcrutil.NewAmount(2.0)
 	if err != nil {
 		t.Fatalf("[NewAmount] unexpected error: %v", err)
@@ -518,6 +550,87 @@ func testHub(t *testing.T, db *bolt.DB) {
 		t.Fatalf("emptyBucket error: %v", err)
 	}
 
+	// Ensure solved blocks are submitted to all healthy nodes.
+	failingConn := &tFailingNodeConnection{}
+	hub.nodesMtx.Lock()
+	hub.nodes = []*node{{host: "a", conn: failingConn, healthy: true}}
+	hub.activeNode = 0
+	hub.nodesMtx.Unlock()
+	hub.AddNodeConnection("b", nodeConn)
+	submission := "submission"
+	_, err = hub.submitWork(&submission)
+	if err != nil {
+		t.Fatalf("[submitWork] unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&failingConn.submissions) != 1 {
+		t.Fatalf("expected a submission to the failing node, got %d",
+			atomic.LoadInt32(&failingConn.submissions))
+	}
+
+	// Ensure requests fail over to a healthy node when the active node
+	// fails them.
+	_, _, err = hub.getWork()
+	if err != nil {
+		t.Fatalf("[getWork] unexpected error: %v", err)
+	}
+	if !hub.isActiveNode("b") {
+		t.Fatal("expected node b to be the active node")
+	}
+	hub.nodesMtx.Lock()
+	healthy := hub.nodes[0].healthy
+	hub.nodesMtx.Unlock()
+	if healthy {
+		t.Fatal("expected node a to be unhealthy")
+	}
+
+	// Ensure unhealthy nodes are not submitted solved blocks.
+	_, err = hub.submitWork(&submission)
+	if err != nil {
+		t.Fatalf("[submitWork] unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&failingConn.submissions) != 1 {
+		t.Fatalf("expected no submission to the unhealthy node, got %d",
+			atomic.LoadInt32(&failingConn.submissions))
+	}
+
+	// Ensure the health check keeps the healthy node active.
+	hub.checkNodes()
+	if !hub.isActiveNode("b") {
+		t.Fatal("expected node b to remain the active node")
+	}
+
+	// Ensure requests error when no node can fulfill them.
+	hub.nodesMtx.Lock()
+	hub.nodes = []*node{{host: "a", conn: failingConn, healthy: true}}
+	hub.activeNode = 0
+	hub.nodesMtx.Unlock()
+	_, _, err = hub.getWork()
+	if err == nil {
+		t.Fatal("[getWork] expected a node unavailable error")
+	}
+
+	// Ensure requests rejected by the active node do not fail over.
+	rejectingConn := &tRejectingNodeConnection{}
+	hub.nodesMtx.Lock()
+	hub.nodes = []*node{{host: "a", conn: rejectingConn, healthy: true}}
+	hub.activeNode = 0
+	hub.nodesMtx.Unlock()
+	hub.AddNodeConnection("b", nodeConn)
+	_, err = hub.getBlock(&chainhash.Hash{})
+	if _, ok := err.(*dcrjson.RPCError); !ok {
+		t.Fatalf("[getBlock] expected an rpc error, got %v", err)
+	}
+	if !hub.isActiveNode("a") {
+		t.Fatal("expected node a to remain the active node")
+	}
+	hub.nodesMtx.Lock()
+	healthy = hub.nodes[0].healthy
+	hub.nodesMtx.Unlock()
+	if !healthy {
+		t.Fatal("expected node a to remain healthy")
+	}
+	hub.SetNodeConnection(nodeConn)
+
 	backup := filepath.Join(filepath.Dir(db.Path()), backupFile)
 
 	cancel()
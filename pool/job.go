@@ -8,11 +8,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
-
-	bolt "go.etcd.io/bbolt"
 )
 
 // Job represents cached copies of work delivered to clients.
@@ -38,6 +37,37 @@ func GenerateJobID(height uint32) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// jobHeight returns the block height encoded in the provided job id.
+func jobHeight(id string) (uint32, error) {
+	if len(id) < 8 {
+		desc := fmt.Sprintf("expected job id of at least 8 characters, "+
+			"got %d", len(id))
+		return 0, MakeError(ErrWrongInputLength, desc, nil)
+	}
+	heightB, err := hex.DecodeString(id[:8])
+	if err != nil {
+		desc := fmt.Sprintf("unable to decode job id %s", id)
+		return 0, MakeError(ErrDecode, desc, err)
+	}
+	return binary.BigEndian.Uint32(heightB), nil
+}
+
+// jobCreatedOn returns the creation time in nanoseconds encoded in the
+// provided job id.
+func jobCreatedOn(id string) (int64, error) {
+	if len(id) < 24 {
+		desc := fmt.Sprintf("expected job id of at least 24 characters, "+
+			"got %d", len(id))
+		return 0, MakeError(ErrWrongInputLength, desc, nil)
+	}
+	nanoB, err := hex.DecodeString(id[8:24])
+	if err != nil {
+		desc := fmt.Sprintf("unable to decode job id %s", id)
+		return 0, MakeError(ErrDecode, desc, err)
+	}
+	return int64(binary.BigEndian.Uint64(nanoB)), nil
+}
+
 // NewJob creates a job instance.
 func NewJob(header string, height uint32) (*Job, error) {
 	id, err := GenerateJobID(height)
@@ -52,106 +82,94 @@ func NewJob(header string, height uint32) (*Job, error) {
 	}, nil
 }
 
-// fetchJobBucket is a helper function for getting the job bucket.
-func fetchJobBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(jobBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(jobBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-
-	return bkt, nil
-}
-
 // FetchJob fetches the job referenced by the provided id.
-func FetchJob(db *bolt.DB, id []byte) (*Job, error) {
-	var job Job
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchJobBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		v := bkt.Get(id)
-		if v == nil {
-			desc := fmt.Sprintf("no value found for job id %s", string(id))
-			return MakeError(ErrValueNotFound, desc, nil)
-		}
-		err = json.Unmarshal(v, &job)
-		return err
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &job, err
+func FetchJob(db Database, id []byte) (*Job, error) {
+	return db.FetchJob(string(id))
 }
 
 // Create persists the job to the database.
-func (job *Job) Create(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchJobBucket(tx)
-		if err != nil {
-			return err
-		}
-
-		jobBytes, err := json.Marshal(job)
-		if err != nil {
-			return err
-		}
-
-		return bkt.Put([]byte(job.UUID), jobBytes)
-	})
-	return err
+func (job *Job) Create(db Database) error {
+	return db.PersistJob(job)
 }
 
 // Update is not supported for jobs.
-func (job *Job) Update(db *bolt.DB) error {
+func (job *Job) Update(db Database) error {
 	desc := "job update not supported"
 	return MakeError(ErrNotSupported, desc, nil)
 }
 
 // Delete removes the associated job from the database.
-func (job *Job) Delete(db *bolt.DB) error {
-	return deleteEntry(db, jobBkt, []byte(job.UUID))
+func (job *Job) Delete(db Database) error {
+	return db.DeleteJob(job.UUID)
 }
 
 // pruneJobs removes all jobs with heights less than the provided height.
-func pruneJobs(db *bolt.DB, height uint32) error {
-	heightBE := heightToBigEndianBytes(height)
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchJobBucket(tx)
-		if err != nil {
-			return err
-		}
+func pruneJobs(db Database, height uint32) error {
+	return db.PruneJobs(height)
+}
 
-		toDelete := [][]byte{}
-		c := bkt.Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			height, err := hex.DecodeString(string(k[:8]))
-			if err != nil {
-				return err
-			}
-
-			if bytes.Compare(height, heightBE) < 0 {
-				toDelete = append(toDelete, k)
-			}
-		}
+// submissionSet tracks the share submissions of jobs delivered to clients
+// for duplicate share detection. Submissions are kept in memory, keyed by
+// job id, and pruned alongside their jobs.
+//
+// Since submissions are not persisted, submissions of jobs created before
+// the set was created, such as jobs persisted before a restart, cannot be
+// checked for duplicates and are rejected as possible duplicates.
+type submissionSet struct {
+	since int64
+	jobs  map[string]map[string]struct{}
+	mtx   sync.Mutex
+}
 
-		for _, entry := range toDelete {
-			err := bkt.Delete(entry)
-			if err != nil {
-				return err
-			}
-		}
+// newSubmissionSet initializes an empty share submission set which tracks
+// submissions of jobs created from now on.
+func newSubmissionSet() *submissionSet {
+	return &submissionSet{
+		since: time.Now().UnixNano(),
+		jobs:  make(map[string]map[string]struct{}),
+	}
+}
 
-		return nil
-	})
+// record records a share submission for the provided job. An
+// ErrShareExists error is returned if the submission has already been
+// recorded or if the job was created before the set.
+func (s *submissionSet) record(jobID string, extraNonce1 string, extraNonce2 string, nTime string, nonce string) error {
+	createdOn, err := jobCreatedOn(jobID)
+	if err != nil {
+		return err
+	}
+	if createdOn < s.since {
+		desc := fmt.Sprintf("job %s predates submission tracking, "+
+			"its submissions may already exist", jobID)
+		return MakeError(ErrShareExists, desc, nil)
+	}
+	id := strings.ToLower(extraNonce1 + extraNonce2 + nTime + nonce)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	submissions, ok := s.jobs[jobID]
+	if !ok {
+		submissions = make(map[string]struct{})
+		s.jobs[jobID] = submissions
+	}
+	if _, ok := submissions[id]; ok {
+		desc := fmt.Sprintf("submission %s of job %s already exists", id,
+			jobID)
+		return MakeError(ErrShareExists, desc, nil)
+	}
+	submissions[id] = struct{}{}
+	return nil
+}
 
-	return err
+// prune removes the share submissions of jobs with heights less than the
+// provided height.
+func (s *submissionSet) prune(height uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for jobID := range s.jobs {
+		// Recorded job ids are valid, the error can safely be ignored.
+		h, _ := jobHeight(jobID)
+		if h < height {
+			delete(s.jobs, jobID)
+		}
+	}
 }
@@ -3,11 +3,9 @@ package pool
 import (
 	"fmt"
 	"testing"
-
-	bolt "go.etcd.io/bbolt"
 )
 
-func persistJob(db *bolt.DB, header string, height uint32) (*Job, error) {
+func persistJob(db Database, header string, height uint32) (*Job, error) {
 	job, err := NewJob(header, height)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create job: %v", err)
@@ -19,7 +17,11 @@ func persistJob(db *bolt.DB, header string, height uint32) (*Job, error) {
 	return job, nil
 }
 
-func testJob(t *testing.T, db *bolt.DB) {
+func testJob(t *testing.T, db Database) {
+	// Share submissions are only tracked for jobs created after the
+	// submission set.
+	submissions := newSubmissionSet()
+
 	jobA, err := persistJob(db, "0700000093bdee7083c6e02147cf76724a685f0148636"+
 		"b2faf96353d1cbf5c0a954100007991153ad03eb0e31ead44b75ebc9f760870098431d4e6"+
 		"aa85e742cbad517ebd853b9bf059e8eeb91591e4a7d4005acc62e92bfd27b17309a5a41dd"+
@@ -69,12 +71,65 @@ func testJob(t *testing.T, db *bolt.DB) {
 
 	jobB.Height = 57
 
+	// Ensure share submissions can be recorded for a job.
+	err = submissions.record(jobA.UUID, "b072e5dc", "00000000",
+		"05ec705e", "116f0200")
+	if err != nil {
+		t.Fatalf("[record] unexpected error: %v", err)
+	}
+
+	// Ensure replayed share submissions are rejected.
+	err = submissions.record(jobA.UUID, "b072e5dc", "00000000",
+		"05EC705E", "116f0200")
+	if !IsError(err, ErrShareExists) {
+		t.Fatalf("expected a share exists error, got %v", err)
+	}
+
+	// Ensure the same share submission can be recorded for a different job.
+	err = submissions.record(jobB.UUID, "b072e5dc", "00000000",
+		"05ec705e", "116f0200")
+	if err != nil {
+		t.Fatalf("[record] unexpected error: %v", err)
+	}
+
+	// Ensure share submissions of jobs created before the submission set,
+	// such as jobs persisted before a restart, are rejected since they
+	// cannot be checked for duplicates.
+	restarted := newSubmissionSet()
+	err = restarted.record(jobB.UUID, "b072e5dc", "00000001",
+		"05ec705e", "116f0200")
+	if !IsError(err, ErrShareExists) {
+		t.Fatalf("expected a share exists error, got %v", err)
+	}
+	jobD, err := NewJob(jobB.Header, 57)
+	if err != nil {
+		t.Fatalf("[NewJob] unexpected error: %v", err)
+	}
+	err = restarted.record(jobD.UUID, "b072e5dc", "00000001",
+		"05ec705e", "116f0200")
+	if err != nil {
+		t.Fatalf("[record] unexpected error: %v", err)
+	}
+
 	// Ensure jobs can be pruned.
 	err = pruneJobs(db, 57)
 	if err != nil {
 		t.Fatalf("PruneJobs error: %v", err)
 	}
 
+	// Ensure share submissions of pruned jobs are pruned as well.
+	submissions.prune(57)
+	err = submissions.record(jobA.UUID, "b072e5dc", "00000000",
+		"05ec705e", "116f0200")
+	if err != nil {
+		t.Fatalf("expected pruned share submission, got %v", err)
+	}
+	err = submissions.record(jobB.UUID, "b072e5dc", "00000000",
+		"05ec705e", "116f0200")
+	if !IsError(err, ErrShareExists) {
+		t.Fatalf("expected a share exists error, got %v", err)
+	}
+
 	// Delete the last remaining job.
 	err = jobC.Delete(db)
 	if err != nil {
@@ -15,6 +15,7 @@ import (
 const (
 	GUIClient = iota
 	PoolClient
+	APIClient
 )
 
 const (
@@ -34,6 +35,12 @@ const (
 	// multiple requests, eg. pagination, establishing a websocket, AJAX form
 	// submissions.
 	guiBurst = 7
+	// apiTokenRate is the token refill rate for the api request bucket,
+	// per second.
+	apiTokenRate = 5
+	// apiBurst is the maximum token usage allowed per second, for api
+	// clients.
+	apiBurst = 10
 )
 
 // RateLimiter keeps connected clients within their allocated request rates.
@@ -58,6 +65,8 @@ func (r *RateLimiter) addRequestLimiter(ip string, clientType int) (*rate.Limite
 		limiter = rate.NewLimiter(guiTokenRate, guiBurst)
 	case PoolClient:
 		limiter = rate.NewLimiter(clientTokenRate, clientBurst)
+	case APIClient:
+		limiter = rate.NewLimiter(apiTokenRate, apiBurst)
 	default:
 		return nil, fmt.Errorf("unknown client type provided: %d", clientType)
 	}
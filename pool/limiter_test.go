@@ -40,6 +40,24 @@ func testLimiter(t *testing.T) {
 		t.Fatalf("expected a non-nil limiter")
 	}
 
+	apiLimiterIP := "10.0.0.1"
+
+	// Ensure the api limiter is within range.
+	if !limiter.withinLimit(apiLimiterIP, APIClient) {
+		t.Fatal("expected limiter to be within limit")
+	}
+
+	// Exhaust the api limiter range.
+	for limiter.withinLimit(apiLimiterIP, APIClient) {
+		continue
+	}
+
+	// Fetch the api limiter.
+	lmt = limiter.fetchLimiter(apiLimiterIP)
+	if lmt == nil {
+		t.Fatalf("expected a non-nil limiter")
+	}
+
 	unknownIP := "8.8.8.8"
 
 	// Ensure the limiter does not create a rate limiter
@@ -57,6 +75,7 @@ func testLimiter(t *testing.T) {
 	// Remove limiters.
 	limiter.removeLimiter(guiLimiterIP)
 	limiter.removeLimiter(poolLimiterIP)
+	limiter.removeLimiter(apiLimiterIP)
 
 	// Ensure the limiters have been removed.
 	lmt = limiter.fetchLimiter(guiLimiterIP)
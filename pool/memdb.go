@@ -0,0 +1,1070 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+)
+
+// MemDB is an in-memory implementation of the pool database, mainly for
+// testing purposes. Entries are json encoded and keyed as they are by the
+// bolt database, collections are iterated in key order.
+type MemDB struct {
+	buckets    map[string]map[string][]byte
+	poolMode   *uint32
+	csrfSecret []byte
+	state      []byte
+	roundWork  string
+	mtx        sync.Mutex
+}
+
+// Ensure MemDB implements the Database interface.
+var _ Database = (*MemDB)(nil)
+
+// NewMemDB creates an empty in-memory database.
+func NewMemDB() *MemDB {
+	db := new(MemDB)
+	db.reset()
+	return db
+}
+
+// reset removes all data of the database. This must be called with the
+// database mutex held.
+func (db *MemDB) reset() {
+	db.buckets = make(map[string]map[string][]byte, len(poolBuckets))
+	for _, bkt := range poolBuckets {
+		db.buckets[string(bkt)] = make(map[string][]byte)
+	}
+	db.poolMode = nil
+	db.csrfSecret = nil
+	db.state = nil
+	db.roundWork = ""
+}
+
+// bucket returns the provided collection. This must be called with the
+// database mutex held.
+func (db *MemDB) bucket(bucket []byte) (map[string][]byte, error) {
+	bkt, ok := db.buckets[string(bucket)]
+	if !ok {
+		desc := fmt.Sprintf("bucket %s not found", string(bucket))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return bkt, nil
+}
+
+// sortedKeys returns the keys of the provided collection in ascending
+// order.
+func sortedKeys(bkt map[string][]byte) []string {
+	keys := make([]string, 0, len(bkt))
+	for k := range bkt {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// get decodes the value of the provided key in the provided collection. An
+// ErrValueNotFound error is returned if no value exists.
+func (db *MemDB) get(bucket []byte, key string, value interface{}, desc string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	v, ok := bkt[key]
+	if !ok {
+		return MakeError(ErrValueNotFound, desc, nil)
+	}
+	return json.Unmarshal(v, value)
+}
+
+// put encodes the provided value with the provided key in the provided
+// collection.
+func (db *MemDB) put(bucket []byte, key string, value interface{}) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	bkt[key] = v
+	return nil
+}
+
+// delete removes the provided key from the provided collection.
+func (db *MemDB) delete(bucket []byte, key string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	delete(bkt, key)
+	return nil
+}
+
+// emptyBucket deletes all entries of the provided collection.
+func (db *MemDB) emptyBucket(bucket []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	_, err := db.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	db.buckets[string(bucket)] = make(map[string][]byte)
+	return nil
+}
+
+// Close is a no-op for the in-memory database.
+func (db *MemDB) Close() error {
+	return nil
+}
+
+// Backup is not supported for the in-memory database.
+func (db *MemDB) Backup(fileName string) error {
+	desc := "backup not supported by the in-memory database"
+	return MakeError(ErrNotSupported, desc, nil)
+}
+
+// HTTPBackup is not supported for the in-memory database.
+func (db *MemDB) HTTPBackup(w http.ResponseWriter) error {
+	desc := "backup not supported by the in-memory database"
+	return MakeError(ErrNotSupported, desc, nil)
+}
+
+// Snapshot is not supported for the in-memory database.
+func (db *MemDB) Snapshot(dir string, compress bool) (string, error) {
+	desc := "snapshot not supported by the in-memory database"
+	return "", MakeError(ErrNotSupported, desc, nil)
+}
+
+// Purge removes all existing data.
+func (db *MemDB) Purge() error {
+	db.mtx.Lock()
+	db.reset()
+	db.mtx.Unlock()
+	return nil
+}
+
+// FetchPoolMode fetches the persisted pool mode.
+func (db *MemDB) FetchPoolMode() (uint32, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if db.poolMode == nil {
+		return 0, MakeError(ErrValueNotFound, "pool mode not set", nil)
+	}
+	return *db.poolMode, nil
+}
+
+// PersistPoolMode saves the provided pool mode.
+func (db *MemDB) PersistPoolMode(mode uint32) error {
+	db.mtx.Lock()
+	db.poolMode = &mode
+	db.mtx.Unlock()
+	return nil
+}
+
+// FetchCSRFSecret fetches the persisted CSRF secret.
+func (db *MemDB) FetchCSRFSecret() ([]byte, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if db.csrfSecret == nil {
+		return nil, MakeError(ErrValueNotFound, "csrf secret not set", nil)
+	}
+	secret := make([]byte, len(db.csrfSecret))
+	copy(secret, db.csrfSecret)
+	return secret, nil
+}
+
+// PersistCSRFSecret saves the provided CSRF secret.
+func (db *MemDB) PersistCSRFSecret(secret []byte) error {
+	db.mtx.Lock()
+	db.csrfSecret = make([]byte, len(secret))
+	copy(db.csrfSecret, secret)
+	db.mtx.Unlock()
+	return nil
+}
+
+// putPaymentState saves the provided payment manager state. This must be
+// called with the database mutex held.
+func (db *MemDB) putPaymentState(state *PaymentState) error {
+	v, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	db.state = v
+	return nil
+}
+
+// fetchPaymentState fetches the persisted payment manager state. This must
+// be called with the database mutex held.
+func (db *MemDB) fetchPaymentState() (*PaymentState, error) {
+	state := &PaymentState{
+		MinedRewards: make(map[uint32]dcrutil.Amount),
+	}
+	if db.state == nil {
+		return state, nil
+	}
+	err := json.Unmarshal(db.state, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.MinedRewards == nil {
+		state.MinedRewards = make(map[uint32]dcrutil.Amount)
+	}
+	return state, nil
+}
+
+// FetchPaymentState fetches the persisted state of the payment manager.
+func (db *MemDB) FetchPaymentState() (*PaymentState, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	return db.fetchPaymentState()
+}
+
+// PersistPaymentState saves the provided payment manager state.
+func (db *MemDB) PersistPaymentState(state *PaymentState) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	return db.putPaymentState(state)
+}
+
+// FetchRoundWork fetches the work contributed to the pool since the last
+// block found by the pool.
+func (db *MemDB) FetchRoundWork() (*big.Rat, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	work := new(big.Rat)
+	if db.roundWork == "" {
+		return work, nil
+	}
+	_, ok := work.SetString(db.roundWork)
+	if !ok {
+		desc := fmt.Sprintf("invalid round work %s", db.roundWork)
+		return nil, MakeError(ErrParse, desc, nil)
+	}
+	return work, nil
+}
+
+// PersistRoundWork saves the provided round work.
+func (db *MemDB) PersistRoundWork(work *big.Rat) error {
+	db.mtx.Lock()
+	db.roundWork = work.String()
+	db.mtx.Unlock()
+	return nil
+}
+
+// FetchAccount fetches the account referenced by the provided id.
+func (db *MemDB) FetchAccount(id string) (*Account, error) {
+	var account Account
+	desc := fmt.Sprintf("no account found for id %s", id)
+	err := db.get(accountBkt, id, &account, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// PersistAccount saves the provided account.
+func (db *MemDB) PersistAccount(account *Account) error {
+	return db.put(accountBkt, account.UUID, account)
+}
+
+// DeleteAccount removes the account referenced by the provided id.
+func (db *MemDB) DeleteAccount(id string) error {
+	return db.delete(accountBkt, id)
+}
+
+// FetchJob fetches the job referenced by the provided id.
+func (db *MemDB) FetchJob(id string) (*Job, error) {
+	var job Job
+	desc := fmt.Sprintf("no value found for job id %s", id)
+	err := db.get(jobBkt, id, &job, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PersistJob saves the provided job.
+func (db *MemDB) PersistJob(job *Job) error {
+	return db.put(jobBkt, job.UUID, job)
+}
+
+// DeleteJob removes the job referenced by the provided id.
+func (db *MemDB) DeleteJob(id string) error {
+	return db.delete(jobBkt, id)
+}
+
+// pruneByHeight removes all entries of the provided collection with keys
+// prefixed by heights less than the provided height.
+func pruneByHeight(bkt map[string][]byte, height uint32) error {
+	heightBE := heightToBigEndianBytes(height)
+	for k := range bkt {
+		keyHeight, err := hex.DecodeString(k[:8])
+		if err != nil {
+			return err
+		}
+		if bytes.Compare(keyHeight, heightBE) < 0 {
+			delete(bkt, k)
+		}
+	}
+	return nil
+}
+
+// PruneJobs removes all jobs with heights less than the provided height.
+func (db *MemDB) PruneJobs(height uint32) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(jobBkt)
+	if err != nil {
+		return err
+	}
+	return pruneByHeight(bkt, height)
+}
+
+// PersistShare saves the provided share.
+func (db *MemDB) PersistShare(share *Share) error {
+	return db.put(shareBkt, string(nanoToBigEndianBytes(share.CreatedOn)),
+		share)
+}
+
+// decodeShares decodes the shares of the provided keys of the share
+// collection.
+func decodeShares(bkt map[string][]byte, keys []string) ([]*Share, error) {
+	shares := make([]*Share, 0, len(keys))
+	for _, k := range keys {
+		var share Share
+		err := json.Unmarshal(bkt[k], &share)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+	return shares, nil
+}
+
+// FetchShares fetches all shares created within the provided inclusive
+// bounds.
+func (db *MemDB) FetchShares(minNano int64, maxNano int64) ([]*Share, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(shareBkt)
+	if err != nil {
+		return nil, err
+	}
+	minKey := string(nanoToBigEndianBytes(minNano))
+	maxKey := string(nanoToBigEndianBytes(maxNano))
+	keys := make([]string, 0)
+	for _, k := range sortedKeys(bkt) {
+		if k >= minKey && k <= maxKey {
+			keys = append(keys, k)
+		}
+	}
+	return decodeShares(bkt, keys)
+}
+
+// FetchLastShares fetches shares from the most recent until the provided
+// function reports the window of shares is complete.
+func (db *MemDB) FetchLastShares(complete func(*Share) bool) ([]*Share, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(shareBkt)
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(bkt)
+	shares := make([]*Share, 0)
+	for i := len(keys) - 1; i >= 0; i-- {
+		var share Share
+		err := json.Unmarshal(bkt[keys[i]], &share)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+		if complete(&share) {
+			break
+		}
+	}
+	return shares, nil
+}
+
+// sharesBefore returns the keys of all shares created before the provided
+// minimum in ascending order.
+func sharesBefore(bkt map[string][]byte, minNano int64) []string {
+	minKey := string(nanoToBigEndianBytes(minNano))
+	keys := make([]string, 0)
+	for _, k := range sortedKeys(bkt) {
+		if k >= minKey {
+			break
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// PruneShares removes all shares created before the provided minimum and
+// saves the provided payment manager state.
+func (db *MemDB) PruneShares(minNano int64, state *PaymentState) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(shareBkt)
+	if err != nil {
+		return err
+	}
+	err = db.putPaymentState(state)
+	if err != nil {
+		return err
+	}
+	for _, k := range sharesBefore(bkt, minNano) {
+		delete(bkt, k)
+	}
+	return nil
+}
+
+// fetchRound fetches the archived round at the provided height. This must
+// be called with the database mutex held.
+func (db *MemDB) fetchRound(height uint32) (*Round, error) {
+	bkt, err := db.bucket(roundBkt)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := bkt[string(heightToBigEndianBytes(height))]
+	if !ok {
+		desc := fmt.Sprintf("no round found for height %d", height)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	var round Round
+	err = json.Unmarshal(v, &round)
+	if err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+// FetchRound fetches the archived round at the provided height.
+func (db *MemDB) FetchRound(height uint32) (*Round, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	return db.fetchRound(height)
+}
+
+// ArchiveRound archives all shares created before the provided minimum as
+// the shares of the provided round and removes them.
+func (db *MemDB) ArchiveRound(round *Round, minNano int64, state *PaymentState) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	sbkt, err := db.bucket(shareBkt)
+	if err != nil {
+		return err
+	}
+	rbkt, err := db.bucket(roundBkt)
+	if err != nil {
+		return err
+	}
+	keys := sharesBefore(sbkt, minNano)
+	round.Shares, err = decodeShares(sbkt, keys)
+	if err != nil {
+		return err
+	}
+	existing, err := db.fetchRound(round.Height)
+	if err != nil && !IsError(err, ErrValueNotFound) {
+		return err
+	}
+	if existing != nil {
+		round.Shares = append(existing.Shares, round.Shares...)
+		round.LastPaymentCreatedOn = existing.LastPaymentCreatedOn
+	}
+	v, err := json.Marshal(round)
+	if err != nil {
+		return err
+	}
+	err = db.putPaymentState(state)
+	if err != nil {
+		return err
+	}
+	rbkt[string(heightToBigEndianBytes(round.Height))] = v
+	for _, k := range keys {
+		delete(sbkt, k)
+	}
+	return nil
+}
+
+// RestoreRound restores the shares of the archived round at the provided
+// height and removes the round.
+func (db *MemDB) RestoreRound(height uint32) (*Round, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	round, err := db.fetchRound(height)
+	if err != nil {
+		return nil, err
+	}
+	state, err := db.fetchPaymentState()
+	if err != nil {
+		return nil, err
+	}
+	sbkt, err := db.bucket(shareBkt)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range round.Shares {
+		v, err := json.Marshal(share)
+		if err != nil {
+			return nil, err
+		}
+		sbkt[string(nanoToBigEndianBytes(share.CreatedOn))] = v
+	}
+	delete(db.buckets[string(roundBkt)],
+		string(heightToBigEndianBytes(height)))
+
+	// Restored shares are credited with the next round.
+	if state.LastPaymentCreatedOn > round.LastPaymentCreatedOn {
+		state.LastPaymentCreatedOn = round.LastPaymentCreatedOn
+		err := db.putPaymentState(state)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return round, nil
+}
+
+// PruneRounds removes all archived rounds with heights less than the
+// provided height.
+func (db *MemDB) PruneRounds(height uint32) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(roundBkt)
+	if err != nil {
+		return err
+	}
+	minKey := string(heightToBigEndianBytes(height))
+	for k := range bkt {
+		if k < minKey {
+			delete(bkt, k)
+		}
+	}
+	return nil
+}
+
+// FetchAcceptedWork fetches the accepted work referenced by the provided id.
+func (db *MemDB) FetchAcceptedWork(id string) (*AcceptedWork, error) {
+	var work AcceptedWork
+	desc := fmt.Sprintf("no value for key %s", id)
+	err := db.get(workBkt, id, &work, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &work, nil
+}
+
+// PersistAcceptedWork saves the provided work.
+func (db *MemDB) PersistAcceptedWork(work *AcceptedWork) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return err
+	}
+	if _, ok := bkt[work.UUID]; ok {
+		desc := fmt.Sprintf("work %s already exists", work.UUID)
+		return MakeError(ErrWorkExists, desc, nil)
+	}
+	v, err := json.Marshal(work)
+	if err != nil {
+		return err
+	}
+	bkt[work.UUID] = v
+	return nil
+}
+
+// UpdateAcceptedWork saves modifications to the provided work.
+func (db *MemDB) UpdateAcceptedWork(work *AcceptedWork) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return err
+	}
+	if _, ok := bkt[work.UUID]; !ok {
+		desc := fmt.Sprintf("work %s not found", work.UUID)
+		return MakeError(ErrWorkNotFound, desc, nil)
+	}
+	v, err := json.Marshal(work)
+	if err != nil {
+		return err
+	}
+	bkt[work.UUID] = v
+	return nil
+}
+
+// AcceptWork transitions the provided work to accepted and saves it.
+func (db *MemDB) AcceptWork(work *AcceptedWork) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return err
+	}
+	if v, ok := bkt[work.UUID]; ok {
+		var existing AcceptedWork
+		err := json.Unmarshal(v, &existing)
+		if err != nil {
+			return err
+		}
+		err = work.acceptOver(&existing)
+		if err != nil {
+			return err
+		}
+	}
+	work.setStatus(WorkAccepted)
+	v, err := json.Marshal(work)
+	if err != nil {
+		return err
+	}
+	bkt[work.UUID] = v
+	return nil
+}
+
+// DeleteAcceptedWork removes the accepted work referenced by the provided
+// id.
+func (db *MemDB) DeleteAcceptedWork(id string) error {
+	return db.delete(workBkt, id)
+}
+
+// ListAcceptedWork fetches all accepted work.
+func (db *MemDB) ListAcceptedWork() ([]*AcceptedWork, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(bkt)
+	minedWork := make([]*AcceptedWork, 0, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		var work AcceptedWork
+		err := json.Unmarshal(bkt[keys[i]], &work)
+		if err != nil {
+			return nil, err
+		}
+		minedWork = append(minedWork, &work)
+	}
+	return minedWork, nil
+}
+
+// workHeight returns the height encoded in the provided accepted work id.
+func workHeight(id string) (uint32, error) {
+	heightB, err := hex.DecodeString(id[:8])
+	if err != nil {
+		return 0, err
+	}
+	return bigEndianBytesToHeight(heightB), nil
+}
+
+// PruneAcceptedWork removes all submitted and accepted work not confirmed
+// as mined work with heights less than the provided height.
+func (db *MemDB) PruneAcceptedWork(height uint32) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return err
+	}
+	for k, v := range bkt {
+		h, err := workHeight(k)
+		if err != nil {
+			return err
+		}
+		if h >= height {
+			continue
+		}
+		var work AcceptedWork
+		err = json.Unmarshal(v, &work)
+		if err != nil {
+			return err
+		}
+		if work.isPrunable() {
+			delete(bkt, k)
+		}
+	}
+	return nil
+}
+
+// MatureAcceptedWork transitions all confirmed mined work with heights less
+// than or equal to the provided height to matured.
+func (db *MemDB) MatureAcceptedWork(height uint32) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workBkt)
+	if err != nil {
+		return err
+	}
+	for k, v := range bkt {
+		h, err := workHeight(k)
+		if err != nil {
+			return err
+		}
+		if h > height {
+			continue
+		}
+		var work AcceptedWork
+		err = json.Unmarshal(v, &work)
+		if err != nil {
+			return err
+		}
+		if work.Status != WorkConfirmed {
+			continue
+		}
+		work.setStatus(WorkMatured)
+		b, err := json.Marshal(work)
+		if err != nil {
+			return err
+		}
+		bkt[k] = b
+	}
+	return nil
+}
+
+// FetchPayment fetches the pending payment referenced by the provided id.
+func (db *MemDB) FetchPayment(id string) (*Payment, error) {
+	var payment Payment
+	desc := fmt.Sprintf("no payment found for id %s", id)
+	err := db.get(paymentBkt, id, &payment, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// PersistPayment saves the provided pending payment.
+func (db *MemDB) PersistPayment(payment *Payment) error {
+	return db.put(paymentBkt, string(payment.id()), payment)
+}
+
+// DeletePayment removes the pending payment referenced by the provided id.
+func (db *MemDB) DeletePayment(id string) error {
+	return db.delete(paymentBkt, id)
+}
+
+// decodePayments decodes all payments of the provided collection, in
+// descending key order if reverse is set.
+func decodePayments(bkt map[string][]byte, reverse bool) ([]*Payment, error) {
+	keys := sortedKeys(bkt)
+	if reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	}
+	payments := make([]*Payment, 0, len(keys))
+	for _, k := range keys {
+		var payment Payment
+		err := json.Unmarshal(bkt[k], &payment)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, &payment)
+	}
+	return payments, nil
+}
+
+// FetchPayments fetches all pending payments.
+func (db *MemDB) FetchPayments() ([]*Payment, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(paymentBkt)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayments(bkt, false)
+}
+
+// ArchivePayments removes the provided payments from the pending payments
+// and archives them.
+func (db *MemDB) ArchivePayments(payments []*Payment) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	pbkt, err := db.bucket(paymentBkt)
+	if err != nil {
+		return err
+	}
+	abkt, err := db.bucket(paymentArchiveBkt)
+	if err != nil {
+		return err
+	}
+	for _, pmt := range payments {
+		delete(pbkt, string(pmt.id()))
+		pmt.CreatedOn = time.Now().UnixNano()
+		v, err := json.Marshal(pmt)
+		if err != nil {
+			return err
+		}
+		abkt[string(pmt.id())] = v
+	}
+	return nil
+}
+
+// FetchArchivedPayments fetches all archived payments.
+func (db *MemDB) FetchArchivedPayments() ([]*Payment, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(paymentArchiveBkt)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayments(bkt, true)
+}
+
+// PersistPayoutAttempt saves the provided payout attempt.
+func (db *MemDB) PersistPayoutAttempt(attempt *PayoutAttempt) error {
+	return db.put(payoutBkt, attempt.UUID, attempt)
+}
+
+// FetchPayoutAttempts fetches all payout attempts.
+func (db *MemDB) FetchPayoutAttempts() ([]*PayoutAttempt, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(payoutBkt)
+	if err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(bkt)
+	attempts := make([]*PayoutAttempt, 0, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		var attempt PayoutAttempt
+		err := json.Unmarshal(bkt[keys[i]], &attempt)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, nil
+}
+
+// historyCollection returns the history collection of the provided
+// resolution. This must be called with the database mutex held.
+func (db *MemDB) historyCollection(resolution string) (map[string][]byte, error) {
+	key, err := historyBucket(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return db.bucket(key)
+}
+
+// PersistHistorySamples saves the provided samples at the provided
+// resolution.
+func (db *MemDB) PersistHistorySamples(resolution string, samples []*HistorySample) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.historyCollection(resolution)
+	if err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		v, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		bkt[string(sample.historyKey())] = v
+	}
+	return nil
+}
+
+// filterHistory decodes the samples of the provided collection with keys
+// matching the provided filter, in key order.
+func filterHistory(bkt map[string][]byte, filter func(key string) bool) ([]*HistorySample, error) {
+	samples := make([]*HistorySample, 0)
+	for _, k := range sortedKeys(bkt) {
+		if !filter(k) {
+			continue
+		}
+		var sample HistorySample
+		err := json.Unmarshal(bkt[k], &sample)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, &sample)
+	}
+	return samples, nil
+}
+
+// FetchHistory fetches the samples of the provided scope and id at the
+// provided resolution taken at or after the provided time.
+func (db *MemDB) FetchHistory(resolution string, scope string, id string, since int64) ([]*HistorySample, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.historyCollection(resolution)
+	if err != nil {
+		return nil, err
+	}
+	prefix := string(historySeriesKey(scope, id))
+	return filterHistory(bkt, func(k string) bool {
+		return strings.HasPrefix(k, prefix) &&
+			historySampleTime([]byte(k)) >= since
+	})
+}
+
+// FetchHistoryRange fetches the samples of all series at the provided
+// resolution taken within the provided start inclusive and end exclusive
+// times.
+func (db *MemDB) FetchHistoryRange(resolution string, start int64, end int64) ([]*HistorySample, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.historyCollection(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return filterHistory(bkt, func(k string) bool {
+		t := historySampleTime([]byte(k))
+		return t >= start && t < end
+	})
+}
+
+// PruneHistory removes all samples at the provided resolution taken before
+// the provided time.
+func (db *MemDB) PruneHistory(resolution string, before int64) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.historyCollection(resolution)
+	if err != nil {
+		return err
+	}
+	for k := range bkt {
+		if historySampleTime([]byte(k)) < before {
+			delete(bkt, k)
+		}
+	}
+	return nil
+}
+
+// FetchWorkers fetches all workers.
+func (db *MemDB) FetchWorkers() ([]*Worker, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workerBkt)
+	if err != nil {
+		return nil, err
+	}
+	workers := make([]*Worker, 0, len(bkt))
+	for _, k := range sortedKeys(bkt) {
+		var worker Worker
+		err := json.Unmarshal(bkt[k], &worker)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, &worker)
+	}
+	return workers, nil
+}
+
+// PersistWorkers saves the provided workers.
+func (db *MemDB) PersistWorkers(workers []*Worker) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workerBkt)
+	if err != nil {
+		return err
+	}
+	for _, worker := range workers {
+		v, err := json.Marshal(worker)
+		if err != nil {
+			return err
+		}
+		bkt[WorkerID(worker.AccountID, worker.Name)] = v
+	}
+	return nil
+}
+
+// DeleteWorkers removes the workers referenced by the provided ids.
+func (db *MemDB) DeleteWorkers(ids []string) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	bkt, err := db.bucket(workerBkt)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(bkt, id)
+	}
+	return nil
+}
+
+// FetchWebhook fetches the webhook referenced by the provided id.
+func (db *MemDB) FetchWebhook(id string) (*Webhook, error) {
+	var webhook Webhook
+	desc := fmt.Sprintf("no webhook found for id %s", id)
+	err := db.get(webhookBkt, id, &webhook, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// accountWebhooks fetches all webhooks registered by the provided account.
+// This must be called with the database mutex held.
+func (db *MemDB) accountWebhooks(accountID string) ([]*Webhook, error) {
+	bkt, err := db.bucket(webhookBkt)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]*Webhook, 0)
+	for _, k := range sortedKeys(bkt) {
+		var webhook Webhook
+		err := json.Unmarshal(bkt[k], &webhook)
+		if err != nil {
+			return nil, err
+		}
+		if webhook.AccountID == accountID {
+			webhooks = append(webhooks, &webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// PersistWebhook saves the provided webhook.
+func (db *MemDB) PersistWebhook(webhook *Webhook) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	registered, err := db.accountWebhooks(webhook.AccountID)
+	if err != nil {
+		return err
+	}
+	err = webhook.checkLimit(registered)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	db.buckets[string(webhookBkt)][webhook.UUID] = v
+	return nil
+}
+
+// DeleteWebhook removes the webhook referenced by the provided id.
+func (db *MemDB) DeleteWebhook(id string) error {
+	return db.delete(webhookBkt, id)
+}
+
+// FetchAccountWebhooks fetches all webhooks registered by the provided
+// account.
+func (db *MemDB) FetchAccountWebhooks(accountID string) ([]*Webhook, error) {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	return db.accountWebhooks(accountID)
+}
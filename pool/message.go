@@ -47,6 +47,30 @@ const (
 	ExtraNonce2Size = 4
 )
 
+// submittedExtraNonce2Size returns the size, in bytes, of the extraNonce2
+// value the provided miner submits in mining.submit messages based on the
+// mining.subscribe response sent to it.
+func submittedExtraNonce2Size(miner string) (int, error) {
+	switch miner {
+	case CPU, InnosiliconD9, ObeliskDCR1:
+		return ExtraNonce2Size, nil
+
+	// The Antminer DR3 and DR5 submit a 12-byte extraNonce comprised of
+	// an 8-byte extraNonce2 and the 4-byte extraNonce1.
+	case AntminerDR3, AntminerDR5:
+		return 12, nil
+
+	// The Whatsminer D1 submits an 8-byte extraNonce comprised of
+	// a 4-byte extraNonce2 and the 4-byte extraNonce1.
+	case WhatsminerD1:
+		return 8, nil
+
+	default:
+		desc := fmt.Sprintf("specified miner %s is unknown", miner)
+		return 0, MakeError(ErrOther, desc, nil)
+	}
+}
+
 // StratumError represents a stratum error message.
 type StratumError struct {
 	Code      uint32  `json:"code"`
@@ -665,6 +689,28 @@ func ParseSubmitWorkRequest(req *Request, miner string) (string, string, string,
 		return "", "", "", "", "", MakeError(ErrParse, desc, nil)
 	}
 
+	// Ensure the extraNonce2 submitted is of the size negotiated with the
+	// miner.
+	extraNonce2Size, err := submittedExtraNonce2Size(miner)
+	if err != nil {
+		return "", "", "", "", "", err
+	}
+	if len(extraNonce2) != extraNonce2Size*2 {
+		desc := fmt.Sprintf("expected %d-byte extraNonce2 for %s, got %s",
+			extraNonce2Size, miner, extraNonce2)
+		return "", "", "", "", "", MakeError(ErrWrongInputLength, desc, nil)
+	}
+
+	// Ensure the nTime and nonce submitted are 4 bytes each.
+	if len(nTime) != 8 {
+		desc := fmt.Sprintf("expected 4-byte nTime, got %s", nTime)
+		return "", "", "", "", "", MakeError(ErrWrongInputLength, desc, nil)
+	}
+	if len(nonce) != 8 {
+		desc := fmt.Sprintf("expected 4-byte nonce, got %s", nonce)
+		return "", "", "", "", "", MakeError(ErrWrongInputLength, desc, nil)
+	}
+
 	return workerName, jobID, extraNonce2, nTime, nonce, nil
 }
 
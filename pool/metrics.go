@@ -0,0 +1,211 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Metric types.
+	counterMetric = "counter"
+	gaugeMetric   = "gauge"
+	summaryMetric = "summary"
+
+	// Metric names.
+	clientsMetric         = "dcrpool_clients"
+	hashRateMetric        = "dcrpool_hashrate"
+	sharesMetric          = "dcrpool_shares_total"
+	blocksMetric          = "dcrpool_blocks_total"
+	paymentRunMetric      = "dcrpool_payment_run_duration_seconds"
+	paymentFailuresMetric = "dcrpool_payment_failures_total"
+	txFeeReserveMetric    = "dcrpool_tx_fee_reserve_atoms"
+	poolBalanceMetric     = "dcrpool_pool_balance_atoms"
+	rpcDurationMetric     = "dcrpool_rpc_duration_seconds"
+
+	// Share statuses.
+	shareAccepted      = "accepted"
+	shareRejected      = "rejected"
+	shareStale         = "stale"
+	shareLowDifficulty = "lowdifficulty"
+
+	// Share rejection reasons.
+	reasonLimitReached  = "limitreached"
+	reasonMalformed     = "malformed"
+	reasonUnknownJob    = "unknownjob"
+	reasonInvalidHeader = "invalidheader"
+	reasonTimestamp     = "timestamp"
+	reasonDuplicate     = "duplicate"
+	reasonInternal      = "internal"
+
+	// Block statuses.
+	blockFound     = "found"
+	blockConfirmed = "confirmed"
+	blockOrphaned  = "orphaned"
+
+	// RPC services.
+	dcrdService   = "dcrd"
+	walletService = "wallet"
+)
+
+// metricFamily represents a named metric and all of its labelled series.
+type metricFamily struct {
+	help   string
+	kind   string
+	series map[string]float64
+}
+
+// Metrics tracks pool telemetry and exposes it in the prometheus text
+// exposition format.
+type Metrics struct {
+	families map[string]*metricFamily
+	mtx      sync.Mutex
+}
+
+// NewMetrics creates a metrics registry with all pool metrics registered.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		families: make(map[string]*metricFamily),
+	}
+	m.register(clientsMetric, gaugeMetric,
+		"Connected clients per endpoint.")
+	m.register(hashRateMetric, gaugeMetric,
+		"Hash rate of connected clients per miner type in hashes per second.")
+	m.register(sharesMetric, counterMetric,
+		"Submitted shares by miner type, status and rejection reason.")
+	m.register(blocksMetric, counterMetric,
+		"Blocks found, confirmed and orphaned by the pool.")
+	m.register(paymentRunMetric, summaryMetric,
+		"Duration of payout runs in seconds.")
+	m.register(paymentFailuresMetric, counterMetric,
+		"Failed payout runs.")
+	m.register(txFeeReserveMetric, gaugeMetric,
+		"Transaction fee reserve of the pool in atoms.")
+	m.register(poolBalanceMetric, gaugeMetric,
+		"Pay per share pool balance, mined block rewards less credited shares, in atoms.")
+	m.register(rpcDurationMetric, summaryMetric,
+		"Latency of dcrd and wallet RPC calls in seconds.")
+	return m
+}
+
+// register adds a metric family with the provided name, type and help text.
+func (m *Metrics) register(name string, kind string, help string) {
+	m.families[name] = &metricFamily{
+		help:   help,
+		kind:   kind,
+		series: make(map[string]float64),
+	}
+}
+
+// metricLabels formats the provided label name and value pairs.
+func metricLabels(labels ...string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", labels[i],
+			strconv.Quote(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// update applies the provided function to the series of the named metric
+// identified by the provided suffix and labels.
+func (m *Metrics) update(name string, suffix string, labels []string, f func(float64) float64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	family, ok := m.families[name]
+	if !ok {
+		log.Errorf("metric %s not registered", name)
+		return
+	}
+	key := suffix + metricLabels(labels...)
+	family.series[key] = f(family.series[key])
+}
+
+// add increments the named metric by the provided value.
+func (m *Metrics) add(name string, value float64, labels ...string) {
+	m.update(name, "", labels, func(current float64) float64 {
+		return current + value
+	})
+}
+
+// set sets the named metric to the provided value.
+func (m *Metrics) set(name string, value float64, labels ...string) {
+	m.update(name, "", labels, func(float64) float64 {
+		return value
+	})
+}
+
+// observe records the provided duration for the named summary metric.
+func (m *Metrics) observe(name string, duration time.Duration, labels ...string) {
+	m.update(name, "_sum", labels, func(current float64) float64 {
+		return current + duration.Seconds()
+	})
+	m.update(name, "_count", labels, func(current float64) float64 {
+		return current + 1
+	})
+}
+
+// replace replaces all series of the named metric with the provided series,
+// keyed by their formatted labels.
+func (m *Metrics) replace(name string, series map[string]float64) {
+	m.mtx.Lock()
+	family, ok := m.families[name]
+	if ok {
+		family.series = series
+	}
+	m.mtx.Unlock()
+}
+
+// fetch returns the value of the named metric series.
+func (m *Metrics) fetch(name string, labels ...string) float64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	family, ok := m.families[name]
+	if !ok {
+		return 0
+	}
+	return family.series[metricLabels(labels...)]
+}
+
+// Write writes all metrics in the prometheus text exposition format.
+func (m *Metrics) Write(w io.Writer) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := m.families[name]
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name,
+			family.help, name, family.kind)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			_, err := fmt.Fprintf(w, "%s%s %s\n", name, key,
+				strconv.FormatFloat(family.series[key], 'g', -1, 64))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
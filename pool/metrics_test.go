@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.add(sharesMetric, 1, "miner", CPU, "status", shareAccepted, "reason", "")
+	m.add(sharesMetric, 1, "miner", CPU, "status", shareAccepted, "reason", "")
+	m.add(sharesMetric, 1, "miner", CPU, "status", shareRejected,
+		"reason", reasonDuplicate)
+	m.set(txFeeReserveMetric, 100)
+	m.set(txFeeReserveMetric, 50)
+	m.observe(rpcDurationMetric, time.Millisecond*500, "service",
+		dcrdService, "method", "getwork")
+	m.observe(rpcDurationMetric, time.Millisecond*250, "service",
+		dcrdService, "method", "getwork")
+	m.set(clientsMetric, 2, "miner", CPU, "port", "5550")
+
+	accepted := m.fetch(sharesMetric, "miner", CPU, "status", shareAccepted,
+		"reason", "")
+	if accepted != 2 {
+		t.Fatalf("expected an accepted share count of 2, got %v", accepted)
+	}
+
+	// Ensure replaced gauge series are not written.
+	m.replace(clientsMetric, map[string]float64{})
+
+	var buf bytes.Buffer
+	err := m.Write(&buf)
+	if err != nil {
+		t.Fatalf("[Write] unexpected error: %v", err)
+	}
+	out := buf.String()
+	expected := []string{
+		"# TYPE dcrpool_shares_total counter\n",
+		`dcrpool_shares_total{miner="cpu",status="accepted",reason=""} 2` + "\n",
+		`dcrpool_shares_total{miner="cpu",status="rejected",reason="duplicate"} 1` + "\n",
+		"# TYPE dcrpool_tx_fee_reserve_atoms gauge\n",
+		"dcrpool_tx_fee_reserve_atoms 50\n",
+		"# TYPE dcrpool_rpc_duration_seconds summary\n",
+		`dcrpool_rpc_duration_seconds_sum{service="dcrd",method="getwork"} 0.75` + "\n",
+		`dcrpool_rpc_duration_seconds_count{service="dcrd",method="getwork"} 2` + "\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected metrics output to contain %q, got %s",
+				line, out)
+		}
+	}
+	if strings.Contains(out, "dcrpool_clients{") {
+		t.Fatalf("expected replaced client metrics to not be written, "+
+			"got %s", out)
+	}
+}
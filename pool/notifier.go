@@ -0,0 +1,403 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+)
+
+const (
+	// EventWorkerOffline is sent when a worker has been offline beyond the
+	// configured threshold.
+	EventWorkerOffline = "worker.offline"
+	// EventHashRateDrop is sent when the hash rate of an account drops
+	// below its baseline by the configured percentage.
+	EventHashRateDrop = "account.hashratedrop"
+	// EventBlockFound is sent when work submitted by the pool is accepted
+	// by the network.
+	EventBlockFound = "block.found"
+	// EventBlockOrphaned is sent when a block mined by the pool is
+	// disconnected from the chain.
+	EventBlockOrphaned = "block.orphaned"
+	// EventPayoutSent is sent when a payout transaction is published.
+	EventPayoutSent = "payout.sent"
+	// EventPaymentFailure is sent when a payout attempt fails.
+	EventPaymentFailure = "payment.failure"
+
+	// WebhookEventHeader is the header of webhook deliveries identifying
+	// the event type.
+	WebhookEventHeader = "X-Dcrpool-Event"
+	// WebhookSignatureHeader is the header of webhook deliveries carrying
+	// the hex encoded HMAC-SHA256 of the payload keyed by the webhook
+	// secret.
+	WebhookSignatureHeader = "X-Dcrpool-Signature"
+
+	// notificationQueueSize is the number of webhook deliveries that can be
+	// queued before further deliveries are dropped.
+	notificationQueueSize = 256
+	// maxConcurrentDeliveries is the maximum number of webhook deliveries
+	// in flight.
+	maxConcurrentDeliveries = 8
+	// webhookTimeout is the timeout of a webhook delivery request.
+	webhookTimeout = time.Second * 10
+	// hashRateBaselineSamples is the number of hash rate samples the hash
+	// rate baseline of an account is averaged over.
+	hashRateBaselineSamples = 60
+)
+
+// Event represents a pool notification event. Events without an account id
+// are only delivered to the operator webhooks.
+type Event struct {
+	Type      string      `json:"type"`
+	AccountID string      `json:"accountid,omitempty"`
+	CreatedOn int64       `json:"createdon"`
+	Data      interface{} `json:"data"`
+}
+
+// NewEvent creates a notification event of the provided type and account.
+func NewEvent(eventType string, accountID string, data interface{}) *Event {
+	return &Event{
+		Type:      eventType,
+		AccountID: accountID,
+		CreatedOn: time.Now().UnixNano(),
+		Data:      data,
+	}
+}
+
+// HashRateDrop details the hash rate drop of an account.
+type HashRateDrop struct {
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Percent  float64 `json:"percent"`
+}
+
+// PayoutSent details the payout of an account.
+type PayoutSent struct {
+	Height        uint32         `json:"height"`
+	TransactionID string         `json:"transactionid"`
+	Amount        dcrutil.Amount `json:"amount"`
+}
+
+// NotifierConfig contains all of the configuration values which should be
+// provided when creating a new instance of Notifier.
+type NotifierConfig struct {
+	// DB represents the pool database.
+	DB Database
+	// URLs are the operator webhook urls receiving all events.
+	URLs []string
+	// Secret is the key deliveries to the operator webhook urls are
+	// signed with.
+	Secret string
+	// MaxRetries is the number of times a failed delivery is retried.
+	MaxRetries uint32
+	// RetryBackoff is the delay before retrying a failed delivery, it
+	// doubles with each retry.
+	RetryBackoff time.Duration
+	// HubWg represents the hub's waitgroup.
+	HubWg *sync.WaitGroup
+}
+
+// delivery represents a queued webhook delivery.
+type delivery struct {
+	url       string
+	secret    string
+	eventType string
+	payload   []byte
+	account   bool
+}
+
+// Notifier delivers pool notification events to the operator webhooks and
+// the webhooks registered by accounts.
+type Notifier struct {
+	cfg           *NotifierConfig
+	client        *http.Client
+	accountClient *http.Client
+	queue         chan *delivery
+}
+
+// noRedirect stops webhook deliveries from following redirects, the
+// redirect response is treated as a failed delivery.
+func noRedirect(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// NewNotifier creates a notifier. Deliveries to account webhooks are only
+// made to publicly routable addresses.
+func NewNotifier(ncfg *NotifierConfig) *Notifier {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhookDialControl,
+	}
+	return &Notifier{
+		cfg: ncfg,
+		client: &http.Client{
+			Timeout:       webhookTimeout,
+			CheckRedirect: noRedirect,
+		},
+		accountClient: &http.Client{
+			Timeout:       webhookTimeout,
+			CheckRedirect: noRedirect,
+			Transport: &http.Transport{
+				DialContext: dialer.DialContext,
+			},
+		},
+		queue: make(chan *delivery, notificationQueueSize),
+	}
+}
+
+// Notify queues the provided event for delivery to the operator webhooks
+// and the webhooks of the event account. Deliveries are dropped if the
+// queue is full.
+func (n *Notifier) Notify(event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("unable to encode %s event: %v", event.Type, err)
+		return
+	}
+	deliveries := make([]*delivery, 0, len(n.cfg.URLs))
+	for _, url := range n.cfg.URLs {
+		deliveries = append(deliveries, &delivery{
+			url:       url,
+			secret:    n.cfg.Secret,
+			eventType: event.Type,
+			payload:   payload,
+		})
+	}
+	if event.AccountID != "" {
+		webhooks, err := fetchAccountWebhooks(n.cfg.DB, event.AccountID)
+		if err != nil {
+			log.Errorf("unable to fetch webhooks of account %s: %v",
+				event.AccountID, err)
+		}
+		for _, webhook := range webhooks {
+			deliveries = append(deliveries, &delivery{
+				url:       webhook.URL,
+				secret:    webhook.Secret,
+				eventType: event.Type,
+				payload:   payload,
+				account:   true,
+			})
+		}
+	}
+	for _, d := range deliveries {
+		select {
+		case n.queue <- d:
+		default:
+			log.Errorf("notification queue full, dropping %s event "+
+				"delivery to %s", d.eventType, d.url)
+		}
+	}
+}
+
+// signPayload returns the hex encoded HMAC-SHA256 of the provided payload
+// keyed by the provided secret.
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts the provided delivery to its webhook url. Responses with a
+// non 2xx status code are considered failed deliveries.
+func (n *Notifier) deliver(ctx context.Context, d *delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.url,
+		bytes.NewReader(d.payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.eventType)
+	if d.secret != "" {
+		req.Header.Set(WebhookSignatureHeader, signPayload(d.secret, d.payload))
+	}
+	client := n.client
+	if d.account {
+		client = n.accountClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// deliverWithRetry delivers the provided delivery, retrying failed
+// deliveries with exponential backoff.
+func (n *Notifier) deliverWithRetry(ctx context.Context, d *delivery) {
+	backoff := n.cfg.RetryBackoff
+	for retries := uint32(0); ; retries++ {
+		err := n.deliver(ctx, d)
+		if err == nil {
+			return
+		}
+		if retries >= n.cfg.MaxRetries {
+			log.Errorf("unable to deliver %s event to %s after %d "+
+				"retries: %v", d.eventType, d.url, retries, err)
+			return
+		}
+		log.Debugf("unable to deliver %s event to %s, retrying in %s: %v",
+			d.eventType, d.url, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// run processes queued webhook deliveries. It must be run as a goroutine.
+func (n *Notifier) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentDeliveries)
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			n.cfg.HubWg.Done()
+			return
+
+		case d := <-n.queue:
+			select {
+			case <-ctx.Done():
+				continue
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(d *delivery) {
+				n.deliverWithRetry(ctx, d)
+				<-sem
+				wg.Done()
+			}(d)
+		}
+	}
+}
+
+// hashRateMonitor tracks the hash rate baselines of accounts to detect hash
+// rate drops. It is not safe for concurrent use.
+type hashRateMonitor struct {
+	baselines map[string]float64
+	dropped   map[string]struct{}
+}
+
+// newHashRateMonitor creates a hash rate monitor.
+func newHashRateMonitor() *hashRateMonitor {
+	return &hashRateMonitor{
+		baselines: make(map[string]float64),
+		dropped:   make(map[string]struct{}),
+	}
+}
+
+// check compares the provided account hash rates against the account
+// baselines and returns the accounts whose hash rate dropped by at least the
+// provided percentage. A drop is reported once until the account recovers.
+// Baselines are updated as a moving average of the provided hash rates.
+func (m *hashRateMonitor) check(rates map[string]float64, dropPercent float64) map[string]*HashRateDrop {
+	drops := make(map[string]*HashRateDrop)
+	for accountID := range rates {
+		if _, ok := m.baselines[accountID]; !ok {
+			m.baselines[accountID] = rates[accountID]
+		}
+	}
+	for accountID, baseline := range m.baselines {
+		current := rates[accountID]
+		threshold := baseline * (1 - dropPercent/100)
+		if baseline > 0 && current < threshold {
+			if _, ok := m.dropped[accountID]; !ok {
+				m.dropped[accountID] = struct{}{}
+				drops[accountID] = &HashRateDrop{
+					Baseline: baseline,
+					Current:  current,
+					Percent:  (baseline - current) / baseline * 100,
+				}
+			}
+		} else {
+			delete(m.dropped, accountID)
+		}
+
+		baseline += (current - baseline) / hashRateBaselineSamples
+		if current == 0 && baseline < 1 {
+			delete(m.baselines, accountID)
+			delete(m.dropped, accountID)
+			continue
+		}
+		m.baselines[accountID] = baseline
+	}
+	return drops
+}
+
+// accountHashRates returns the hash rates of the accounts of the provided
+// clients.
+func accountHashRates(clients []*Client) map[string]float64 {
+	rates := make(map[string]*big.Rat)
+	for _, c := range clients {
+		accountID := c.FetchAccountID()
+		if accountID == "" {
+			continue
+		}
+		rate, ok := rates[accountID]
+		if !ok {
+			rate = new(big.Rat)
+			rates[accountID] = rate
+		}
+		rate.Add(rate, c.FetchHashRate())
+	}
+	hashRates := make(map[string]float64, len(rates))
+	for accountID, rate := range rates {
+		hashRates[accountID], _ = rate.Float64()
+	}
+	return hashRates
+}
+
+// notify queues the provided event for delivery.
+func (h *Hub) notify(event *Event) {
+	h.notifier.Notify(event)
+}
+
+// notifyHashRateDrops notifies accounts of hash rate drops beyond the
+// configured percentage.
+func (h *Hub) notifyHashRateDrops() {
+	if h.cfg.HashRateDropPercent <= 0 {
+		return
+	}
+	rates := accountHashRates(h.FetchClients())
+	drops := h.hashRates.check(rates, h.cfg.HashRateDropPercent)
+	for accountID, drop := range drops {
+		h.notify(NewEvent(EventHashRateDrop, accountID, drop))
+	}
+}
+
+// notifyOfflineWorkers notifies accounts of workers offline beyond the
+// configured threshold.
+func (h *Hub) notifyOfflineWorkers(now time.Time) {
+	if h.cfg.WorkerOfflineTimeout <= 0 {
+		return
+	}
+	workers := h.workers.fetchOfflineWorkers(h.cfg.WorkerOfflineTimeout, now)
+	for _, worker := range workers {
+		h.notify(NewEvent(EventWorkerOffline, worker.AccountID, worker))
+	}
+}
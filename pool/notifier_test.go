@@ -0,0 +1,346 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+// signMessage returns the base64 encoded compact signature of the provided
+// message by the provided key, as created by decred wallets.
+func signMessage(key *secp256k1.PrivateKey, message string) (string, error) {
+	var buf bytes.Buffer
+	err := wire.WriteVarString(&buf, 0, signedMessagePrefix)
+	if err != nil {
+		return "", err
+	}
+	err = wire.WriteVarString(&buf, 0, message)
+	if err != nil {
+		return "", err
+	}
+	sig, err := secp256k1.SignCompact(key, chainhash.HashB(buf.Bytes()), true)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+func testNotifier(t *testing.T, db Database) {
+	// Resolve webhook hosts without network access, internal.example
+	// resolves to a private address.
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		if host == "internal.example" {
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		}
+		return []net.IP{net.ParseIP("93.184.216.34")}, nil
+	}
+
+	// Ensure only absolute http and https webhook urls are accepted.
+	for _, url := range []string{"", "ftp://example.com", "/hook", "http://"} {
+		_, err := NewWebhook(xID, url)
+		if err == nil {
+			t.Fatalf("expected an invalid webhook url error for %q", url)
+		}
+	}
+
+	// Ensure webhooks to internal addresses are rejected at registration
+	// and at delivery.
+	for _, url := range []string{"http://127.0.0.1/hook", "http://[::1]/hook",
+		"http://10.0.0.1/hook", "http://169.254.169.254/latest",
+		"https://internal.example/hook"} {
+		_, err := NewWebhook(xID, url)
+		if err == nil {
+			t.Fatalf("expected an internal webhook url error for %q", url)
+		}
+	}
+	for _, address := range []string{"127.0.0.1:80", "[::1]:443",
+		"192.168.1.1:443", "0.0.0.0:80"} {
+		err := webhookDialControl("tcp", address, nil)
+		if err == nil {
+			t.Fatalf("expected a dial error for internal address %s",
+				address)
+		}
+	}
+	err := webhookDialControl("tcp", "93.184.216.34:443", nil)
+	if err != nil {
+		t.Fatalf("[webhookDialControl] unexpected error: %v", err)
+	}
+
+	// Ensure webhook registrations require a signature of the webhook
+	// message by the account address.
+	params := chaincfg.SimNetParams()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("[GeneratePrivateKey] unexpected error: %v", err)
+	}
+	pubKeyAddr, err := dcrutil.NewAddressSecpPubKey(
+		key.PubKey().SerializeCompressed(), params)
+	if err != nil {
+		t.Fatalf("[NewAddressSecpPubKey] unexpected error: %v", err)
+	}
+	owner, err := persistAccount(db,
+		pubKeyAddr.AddressPubKeyHash().EncodeAddress(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookURL := "https://example.com/hook"
+	sig, err := signMessage(key, WebhookMessage(hookURL))
+	if err != nil {
+		t.Fatalf("[signMessage] unexpected error: %v", err)
+	}
+	err = checkWebhookOwner(db, params, owner.UUID, hookURL, sig)
+	if err != nil {
+		t.Fatalf("[checkWebhookOwner] unexpected error: %v", err)
+	}
+	err = checkWebhookOwner(db, params, owner.UUID,
+		"https://example.com/other", sig)
+	if !IsError(err, ErrInvalidSignature) {
+		t.Fatalf("expected an invalid signature error for another url, "+
+			"got %v", err)
+	}
+	err = checkWebhookOwner(db, params, xID, hookURL, sig)
+	if !IsError(err, ErrInvalidSignature) {
+		t.Fatalf("expected an invalid signature error for another "+
+			"account, got %v", err)
+	}
+	err = checkWebhookOwner(db, params, "unknown", hookURL, sig)
+	if !IsError(err, ErrValueNotFound) {
+		t.Fatalf("expected a value not found error for an unknown "+
+			"account, got %v", err)
+	}
+	err = checkWebhookOwner(db, params, owner.UUID, hookURL, "invalid")
+	if !IsError(err, ErrInvalidSignature) {
+		t.Fatalf("expected an invalid signature error, got %v", err)
+	}
+	err = db.DeleteAccount(owner.UUID)
+	if err != nil {
+		t.Fatalf("[DeleteAccount] unexpected error: %v", err)
+	}
+
+	// Ensure accounts are limited in the number of webhooks registered.
+	for i := 0; i < MaxAccountWebhooks; i++ {
+		webhook, err := NewWebhook(yID, "https://example.com/hook")
+		if err != nil {
+			t.Fatalf("[NewWebhook] unexpected error: %v", err)
+		}
+		err = webhook.Create(db)
+		if err != nil {
+			t.Fatalf("[Create] unexpected error: %v", err)
+		}
+	}
+	webhook, err := NewWebhook(yID, "https://example.com/hook")
+	if err != nil {
+		t.Fatalf("[NewWebhook] unexpected error: %v", err)
+	}
+	err = webhook.Create(db)
+	if err == nil {
+		t.Fatal("expected a maximum webhooks error")
+	}
+	webhooks, err := fetchAccountWebhooks(db, yID)
+	if err != nil {
+		t.Fatalf("[fetchAccountWebhooks] unexpected error: %v", err)
+	}
+	if len(webhooks) != MaxAccountWebhooks {
+		t.Fatalf("expected %d webhooks, got %d", MaxAccountWebhooks,
+			len(webhooks))
+	}
+	for _, webhook := range webhooks {
+		err := webhook.Delete(db)
+		if err != nil {
+			t.Fatalf("[Delete] unexpected error: %v", err)
+		}
+	}
+	_, err = FetchWebhook(db, webhooks[0].UUID)
+	if !IsError(err, ErrValueNotFound) {
+		t.Fatalf("expected a value not found error, got %v", err)
+	}
+
+	// Ensure events are delivered to the operator and account webhooks,
+	// failed deliveries are retried and deliveries are signed.
+	type received struct {
+		path      string
+		eventType string
+		event     Event
+	}
+	var mtx sync.Mutex
+	attempts := make(map[string]int)
+	recvCh := make(chan received, 5)
+	secrets := map[string]string{"/operator": "operatorsecret"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mtx.Lock()
+		attempts[r.URL.Path]++
+		count := attempts[r.URL.Path]
+		secret := secrets[r.URL.Path]
+		mtx.Unlock()
+		if r.URL.Path == "/operator" && count < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(WebhookSignatureHeader) != signPayload(secret, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event Event
+		err = json.Unmarshal(body, &event)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		recvCh <- received{
+			path:      r.URL.Path,
+			eventType: r.Header.Get(WebhookEventHeader),
+			event:     event,
+		}
+	}))
+	defer server.Close()
+
+	// The test server listens on a loopback address account webhooks are
+	// not allowed to deliver to, the webhook is created directly.
+	_, err = NewWebhook(xID, server.URL+"/account")
+	if err == nil {
+		t.Fatal("expected a loopback webhook url error")
+	}
+	webhook = &Webhook{
+		UUID:      "accountwebhook",
+		AccountID: xID,
+		URL:       server.URL + "/account",
+		Secret:    "accountsecret",
+		CreatedOn: time.Now().UnixNano(),
+	}
+	err = webhook.Create(db)
+	if err != nil {
+		t.Fatalf("[Create] unexpected error: %v", err)
+	}
+	mtx.Lock()
+	secrets["/account"] = webhook.Secret
+	mtx.Unlock()
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	n := NewNotifier(&NotifierConfig{
+		DB:           db,
+		URLs:         []string{server.URL + "/operator"},
+		Secret:       "operatorsecret",
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond * 10,
+		HubWg:        &wg,
+	})
+
+	// Ensure account deliveries to loopback addresses are refused.
+	err = n.deliver(ctx, &delivery{
+		url:       webhook.URL,
+		eventType: EventBlockFound,
+		payload:   []byte("{}"),
+		account:   true,
+	})
+	if err == nil {
+		t.Fatal("expected an account delivery to a loopback address to " +
+			"be refused")
+	}
+	n.accountClient = n.client
+
+	wg.Add(1)
+	go n.run(ctx)
+
+	n.Notify(NewEvent(EventBlockFound, xID, &AcceptedWork{Height: 10}))
+	paths := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case recv := <-recvCh:
+			if recv.eventType != EventBlockFound ||
+				recv.event.Type != EventBlockFound ||
+				recv.event.AccountID != xID {
+				t.Fatalf("unexpected event delivered to %s: %+v",
+					recv.path, recv.event)
+			}
+			paths[recv.path] = true
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for event deliveries")
+		}
+	}
+	if !paths["/operator"] || !paths["/account"] {
+		t.Fatalf("expected deliveries to the operator and account "+
+			"webhooks, got %v", paths)
+	}
+	mtx.Lock()
+	operatorAttempts := attempts["/operator"]
+	mtx.Unlock()
+	if operatorAttempts != 3 {
+		t.Fatalf("expected 3 delivery attempts to the operator webhook, "+
+			"got %d", operatorAttempts)
+	}
+
+	// Ensure events of other accounts are only delivered to the operator.
+	n.Notify(NewEvent(EventPayoutSent, yID, &PayoutSent{Height: 10}))
+	select {
+	case recv := <-recvCh:
+		if recv.path != "/operator" {
+			t.Fatalf("expected a delivery to the operator webhook, got %s",
+				recv.path)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for event delivery")
+	}
+	cancel()
+	wg.Wait()
+
+	// Ensure account hash rates are aggregated from clients.
+	rates := accountHashRates([]*Client{
+		{account: xID, hashRate: big.NewRat(100, 1)},
+		{account: xID, hashRate: big.NewRat(50, 1)},
+		{hashRate: big.NewRat(5, 1)},
+	})
+	if len(rates) != 1 || rates[xID] != 150 {
+		t.Fatalf("unexpected account hash rates: %v", rates)
+	}
+
+	// Ensure hash rate drops are reported once until the account recovers.
+	monitor := newHashRateMonitor()
+	drops := monitor.check(map[string]float64{xID: 100, yID: 100}, 50)
+	if len(drops) != 0 {
+		t.Fatalf("expected no hash rate drops, got %v", drops)
+	}
+	drops = monitor.check(map[string]float64{xID: 40, yID: 90}, 50)
+	if len(drops) != 1 || drops[xID] == nil || drops[xID].Percent != 60 {
+		t.Fatalf("expected a 60%% hash rate drop of account %s, got %v",
+			xID, drops)
+	}
+	drops = monitor.check(map[string]float64{yID: 90}, 50)
+	if len(drops) != 0 {
+		t.Fatalf("expected hash rate drops to be reported once, got %v",
+			drops)
+	}
+	monitor.check(map[string]float64{xID: 100, yID: 90}, 50)
+	drops = monitor.check(map[string]float64{xID: 10, yID: 90}, 50)
+	if drops[xID] == nil {
+		t.Fatalf("expected a repeated hash rate drop of account %s after "+
+			"recovering", xID)
+	}
+
+	// Empty the webhook bucket.
+	err = emptyBucket(db, webhookBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}
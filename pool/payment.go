@@ -6,12 +6,10 @@ package pool
 
 import (
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/decred/dcrd/dcrutil/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 // Payment represents an outstanding payment for a pool account.
@@ -44,85 +42,29 @@ func GeneratePaymentID(createdOnNano int64, height uint32, account string) []byt
 	return []byte(id)
 }
 
-// fetchPaymentBucket is a helper function for getting the payment bucket.
-func fetchPaymentBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(paymentBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(paymentBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	return bkt, nil
-}
-
-// fetchPaymentArchiveBucket is a helper function for getting the
-// payment archive bucket.
-func fetchPaymentArchiveBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(paymentArchiveBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(paymentArchiveBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	return bkt, nil
+// id returns the unique id of the payment.
+func (pmt *Payment) id() []byte {
+	return GeneratePaymentID(pmt.CreatedOn, pmt.Height, pmt.Account)
 }
 
 // GetPayment fetches the payment referenced by the provided id.
-func GetPayment(db *bolt.DB, id []byte) (*Payment, error) {
-	var payment Payment
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchPaymentBucket(tx)
-		if err != nil {
-			return err
-		}
-		v := bkt.Get(id)
-		if v == nil {
-			desc := fmt.Sprintf("no payment found for id %s", string(id))
-			return MakeError(ErrValueNotFound, desc, nil)
-		}
-		err = json.Unmarshal(v, &payment)
-		return err
-	})
-	if err != nil {
-		return nil, err
-	}
-	return &payment, err
+func GetPayment(db Database, id []byte) (*Payment, error) {
+	return db.FetchPayment(string(id))
 }
 
 // Create persists a payment to the database.
-func (pmt *Payment) Create(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchPaymentBucket(tx)
-		if err != nil {
-			return err
-		}
-		b, err := json.Marshal(pmt)
-		if err != nil {
-			return err
-		}
-		id := GeneratePaymentID(pmt.CreatedOn, pmt.Height, pmt.Account)
-		return bkt.Put(id, b)
-	})
-	return err
+func (pmt *Payment) Create(db Database) error {
+	return db.PersistPayment(pmt)
 }
 
 // Update persists the updated payment to the database.
-func (pmt *Payment) Update(db *bolt.DB) error {
+func (pmt *Payment) Update(db Database) error {
 	return pmt.Create(db)
 }
 
 // Delete purges the referenced pending payment from the database.
-func (pmt *Payment) Delete(db *bolt.DB) error {
-	id := GeneratePaymentID(pmt.CreatedOn, pmt.Height, pmt.Account)
-	return deleteEntry(db, paymentBkt, id)
+func (pmt *Payment) Delete(db Database) error {
+	return db.DeletePayment(string(pmt.id()))
 }
 
 // PaymentBundle is a convenience type for grouping payments for an account.
@@ -150,7 +92,7 @@ func (bundle *PaymentBundle) Total() dcrutil.Amount {
 
 // UpdateAsPaid updates all associated payments referenced by a payment bundle
 // as paid.
-func (bundle *PaymentBundle) UpdateAsPaid(db *bolt.DB, height uint32, txid string) {
+func (bundle *PaymentBundle) UpdateAsPaid(db Database, height uint32, txid string) {
 	for idx := 0; idx < len(bundle.Payments); idx++ {
 		bundle.Payments[idx].TransactionID = txid
 		bundle.Payments[idx].PaidOnHeight = height
@@ -159,36 +101,8 @@ func (bundle *PaymentBundle) UpdateAsPaid(db *bolt.DB, height uint32, txid strin
 
 // ArchivePayments removes all payments included in the payment bundle from the
 // payment bucket and archives them.
-func (bundle *PaymentBundle) ArchivePayments(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		pbkt, err := fetchPaymentBucket(tx)
-		if err != nil {
-			return err
-		}
-		abkt, err := fetchPaymentArchiveBucket(tx)
-		if err != nil {
-			return err
-		}
-		for _, pmt := range bundle.Payments {
-			id := GeneratePaymentID(pmt.CreatedOn, pmt.Height, pmt.Account)
-			err := pbkt.Delete(id)
-			if err != nil {
-				return err
-			}
-			pmt.CreatedOn = time.Now().UnixNano()
-			pmtBytes, err := json.Marshal(pmt)
-			if err != nil {
-				return err
-			}
-			id = GeneratePaymentID(pmt.CreatedOn, pmt.Height, pmt.Account)
-			err = abkt.Put(id, pmtBytes)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	return err
+func (bundle *PaymentBundle) ArchivePayments(db Database) error {
+	return db.ArchivePayments(bundle.Payments)
 }
 
 // generatePaymentBundles creates batched payments from the provided set of
@@ -213,36 +127,24 @@ func generatePaymentBundles(payments []*Payment) []*PaymentBundle {
 	return bundles
 }
 
-// filterPayments iterates the payments bucket, the result set is generated
+// filterPayments iterates the pending payments, the result set is generated
 // based on the provided filter.
-func filterPayments(db *bolt.DB, filter func(payment *Payment) bool) ([]*Payment, error) {
-	payments := make([]*Payment, 0)
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchPaymentBucket(tx)
-		if err != nil {
-			return err
-		}
-		cursor := bkt.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var payment Payment
-			err := json.Unmarshal(v, &payment)
-			if err != nil {
-				return err
-			}
-			if filter(&payment) {
-				payments = append(payments, &payment)
-			}
-		}
-		return nil
-	})
+func filterPayments(db Database, filter func(payment *Payment) bool) ([]*Payment, error) {
+	pmts, err := db.FetchPayments()
 	if err != nil {
 		return nil, err
 	}
+	payments := make([]*Payment, 0)
+	for _, payment := range pmts {
+		if filter(payment) {
+			payments = append(payments, payment)
+		}
+	}
 	return payments, nil
 }
 
 // fetchPendingPayments fetches all unpaid payments.
-func fetchPendingPayments(db *bolt.DB) ([]*Payment, error) {
+func fetchPendingPayments(db Database) ([]*Payment, error) {
 	filter := func(payment *Payment) bool {
 		return payment.PaidOnHeight == 0
 	}
@@ -255,7 +157,7 @@ func fetchPendingPayments(db *bolt.DB) ([]*Payment, error) {
 
 // fetchMaturePendingPayments fetches all payments past their estimated
 // maturities which have not been paid yet.
-func fetchMaturePendingPayments(db *bolt.DB, height uint32) ([]*Payment, error) {
+func fetchMaturePendingPayments(db Database, height uint32) ([]*Payment, error) {
 	filter := func(payment *Payment) bool {
 		return payment.PaidOnHeight == 0 &&
 			payment.EstimatedMaturity <= height
@@ -269,7 +171,7 @@ func fetchMaturePendingPayments(db *bolt.DB, height uint32) ([]*Payment, error)
 
 // fetchPendingPaymentsAtHeight fetches all pending payments at the provided
 // height.
-func fetchPendingPaymentsAtHeight(db *bolt.DB, height uint32) ([]*Payment, error) {
+func fetchPendingPaymentsAtHeight(db Database, height uint32) ([]*Payment, error) {
 	filter := func(payment *Payment) bool {
 		return payment.PaidOnHeight == 0 && payment.Height == height
 	}
@@ -280,9 +182,31 @@ func fetchPendingPaymentsAtHeight(db *bolt.DB, height uint32) ([]*Payment, error
 	return payments, nil
 }
 
+// fetchPaidPaymentsAtHeight fetches all paid and archived payments at the
+// provided height.
+func fetchPaidPaymentsAtHeight(db Database, height uint32) ([]*Payment, error) {
+	filter := func(payment *Payment) bool {
+		return payment.PaidOnHeight != 0 && payment.Height == height
+	}
+	payments, err := filterPayments(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := fetchArchivedPayments(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range archived {
+		if payment.Height == height {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
 // generatePaymentDetails generates kv pair of addresses and payment amounts
 // from the provided eligible payments.
-func generatePaymentDetails(db *bolt.DB, poolFeeAddr dcrutil.Address,
+func generatePaymentDetails(db Database, poolFeeAddr dcrutil.Address,
 	eligiblePmts []*PaymentBundle) (map[string]dcrutil.Amount, *dcrutil.Amount, error) {
 	var targetAmt dcrutil.Amount
 	pmts := make(map[string]dcrutil.Amount)
@@ -306,28 +230,8 @@ func generatePaymentDetails(db *bolt.DB, poolFeeAddr dcrutil.Address,
 
 // fetchArchivedPayments fetches all archived payments. List is ordered, most
 // recent comes first.
-func fetchArchivedPayments(db *bolt.DB) ([]*Payment, error) {
-	pmts := make([]*Payment, 0)
-	err := db.View(func(tx *bolt.Tx) error {
-		abkt, err := fetchPaymentArchiveBucket(tx)
-		if err != nil {
-			return err
-		}
-		c := abkt.Cursor()
-		for k, v := c.Last(); k != nil; k, v = c.Prev() {
-			var payment Payment
-			err := json.Unmarshal(v, &payment)
-			if err != nil {
-				return err
-			}
-			pmts = append(pmts, &payment)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return pmts, nil
+func fetchArchivedPayments(db Database) ([]*Payment, error) {
+	return db.FetchArchivedPayments()
 }
 
 // FetchPendingPayments fetches all unpaid payments.
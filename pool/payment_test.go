@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/decred/dcrd/dcrutil/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 // makePaymentBundle creates a new payment bundle.
@@ -22,7 +21,7 @@ func makePaymentBundle(account string, count uint32, paymentAmount dcrutil.Amoun
 	return bundle
 }
 
-func testGeneratePaymentDetails(t *testing.T, db *bolt.DB) {
+func testGeneratePaymentDetails(t *testing.T, db Database) {
 	count := uint32(3)
 	pmtAmt, _ := dcrutil.NewAmount(10.5)
 	bundleX := makePaymentBundle(xID, count, pmtAmt)
@@ -99,7 +98,7 @@ func testGeneratePaymentDetails(t *testing.T, db *bolt.DB) {
 	}
 }
 
-func testAccountPayments(t *testing.T, db *bolt.DB) {
+func testAccountPayments(t *testing.T, db Database) {
 	count := uint32(2)
 	amt, _ := dcrutil.NewAmount(5)
 
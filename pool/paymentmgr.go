@@ -1,7 +1,6 @@
 package pool
 
 import (
-	"encoding/binary"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -9,23 +8,35 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/dcrutil/v2"
 	"github.com/decred/dcrd/mempool/v3"
+	"github.com/decred/dcrd/wire"
 	txrules "github.com/decred/dcrwallet/wallet/v3/txrules"
-	bolt "go.etcd.io/bbolt"
 )
 
 type PaymentMgrConfig struct {
 	// DB represents the pool database.
-	DB *bolt.DB
+	DB Database
 	// ActiveNet represents the network being mined on.
 	ActiveNet *chaincfg.Params
 	// PoolFee represents the fee charged to participating accounts of the pool.
 	PoolFee float64
 	// LastNPeriod represents the period to source shares from when using the
-	// PPLNS payment scheme.
+	// PPLNS payment scheme with the period window.
 	LastNPeriod time.Duration
+	// PPLNSWindow represents the window of shares of the PPLNS payment
+	// scheme.
+	PPLNSWindow string
+	// LastNShares represents the number of shares to source when using the
+	// PPLNS payment scheme with the shares window.
+	LastNShares uint32
+	// LastNWork represents the multiple of the network difficulty to source
+	// shares worth of when using the PPLNS payment scheme with the work
+	// window.
+	LastNWork float64
 	// SoloPool represents the solo pool mining mode.
 	SoloPool bool
 	// PaymentMethod represents the payment scheme of the pool.
@@ -35,11 +46,32 @@ type PaymentMgrConfig struct {
 	MinPayment dcrutil.Amount
 	// PoolFeeAddrs represents the pool fee addresses of the pool.
 	PoolFeeAddrs []dcrutil.Address
+	// MiningAddrs represents the addresses the pool mines to.
+	MiningAddrs []dcrutil.Address
 	// MaxTxFeeReserve represents the maximum value the tx free reserve can be.
 	MaxTxFeeReserve dcrutil.Amount
 	// PublishTransaction generates a transaction from the provided payouts
-	// and publishes it.
-	PublishTransaction func(map[dcrutil.Address]dcrutil.Amount, dcrutil.Amount) (string, error)
+	// and publishes it. The provided status function is called as the
+	// transaction progresses through construction and signing.
+	PublishTransaction func(map[dcrutil.Address]dcrutil.Amount, dcrutil.Amount, func(PayoutStatus)) (string, error)
+	// GetBlock fetches the block associated with the provided block hash.
+	GetBlock func(*chainhash.Hash) (*wire.MsgBlock, error)
+	// RecordPaymentRun records the duration and outcome of a payout run.
+	RecordPaymentRun func(time.Duration, error)
+	// Notify sends the provided notification event.
+	Notify func(*Event)
+}
+
+// PaymentState represents the persisted state of the payment manager.
+type PaymentState struct {
+	LastPaymentHeight    uint32
+	LastPaymentPaidOn    uint64
+	LastPaymentCreatedOn uint64
+	TxFeeReserve         dcrutil.Amount
+	PoolBalance          dcrutil.Amount
+	// MinedRewards represents the rewards of recently mined blocks credited
+	// to the pool balance, keyed by height.
+	MinedRewards map[uint32]dcrutil.Amount
 }
 
 // PaymentMgr handles generating shares and paying out dividends to
@@ -48,10 +80,19 @@ type PaymentMgr struct {
 	lastPaymentHeight    uint32 // update atomically.
 	lastPaymentPaidOn    uint64 // update atomically.
 	lastPaymentCreatedOn uint64 // update atomically.
+	payoutFailures       uint32 // update atomically.
+	nextPayoutHeight     uint32 // update atomically.
 
 	cfg             *PaymentMgrConfig
+	schemes         map[string]PaymentScheme
+	payoutScripts   [][]byte
 	txFeeReserve    dcrutil.Amount
 	txFeeReserveMtx sync.RWMutex
+	poolBalance     dcrutil.Amount
+	minedRewards    map[uint32]dcrutil.Amount
+	poolBalanceMtx  sync.RWMutex
+	netDiff         *big.Rat
+	netDiffMtx      sync.RWMutex
 	paymentReqs     map[string]struct{}
 	paymentReqsMtx  sync.RWMutex
 }
@@ -61,30 +102,64 @@ func NewPaymentMgr(pCfg *PaymentMgrConfig) (*PaymentMgr, error) {
 	pm := &PaymentMgr{
 		cfg:          pCfg,
 		txFeeReserve: dcrutil.Amount(0),
+		minedRewards: make(map[uint32]dcrutil.Amount),
 		paymentReqs:  make(map[string]struct{}),
 	}
+	subsidies := standalone.NewSubsidyCache(pCfg.ActiveNet)
+	pm.schemes = map[string]PaymentScheme{
+		PROP:  &propScheme{pm: pm},
+		PPS:   &ppsScheme{pm: pm, subsidies: subsidies},
+		FPPS:  &ppsScheme{pm: pm, subsidies: subsidies, fullPay: true},
+		PPLNS: &pplnsScheme{pm: pm},
+	}
+	scripts, err := payoutScripts(pCfg.MiningAddrs)
+	if err != nil {
+		return nil, err
+	}
+	pm.payoutScripts = scripts
 	rand.Seed(time.Now().UnixNano())
-	err := pm.cfg.DB.Update(func(tx *bolt.Tx) error {
-		err := pm.loadLastPaymentHeight(tx)
-		if err != nil {
-			return err
-		}
-		err = pm.loadLastPaymentPaidOn(tx)
-		if err != nil {
-			return err
-		}
-		err = pm.loadLastPaymentCreatedOn(tx)
-		if err != nil {
-			return err
-		}
-		return pm.loadTxFeeReserve(tx)
-	})
+	err = pm.loadState()
 	if err != nil {
 		return nil, err
 	}
+
+	// Resume the payout retry backoff if the last payout attempt failed.
+	attempt, err := fetchLastPayoutAttempt(pm.cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+	if attempt != nil && attempt.Status == PayoutFailed {
+		atomic.StoreUint32(&pm.payoutFailures, attempt.Failures)
+		atomic.StoreUint32(&pm.nextPayoutHeight, attempt.NextAttemptHeight)
+	}
 	return pm, nil
 }
 
+// coinbaseReward returns the reward paid to the pool mining addresses by the
+// coinbase of the provided block.
+func (pm *PaymentMgr) coinbaseReward(block *wire.MsgBlock) (dcrutil.Amount, error) {
+	return coinbaseReward(block, pm.payoutScripts)
+}
+
+// recordPayoutFailure marks the provided payout attempt as failed and
+// schedules the next payout attempt after the retry backoff.
+func (pm *PaymentMgr) recordPayoutFailure(attempt *PayoutAttempt, height uint32, pErr error) {
+	failures := atomic.AddUint32(&pm.payoutFailures, 1)
+	nextHeight := height + payoutRetryBackoff(failures)
+	atomic.StoreUint32(&pm.nextPayoutHeight, nextHeight)
+	attempt.Status = PayoutFailed
+	attempt.Error = pErr.Error()
+	attempt.Failures = failures
+	attempt.NextAttemptHeight = nextHeight
+	err := attempt.Update(pm.cfg.DB)
+	if err != nil {
+		log.Errorf("unable to persist failed payout attempt: %v", err)
+	}
+	log.Errorf("payout attempt at height #%d failed (%d consecutive "+
+		"failures), retrying at height #%d: %v", height, failures,
+		nextHeight, pErr)
+}
+
 // setLastPaymentHeight updates the last payment height.
 func (pm *PaymentMgr) setLastPaymentHeight(height uint32) {
 	atomic.StoreUint32(&pm.lastPaymentHeight, height)
@@ -95,38 +170,6 @@ func (pm *PaymentMgr) fetchLastPaymentHeight() uint32 {
 	return atomic.LoadUint32(&pm.lastPaymentHeight)
 }
 
-// persistLastPaymentHeight saves the last payment height to the db.
-func (pm *PaymentMgr) persistLastPaymentHeight(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	height := atomic.LoadUint32(&pm.lastPaymentHeight)
-	b := make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, height)
-	err := pbkt.Put(lastPaymentHeight, b)
-	return err
-}
-
-// loadLastPaymentHeight fetches the last payment height from the db.
-func (pm *PaymentMgr) loadLastPaymentHeight(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	lastPaymentHeightB := pbkt.Get(lastPaymentHeight)
-	if lastPaymentHeightB == nil {
-		pm.setLastPaymentHeight(0)
-		b := make([]byte, 4)
-		binary.LittleEndian.PutUint32(b, 0)
-		return pbkt.Put(lastPaymentHeight, b)
-	}
-	pm.setLastPaymentHeight(binary.LittleEndian.Uint32(lastPaymentHeightB))
-	return nil
-}
-
 // setLastPaymentPaidOn updates the last payment paid on time.
 func (pm *PaymentMgr) setLastPaymentPaidOn(time uint64) {
 	atomic.StoreUint64(&pm.lastPaymentPaidOn, time)
@@ -137,41 +180,6 @@ func (pm *PaymentMgr) fetchLastPaymentPaidOn() uint64 {
 	return atomic.LoadUint64(&pm.lastPaymentPaidOn)
 }
 
-// persistLastPaymentPaidOn saves the last payment paid on time to the db.
-func (pm *PaymentMgr) persistLastPaymentPaidOn(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	return pbkt.Put(lastPaymentPaidOn,
-		nanoToBigEndianBytes(int64(pm.lastPaymentPaidOn)))
-}
-
-// bigEndianBytesToNano returns nanosecond time from the provided
-// big endian bytes.
-func bigEndianBytesToNano(b []byte) uint64 {
-	return binary.BigEndian.Uint64(b)
-}
-
-// loadLastPaymentPaidOn fetches the last payment paid on time from the db.
-func (pm *PaymentMgr) loadLastPaymentPaidOn(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	lastPaymentPaidOnB := pbkt.Get(lastPaymentPaidOn)
-	if lastPaymentPaidOnB == nil {
-		pm.setLastPaymentPaidOn(0)
-		b := make([]byte, 8)
-		binary.LittleEndian.PutUint64(b, 0)
-		return pbkt.Put(lastPaymentPaidOn, b)
-	}
-	pm.setLastPaymentPaidOn(bigEndianBytesToNano(lastPaymentPaidOnB))
-	return nil
-}
-
 // setLastPaymentCreatedOn updates the last payment created on time.
 func (pm *PaymentMgr) setLastPaymentCreatedOn(time uint64) {
 	atomic.StoreUint64(&pm.lastPaymentCreatedOn, time)
@@ -182,35 +190,6 @@ func (pm *PaymentMgr) fetchLastPaymentCreatedOn() uint64 {
 	return atomic.LoadUint64(&pm.lastPaymentCreatedOn)
 }
 
-// persistLastPaymentCreatedOn saves the last payment created on time to the db.
-func (pm *PaymentMgr) persistLastPaymentCreatedOn(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	return pbkt.Put(lastPaymentCreatedOn,
-		nanoToBigEndianBytes(int64(pm.lastPaymentCreatedOn)))
-}
-
-// loadLastPaymentCreaedOn fetches the last payment created on time from the db.
-func (pm *PaymentMgr) loadLastPaymentCreatedOn(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	lastPaymentCreatedOnB := pbkt.Get(lastPaymentCreatedOn)
-	if lastPaymentCreatedOnB == nil {
-		pm.setLastPaymentCreatedOn(0)
-		b := make([]byte, 8)
-		binary.LittleEndian.PutUint64(b, 0)
-		return pbkt.Put(lastPaymentCreatedOn, b)
-	}
-	pm.setLastPaymentCreatedOn(bigEndianBytesToNano(lastPaymentCreatedOnB))
-	return nil
-}
-
 // setTxFeeReserve updates the tx fee reserve.
 func (pm *PaymentMgr) setTxFeeReserve(amt dcrutil.Amount) {
 	pm.txFeeReserveMtx.Lock()
@@ -225,37 +204,88 @@ func (pm *PaymentMgr) fetchTxFeeReserve() dcrutil.Amount {
 	return pm.txFeeReserve
 }
 
-// persistTxFeeReserve saves the tx fee reserve to the db.
-func (pm *PaymentMgr) persistTxFeeReserve(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
-	}
-	b := make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, uint32(pm.txFeeReserve))
-	return pbkt.Put(txFeeReserve, b)
+// fetchPoolBalance fetches the pool balance.
+func (pm *PaymentMgr) fetchPoolBalance() dcrutil.Amount {
+	pm.poolBalanceMtx.RLock()
+	defer pm.poolBalanceMtx.RUnlock()
+	return pm.poolBalance
 }
 
-// loadTxFeeReserve fetches the tx fee reserve from the db.
-func (pm *PaymentMgr) loadTxFeeReserve(tx *bolt.Tx) error {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return MakeError(ErrBucketNotFound, desc, nil)
+// creditPoolBalance adds the reward of the block mined by the pool at the
+// provided height to the pool balance. Rewards are tracked until they
+// mature, like archived rounds, so orphaned blocks can be reverted from the
+// pool balance.
+func (pm *PaymentMgr) creditPoolBalance(height uint32, reward dcrutil.Amount) {
+	retention := uint32(pm.cfg.ActiveNet.CoinbaseMaturity) + MaxReorgLimit
+	pm.poolBalanceMtx.Lock()
+	pm.poolBalance += reward
+	pm.minedRewards[height] += reward
+	for minedHeight := range pm.minedRewards {
+		if minedHeight+retention < height {
+			delete(pm.minedRewards, minedHeight)
+		}
 	}
-	txFeeReserveB := pbkt.Get(txFeeReserve)
-	if txFeeReserveB == nil {
-		pm.setTxFeeReserve(dcrutil.Amount(0))
-		b := make([]byte, 4)
-		binary.LittleEndian.PutUint32(b, 0)
-		return pbkt.Put(txFeeReserve, b)
+	pm.poolBalanceMtx.Unlock()
+}
+
+// debitPoolBalance deducts the provided amount from the pool balance.
+func (pm *PaymentMgr) debitPoolBalance(amt dcrutil.Amount) {
+	pm.poolBalanceMtx.Lock()
+	pm.poolBalance -= amt
+	pm.poolBalanceMtx.Unlock()
+}
+
+// revertPoolBalance deducts the reward of the orphaned block mined by the
+// pool at the provided height from the pool balance.
+func (pm *PaymentMgr) revertPoolBalance(height uint32) dcrutil.Amount {
+	pm.poolBalanceMtx.Lock()
+	defer pm.poolBalanceMtx.Unlock()
+	reward := pm.minedRewards[height]
+	pm.poolBalance -= reward
+	delete(pm.minedRewards, height)
+	return reward
+}
+
+// loadState fetches the persisted state of the payment manager from the db.
+func (pm *PaymentMgr) loadState() error {
+	state, err := pm.cfg.DB.FetchPaymentState()
+	if err != nil {
+		return err
 	}
-	amt := dcrutil.Amount(binary.LittleEndian.Uint32(txFeeReserveB))
-	pm.setTxFeeReserve(amt)
+	pm.setLastPaymentHeight(state.LastPaymentHeight)
+	pm.setLastPaymentPaidOn(state.LastPaymentPaidOn)
+	pm.setLastPaymentCreatedOn(state.LastPaymentCreatedOn)
+	pm.setTxFeeReserve(state.TxFeeReserve)
+	pm.poolBalanceMtx.Lock()
+	pm.poolBalance = state.PoolBalance
+	pm.minedRewards = state.MinedRewards
+	pm.poolBalanceMtx.Unlock()
 	return nil
 }
 
+// paymentState returns a snapshot of the state of the payment manager.
+func (pm *PaymentMgr) paymentState() *PaymentState {
+	state := &PaymentState{
+		LastPaymentHeight:    pm.fetchLastPaymentHeight(),
+		LastPaymentPaidOn:    pm.fetchLastPaymentPaidOn(),
+		LastPaymentCreatedOn: pm.fetchLastPaymentCreatedOn(),
+		TxFeeReserve:         pm.fetchTxFeeReserve(),
+		MinedRewards:         make(map[uint32]dcrutil.Amount),
+	}
+	pm.poolBalanceMtx.RLock()
+	state.PoolBalance = pm.poolBalance
+	for height, reward := range pm.minedRewards {
+		state.MinedRewards[height] = reward
+	}
+	pm.poolBalanceMtx.RUnlock()
+	return state
+}
+
+// persistState saves the state of the payment manager to the db.
+func (pm *PaymentMgr) persistState() error {
+	return pm.cfg.DB.PersistPaymentState(pm.paymentState())
+}
+
 // replenishTxFeeReserve uses collected pool fees to replenish the
 // pool's tx fee reserve. The remaining pool fee amount after replenishing
 // the fee reserve is returned.
@@ -273,137 +303,154 @@ func (pm *PaymentMgr) replenishTxFeeReserve(poolFee dcrutil.Amount) dcrutil.Amou
 	return poolFee
 }
 
-// PPLNSSharePercentages calculates the current mining reward percentages
-// due participating pool accounts based on work performed measured by
-// the PPS payment scheme.
-func (pm *PaymentMgr) PPSSharePercentages() (map[string]*big.Rat, error) {
-	now := nanoToBigEndianBytes(time.Now().UnixNano())
-	lastPaymentCreatedOn := pm.fetchLastPaymentCreatedOn()
-	shares, err := PPSEligibleShares(pm.cfg.DB, nanoToBigEndianBytes(int64(lastPaymentCreatedOn)), now)
-	if err != nil {
-		return nil, err
-	}
-	if len(shares) == 0 {
-		return make(map[string]*big.Rat), nil
-	}
-	percentages, err := sharePercentages(shares)
-	if err != nil {
-		return nil, err
+// setNetworkDifficulty updates the network difficulty.
+func (pm *PaymentMgr) setNetworkDifficulty(netDiff *big.Rat) {
+	pm.netDiffMtx.Lock()
+	pm.netDiff = netDiff
+	pm.netDiffMtx.Unlock()
+}
+
+// fetchNetworkDifficulty fetches the network difficulty of the last
+// connected block. It is nil until a block is connected.
+func (pm *PaymentMgr) fetchNetworkDifficulty() *big.Rat {
+	pm.netDiffMtx.RLock()
+	defer pm.netDiffMtx.RUnlock()
+	return pm.netDiff
+}
+
+// paymentScheme returns the payment scheme of the configured payment method.
+func (pm *PaymentMgr) paymentScheme() (PaymentScheme, error) {
+	scheme, ok := pm.schemes[pm.cfg.PaymentMethod]
+	if !ok {
+		return nil, fmt.Errorf("unknown payment method provided %v",
+			pm.cfg.PaymentMethod)
 	}
-	return percentages, nil
+	return scheme, nil
 }
 
-// PPLNSSharePercentages calculates the current mining reward percentages due pool
-// accounts based on work performed measured by the PPLNS payment scheme.
-func (pm *PaymentMgr) PPLNSSharePercentages() (map[string]*big.Rat, error) {
-	now := time.Now()
-	min := now.Add(-pm.cfg.LastNPeriod)
-	minNano := nanoToBigEndianBytes(min.UnixNano())
-	shares, err := PPLNSEligibleShares(pm.cfg.DB, minNano)
+// sharePercentages calculates the current mining reward percentages due
+// participating pool accounts per the configured payment scheme.
+func (pm *PaymentMgr) sharePercentages() (map[string]*big.Rat, error) {
+	scheme, err := pm.paymentScheme()
 	if err != nil {
 		return nil, err
 	}
-	if len(shares) == 0 {
-		return make(map[string]*big.Rat), nil
-	}
+	return scheme.SharePercentages()
+}
 
-	// Deduct pool fees and calculate the payment due each participating
-	// account.
-	percentages, err := sharePercentages(shares)
+// creditShares credits work performed towards the provided connected block
+// per the configured payment scheme. This should be called for every
+// connected block, in pool mining mode.
+func (pm *PaymentMgr) creditShares(header *wire.BlockHeader) error {
+	pm.setNetworkDifficulty(networkDifficulty(pm.cfg.ActiveNet, header.Bits))
+	scheme, err := pm.paymentScheme()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return percentages, nil
+	return scheme.CreditShares(header)
 }
 
-// PayPerShare generates a payment bundle comprised of payments to all
-// participating accounts. Payments are calculated based on work contributed
-// to the pool since the last payment batch.
-func (pm *PaymentMgr) payPerShare(coinbase dcrutil.Amount, height uint32) error {
-	now := time.Now()
-	percentages, err := pm.PPSSharePercentages()
+// generatePayments creates payments for participating accounts. This should
+// only be called when a block is confirmed mined, in pool mining mode.
+func (pm *PaymentMgr) generatePayments(height uint32, coinbase dcrutil.Amount) error {
+	scheme, err := pm.paymentScheme()
 	if err != nil {
 		return err
 	}
-	estMaturity := height + uint32(pm.cfg.ActiveNet.CoinbaseMaturity)
-	payments, err := CalculatePayments(percentages, coinbase, pm.cfg.PoolFee, height, estMaturity)
+	return scheme.GeneratePayments(height, coinbase)
+}
+
+// orphanedPayments returns the pending payments generated for the orphaned
+// block mined by the pool at the provided height per the configured payment
+// scheme.
+func (pm *PaymentMgr) orphanedPayments(height uint32) ([]*Payment, error) {
+	scheme, err := pm.paymentScheme()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return scheme.OrphanedPayments(height)
+}
+
+// estimatedMaturity returns the estimated maturity height of payments
+// created at the provided height.
+func (pm *PaymentMgr) estimatedMaturity(height uint32) uint32 {
+	// A zero coinbase maturity allows immediately mature payments for
+	// testing purposes.
+	return height + uint32(pm.cfg.ActiveNet.CoinbaseMaturity)
+}
+
+// createPayments persists the provided payments of the block mined by the
+// pool at the provided height and updates the last payment created on time.
+// Shares created before the provided minimum are pruned since they can no
+// longer be credited, they are archived as the round of the block.
+func (pm *PaymentMgr) createPayments(height uint32, payments []*Payment, minNano int64) error {
+	prevPaymentCreatedOn := pm.fetchLastPaymentCreatedOn()
 	for _, payment := range payments {
 		err := payment.Create(pm.cfg.DB)
 		if err != nil {
 			return err
 		}
 	}
-	lastPaymentCreatedOn := uint64(payments[len(payments)-1].CreatedOn)
-	pm.setLastPaymentCreatedOn(lastPaymentCreatedOn)
-	err = pm.cfg.DB.Update(func(tx *bolt.Tx) error {
-		// Update the last payment created on time and prune invalidated shares.
-		err := pm.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return err
-		}
-		return pruneShares(tx, now.UnixNano())
-	})
-	return err
+	if len(payments) > 0 {
+		lastPaymentCreatedOn := uint64(payments[len(payments)-1].CreatedOn)
+		pm.setLastPaymentCreatedOn(lastPaymentCreatedOn)
+	}
+
+	// Update the last payment created on time and archive the invalidated
+	// shares as the round of the block.
+	round := newRound(height, prevPaymentCreatedOn)
+	return pm.cfg.DB.ArchiveRound(round, minNano, pm.paymentState())
 }
 
-// payPerLastNShares generates a payment bundle comprised of payments to all
-// participating accounts within the lastNPeriod of the pool.
-func (pm *PaymentMgr) payPerLastNShares(coinbase dcrutil.Amount, height uint32) error {
-	percentages, err := pm.PPLNSSharePercentages()
+// revertRound reverts the payments generated for the invalidated block mined
+// by the pool at the provided height. Pending payments are removed and the
+// shares pruned for the round are restored. Payments already paid cannot be
+// reverted and are returned for reporting.
+func (pm *PaymentMgr) revertRound(height uint32) (*RoundReversal, error) {
+	payments, err := pm.orphanedPayments(height)
 	if err != nil {
-		return err
-	}
-	var estMaturity uint32
-	coinbaseMaturity := pm.cfg.ActiveNet.CoinbaseMaturity
-	if coinbaseMaturity == 0 {
-		// Allow immediately mature payments for testing purposes.
-		estMaturity = height
+		return nil, err
 	}
-	if coinbaseMaturity > 0 {
-		estMaturity = height + uint32(coinbaseMaturity)
+	for _, pmt := range payments {
+		err := pmt.Delete(pm.cfg.DB)
+		if err != nil {
+			return nil, err
+		}
 	}
-	payments, err := CalculatePayments(percentages, coinbase, pm.cfg.PoolFee,
-		height, estMaturity)
+	paid, err := fetchPaidPaymentsAtHeight(pm.cfg.DB, height)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, payment := range payments {
-		err := payment.Create(pm.cfg.DB)
-		if err != nil {
-			return err
-		}
+	reversal := &RoundReversal{
+		Height:          height,
+		RemovedPayments: payments,
+		PaidPayments:    paid,
 	}
-	lastPaymentCreatedOn := uint64(payments[len(payments)-1].CreatedOn)
-	pm.setLastPaymentCreatedOn(lastPaymentCreatedOn)
-	err = pm.cfg.DB.Update(func(tx *bolt.Tx) error {
-		// Update the last payment created on time and prune invalidated shares.
-		err := pm.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return err
+	round, err := pm.cfg.DB.RestoreRound(height)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			return reversal, nil
 		}
-		minNano := time.Now().Add(-pm.cfg.LastNPeriod).UnixNano()
-		return pruneShares(tx, minNano)
-	})
-	return err
-}
-
-// generatePayments creates payments for participating accounts. This should
-// only be called when a block is confirmed mined, in pool mining mode.
-func (pm *PaymentMgr) generatePayments(height uint32, coinbase dcrutil.Amount) error {
-	cfg := pm.cfg
-	switch cfg.PaymentMethod {
-	case PPS:
-		return pm.payPerShare(coinbase, height)
+		return nil, err
+	}
+	reversal.RestoredShares = len(round.Shares)
 
-	case PPLNS:
-		return pm.payPerLastNShares(coinbase, height)
+	// Restored shares are credited with the next round.
+	if pm.fetchLastPaymentCreatedOn() > round.LastPaymentCreatedOn {
+		pm.setLastPaymentCreatedOn(round.LastPaymentCreatedOn)
+	}
+	return reversal, nil
+}
 
-	default:
-		return fmt.Errorf("unknown payment method provided %v", cfg.PaymentMethod)
+// pruneRounds removes archived rounds that can no longer be invalidated as
+// of the provided connected block height. Rounds are retained until the
+// payments of the round mature.
+func (pm *PaymentMgr) pruneRounds(height uint32) error {
+	retention := uint32(pm.cfg.ActiveNet.CoinbaseMaturity) + MaxReorgLimit
+	if height <= retention {
+		return nil
 	}
+	return pruneRounds(pm.cfg.DB, height-retention)
 }
 
 // isPaymentRequested checks if a payment request exists for the
@@ -432,6 +479,15 @@ func (pm *PaymentMgr) addPaymentRequest(addr string) error {
 	return nil
 }
 
+// clearPaymentRequests removes all payment requests.
+func (pm *PaymentMgr) clearPaymentRequests() {
+	pm.paymentReqsMtx.Lock()
+	for accountID := range pm.paymentReqs {
+		delete(pm.paymentReqs, accountID)
+	}
+	pm.paymentReqsMtx.Unlock()
+}
+
 // fetchEligiblePaymentBundles fetches payment bundles greater than the
 // configured minimum payment.
 func (pm *PaymentMgr) fetchEligiblePaymentBundles(height uint32) ([]*PaymentBundle, error) {
@@ -475,17 +531,17 @@ func (pm *PaymentMgr) payDividends(height uint32) error {
 	if lastPaymentHeight != 0 && (height-lastPaymentHeight) < 3 {
 		return nil
 	}
+
+	// Back off from retrying failed payouts until the next eligible height.
+	if height < atomic.LoadUint32(&pm.nextPayoutHeight) {
+		return nil
+	}
 	eligiblePmts, err := pm.fetchEligiblePaymentBundles(height)
 	if err != nil {
 		return err
 	}
-	pm.paymentReqsMtx.Lock()
-	for accountID := range pm.paymentReqs {
-		delete(pm.paymentReqs, accountID)
-	}
-	pm.paymentReqsMtx.Unlock()
-
 	if len(eligiblePmts) == 0 {
+		pm.clearPaymentRequests()
 		return nil
 	}
 
@@ -494,6 +550,7 @@ func (pm *PaymentMgr) payDividends(height uint32) error {
 	if err != nil {
 		return err
 	}
+	txFeeReserve := pm.fetchTxFeeReserve()
 	poolFee, ok := pmtDetails[addr.String()]
 	if ok {
 		// Replenish the tx fee reserve if a pool fee bundle entry exists.
@@ -513,28 +570,144 @@ func (pm *PaymentMgr) payDividends(height uint32) error {
 		pmts[addr] = amt
 	}
 
-	txid, err := pm.cfg.PublishTransaction(pmts, *targetAmt)
+	attempt := NewPayoutAttempt(height, *targetAmt)
+	err = attempt.Create(pm.cfg.DB)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	txid, err := pm.cfg.PublishTransaction(pmts, *targetAmt,
+		func(status PayoutStatus) {
+			attempt.Status = status
+			err := attempt.Update(pm.cfg.DB)
+			if err != nil {
+				log.Errorf("unable to update payout attempt: %v", err)
+			}
+		})
+	pm.cfg.RecordPaymentRun(time.Since(start), err)
 	if err != nil {
+		// Restore the tx fee reserve since the replenishment was not
+		// paid out.
+		pm.setTxFeeReserve(txFeeReserve)
+		pm.recordPayoutFailure(attempt, height, err)
+		pm.cfg.Notify(NewEvent(EventPaymentFailure, "", attempt))
 		return err
 	}
+	atomic.StoreUint32(&pm.payoutFailures, 0)
+	atomic.StoreUint32(&pm.nextPayoutHeight, 0)
+	pm.clearPaymentRequests()
+	pm.setLastPaymentHeight(height)
+
+	// The payout transaction is published, the paid payments are archived
+	// first to ensure they are not paid again. All payments are archived
+	// at once so a failure leaves none of them archived.
+	paid := make([]*Payment, 0)
 	for _, bundle := range eligiblePmts {
 		bundle.UpdateAsPaid(pm.cfg.DB, height, txid)
-		err = bundle.ArchivePayments(pm.cfg.DB)
-		if err != nil {
-			return err
-		}
+		paid = append(paid, bundle.Payments...)
 	}
-	err = pm.cfg.DB.Update(func(tx *bolt.Tx) error {
-		err = pm.persistTxFeeReserve(tx)
-		if err != nil {
-			return err
+	err = pm.cfg.DB.ArchivePayments(paid)
+	if err != nil {
+		desc := fmt.Sprintf("unable to archive payments paid by "+
+			"published payout %s", txid)
+		return MakeError(ErrOther, desc, err)
+	}
+	err = pm.persistState()
+	if err != nil {
+		return err
+	}
+
+	// Failing to track the payout attempt does not affect the published
+	// payout.
+	attempt.Status = PayoutPublished
+	attempt.TransactionID = txid
+	err = attempt.Update(pm.cfg.DB)
+	if err != nil {
+		log.Errorf("unable to update payout attempt %s: %v", attempt.UUID,
+			err)
+	}
+	pm.cfg.Notify(NewEvent(EventPayoutSent, "", attempt))
+	for _, bundle := range eligiblePmts {
+		pm.cfg.Notify(NewEvent(EventPayoutSent, bundle.Account, &PayoutSent{
+			Height:        height,
+			TransactionID: txid,
+			Amount:        bundle.Total(),
+		}))
+	}
+	return nil
+}
+
+// confirmPayouts marks published payouts mined by the block associated with
+// the provided block hash as confirmed. Published payouts not mined within
+// the payout confirmation window are flagged as unconfirmed.
+func (pm *PaymentMgr) confirmPayouts(blockHash *chainhash.Hash, height uint32) error {
+	attempts, err := fetchTrackedPayoutAttempts(pm.cfg.DB)
+	if err != nil {
+		return err
+	}
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	block, err := pm.cfg.GetBlock(blockHash)
+	if err != nil {
+		desc := fmt.Sprintf("unable to fetch block %s: %v",
+			blockHash.String(), err)
+		return MakeError(ErrOther, desc, err)
+	}
+	txids := make(map[string]struct{}, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txids[tx.TxHash().String()] = struct{}{}
+	}
+
+	for _, attempt := range attempts {
+		if _, ok := txids[attempt.TransactionID]; ok {
+			attempt.Status = PayoutConfirmed
+			attempt.ConfirmedHeight = height
+			err := attempt.Update(pm.cfg.DB)
+			if err != nil {
+				return err
+			}
+			log.Infof("Payout transaction %s confirmed at height #%d",
+				attempt.TransactionID, height)
+			continue
+		}
+
+		if attempt.Status == PayoutPublished &&
+			height > attempt.Height+payoutConfirmationWindow {
+			attempt.Status = PayoutUnconfirmed
+			err := attempt.Update(pm.cfg.DB)
+			if err != nil {
+				return err
+			}
+			log.Warnf("Payout transaction %s published at height #%d "+
+				"not mined within %d blocks", attempt.TransactionID,
+				attempt.Height, payoutConfirmationWindow)
 		}
-		pm.setLastPaymentHeight(height)
-		err = pm.persistLastPaymentHeight(tx)
+	}
+	return nil
+}
+
+// unconfirmPayouts reverts payouts confirmed by the block at the provided
+// height to published.
+func (pm *PaymentMgr) unconfirmPayouts(height uint32) error {
+	attempts, err := filterPayoutAttempts(pm.cfg.DB,
+		func(attempt *PayoutAttempt) bool {
+			return attempt.Status == PayoutConfirmed &&
+				attempt.ConfirmedHeight == height
+		})
+	if err != nil {
+		return err
+	}
+	for _, attempt := range attempts {
+		attempt.Status = PayoutPublished
+		attempt.ConfirmedHeight = 0
+		err := attempt.Update(pm.cfg.DB)
 		if err != nil {
 			return err
 		}
-		return pm.persistLastPaymentPaidOn(tx)
-	})
-	return err
+		log.Infof("Payout transaction %s unconfirmed via disconnected "+
+			"block #%d", attempt.TransactionID, height)
+	}
+	return nil
 }
@@ -3,15 +3,15 @@ package pool
 import (
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/dcrutil/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
-func testPaymentMgr(t *testing.T, db *bolt.DB) {
+func testPaymentMgr(t *testing.T, db Database) {
 	minPayment, err := dcrutil.NewAmount(2.0)
 	if err != nil {
 		t.Fatalf("[NewAmount] unexpected error: %v", err)
@@ -21,19 +21,24 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 		t.Fatalf("[NewAmount] unexpected error: %v", err)
 	}
 	activeNet := chaincfg.SimNetParams()
+	events := make([]*Event, 0)
 	pCfg := &PaymentMgrConfig{
 		DB:              db,
 		ActiveNet:       activeNet,
 		PoolFee:         0.1,
 		LastNPeriod:     time.Second * 120,
 		SoloPool:        false,
-		PaymentMethod:   PPS,
+		PaymentMethod:   PROP,
 		MinPayment:      minPayment,
 		PoolFeeAddrs:    []dcrutil.Address{poolFeeAddrs},
 		MaxTxFeeReserve: maxTxFeeReserve,
-		PublishTransaction: func(map[dcrutil.Address]dcrutil.Amount, dcrutil.Amount) (string, error) {
+		PublishTransaction: func(map[dcrutil.Address]dcrutil.Amount, dcrutil.Amount, func(PayoutStatus)) (string, error) {
 			return "", nil
 		},
+		RecordPaymentRun: func(time.Duration, error) {},
+		Notify: func(event *Event) {
+			events = append(events, event)
+		},
 	}
 	mgr, err := NewPaymentMgr(pCfg)
 	if err != nil {
@@ -41,25 +46,7 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	}
 
 	// Ensure backed up values to the database persist and load as expected.
-	err = db.Update(func(tx *bolt.Tx) error {
-		err = mgr.loadLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to load last payment height: %v", err)
-		}
-		err = mgr.loadLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to load last payment created on: %v", err)
-		}
-		err = mgr.loadLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to load last payment paid on: %v", err)
-		}
-		err = mgr.loadTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to load tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.loadState()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -96,48 +83,12 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 		t.Fatalf("[NewAmount] unexpected error: %v", err)
 	}
 	mgr.setTxFeeReserve(feeReserve)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("[persistLastPaymentHeight] unable to persist last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("[persistLastPaymentPaidOn] unable to persist last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("[persistLastPaymentCreatedOn] unable to persist last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("[persistTxFeeReserve] unable to persist tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = db.View(func(tx *bolt.Tx) error {
-		err := mgr.loadLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("[loadLastPaymentHeight] unable to load last payment height: %v", err)
-		}
-		err = mgr.loadLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("[loadLastPaymentPaidOn] unable to load last payment paid on: %v", err)
-		}
-		err = mgr.loadLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("[loadLastPaymentCreatedOn] unable to load last payment created on: %v", err)
-		}
-		err = mgr.loadTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("[loadTxFeeReserve] unable to load tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.loadState()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -200,30 +151,12 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Ensure Pay-Per-Share (PPS) works as expected.
+	// Ensure Proportional (PROP) works as expected.
 	now := time.Now()
 	sixtyBefore := now.Add(-(time.Second * 60)).UnixNano()
 	thirtyBefore := now.Add(-(time.Second * 30)).UnixNano()
@@ -254,15 +187,15 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	previousPaymentCreatedOn := int64(mgr.fetchLastPaymentCreatedOn())
 	err = mgr.generatePayments(height, coinbase)
 	if err != nil {
-		t.Fatalf("[PPS] unable to generate payments: %v", err)
+		t.Fatalf("[PROP] unable to generate payments: %v", err)
 	}
 	currentPaymentCreatedOn := int64(mgr.fetchLastPaymentCreatedOn())
 	if currentPaymentCreatedOn < now.UnixNano() {
-		t.Fatalf("[PPS] expected last payment created on time to "+
+		t.Fatalf("[PROP] expected last payment created on time to "+
 			"be greater than %v,got %v", now, currentPaymentCreatedOn)
 	}
 	if currentPaymentCreatedOn < previousPaymentCreatedOn {
-		t.Fatalf("[PPS] expected last payment created on time to "+
+		t.Fatalf("[PROP] expected last payment created on time to "+
 			"be greater than %v,got %v", previousPaymentCreatedOn,
 			currentPaymentCreatedOn)
 	}
@@ -276,7 +209,7 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	expectedBundleCount := 3
 	bundles := generatePaymentBundles(pmts)
 	if len(bundles) != expectedBundleCount {
-		t.Fatalf("[PPS] expected %v payment bundles, got %v.",
+		t.Fatalf("[PROP] expected %v payment bundles, got %v.",
 			expectedBundleCount, len(bundles))
 	}
 
@@ -296,14 +229,14 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	// Ensure the two account payment bundles have the same payments since
 	// they have the same share weights.
 	if xb.Total() != yb.Total() {
-		t.Fatalf("[PPS] expected equal account amounts, %v != %v",
+		t.Fatalf("[PROP] expected equal account amounts, %v != %v",
 			xb.Total(), yb.Total())
 	}
 
 	// Ensure the fee payment is the exact fee percentage of the total amount.
 	expectedFeeAmt := coinbase.MulF64(mgr.cfg.PoolFee)
 	if fb.Total() != expectedFeeAmt {
-		t.Fatalf("[PPS]expected %v fee payment amount, got %v",
+		t.Fatalf("[PROP]expected %v fee payment amount, got %v",
 			fb.Total(), expectedFeeAmt)
 	}
 
@@ -311,19 +244,19 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	// coinbase amount.
 	sum := xb.Total() + yb.Total() + fb.Total()
 	if sum != coinbase {
-		t.Fatalf("[PPS] expected the sum of all payments to be %v, got %v", coinbase, sum)
+		t.Fatalf("[PROP] expected the sum of all payments to be %v, got %v", coinbase, sum)
 	}
 
 	// Empty the share bucket.
 	err = emptyBucket(db, shareBkt)
 	if err != nil {
-		t.Fatalf("[PPS] emptyBucket error: %v", err)
+		t.Fatalf("[PROP] emptyBucket error: %v", err)
 	}
 
 	// Empty the payment bucket.
 	err = emptyBucket(db, paymentBkt)
 	if err != nil {
-		t.Fatalf("[PPS] emptyBucket error: %v", err)
+		t.Fatalf("[PROP] emptyBucket error: %v", err)
 	}
 
 	// Reset backed up values to their defaults.
@@ -331,25 +264,7 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -458,31 +373,13 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Ensure minimum processing payment amount is enforced.
-	pCfg.PaymentMethod = PPS
+	pCfg.PaymentMethod = PROP
 	xShareCount := 10
 	yShareCount := 5
 
@@ -538,25 +435,7 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -621,25 +500,7 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -710,13 +571,115 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 			expectedFeeAmt, fb.Total())
 	}
 
+	// Ensure failed payouts are recorded and retried after a backoff.
+	publishTx := pCfg.PublishTransaction
+	pCfg.PublishTransaction = func(map[dcrutil.Address]dcrutil.Amount, dcrutil.Amount, func(PayoutStatus)) (string, error) {
+		return "", fmt.Errorf("wallet unavailable")
+	}
+	txFeeReserve = mgr.fetchTxFeeReserve()
+	lastPaymentHeight = mgr.fetchLastPaymentHeight()
+	err = mgr.payDividends(paymentMaturity)
+	if err == nil {
+		t.Fatal("[payDividends] expected a wallet unavailable error")
+	}
+	if mgr.fetchLastPaymentHeight() != lastPaymentHeight {
+		t.Fatal("expected an unchanged payment height")
+	}
+	if mgr.fetchTxFeeReserve() != txFeeReserve {
+		t.Fatalf("expected a tx fee reserve of %v, got %v", txFeeReserve,
+			mgr.fetchTxFeeReserve())
+	}
+	attempt, err := fetchLastPayoutAttempt(db)
+	if err != nil {
+		t.Fatalf("[fetchLastPayoutAttempt] unexpected error: %v", err)
+	}
+	if attempt == nil || attempt.Status != PayoutFailed {
+		t.Fatalf("expected a failed payout attempt, got %v", attempt)
+	}
+	if attempt.Error != "wallet unavailable" || attempt.Failures != 1 ||
+		attempt.NextAttemptHeight != paymentMaturity+1 {
+		t.Fatalf("unexpected failed payout attempt %v", attempt)
+	}
+	if len(events) != 1 || events[0].Type != EventPaymentFailure {
+		t.Fatalf("expected a payment failure event, got %v", events)
+	}
+	err = mgr.payDividends(paymentMaturity)
+	if err != nil {
+		t.Fatalf("[payDividends] expected the payout to be backed off, "+
+			"got %v", err)
+	}
+	err = mgr.payDividends(paymentMaturity + 1)
+	if err == nil {
+		t.Fatal("[payDividends] expected a wallet unavailable error")
+	}
+	attempts, err := filterPayoutAttempts(db, func(attempt *PayoutAttempt) bool {
+		return attempt.Status == PayoutFailed
+	})
+	if err != nil {
+		t.Fatalf("[filterPayoutAttempts] unexpected error: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected %d failed payout attempts, got %d", 2,
+			len(attempts))
+	}
+	if attempts[0].Failures != 2 ||
+		attempts[0].NextAttemptHeight != paymentMaturity+3 {
+		t.Fatalf("unexpected failed payout attempt %v", attempts[0])
+	}
+
+	// Ensure the retry backoff is doubled per failure up to the maximum.
+	backoffs := map[uint32]uint32{0: 0, 1: 1, 2: 2, 3: 4, 6: 32, 10: 32}
+	for failures, expected := range backoffs {
+		backoff := payoutRetryBackoff(failures)
+		if backoff != expected {
+			t.Fatalf("[payoutRetryBackoff] expected a backoff of %d for "+
+				"%d failures, got %d", expected, failures, backoff)
+		}
+	}
+	pCfg.PublishTransaction = func(pmts map[dcrutil.Address]dcrutil.Amount, amt dcrutil.Amount, setStatus func(PayoutStatus)) (string, error) {
+		setStatus(PayoutConstructed)
+		setStatus(PayoutSigned)
+		return publishTx(pmts, amt, setStatus)
+	}
+	atomic.StoreUint32(&mgr.payoutFailures, 0)
+	atomic.StoreUint32(&mgr.nextPayoutHeight, 0)
+
 	// Ensure dividend payments work as expected.
+	events = events[:0]
 	lastPaymentHeight = mgr.fetchLastPaymentHeight()
 	err = mgr.payDividends(paymentMaturity)
 	if err != nil {
 		t.Fatalf("[payDividends] unexpected error: %v", err)
 	}
 
+	// Ensure payout sent events were sent for the pool and account X.
+	var poolPayoutSent, xPayoutSent bool
+	for _, event := range events {
+		if event.Type != EventPayoutSent {
+			t.Fatalf("expected a payout sent event, got %s", event.Type)
+		}
+		switch event.AccountID {
+		case "":
+			poolPayoutSent = true
+		case xID:
+			xPayoutSent = true
+		}
+	}
+	if !poolPayoutSent || !xPayoutSent {
+		t.Fatalf("expected payout sent events for the pool and account "+
+			"%s, got %v", xID, events)
+	}
+
+	// Ensure the payout attempt was recorded as published.
+	attempt, err = fetchLastPayoutAttempt(db)
+	if err != nil {
+		t.Fatalf("[fetchLastPayoutAttempt] unexpected error: %v", err)
+	}
+	if attempt.Status != PayoutPublished || attempt.Failures != 0 {
+		t.Fatalf("expected a published payout attempt, got %v", attempt)
+	}
+	pCfg.PublishTransaction = publishTx
+
 	// Ensure the last payment height changed because
 	// dividends were paid.
 	currentPaymentHeight := mgr.fetchLastPaymentHeight()
@@ -736,30 +699,18 @@ func testPaymentMgr(t *testing.T, db *bolt.DB) {
 		t.Fatalf("emptyBucket error: %v", err)
 	}
 
+	// Empty the payout bucket.
+	err = emptyBucket(db, payoutBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+
 	// Reset backed up values to their defaults.
 	mgr.setLastPaymentHeight(0)
 	mgr.setLastPaymentPaidOn(0)
 	mgr.setLastPaymentCreatedOn(0)
 	mgr.setTxFeeReserve(zeroAmount)
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := mgr.persistLastPaymentHeight(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment height: %v", err)
-		}
-		err = mgr.persistLastPaymentPaidOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment paid on: %v", err)
-		}
-		err = mgr.persistLastPaymentCreatedOn(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default last payment created on: %v", err)
-		}
-		err = mgr.persistTxFeeReserve(tx)
-		if err != nil {
-			return fmt.Errorf("unable to persist default tx fee reserve: %v", err)
-		}
-		return nil
-	})
+	err = mgr.persistState()
 	if err != nil {
 		t.Fatal(err)
 	}
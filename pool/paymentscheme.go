@@ -0,0 +1,379 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+var (
+	// PeriodWindow represents the PPLNS window of shares submitted within
+	// the last n period.
+	PeriodWindow = "period"
+
+	// SharesWindow represents the PPLNS window of the last n shares.
+	SharesWindow = "shares"
+
+	// WorkWindow represents the PPLNS window of the most recent shares worth
+	// n times the network difficulty.
+	WorkWindow = "work"
+
+	// PPLNSWindows represents the PPLNS windows supported by the pool.
+	PPLNSWindows = []string{PeriodWindow, SharesWindow, WorkWindow}
+)
+
+// PaymentScheme represents a method of distributing mining rewards to
+// participating pool accounts. Payment schemes are registered with the
+// payment manager by payment method.
+type PaymentScheme interface {
+	// SharePercentages calculates the current mining reward percentages
+	// due participating accounts.
+	SharePercentages() (map[string]*big.Rat, error)
+
+	// CreditShares credits work performed towards the provided connected
+	// block. It is called for every block connected to the chain.
+	CreditShares(header *wire.BlockHeader) error
+
+	// GeneratePayments distributes the provided coinbase of the block
+	// mined by the pool at the provided height. It is called when the
+	// mined block is confirmed.
+	GeneratePayments(height uint32, coinbase dcrutil.Amount) error
+
+	// OrphanedPayments returns the pending payments to be removed when the
+	// block mined by the pool at the provided height is orphaned.
+	OrphanedPayments(height uint32) ([]*Payment, error)
+}
+
+// networkDifficulty returns the network difficulty of the provided compact
+// target.
+func networkDifficulty(net *chaincfg.Params, bits uint32) *big.Rat {
+	target := new(big.Rat).SetInt(standalone.CompactToBig(bits))
+	return new(big.Rat).Quo(new(big.Rat).SetInt(net.PowLimit), target)
+}
+
+// roundSharePercentages calculates the mining reward percentages due
+// participating accounts based on work performed since the last payment
+// batch.
+func roundSharePercentages(pm *PaymentMgr) (map[string]*big.Rat, error) {
+	now := time.Now().UnixNano()
+	lastPaymentCreatedOn := pm.fetchLastPaymentCreatedOn()
+	shares, err := PPSEligibleShares(pm.cfg.DB, int64(lastPaymentCreatedOn),
+		now)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) == 0 {
+		return make(map[string]*big.Rat), nil
+	}
+	return sharePercentages(shares)
+}
+
+// propScheme implements the proportional (PROP) payment scheme. The reward
+// of a block mined by the pool is distributed proportionally to the work
+// performed in the round since the last block mined by the pool.
+type propScheme struct {
+	pm *PaymentMgr
+}
+
+// SharePercentages calculates the current mining reward percentages due
+// participating accounts based on work performed in the current round.
+func (s *propScheme) SharePercentages() (map[string]*big.Rat, error) {
+	return roundSharePercentages(s.pm)
+}
+
+// CreditShares is a no-op since shares are only credited when the pool
+// mines a block.
+func (s *propScheme) CreditShares(*wire.BlockHeader) error {
+	return nil
+}
+
+// GeneratePayments generates a payment bundle comprised of payments to all
+// participating accounts of the round.
+func (s *propScheme) GeneratePayments(height uint32, coinbase dcrutil.Amount) error {
+	pm := s.pm
+	now := time.Now()
+	percentages, err := s.SharePercentages()
+	if err != nil {
+		return err
+	}
+	payments, err := CalculatePayments(percentages, coinbase, pm.cfg.PoolFee,
+		height, pm.estimatedMaturity(height))
+	if err != nil {
+		return err
+	}
+	return pm.createPayments(height, payments, now.UnixNano())
+}
+
+// OrphanedPayments returns the pending payments generated from the coinbase
+// of the orphaned block.
+func (s *propScheme) OrphanedPayments(height uint32) ([]*Payment, error) {
+	return fetchPendingPaymentsAtHeight(s.pm.cfg.DB, height)
+}
+
+// pplnsScheme implements the pay per last n shares (PPLNS) payment scheme.
+// The reward of a block mined by the pool is distributed proportionally to
+// the work performed within the configured PPLNS window, the last n period,
+// the last n shares or the most recent shares worth n times the network
+// difficulty.
+type pplnsScheme struct {
+	pm *PaymentMgr
+}
+
+// windowShares fetches the shares within the configured PPLNS window. The
+// creation time shares older than the window are pruned before is also
+// returned.
+func (s *pplnsScheme) windowShares() ([]*Share, int64, error) {
+	pm := s.pm
+	var shares []*Share
+	var err error
+	switch pm.cfg.PPLNSWindow {
+	case SharesWindow:
+		shares, err = PPLNSLastNShares(pm.cfg.DB, pm.cfg.LastNShares)
+
+	case WorkWindow:
+		// All retained shares are eligible until the network difficulty
+		// is known.
+		var work *big.Rat
+		netDiff := pm.fetchNetworkDifficulty()
+		if netDiff != nil {
+			work = new(big.Rat).Mul(netDiff,
+				new(big.Rat).SetFloat64(pm.cfg.LastNWork))
+		}
+		shares, err = PPLNSLastNWork(pm.cfg.DB, work)
+
+	default:
+		min := time.Now().Add(-pm.cfg.LastNPeriod).UnixNano()
+		shares, err = PPLNSEligibleShares(pm.cfg.DB, min)
+		return shares, min, err
+	}
+	if err != nil || len(shares) == 0 {
+		return shares, 0, err
+	}
+
+	// Shares are fetched from the most recent. Shares older than the last
+	// share of the window are outside future windows, unless the network
+	// difficulty increases for the work window.
+	return shares, shares[len(shares)-1].CreatedOn, nil
+}
+
+// SharePercentages calculates the current mining reward percentages due
+// participating accounts based on work performed within the PPLNS window.
+func (s *pplnsScheme) SharePercentages() (map[string]*big.Rat, error) {
+	shares, _, err := s.windowShares()
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) == 0 {
+		return make(map[string]*big.Rat), nil
+	}
+	return sharePercentages(shares)
+}
+
+// CreditShares is a no-op since shares are only credited when the pool
+// mines a block.
+func (s *pplnsScheme) CreditShares(*wire.BlockHeader) error {
+	return nil
+}
+
+// GeneratePayments generates a payment bundle comprised of payments to all
+// participating accounts within the PPLNS window. Shares outside of the
+// window are pruned.
+func (s *pplnsScheme) GeneratePayments(height uint32, coinbase dcrutil.Amount) error {
+	pm := s.pm
+	shares, minNano, err := s.windowShares()
+	if err != nil {
+		return err
+	}
+	percentages := make(map[string]*big.Rat)
+	if len(shares) > 0 {
+		percentages, err = sharePercentages(shares)
+		if err != nil {
+			return err
+		}
+	}
+	payments, err := CalculatePayments(percentages, coinbase, pm.cfg.PoolFee,
+		height, pm.estimatedMaturity(height))
+	if err != nil {
+		return err
+	}
+	return pm.createPayments(height, payments, minNano)
+}
+
+// OrphanedPayments returns the pending payments generated from the coinbase
+// of the orphaned block.
+func (s *pplnsScheme) OrphanedPayments(height uint32) ([]*Payment, error) {
+	return fetchPendingPaymentsAtHeight(s.pm.cfg.DB, height)
+}
+
+// ppsScheme implements the pay per share (PPS) and full pay per share (FPPS)
+// payment schemes. Each share is credited at its expected value as blocks
+// are connected, independent of the pool mining blocks. The expected value
+// of a share is its probability of solving the block, the ratio of the share
+// difficulty to the network difficulty, multiplied by the work subsidy of
+// the block. FPPS additionally includes the transaction fees of the block.
+//
+// The pool bears the variance of mining blocks. The pool balance tracks the
+// rewards of blocks mined by the pool less the shares credited.
+type ppsScheme struct {
+	pm        *PaymentMgr
+	subsidies *standalone.SubsidyCache
+	fullPay   bool
+}
+
+// SharePercentages calculates the current mining reward percentages due
+// participating accounts based on work performed since shares were last
+// credited.
+func (s *ppsScheme) SharePercentages() (map[string]*big.Rat, error) {
+	return roundSharePercentages(s.pm)
+}
+
+// blockReward returns the reward shares mined towards the provided block
+// are valued against.
+func (s *ppsScheme) blockReward(header *wire.BlockHeader) (dcrutil.Amount, error) {
+	height := int64(header.Height)
+	subsidy := s.subsidies.CalcWorkSubsidy(height, header.Voters)
+	if !s.fullPay {
+		return dcrutil.Amount(subsidy), nil
+	}
+
+	// The transaction fees of the block are the value of the coinbase
+	// outputs in excess of the work and treasury subsidies. Shares are
+	// credited for every connected block, most of which are mined by
+	// others, so the coinbase outputs are not matched against the pool
+	// mining addresses.
+	hash := header.BlockHash()
+	block, err := s.pm.cfg.GetBlock(&hash)
+	if err != nil {
+		return 0, err
+	}
+	if len(block.Transactions) == 0 {
+		desc := fmt.Sprintf("block #%d has no coinbase transaction",
+			header.Height)
+		return 0, MakeError(ErrCoinbaseReward, desc, nil)
+	}
+	var total int64
+	for _, out := range block.Transactions[0].TxOut {
+		total += out.Value
+	}
+	fees := total - subsidy - s.subsidies.CalcTreasurySubsidy(height,
+		header.Voters)
+	if fees < 0 {
+		fees = 0
+	}
+	return dcrutil.Amount(subsidy + fees), nil
+}
+
+// shareValues calculates the expected value of the provided shares per
+// account against the provided network difficulty and block reward.
+func shareValues(shares []*Share, netDiff *big.Rat, reward dcrutil.Amount) map[string]*big.Rat {
+	one := new(big.Rat).SetInt64(1)
+	values := make(map[string]*big.Rat)
+	for _, share := range shares {
+		// Shares recorded without a difficulty cannot be valued.
+		if share.Difficulty == nil {
+			continue
+		}
+
+		// The probability of a share solving the block is calculated as:
+		//
+		//    probability = share_difficulty / network_difficulty
+		//
+		// The result is clamped to 1 if it exceeds it.
+		probability := new(big.Rat).Quo(share.Difficulty, netDiff)
+		if probability.Cmp(one) > 0 {
+			probability.Set(one)
+		}
+		value := probability.Mul(probability,
+			new(big.Rat).SetInt64(int64(reward)))
+		if total, ok := values[share.Account]; ok {
+			total.Add(total, value)
+			continue
+		}
+		values[share.Account] = value
+	}
+	return values
+}
+
+// CreditShares credits all shares submitted since shares were last credited
+// at their expected value against the provided connected block. The pool fee
+// is deducted from the value of each account's shares.
+func (s *ppsScheme) CreditShares(header *wire.BlockHeader) error {
+	pm := s.pm
+	now := time.Now().UnixNano()
+	lastPaymentCreatedOn := pm.fetchLastPaymentCreatedOn()
+	shares, err := PPSEligibleShares(pm.cfg.DB, int64(lastPaymentCreatedOn),
+		now)
+	if err != nil {
+		return err
+	}
+	if len(shares) == 0 {
+		return nil
+	}
+	reward, err := s.blockReward(header)
+	if err != nil {
+		return err
+	}
+	netDiff := networkDifficulty(pm.cfg.ActiveNet, header.Bits)
+	values := shareValues(shares, netDiff, reward)
+
+	estMaturity := pm.estimatedMaturity(header.Height)
+	payments := make([]*Payment, 0, len(values)+1)
+	var credited, fees dcrutil.Amount
+	for account, value := range values {
+		amt, _ := value.Float64()
+		total := dcrutil.Amount(amt)
+		fee := total.MulF64(pm.cfg.PoolFee)
+		if total-fee <= 0 {
+			continue
+		}
+		payments = append(payments, NewPayment(account, total-fee,
+			header.Height, estMaturity))
+		credited += total
+		fees += fee
+	}
+	if fees > 0 {
+		payments = append(payments, NewPayment(poolFeesK, fees,
+			header.Height, estMaturity))
+	}
+	for _, payment := range payments {
+		err := payment.Create(pm.cfg.DB)
+		if err != nil {
+			return err
+		}
+	}
+	pm.debitPoolBalance(credited)
+	pm.setLastPaymentCreatedOn(uint64(now))
+
+	// Update the pool balance, the last payment created on time and prune
+	// credited shares.
+	return pm.cfg.DB.PruneShares(now+1, pm.paymentState())
+}
+
+// GeneratePayments credits the provided coinbase to the pool balance since
+// shares have already been paid for when credited.
+func (s *ppsScheme) GeneratePayments(height uint32, coinbase dcrutil.Amount) error {
+	pm := s.pm
+	pm.creditPoolBalance(height, coinbase)
+	return pm.persistState()
+}
+
+// OrphanedPayments reverts the reward of the orphaned block credited to the
+// pool balance. No payments are removed since shares are credited
+// independent of the pool mining blocks.
+func (s *ppsScheme) OrphanedPayments(height uint32) ([]*Payment, error) {
+	pm := s.pm
+	reward := pm.revertPoolBalance(height)
+	if reward == 0 {
+		return nil, nil
+	}
+	return nil, pm.persistState()
+}
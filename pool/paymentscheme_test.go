@@ -0,0 +1,342 @@
+package pool
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+func testPaymentSchemes(t *testing.T, db Database) {
+	activeNet := chaincfg.SimNetParams()
+	subsidies := standalone.NewSubsidyCache(activeNet)
+	height := uint32(20)
+	header := &wire.BlockHeader{
+		Height: height,
+		Bits:   activeNet.PowLimitBits,
+		Voters: 5,
+	}
+	workSubsidy := subsidies.CalcWorkSubsidy(int64(height), header.Voters)
+	treasurySubsidy := subsidies.CalcTreasurySubsidy(int64(height),
+		header.Voters)
+	txFees := int64(5e7)
+
+	// The coinbase of connected blocks pays to a non-pool script unless
+	// changed, blocks are mined by others.
+	var coinbaseScript []byte
+	pCfg := &PaymentMgrConfig{
+		DB:            db,
+		ActiveNet:     activeNet,
+		PoolFee:       0.1,
+		LastNPeriod:   time.Second * 120,
+		PaymentMethod: PPS,
+		PoolFeeAddrs:  []dcrutil.Address{poolFeeAddrs},
+		MiningAddrs:   []dcrutil.Address{miningAddr},
+		GetBlock: func(*chainhash.Hash) (*wire.MsgBlock, error) {
+			coinbase := wire.NewMsgTx()
+			coinbase.AddTxOut(wire.NewTxOut(treasurySubsidy, nil))
+			coinbase.AddTxOut(wire.NewTxOut(0, nil))
+			coinbase.AddTxOut(wire.NewTxOut(workSubsidy+txFees,
+				coinbaseScript))
+			return &wire.MsgBlock{
+				Header:       *header,
+				Transactions: []*wire.MsgTx{coinbase},
+			}, nil
+		},
+		RecordPaymentRun: func(time.Duration, error) {},
+		Notify:           func(*Event) {},
+	}
+	mgr, err := NewPaymentMgr(pCfg)
+	if err != nil {
+		t.Fatalf("[NewPaymentMgr] unexpected error: %v", err)
+	}
+
+	// Ensure unknown payment methods are rejected.
+	pCfg.PaymentMethod = "unknown"
+	err = mgr.creditShares(header)
+	if err == nil {
+		t.Fatal("expected an unknown payment method error")
+	}
+
+	target := new(big.Rat).SetInt(standalone.CompactToBig(header.Bits))
+	netDiff := new(big.Rat).Quo(new(big.Rat).SetInt(activeNet.PowLimit),
+		target)
+	shareDiff := new(big.Rat).Quo(netDiff, new(big.Rat).SetInt64(1000))
+	createShares := func(accounts ...string) {
+		now := time.Now().UnixNano()
+		for i, account := range accounts {
+			share := &Share{
+				Account:    account,
+				Weight:     new(big.Rat).SetInt64(1),
+				Difficulty: shareDiff,
+				CreatedOn:  now + int64(i),
+			}
+			err := share.Create(db)
+			if err != nil {
+				t.Fatalf("unable to persist share: %v", err)
+			}
+		}
+	}
+	creditedAmounts := func() (dcrutil.Amount, dcrutil.Amount, dcrutil.Amount) {
+		pmts, err := fetchPendingPayments(db)
+		if err != nil {
+			t.Fatalf("[fetchPendingPayments] unexpected error: %v", err)
+		}
+		var x, y, fees dcrutil.Amount
+		for _, pmt := range pmts {
+			switch pmt.Account {
+			case xID:
+				x += pmt.Amount
+			case yID:
+				y += pmt.Amount
+			case poolFeesK:
+				fees += pmt.Amount
+			}
+		}
+		return x, y, fees
+	}
+
+	// Ensure PPS credits shares at their expected value of the work subsidy
+	// less pool fees and debits the pool balance.
+	pCfg.PaymentMethod = PPS
+	createShares(xID, xID, xID, yID)
+	err = mgr.creditShares(header)
+	if err != nil {
+		t.Fatalf("[PPS] unable to credit shares: %v", err)
+	}
+	x, y, fees := creditedAmounts()
+	value, _ := big.NewRat(3*workSubsidy, 1000).Float64()
+	expectedX := dcrutil.Amount(value)
+	expectedX -= expectedX.MulF64(pCfg.PoolFee)
+	if x != expectedX {
+		t.Fatalf("[PPS] expected a credit of %v for account x, got %v",
+			expectedX, x)
+	}
+	if x-3*y > 3 || 3*y-x > 3 {
+		t.Fatalf("[PPS] expected account x to be credited thrice account "+
+			"y, got %v and %v", x, y)
+	}
+	credited := x + y + fees
+	if mgr.fetchPoolBalance() != -credited {
+		t.Fatalf("[PPS] expected a pool balance of %v, got %v", -credited,
+			mgr.fetchPoolBalance())
+	}
+
+	// Ensure credited shares are pruned.
+	shares, err := PPSEligibleShares(db, 0, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("[PPSEligibleShares] unexpected error: %v", err)
+	}
+	if len(shares) != 0 {
+		t.Fatalf("[PPS] expected credited shares to be pruned, got %d",
+			len(shares))
+	}
+
+	// Ensure blocks mined by the pool are credited to the pool balance
+	// without generating payments.
+	coinbase := dcrutil.Amount(workSubsidy)
+	err = mgr.generatePayments(height, coinbase)
+	if err != nil {
+		t.Fatalf("[PPS] unable to generate payments: %v", err)
+	}
+	if mgr.fetchPoolBalance() != coinbase-credited {
+		t.Fatalf("[PPS] expected a pool balance of %v, got %v",
+			coinbase-credited, mgr.fetchPoolBalance())
+	}
+	x2, y2, fees2 := creditedAmounts()
+	if x2 != x || y2 != y || fees2 != fees {
+		t.Fatal("[PPS] expected no payments for a mined block")
+	}
+
+	// Ensure the pool balance persists.
+	mgr, err = NewPaymentMgr(pCfg)
+	if err != nil {
+		t.Fatalf("[NewPaymentMgr] unexpected error: %v", err)
+	}
+	if mgr.fetchPoolBalance() != coinbase-credited {
+		t.Fatalf("[PPS] expected a persisted pool balance of %v, got %v",
+			coinbase-credited, mgr.fetchPoolBalance())
+	}
+
+	// Ensure orphaned mined blocks are reverted from the pool balance
+	// without removing credited shares.
+	pmts, err := mgr.orphanedPayments(height)
+	if err != nil {
+		t.Fatalf("[PPS] unable to fetch orphaned payments: %v", err)
+	}
+	if len(pmts) != 0 {
+		t.Fatalf("[PPS] expected no orphaned payments, got %d", len(pmts))
+	}
+	if mgr.fetchPoolBalance() != -credited {
+		t.Fatalf("[PPS] expected a pool balance of %v, got %v", -credited,
+			mgr.fetchPoolBalance())
+	}
+
+	// Ensure mined blocks orphaned beyond the reorg limit are reverted
+	// from the pool balance.
+	err = mgr.generatePayments(height, coinbase)
+	if err != nil {
+		t.Fatalf("[PPS] unable to generate payments: %v", err)
+	}
+	laterHeight := height + MaxReorgLimit + 1
+	err = mgr.generatePayments(laterHeight, coinbase)
+	if err != nil {
+		t.Fatalf("[PPS] unable to generate payments: %v", err)
+	}
+	_, err = mgr.orphanedPayments(height)
+	if err != nil {
+		t.Fatalf("[PPS] unable to fetch orphaned payments: %v", err)
+	}
+	if mgr.fetchPoolBalance() != coinbase-credited {
+		t.Fatalf("[PPS] expected a pool balance of %v, got %v",
+			coinbase-credited, mgr.fetchPoolBalance())
+	}
+	_, err = mgr.orphanedPayments(laterHeight)
+	if err != nil {
+		t.Fatalf("[PPS] unable to fetch orphaned payments: %v", err)
+	}
+
+	// Ensure FPPS credits shares including the transaction fees of the
+	// connected block.
+	err = emptyBucket(db, paymentBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	pCfg.PaymentMethod = FPPS
+	createShares(xID, xID, xID, yID)
+	err = mgr.creditShares(header)
+	if err != nil {
+		t.Fatalf("[FPPS] unable to credit shares: %v", err)
+	}
+	x, _, _ = creditedAmounts()
+	value, _ = big.NewRat(3*(workSubsidy+txFees), 1000).Float64()
+	expectedX = dcrutil.Amount(value)
+	expectedX -= expectedX.MulF64(pCfg.PoolFee)
+	if x != expectedX {
+		t.Fatalf("[FPPS] expected a credit of %v for account x, got %v",
+			expectedX, x)
+	}
+
+	// Ensure FPPS credits shares of blocks mined by others paying to
+	// addresses other than the pool mining addresses.
+	err = emptyBucket(db, paymentBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	coinbaseScript, err = txscript.PayToAddrScript(poolFeeAddrs)
+	if err != nil {
+		t.Fatalf("[PayToAddrScript] unexpected error: %v", err)
+	}
+	createShares(xID, xID, xID, yID)
+	err = mgr.creditShares(header)
+	if err != nil {
+		t.Fatalf("[FPPS] unable to credit shares of a block mined by "+
+			"others: %v", err)
+	}
+	x, _, _ = creditedAmounts()
+	if x != expectedX {
+		t.Fatalf("[FPPS] expected a credit of %v for account x, got %v",
+			expectedX, x)
+	}
+	coinbaseScript = nil
+
+	// Ensure PROP does not credit shares of connected blocks.
+	err = emptyBucket(db, paymentBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	pCfg.PaymentMethod = PROP
+	createShares(xID, xID, xID, yID)
+	err = mgr.creditShares(header)
+	if err != nil {
+		t.Fatalf("[PROP] unable to credit shares: %v", err)
+	}
+	x, y, fees = creditedAmounts()
+	if x != 0 || y != 0 || fees != 0 {
+		t.Fatal("[PROP] expected no payments for a connected block")
+	}
+	percentages, err := mgr.sharePercentages()
+	if err != nil {
+		t.Fatalf("[PROP] unable to fetch share percentages: %v", err)
+	}
+	if percentages[xID].Cmp(big.NewRat(3, 4)) != 0 {
+		t.Fatalf("[PROP] expected a share percentage of 3/4 for account "+
+			"x, got %v", percentages[xID])
+	}
+
+	// Ensure the PPLNS shares window sources the last n shares and prunes
+	// shares outside of the window.
+	err = emptyBucket(db, shareBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	pCfg.PaymentMethod = PPLNS
+	pCfg.PPLNSWindow = SharesWindow
+	pCfg.LastNShares = 4
+	createShares(yID, yID, yID, yID)
+	createShares(xID, xID, xID, yID)
+	percentages, err = mgr.sharePercentages()
+	if err != nil {
+		t.Fatalf("[PPLNS] unable to fetch share percentages: %v", err)
+	}
+	if percentages[xID].Cmp(big.NewRat(3, 4)) != 0 {
+		t.Fatalf("[PPLNS] expected a share percentage of 3/4 for account "+
+			"x, got %v", percentages[xID])
+	}
+	err = mgr.generatePayments(height, coinbase)
+	if err != nil {
+		t.Fatalf("[PPLNS] unable to generate payments: %v", err)
+	}
+	x, y, _ = creditedAmounts()
+	if x-3*y > 3 || 3*y-x > 3 {
+		t.Fatalf("[PPLNS] expected account x to be paid thrice account "+
+			"y, got %v and %v", x, y)
+	}
+	shares, err = PPSEligibleShares(db, 0, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("[PPSEligibleShares] unexpected error: %v", err)
+	}
+	if len(shares) != 4 {
+		t.Fatalf("[PPLNS] expected 4 shares after pruning, got %d",
+			len(shares))
+	}
+
+	// Ensure the PPLNS work window sources the most recent shares worth n
+	// times the network difficulty.
+	pCfg.PPLNSWindow = WorkWindow
+	pCfg.LastNWork = 0.0015
+	percentages, err = mgr.sharePercentages()
+	if err != nil {
+		t.Fatalf("[PPLNS] unable to fetch share percentages: %v", err)
+	}
+	if percentages[xID].Cmp(big.NewRat(1, 2)) != 0 ||
+		percentages[yID].Cmp(big.NewRat(1, 2)) != 0 {
+		t.Fatalf("[PPLNS] expected equal share percentages for accounts "+
+			"x and y, got %v", percentages)
+	}
+
+	// Empty the share and payment buckets.
+	err = emptyBucket(db, shareBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, paymentBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+
+	// Reset backed up values to their defaults.
+	mgr.setLastPaymentCreatedOn(0)
+	mgr.debitPoolBalance(mgr.fetchPoolBalance())
+	err = mgr.persistState()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
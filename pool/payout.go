@@ -0,0 +1,329 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/rpc/walletrpc"
+)
+
+// PayoutStatus represents the state of a payout attempt.
+type PayoutStatus string
+
+const (
+	// PayoutPending indicates the payout transaction is yet to be
+	// constructed.
+	PayoutPending PayoutStatus = "pending"
+	// PayoutConstructed indicates the payout transaction has been
+	// constructed by the wallet.
+	PayoutConstructed PayoutStatus = "constructed"
+	// PayoutSigned indicates the payout transaction has been signed.
+	PayoutSigned PayoutStatus = "signed"
+	// PayoutPublished indicates the payout transaction has been published
+	// to the network.
+	PayoutPublished PayoutStatus = "published"
+	// PayoutConfirmed indicates the payout transaction has been mined.
+	PayoutConfirmed PayoutStatus = "confirmed"
+	// PayoutUnconfirmed indicates the payout transaction was not mined
+	// within the payout confirmation window. It could have been evicted
+	// from the mempool or double spent.
+	PayoutUnconfirmed PayoutStatus = "unconfirmed"
+	// PayoutFailed indicates the payout attempt failed.
+	PayoutFailed PayoutStatus = "failed"
+
+	// maxPayoutRetryBackoff is the maximum number of blocks to wait before
+	// retrying a failed payout.
+	maxPayoutRetryBackoff = 32
+
+	// payoutConfirmationWindow is the number of blocks a published payout
+	// transaction is expected to be mined within.
+	payoutConfirmationWindow = 12
+)
+
+// PayoutAttempt represents an attempt at paying out mature payments to
+// participating accounts.
+type PayoutAttempt struct {
+	UUID              string         `json:"uuid"`
+	Height            uint32         `json:"height"`
+	Amount            dcrutil.Amount `json:"amount"`
+	Status            PayoutStatus   `json:"status"`
+	TransactionID     string         `json:"transactionid"`
+	Error             string         `json:"error"`
+	Failures          uint32         `json:"failures"`
+	NextAttemptHeight uint32         `json:"nextattemptheight"`
+	ConfirmedHeight   uint32         `json:"confirmedheight"`
+	CreatedOn         int64          `json:"createdon"`
+	UpdatedOn         int64          `json:"updatedon"`
+}
+
+// NewPayoutAttempt creates a payout attempt for the provided amount at the
+// provided height.
+func NewPayoutAttempt(height uint32, amount dcrutil.Amount) *PayoutAttempt {
+	now := time.Now().UnixNano()
+	return &PayoutAttempt{
+		UUID:      hex.EncodeToString(nanoToBigEndianBytes(now)),
+		Height:    height,
+		Amount:    amount,
+		Status:    PayoutPending,
+		CreatedOn: now,
+		UpdatedOn: now,
+	}
+}
+
+// payoutRetryBackoff returns the number of blocks to wait before retrying a
+// payout after the provided number of consecutive failures. The backoff
+// doubles with each failure up to the maximum payout retry backoff.
+func payoutRetryBackoff(failures uint32) uint32 {
+	if failures == 0 {
+		return 0
+	}
+	backoff := uint32(1)
+	for i := uint32(1); i < failures && backoff < maxPayoutRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// Create persists a payout attempt to the database.
+func (attempt *PayoutAttempt) Create(db Database) error {
+	return db.PersistPayoutAttempt(attempt)
+}
+
+// Update persists the updated payout attempt to the database.
+func (attempt *PayoutAttempt) Update(db Database) error {
+	attempt.UpdatedOn = time.Now().UnixNano()
+	return attempt.Create(db)
+}
+
+// filterPayoutAttempts iterates the payout attempts, the result set is
+// generated based on the provided filter. List is ordered, most recent
+// comes first.
+func filterPayoutAttempts(db Database, filter func(*PayoutAttempt) bool) ([]*PayoutAttempt, error) {
+	all, err := db.FetchPayoutAttempts()
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]*PayoutAttempt, 0)
+	for _, attempt := range all {
+		if filter(attempt) {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+// fetchLastPayoutAttempt fetches the most recent payout attempt, it returns
+// nil if no payout has been attempted.
+func fetchLastPayoutAttempt(db Database) (*PayoutAttempt, error) {
+	attempts, err := db.FetchPayoutAttempts()
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+	return attempts[0], nil
+}
+
+// FetchPayoutAttempts fetches all payout attempts. List is ordered, most
+// recent comes first.
+func (h *Hub) FetchPayoutAttempts() ([]*PayoutAttempt, error) {
+	return filterPayoutAttempts(h.db, func(*PayoutAttempt) bool {
+		return true
+	})
+}
+
+// FetchFailedPayoutAttempts fetches all failed payout attempts. List is
+// ordered, most recent comes first.
+func (h *Hub) FetchFailedPayoutAttempts() ([]*PayoutAttempt, error) {
+	return filterPayoutAttempts(h.db, func(attempt *PayoutAttempt) bool {
+		return attempt.Status == PayoutFailed
+	})
+}
+
+// fetchTrackedPayoutAttempts fetches all published payout attempts yet to be
+// confirmed mined.
+func fetchTrackedPayoutAttempts(db Database) ([]*PayoutAttempt, error) {
+	return filterPayoutAttempts(db, func(attempt *PayoutAttempt) bool {
+		return attempt.Status == PayoutPublished ||
+			attempt.Status == PayoutUnconfirmed
+	})
+}
+
+// PayoutReconciliation represents the outcome of reconciling the archived
+// payments of a payout transaction against the wallet's transaction history.
+type PayoutReconciliation struct {
+	TransactionID string              `json:"transactionid"`
+	Confirmations int32               `json:"confirmations"`
+	Payments      int                 `json:"payments"`
+	Unmatched     []*UnmatchedPayment `json:"unmatched"`
+	Error         string              `json:"error"`
+}
+
+// UnmatchedPayment represents the archived payments of an account not paid
+// by an output of the payout transaction to the account's address.
+type UnmatchedPayment struct {
+	Account  string         `json:"account"`
+	Address  string         `json:"address"`
+	Expected dcrutil.Amount `json:"expected"`
+	Paid     dcrutil.Amount `json:"paid"`
+	Reason   string         `json:"reason"`
+}
+
+// Reconciled returns whether all archived payments of the payout transaction
+// were found in a mined wallet transaction.
+func (r *PayoutReconciliation) Reconciled() bool {
+	return r.Error == "" && r.Confirmations > 0 && len(r.Unmatched) == 0
+}
+
+// reconcilePayments compares archived payments against the payout
+// transactions fetched using the provided function. The payments of each
+// account are expected to be paid by the outputs of the transaction to the
+// account's address. Pool fee payments are only checked for confirmation
+// since the paid amount is adjusted by the tx fee reserve.
+func reconcilePayments(db Database, params *chaincfg.Params, getTx func(*chainhash.Hash) (*walletrpc.GetTransactionResponse, error)) ([]*PayoutReconciliation, error) {
+	pmts, err := fetchArchivedPayments(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the archived payment totals by transaction and account.
+	totals := make(map[string]map[string]dcrutil.Amount)
+	counts := make(map[string]int)
+	txids := make([]string, 0)
+	for _, pmt := range pmts {
+		accounts, ok := totals[pmt.TransactionID]
+		if !ok {
+			accounts = make(map[string]dcrutil.Amount)
+			totals[pmt.TransactionID] = accounts
+			txids = append(txids, pmt.TransactionID)
+		}
+		accounts[pmt.Account] += pmt.Amount
+		counts[pmt.TransactionID]++
+	}
+
+	recs := make([]*PayoutReconciliation, 0, len(txids))
+	for _, txid := range txids {
+		rec := &PayoutReconciliation{
+			TransactionID: txid,
+			Payments:      counts[txid],
+			Unmatched:     make([]*UnmatchedPayment, 0),
+		}
+		recs = append(recs, rec)
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			rec.Error = fmt.Sprintf("invalid transaction id: %v", err)
+			continue
+		}
+		resp, err := getTx(hash)
+		if err != nil {
+			rec.Error = err.Error()
+			continue
+		}
+		if resp.Transaction == nil {
+			rec.Error = "transaction not found"
+			continue
+		}
+		rec.Confirmations = resp.Confirmations
+		var tx wire.MsgTx
+		err = tx.Deserialize(bytes.NewReader(resp.Transaction.Transaction))
+		if err != nil {
+			rec.Error = fmt.Sprintf("unable to deserialize "+
+				"transaction: %v", err)
+			continue
+		}
+		paid := make(map[string]dcrutil.Amount, len(tx.TxOut))
+		for _, out := range tx.TxOut {
+			paid[string(out.PkScript)] += dcrutil.Amount(out.Value)
+		}
+		for account, amt := range totals[txid] {
+			if account == poolFeesK {
+				continue
+			}
+			unmatched := reconcileAccount(db, params, account, amt, paid)
+			if unmatched != nil {
+				rec.Unmatched = append(rec.Unmatched, unmatched)
+			}
+		}
+		sort.Slice(rec.Unmatched, func(i, j int) bool {
+			return rec.Unmatched[i].Account < rec.Unmatched[j].Account
+		})
+	}
+	return recs, nil
+}
+
+// reconcileAccount compares the archived payment total of the provided
+// account against the provided payout transaction output totals, keyed by
+// output script. The unmatched payment of the account is returned if its
+// address is not paid the expected amount, nil otherwise.
+func reconcileAccount(db Database, params *chaincfg.Params, account string, amt dcrutil.Amount, paid map[string]dcrutil.Amount) *UnmatchedPayment {
+	unmatched := &UnmatchedPayment{
+		Account:  account,
+		Expected: amt,
+	}
+	acc, err := db.FetchAccount(account)
+	if err != nil {
+		unmatched.Reason = fmt.Sprintf("unable to fetch account: %v", err)
+		return unmatched
+	}
+	unmatched.Address = acc.Address
+	addr, err := dcrutil.DecodeAddress(acc.Address, params)
+	if err != nil {
+		unmatched.Reason = fmt.Sprintf("unable to decode address: %v", err)
+		return unmatched
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		unmatched.Reason = fmt.Sprintf("unable to create address "+
+			"script: %v", err)
+		return unmatched
+	}
+	total, ok := paid[string(script)]
+	if !ok {
+		unmatched.Reason = "no output pays the account address"
+		return unmatched
+	}
+	if total != amt {
+		unmatched.Paid = total
+		unmatched.Reason = "outputs to the account address pay a " +
+			"different amount"
+		return unmatched
+	}
+	return nil
+}
+
+// ReconcilePayments compares all archived payments against the wallet's
+// transaction history, proving each archived payment was paid by a mined
+// transaction.
+func (h *Hub) ReconcilePayments() ([]*PayoutReconciliation, error) {
+	walletConn := h.fetchWalletConnection()
+	if walletConn == nil {
+		return nil, MakeError(ErrOther, "wallet connection unset", nil)
+	}
+	getTx := func(hash *chainhash.Hash) (*walletrpc.GetTransactionResponse, error) {
+		req := &walletrpc.GetTransactionRequest{
+			TransactionHash: hash[:],
+		}
+		start := time.Now()
+		resp, err := walletConn.GetTransaction(context.TODO(), req)
+		h.observeRPC(walletService, "gettransaction", start)
+		if err != nil {
+			return nil, h.handleWalletError(err)
+		}
+		return resp, nil
+	}
+	return reconcilePayments(h.db, h.cfg.ActiveNet, getTx)
+}
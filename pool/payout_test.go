@@ -0,0 +1,242 @@
+package pool
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/rpc/walletrpc"
+)
+
+func testPayouts(t *testing.T, db Database) {
+	// Create the payout transactions.
+	params := chaincfg.SimNetParams()
+	scripts := make(map[string][]byte)
+	for _, address := range []string{xAddr, yAddr} {
+		addr, err := dcrutil.DecodeAddress(address, params)
+		if err != nil {
+			t.Fatalf("[DecodeAddress] unexpected error: %v", err)
+		}
+		scripts[address], err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("[PayToAddrScript] unexpected error: %v", err)
+		}
+	}
+	txA := wire.NewMsgTx()
+	txA.AddTxOut(wire.NewTxOut(500, scripts[xAddr]))
+	txA.AddTxOut(wire.NewTxOut(700, scripts[yAddr]))
+	txB := wire.NewMsgTx()
+	txB.AddTxOut(wire.NewTxOut(900, scripts[xAddr]))
+	txB.AddTxOut(wire.NewTxOut(100, scripts[yAddr]))
+	txs := map[chainhash.Hash]*wire.MsgTx{
+		txA.TxHash(): txA,
+		txB.TxHash(): txB,
+	}
+
+	block := &wire.MsgBlock{
+		Transactions: []*wire.MsgTx{txA},
+	}
+	blockHash := block.BlockHash()
+	mgr := &PaymentMgr{
+		cfg: &PaymentMgrConfig{
+			DB: db,
+			GetBlock: func(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+				if !hash.IsEqual(&blockHash) {
+					return nil, fmt.Errorf("unknown block %s", hash)
+				}
+				return block, nil
+			},
+		},
+	}
+
+	// Ensure published payouts are confirmed by the block mining them.
+	attemptA := NewPayoutAttempt(10, dcrutil.Amount(1200))
+	attemptA.Status = PayoutPublished
+	attemptA.TransactionID = txA.TxHash().String()
+	err := attemptA.Create(db)
+	if err != nil {
+		t.Fatalf("[Create] unexpected error: %v", err)
+	}
+	attemptB := NewPayoutAttempt(11, dcrutil.Amount(900))
+	attemptB.Status = PayoutPublished
+	attemptB.TransactionID = txB.TxHash().String()
+	err = attemptB.Create(db)
+	if err != nil {
+		t.Fatalf("[Create] unexpected error: %v", err)
+	}
+
+	err = mgr.confirmPayouts(&blockHash, 12)
+	if err != nil {
+		t.Fatalf("[confirmPayouts] unexpected error: %v", err)
+	}
+	attempts, err := fetchTrackedPayoutAttempts(db)
+	if err != nil {
+		t.Fatalf("[fetchTrackedPayoutAttempts] unexpected error: %v", err)
+	}
+	if len(attempts) != 1 {
+		t.Fatalf("expected one tracked payout attempt, got %d", len(attempts))
+	}
+	if attempts[0].UUID != attemptB.UUID {
+		t.Fatalf("expected payout attempt %s to be tracked, got %s",
+			attemptB.UUID, attempts[0].UUID)
+	}
+
+	// Ensure a published payout not mined within the confirmation window is
+	// flagged as unconfirmed.
+	err = mgr.confirmPayouts(&blockHash, 11+payoutConfirmationWindow+1)
+	if err != nil {
+		t.Fatalf("[confirmPayouts] unexpected error: %v", err)
+	}
+	attempts, err = fetchTrackedPayoutAttempts(db)
+	if err != nil {
+		t.Fatalf("[fetchTrackedPayoutAttempts] unexpected error: %v", err)
+	}
+	if attempts[0].Status != PayoutUnconfirmed {
+		t.Fatalf("expected payout attempt status %s, got %s",
+			PayoutUnconfirmed, attempts[0].Status)
+	}
+
+	// Ensure payouts confirmed by a disconnected block are reverted to
+	// published.
+	err = mgr.unconfirmPayouts(12)
+	if err != nil {
+		t.Fatalf("[unconfirmPayouts] unexpected error: %v", err)
+	}
+	attempts, err = fetchTrackedPayoutAttempts(db)
+	if err != nil {
+		t.Fatalf("[fetchTrackedPayoutAttempts] unexpected error: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected two tracked payout attempts, got %d", len(attempts))
+	}
+	for _, attempt := range attempts {
+		if attempt.UUID == attemptA.UUID &&
+			(attempt.Status != PayoutPublished || attempt.ConfirmedHeight != 0) {
+			t.Fatalf("expected payout attempt %s to be reverted to "+
+				"published, got status %s at height %d", attempt.UUID,
+				attempt.Status, attempt.ConfirmedHeight)
+		}
+	}
+
+	// Archive payments paid by the payout transactions.
+	bundleX := newPaymentBundle(xID)
+	bundleX.Payments = []*Payment{
+		NewPayment(xID, dcrutil.Amount(200), 10, 20),
+		NewPayment(xID, dcrutil.Amount(300), 10, 20),
+	}
+	bundleX.UpdateAsPaid(db, 30, txA.TxHash().String())
+	bundleY := newPaymentBundle(yID)
+	bundleY.Payments = []*Payment{
+		NewPayment(yID, dcrutil.Amount(700), 10, 20),
+	}
+	bundleY.UpdateAsPaid(db, 30, txA.TxHash().String())
+	bundleFee := newPaymentBundle(poolFeesK)
+	bundleFee.Payments = []*Payment{
+		NewPayment(poolFeesK, dcrutil.Amount(50), 10, 20),
+	}
+	bundleFee.UpdateAsPaid(db, 30, txA.TxHash().String())
+	bundleZ := newPaymentBundle(yID)
+	bundleZ.Payments = []*Payment{
+		NewPayment(yID, dcrutil.Amount(900), 11, 21),
+	}
+	bundleZ.UpdateAsPaid(db, 31, txB.TxHash().String())
+	bundleW := newPaymentBundle("unknown")
+	bundleW.Payments = []*Payment{
+		NewPayment("unknown", dcrutil.Amount(100), 11, 21),
+	}
+	bundleW.UpdateAsPaid(db, 31, txB.TxHash().String())
+	bundles := []*PaymentBundle{bundleX, bundleY, bundleFee, bundleZ, bundleW}
+	for _, bundle := range bundles {
+		err = bundle.ArchivePayments(db)
+		if err != nil {
+			t.Fatalf("[ArchivePayments] unexpected error: %v", err)
+		}
+	}
+
+	// Ensure archived payments reconcile against the wallet's transactions.
+	getTx := func(hash *chainhash.Hash) (*walletrpc.GetTransactionResponse, error) {
+		tx, ok := txs[*hash]
+		if !ok {
+			return nil, fmt.Errorf("unknown transaction %s", hash)
+		}
+		var buf bytes.Buffer
+		err := tx.Serialize(&buf)
+		if err != nil {
+			return nil, err
+		}
+		return &walletrpc.GetTransactionResponse{
+			Transaction: &walletrpc.TransactionDetails{
+				Hash:        hash[:],
+				Transaction: buf.Bytes(),
+			},
+			Confirmations: 6,
+		}, nil
+	}
+	recs, err := reconcilePayments(db, params, getTx)
+	if err != nil {
+		t.Fatalf("[reconcilePayments] unexpected error: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected two payout reconciliations, got %d", len(recs))
+	}
+	for _, rec := range recs {
+		switch rec.TransactionID {
+		case txA.TxHash().String():
+			if !rec.Reconciled() {
+				t.Fatalf("expected payout %s to be reconciled, "+
+					"unmatched accounts: %v, error: %s", rec.TransactionID,
+					rec.Unmatched, rec.Error)
+			}
+			if rec.Payments != 4 {
+				t.Fatalf("expected 4 payments for payout %s, got %d",
+					rec.TransactionID, rec.Payments)
+			}
+
+		case txB.TxHash().String():
+			if rec.Reconciled() {
+				t.Fatalf("expected payout %s to not be reconciled",
+					rec.TransactionID)
+			}
+			// The output of the same amount paying another address and
+			// the payment of an unknown account must not match.
+			if len(rec.Unmatched) != 2 {
+				t.Fatalf("expected 2 unmatched accounts, got %d",
+					len(rec.Unmatched))
+			}
+			unmatched := make(map[string]*UnmatchedPayment)
+			for _, u := range rec.Unmatched {
+				unmatched[u.Account] = u
+			}
+			u, ok := unmatched[yID]
+			if !ok || u.Address != yAddr || u.Expected != 900 ||
+				u.Paid != 100 {
+				t.Fatalf("expected account %s to be unmatched paying "+
+					"100 of 900, got %+v", yID, u)
+			}
+			u, ok = unmatched["unknown"]
+			if !ok || u.Reason == "" {
+				t.Fatalf("expected the unknown account to be "+
+					"unmatched, got %+v", u)
+			}
+
+		default:
+			t.Fatalf("unexpected payout reconciliation %s",
+				rec.TransactionID)
+		}
+	}
+
+	// Empty the payout and payment archive buckets.
+	err = emptyBucket(db, payoutBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, paymentArchiveBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}
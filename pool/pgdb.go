@@ -0,0 +1,1288 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	_ "github.com/lib/pq" // Register the postgres driver.
+)
+
+const (
+	// pgPayments is the table of pending payments.
+	pgPayments = "payments"
+	// pgArchivedPayments is the table of archived payments.
+	pgArchivedPayments = "archivedpayments"
+)
+
+// pgTables maps the buckets of the pool database to the postgres tables
+// storing their entries.
+var pgTables = map[string]string{
+	string(accountBkt):        "accounts",
+	string(shareBkt):          "shares",
+	string(workBkt):           "acceptedwork",
+	string(jobBkt):            "jobs",
+	string(paymentBkt):        pgPayments,
+	string(paymentArchiveBkt): pgArchivedPayments,
+	string(payoutBkt):         "payouts",
+	string(workerBkt):         "workers",
+	string(webhookBkt):        "webhooks",
+	string(roundBkt):          "rounds",
+}
+
+// PostgresConfig contains the connection details of a postgres database.
+type PostgresConfig struct {
+	// Host is the host of the postgres server.
+	Host string
+	// Port is the port of the postgres server.
+	Port uint32
+	// User is the user to connect as.
+	User string
+	// Pass is the password of the user.
+	Pass string
+	// DBName is the name of the database.
+	DBName string
+	// SSLMode is the SSL mode of the connection. {disable, require,
+	// verify-ca, verify-full}
+	SSLMode string
+}
+
+// dataSourceName returns the connection url of the postgres database.
+func (cfg *PostgresConfig) dataSourceName() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Pass),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port))),
+		Path:     cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// PostgresDB is the postgres implementation of the pool database. Entries
+// queried by their fields are stored in typed columns, all others are json
+// encoded. Pool metadata is stored as key value pairs keyed as it is by the
+// bolt database.
+type PostgresDB struct {
+	*sql.DB
+}
+
+// Ensure PostgresDB implements the Database interface.
+var _ Database = (*PostgresDB)(nil)
+
+// pgQuerier is satisfied by both postgres connections and transactions.
+type pgQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// pgScanner is satisfied by both a single queried row and a set of queried
+// rows.
+type pgScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// openPostgresDB creates a connection to the postgres database referenced by
+// the provided connection url and migrates its schema to the latest version.
+func openPostgresDB(dsn string) (*PostgresDB, error) {
+	sqlDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, MakeError(ErrDBOpen, "unable to open postgres db", err)
+	}
+	err = sqlDB.Ping()
+	if err != nil {
+		sqlDB.Close()
+		return nil, MakeError(ErrDBOpen, "unable to connect to postgres db", err)
+	}
+	db := &PostgresDB{sqlDB}
+	err = migratePostgresDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenPostgresDB creates a connection to the postgres pool database and
+// migrates its schema to the latest version, the pool mode of the database
+// is not checked.
+func OpenPostgresDB(cfg *PostgresConfig) (*PostgresDB, error) {
+	return openPostgresDB(cfg.dataSourceName())
+}
+
+// InitPostgresDB handles the connection to and the schema migration of the
+// postgres pool database. Postgres databases are not backed up by the pool,
+// a database of a different pool mode is therefore refused instead of being
+// wiped.
+func InitPostgresDB(cfg *PostgresConfig, isSoloPool bool) (*PostgresDB, error) {
+	db, err := openPostgresDB(cfg.dataSourceName())
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := db.FetchPoolMode()
+	if err != nil {
+		if !IsError(err, ErrValueNotFound) {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+	if isSoloPool != (mode == 1) {
+		db.Close()
+		desc := "postgres db pool mode does not match the configured " +
+			"pool mode, empty the database or use another one to switch " +
+			"pool modes"
+		return nil, MakeError(ErrOther, desc, nil)
+	}
+	return db, nil
+}
+
+// update executes the provided function within a transaction, the
+// transaction is rolled back if the function errors.
+func (db *PostgresDB) update(f func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	err = f(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Backup is not supported for the postgres database, backups are managed
+// by the postgres server.
+func (db *PostgresDB) Backup(fileName string) error {
+	desc := "backup not supported by the postgres database"
+	return MakeError(ErrNotSupported, desc, nil)
+}
+
+// HTTPBackup is not supported for the postgres database, backups are
+// managed by the postgres server.
+func (db *PostgresDB) HTTPBackup(w http.ResponseWriter) error {
+	desc := "backup not supported by the postgres database"
+	return MakeError(ErrNotSupported, desc, nil)
+}
+
+// Snapshot is not supported for the postgres database, backups are
+// managed by the postgres server.
+func (db *PostgresDB) Snapshot(dir string, compress bool) (string, error) {
+	desc := "snapshot not supported by the postgres database"
+	return "", MakeError(ErrNotSupported, desc, nil)
+}
+
+// Purge removes all existing data.
+func (db *PostgresDB) Purge() error {
+	return db.update(func(tx *sql.Tx) error {
+		for _, table := range pgTables {
+			_, err := tx.Exec("DELETE FROM " + table)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("DELETE FROM history")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM metadata")
+		return err
+	})
+}
+
+// emptyBucket deletes all entries of the table of the provided bucket.
+func (db *PostgresDB) emptyBucket(bucket []byte) error {
+	if string(bucket) == string(minuteHistoryBkt) ||
+		string(bucket) == string(hourHistoryBkt) {
+		resolution := MinuteResolution
+		if string(bucket) == string(hourHistoryBkt) {
+			resolution = HourResolution
+		}
+		_, err := db.Exec("DELETE FROM history WHERE resolution = $1",
+			resolution)
+		return err
+	}
+	table, ok := pgTables[string(bucket)]
+	if !ok {
+		desc := fmt.Sprintf("bucket %s not found", string(bucket))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+	_, err := db.Exec("DELETE FROM " + table)
+	return err
+}
+
+// fetchMetadata fetches the value of the provided metadata key. An
+// ErrValueNotFound error is returned if no value exists.
+func fetchMetadata(q pgQuerier, key []byte) (string, error) {
+	var value string
+	err := q.QueryRow("SELECT value FROM metadata WHERE key = $1",
+		string(key)).Scan(&value)
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no value found for %s", string(key))
+		return "", MakeError(ErrValueNotFound, desc, nil)
+	}
+	return value, err
+}
+
+// putMetadata saves the provided value of the provided metadata key.
+func putMetadata(q pgQuerier, key []byte, value string) error {
+	_, err := q.Exec("INSERT INTO metadata (key, value) VALUES ($1, $2) "+
+		"ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value",
+		string(key), value)
+	return err
+}
+
+// FetchPoolMode fetches the persisted pool mode.
+func (db *PostgresDB) FetchPoolMode() (uint32, error) {
+	v, err := fetchMetadata(db, soloPool)
+	if err != nil {
+		return 0, err
+	}
+	mode, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		desc := fmt.Sprintf("invalid pool mode %s", v)
+		return 0, MakeError(ErrParse, desc, err)
+	}
+	return uint32(mode), nil
+}
+
+// PersistPoolMode saves the provided pool mode.
+func (db *PostgresDB) PersistPoolMode(mode uint32) error {
+	return putMetadata(db, soloPool, strconv.FormatUint(uint64(mode), 10))
+}
+
+// FetchCSRFSecret fetches the persisted CSRF secret.
+func (db *PostgresDB) FetchCSRFSecret() ([]byte, error) {
+	v, err := fetchMetadata(db, csrfSecret)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, MakeError(ErrDecode, "unable to decode csrf secret", err)
+	}
+	return secret, nil
+}
+
+// PersistCSRFSecret saves the provided CSRF secret.
+func (db *PostgresDB) PersistCSRFSecret(secret []byte) error {
+	return putMetadata(db, csrfSecret, hex.EncodeToString(secret))
+}
+
+// fetchPgPaymentState fetches the persisted payment manager state.
+func fetchPgPaymentState(q pgQuerier) (*PaymentState, error) {
+	state := &PaymentState{
+		MinedRewards: make(map[uint32]dcrutil.Amount),
+	}
+	rows, err := q.Query("SELECT key, value FROM metadata")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case string(lastPaymentHeight):
+			var height uint64
+			height, err = strconv.ParseUint(value, 10, 32)
+			state.LastPaymentHeight = uint32(height)
+		case string(lastPaymentPaidOn):
+			state.LastPaymentPaidOn, err = strconv.ParseUint(value, 10, 64)
+		case string(lastPaymentCreatedOn):
+			state.LastPaymentCreatedOn, err = strconv.ParseUint(value, 10, 64)
+		case string(txFeeReserve):
+			var amt int64
+			amt, err = strconv.ParseInt(value, 10, 64)
+			state.TxFeeReserve = dcrutil.Amount(amt)
+		case string(poolBalance):
+			var amt int64
+			amt, err = strconv.ParseInt(value, 10, 64)
+			state.PoolBalance = dcrutil.Amount(amt)
+		case string(minedRewards):
+			err = json.Unmarshal([]byte(value), &state.MinedRewards)
+		default:
+			continue
+		}
+		if err != nil {
+			desc := fmt.Sprintf("invalid %s value %s", key, value)
+			return nil, MakeError(ErrParse, desc, err)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if state.MinedRewards == nil {
+		state.MinedRewards = make(map[uint32]dcrutil.Amount)
+	}
+	return state, nil
+}
+
+// putPgPaymentState saves the provided payment manager state.
+func putPgPaymentState(q pgQuerier, state *PaymentState) error {
+	rewards, err := json.Marshal(state.MinedRewards)
+	if err != nil {
+		return err
+	}
+	values := []struct {
+		key   []byte
+		value string
+	}{
+		{lastPaymentHeight, strconv.FormatUint(uint64(state.LastPaymentHeight), 10)},
+		{lastPaymentPaidOn, strconv.FormatUint(state.LastPaymentPaidOn, 10)},
+		{lastPaymentCreatedOn, strconv.FormatUint(state.LastPaymentCreatedOn, 10)},
+		{txFeeReserve, strconv.FormatInt(int64(state.TxFeeReserve), 10)},
+		{poolBalance, strconv.FormatInt(int64(state.PoolBalance), 10)},
+		{minedRewards, string(rewards)},
+	}
+	for _, v := range values {
+		err := putMetadata(q, v.key, v.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchPaymentState fetches the persisted state of the payment manager.
+func (db *PostgresDB) FetchPaymentState() (*PaymentState, error) {
+	return fetchPgPaymentState(db)
+}
+
+// PersistPaymentState saves the provided payment manager state.
+func (db *PostgresDB) PersistPaymentState(state *PaymentState) error {
+	return db.update(func(tx *sql.Tx) error {
+		return putPgPaymentState(tx, state)
+	})
+}
+
+// FetchRoundWork fetches the work contributed to the pool since the last
+// block found by the pool.
+func (db *PostgresDB) FetchRoundWork() (*big.Rat, error) {
+	work := new(big.Rat)
+	v, err := fetchMetadata(db, roundWork)
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			return work, nil
+		}
+		return nil, err
+	}
+	_, ok := work.SetString(v)
+	if !ok {
+		desc := fmt.Sprintf("invalid round work %s", v)
+		return nil, MakeError(ErrParse, desc, nil)
+	}
+	return work, nil
+}
+
+// PersistRoundWork saves the provided round work.
+func (db *PostgresDB) PersistRoundWork(work *big.Rat) error {
+	return putMetadata(db, roundWork, work.String())
+}
+
+// FetchAccount fetches the account referenced by the provided id.
+func (db *PostgresDB) FetchAccount(id string) (*Account, error) {
+	var createdOn int64
+	account := &Account{UUID: id}
+	err := db.QueryRow("SELECT address, createdon FROM accounts "+
+		"WHERE uuid = $1", id).Scan(&account.Address, &createdOn)
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no account found for id %s", id)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	account.CreatedOn = uint64(createdOn)
+	return account, nil
+}
+
+// putPgAccount saves the provided account.
+func putPgAccount(q pgQuerier, account *Account) error {
+	_, err := q.Exec("INSERT INTO accounts (uuid, address, createdon) "+
+		"VALUES ($1, $2, $3) ON CONFLICT (uuid) DO UPDATE SET "+
+		"address = EXCLUDED.address, createdon = EXCLUDED.createdon",
+		account.UUID, account.Address, int64(account.CreatedOn))
+	return err
+}
+
+// PersistAccount saves the provided account.
+func (db *PostgresDB) PersistAccount(account *Account) error {
+	return putPgAccount(db, account)
+}
+
+// DeleteAccount removes the account referenced by the provided id.
+func (db *PostgresDB) DeleteAccount(id string) error {
+	_, err := db.Exec("DELETE FROM accounts WHERE uuid = $1", id)
+	return err
+}
+
+// FetchJob fetches the job referenced by the provided id.
+func (db *PostgresDB) FetchJob(id string) (*Job, error) {
+	var height int64
+	job := &Job{UUID: id}
+	err := db.QueryRow("SELECT height, header FROM jobs WHERE uuid = $1",
+		id).Scan(&height, &job.Header)
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no value found for job id %s", id)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Height = uint32(height)
+	return job, nil
+}
+
+// putPgJob saves the provided job.
+func putPgJob(q pgQuerier, job *Job) error {
+	_, err := q.Exec("INSERT INTO jobs (uuid, height, header) "+
+		"VALUES ($1, $2, $3) ON CONFLICT (uuid) DO UPDATE SET "+
+		"height = EXCLUDED.height, header = EXCLUDED.header",
+		job.UUID, int64(job.Height), job.Header)
+	return err
+}
+
+// PersistJob saves the provided job.
+func (db *PostgresDB) PersistJob(job *Job) error {
+	return putPgJob(db, job)
+}
+
+// DeleteJob removes the job referenced by the provided id.
+func (db *PostgresDB) DeleteJob(id string) error {
+	_, err := db.Exec("DELETE FROM jobs WHERE uuid = $1", id)
+	return err
+}
+
+// PruneJobs removes all jobs with heights less than the provided height.
+func (db *PostgresDB) PruneJobs(height uint32) error {
+	_, err := db.Exec("DELETE FROM jobs WHERE height < $1", int64(height))
+	return err
+}
+
+// ratString returns the nullable string representation of the provided
+// rational.
+func ratString(r *big.Rat) sql.NullString {
+	if r == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: r.String(), Valid: true}
+}
+
+// parseRat parses the provided nullable string representation of a
+// rational.
+func parseRat(s sql.NullString) (*big.Rat, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	r, ok := new(big.Rat).SetString(s.String)
+	if !ok {
+		desc := fmt.Sprintf("invalid rational %s", s.String)
+		return nil, MakeError(ErrParse, desc, nil)
+	}
+	return r, nil
+}
+
+// pgShareColumns are the columns of the shares table.
+const pgShareColumns = "createdon, account, weight, difficulty"
+
+// putPgShare saves the provided share.
+func putPgShare(q pgQuerier, share *Share) error {
+	_, err := q.Exec("INSERT INTO shares ("+pgShareColumns+") "+
+		"VALUES ($1, $2, $3, $4) ON CONFLICT (createdon) DO UPDATE SET "+
+		"account = EXCLUDED.account, weight = EXCLUDED.weight, "+
+		"difficulty = EXCLUDED.difficulty",
+		share.CreatedOn, share.Account, ratString(share.Weight),
+		ratString(share.Difficulty))
+	return err
+}
+
+// scanPgShare scans a share from the provided row.
+func scanPgShare(row pgScanner) (*Share, error) {
+	var share Share
+	var weight, difficulty sql.NullString
+	err := row.Scan(&share.CreatedOn, &share.Account, &weight, &difficulty)
+	if err != nil {
+		return nil, err
+	}
+	share.Weight, err = parseRat(weight)
+	if err != nil {
+		return nil, err
+	}
+	share.Difficulty, err = parseRat(difficulty)
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// queryPgShares fetches the shares of the provided query.
+func queryPgShares(q pgQuerier, query string, args ...interface{}) ([]*Share, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := make([]*Share, 0)
+	for rows.Next() {
+		share, err := scanPgShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// PersistShare saves the provided share.
+func (db *PostgresDB) PersistShare(share *Share) error {
+	return putPgShare(db, share)
+}
+
+// FetchShares fetches all shares created within the provided inclusive
+// bounds.
+func (db *PostgresDB) FetchShares(minNano int64, maxNano int64) ([]*Share, error) {
+	return queryPgShares(db, "SELECT "+pgShareColumns+" FROM shares "+
+		"WHERE createdon >= $1 AND createdon <= $2 ORDER BY createdon",
+		minNano, maxNano)
+}
+
+// FetchLastShares fetches shares from the most recent until the provided
+// function reports the window of shares is complete.
+func (db *PostgresDB) FetchLastShares(complete func(*Share) bool) ([]*Share, error) {
+	rows, err := db.Query("SELECT " + pgShareColumns + " FROM shares " +
+		"ORDER BY createdon DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := make([]*Share, 0)
+	for rows.Next() {
+		share, err := scanPgShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+		if complete(share) {
+			break
+		}
+	}
+	return shares, rows.Err()
+}
+
+// PruneShares removes all shares created before the provided minimum and
+// saves the provided payment manager state.
+func (db *PostgresDB) PruneShares(minNano int64, state *PaymentState) error {
+	return db.update(func(tx *sql.Tx) error {
+		err := putPgPaymentState(tx, state)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM shares WHERE createdon < $1", minNano)
+		return err
+	})
+}
+
+// fetchPgRound fetches the archived round at the provided height.
+func fetchPgRound(q pgQuerier, height uint32) (*Round, error) {
+	var lastPaymentCreatedOn int64
+	var shares []byte
+	round := &Round{Height: height}
+	err := q.QueryRow("SELECT lastpaymentcreatedon, createdon, shares "+
+		"FROM rounds WHERE height = $1", int64(height)).Scan(
+		&lastPaymentCreatedOn, &round.CreatedOn, &shares)
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no round found for height %d", height)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	round.LastPaymentCreatedOn = uint64(lastPaymentCreatedOn)
+	err = json.Unmarshal(shares, &round.Shares)
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// putPgRound saves the provided round.
+func putPgRound(q pgQuerier, round *Round) error {
+	shares, err := json.Marshal(round.Shares)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("INSERT INTO rounds (height, lastpaymentcreatedon, "+
+		"createdon, shares) VALUES ($1, $2, $3, $4) ON CONFLICT (height) "+
+		"DO UPDATE SET lastpaymentcreatedon = "+
+		"EXCLUDED.lastpaymentcreatedon, createdon = EXCLUDED.createdon, "+
+		"shares = EXCLUDED.shares", int64(round.Height),
+		int64(round.LastPaymentCreatedOn), round.CreatedOn, string(shares))
+	return err
+}
+
+// FetchRound fetches the archived round at the provided height.
+func (db *PostgresDB) FetchRound(height uint32) (*Round, error) {
+	return fetchPgRound(db, height)
+}
+
+// ArchiveRound archives all shares created before the provided minimum as
+// the shares of the provided round and removes them.
+func (db *PostgresDB) ArchiveRound(round *Round, minNano int64, state *PaymentState) error {
+	return db.update(func(tx *sql.Tx) error {
+		err := putPgPaymentState(tx, state)
+		if err != nil {
+			return err
+		}
+		round.Shares, err = queryPgShares(tx, "SELECT "+pgShareColumns+
+			" FROM shares WHERE createdon < $1 ORDER BY createdon", minNano)
+		if err != nil {
+			return err
+		}
+		existing, err := fetchPgRound(tx, round.Height)
+		if err != nil && !IsError(err, ErrValueNotFound) {
+			return err
+		}
+		if existing != nil {
+			round.Shares = append(existing.Shares, round.Shares...)
+			round.LastPaymentCreatedOn = existing.LastPaymentCreatedOn
+		}
+		err = putPgRound(tx, round)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM shares WHERE createdon < $1", minNano)
+		return err
+	})
+}
+
+// RestoreRound restores the shares of the archived round at the provided
+// height and removes the round.
+func (db *PostgresDB) RestoreRound(height uint32) (*Round, error) {
+	var round *Round
+	err := db.update(func(tx *sql.Tx) error {
+		var err error
+		round, err = fetchPgRound(tx, height)
+		if err != nil {
+			return err
+		}
+		for _, share := range round.Shares {
+			err := putPgShare(tx, share)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("DELETE FROM rounds WHERE height = $1",
+			int64(height))
+		if err != nil {
+			return err
+		}
+
+		// Restored shares are credited with the next round.
+		v, err := fetchMetadata(tx, lastPaymentCreatedOn)
+		if err != nil {
+			if IsError(err, ErrValueNotFound) {
+				return nil
+			}
+			return err
+		}
+		createdOn, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			desc := fmt.Sprintf("invalid %s value %s",
+				string(lastPaymentCreatedOn), v)
+			return MakeError(ErrParse, desc, err)
+		}
+		if createdOn > round.LastPaymentCreatedOn {
+			return putMetadata(tx, lastPaymentCreatedOn,
+				strconv.FormatUint(round.LastPaymentCreatedOn, 10))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// PruneRounds removes all archived rounds with heights less than the
+// provided height.
+func (db *PostgresDB) PruneRounds(height uint32) error {
+	_, err := db.Exec("DELETE FROM rounds WHERE height < $1", int64(height))
+	return err
+}
+
+// pgWorkColumns are the columns of the accepted work table.
+const pgWorkColumns = "uuid, blockhash, prevhash, height, minedby, miner, " +
+	"createdon, status, submittedon, acceptedon, confirmedon, orphanedon, " +
+	"maturedon, reward, networkdifficulty, effort"
+
+// pgWorkUpsert is the statement saving accepted work, existing work is
+// updated.
+const pgWorkUpsert = "INSERT INTO acceptedwork (" + pgWorkColumns + ") " +
+	"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, " +
+	"$15, $16) ON CONFLICT (uuid) DO UPDATE SET blockhash = " +
+	"EXCLUDED.blockhash, prevhash = EXCLUDED.prevhash, height = " +
+	"EXCLUDED.height, minedby = EXCLUDED.minedby, miner = EXCLUDED.miner, " +
+	"createdon = EXCLUDED.createdon, status = EXCLUDED.status, " +
+	"submittedon = EXCLUDED.submittedon, acceptedon = EXCLUDED.acceptedon, " +
+	"confirmedon = EXCLUDED.confirmedon, orphanedon = EXCLUDED.orphanedon, " +
+	"maturedon = EXCLUDED.maturedon, reward = EXCLUDED.reward, " +
+	"networkdifficulty = EXCLUDED.networkdifficulty, effort = " +
+	"EXCLUDED.effort"
+
+// pgWorkArgs returns the column values of the provided work.
+func pgWorkArgs(work *AcceptedWork) []interface{} {
+	return []interface{}{work.UUID, work.BlockHash, work.PrevHash,
+		int64(work.Height), work.MinedBy, work.Miner, work.CreatedOn,
+		string(work.Status), work.SubmittedOn, work.AcceptedOn,
+		work.ConfirmedOn, work.OrphanedOn, work.MaturedOn,
+		int64(work.Reward), work.NetworkDifficulty, work.Effort}
+}
+
+// scanPgWork scans accepted work from the provided row.
+func scanPgWork(row pgScanner) (*AcceptedWork, error) {
+	var work AcceptedWork
+	var height, reward int64
+	var status string
+	err := row.Scan(&work.UUID, &work.BlockHash, &work.PrevHash, &height,
+		&work.MinedBy, &work.Miner, &work.CreatedOn, &status,
+		&work.SubmittedOn, &work.AcceptedOn, &work.ConfirmedOn,
+		&work.OrphanedOn, &work.MaturedOn, &reward,
+		&work.NetworkDifficulty, &work.Effort)
+	if err != nil {
+		return nil, err
+	}
+	work.Height = uint32(height)
+	work.Status = WorkStatus(status)
+	work.Reward = dcrutil.Amount(reward)
+	return &work, nil
+}
+
+// fetchPgWork fetches the accepted work referenced by the provided id,
+// locking it for update within transactions.
+func fetchPgWork(q pgQuerier, id string, forUpdate bool) (*AcceptedWork, error) {
+	query := "SELECT " + pgWorkColumns + " FROM acceptedwork WHERE uuid = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	work, err := scanPgWork(q.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no value for key %s", id)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	return work, err
+}
+
+// putPgWork saves the provided accepted work.
+func putPgWork(q pgQuerier, work *AcceptedWork) error {
+	_, err := q.Exec(pgWorkUpsert, pgWorkArgs(work)...)
+	return err
+}
+
+// FetchAcceptedWork fetches the accepted work referenced by the provided id.
+func (db *PostgresDB) FetchAcceptedWork(id string) (*AcceptedWork, error) {
+	return fetchPgWork(db, id, false)
+}
+
+// PersistAcceptedWork saves the provided work.
+func (db *PostgresDB) PersistAcceptedWork(work *AcceptedWork) error {
+	res, err := db.Exec("INSERT INTO acceptedwork ("+pgWorkColumns+") "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, "+
+		"$14, $15, $16) ON CONFLICT (uuid) DO NOTHING", pgWorkArgs(work)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		desc := fmt.Sprintf("work %s already exists", work.UUID)
+		return MakeError(ErrWorkExists, desc, nil)
+	}
+	return nil
+}
+
+// UpdateAcceptedWork saves modifications to the provided work.
+func (db *PostgresDB) UpdateAcceptedWork(work *AcceptedWork) error {
+	return db.update(func(tx *sql.Tx) error {
+		_, err := fetchPgWork(tx, work.UUID, true)
+		if err != nil {
+			if IsError(err, ErrValueNotFound) {
+				desc := fmt.Sprintf("work %s not found", work.UUID)
+				return MakeError(ErrWorkNotFound, desc, nil)
+			}
+			return err
+		}
+		return putPgWork(tx, work)
+	})
+}
+
+// AcceptWork transitions the provided work to accepted and saves it.
+func (db *PostgresDB) AcceptWork(work *AcceptedWork) error {
+	return db.update(func(tx *sql.Tx) error {
+		existing, err := fetchPgWork(tx, work.UUID, true)
+		if err != nil && !IsError(err, ErrValueNotFound) {
+			return err
+		}
+		if existing != nil {
+			err := work.acceptOver(existing)
+			if err != nil {
+				return err
+			}
+		}
+		work.setStatus(WorkAccepted)
+		return putPgWork(tx, work)
+	})
+}
+
+// DeleteAcceptedWork removes the accepted work referenced by the provided
+// id.
+func (db *PostgresDB) DeleteAcceptedWork(id string) error {
+	_, err := db.Exec("DELETE FROM acceptedwork WHERE uuid = $1", id)
+	return err
+}
+
+// ListAcceptedWork fetches all accepted work.
+func (db *PostgresDB) ListAcceptedWork() ([]*AcceptedWork, error) {
+	rows, err := db.Query("SELECT " + pgWorkColumns + " FROM acceptedwork " +
+		"ORDER BY uuid DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	minedWork := make([]*AcceptedWork, 0)
+	for rows.Next() {
+		work, err := scanPgWork(rows)
+		if err != nil {
+			return nil, err
+		}
+		minedWork = append(minedWork, work)
+	}
+	return minedWork, rows.Err()
+}
+
+// PruneAcceptedWork removes all submitted and accepted work not confirmed
+// as mined work with heights less than the provided height.
+func (db *PostgresDB) PruneAcceptedWork(height uint32) error {
+	_, err := db.Exec("DELETE FROM acceptedwork WHERE height < $1 AND "+
+		"status IN ($2, $3)", int64(height), string(WorkSubmitted),
+		string(WorkAccepted))
+	return err
+}
+
+// MatureAcceptedWork transitions all confirmed mined work with heights less
+// than or equal to the provided height to matured.
+func (db *PostgresDB) MatureAcceptedWork(height uint32) error {
+	_, err := db.Exec("UPDATE acceptedwork SET status = $1, maturedon = $2 "+
+		"WHERE height <= $3 AND status = $4", string(WorkMatured),
+		time.Now().Unix(), int64(height), string(WorkConfirmed))
+	return err
+}
+
+// pgPaymentColumns are the columns of the payment tables.
+const pgPaymentColumns = "uuid, account, estimatedmaturity, height, " +
+	"amount, createdon, paidonheight, transactionid"
+
+// putPgPayment saves the provided payment to the provided payment table.
+func putPgPayment(q pgQuerier, table string, payment *Payment) error {
+	_, err := q.Exec("INSERT INTO "+table+" ("+pgPaymentColumns+") "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (uuid) DO "+
+		"UPDATE SET account = EXCLUDED.account, estimatedmaturity = "+
+		"EXCLUDED.estimatedmaturity, height = EXCLUDED.height, amount = "+
+		"EXCLUDED.amount, createdon = EXCLUDED.createdon, paidonheight = "+
+		"EXCLUDED.paidonheight, transactionid = EXCLUDED.transactionid",
+		string(payment.id()), payment.Account,
+		int64(payment.EstimatedMaturity), int64(payment.Height),
+		int64(payment.Amount), payment.CreatedOn,
+		int64(payment.PaidOnHeight), payment.TransactionID)
+	return err
+}
+
+// scanPgPayment scans a payment from the provided row.
+func scanPgPayment(row pgScanner) (*Payment, error) {
+	var payment Payment
+	var id string
+	var estMaturity, height, amount, paidOnHeight int64
+	err := row.Scan(&id, &payment.Account, &estMaturity, &height, &amount,
+		&payment.CreatedOn, &paidOnHeight, &payment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	payment.EstimatedMaturity = uint32(estMaturity)
+	payment.Height = uint32(height)
+	payment.Amount = dcrutil.Amount(amount)
+	payment.PaidOnHeight = uint32(paidOnHeight)
+	return &payment, nil
+}
+
+// fetchPgPayments fetches all payments of the provided payment table, in
+// descending id order if reverse is set.
+func fetchPgPayments(q pgQuerier, table string, reverse bool) ([]*Payment, error) {
+	query := "SELECT " + pgPaymentColumns + " FROM " + table + " ORDER BY uuid"
+	if reverse {
+		query += " DESC"
+	}
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	payments := make([]*Payment, 0)
+	for rows.Next() {
+		payment, err := scanPgPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// FetchPayment fetches the pending payment referenced by the provided id.
+func (db *PostgresDB) FetchPayment(id string) (*Payment, error) {
+	payment, err := scanPgPayment(db.QueryRow("SELECT "+pgPaymentColumns+
+		" FROM "+pgPayments+" WHERE uuid = $1", id))
+	if err == sql.ErrNoRows {
+		desc := fmt.Sprintf("no payment found for id %s", id)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	return payment, err
+}
+
+// PersistPayment saves the provided pending payment.
+func (db *PostgresDB) PersistPayment(payment *Payment) error {
+	return putPgPayment(db, pgPayments, payment)
+}
+
+// DeletePayment removes the pending payment referenced by the provided id.
+func (db *PostgresDB) DeletePayment(id string) error {
+	_, err := db.Exec("DELETE FROM "+pgPayments+" WHERE uuid = $1", id)
+	return err
+}
+
+// FetchPayments fetches all pending payments.
+func (db *PostgresDB) FetchPayments() ([]*Payment, error) {
+	return fetchPgPayments(db, pgPayments, false)
+}
+
+// ArchivePayments removes the provided payments from the pending payments
+// and archives them.
+func (db *PostgresDB) ArchivePayments(payments []*Payment) error {
+	return db.update(func(tx *sql.Tx) error {
+		for _, pmt := range payments {
+			_, err := tx.Exec("DELETE FROM "+pgPayments+" WHERE uuid = $1",
+				string(pmt.id()))
+			if err != nil {
+				return err
+			}
+			pmt.CreatedOn = time.Now().UnixNano()
+			err = putPgPayment(tx, pgArchivedPayments, pmt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchArchivedPayments fetches all archived payments.
+func (db *PostgresDB) FetchArchivedPayments() ([]*Payment, error) {
+	return fetchPgPayments(db, pgArchivedPayments, true)
+}
+
+// putPgPayoutAttempt saves the provided payout attempt.
+func putPgPayoutAttempt(q pgQuerier, attempt *PayoutAttempt) error {
+	v, err := json.Marshal(attempt)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("INSERT INTO payouts (uuid, data) VALUES ($1, $2) "+
+		"ON CONFLICT (uuid) DO UPDATE SET data = EXCLUDED.data",
+		attempt.UUID, string(v))
+	return err
+}
+
+// PersistPayoutAttempt saves the provided payout attempt.
+func (db *PostgresDB) PersistPayoutAttempt(attempt *PayoutAttempt) error {
+	return putPgPayoutAttempt(db, attempt)
+}
+
+// FetchPayoutAttempts fetches all payout attempts.
+func (db *PostgresDB) FetchPayoutAttempts() ([]*PayoutAttempt, error) {
+	rows, err := db.Query("SELECT data FROM payouts ORDER BY uuid DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attempts := make([]*PayoutAttempt, 0)
+	for rows.Next() {
+		var v []byte
+		err := rows.Scan(&v)
+		if err != nil {
+			return nil, err
+		}
+		var attempt PayoutAttempt
+		err = json.Unmarshal(v, &attempt)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// pgHistoryColumns are the columns of the history table, excluding the
+// resolution.
+const pgHistoryColumns = "scope, id, time, hashrate, accepted, rejected"
+
+// putPgHistorySample saves the provided sample at the provided resolution.
+func putPgHistorySample(q pgQuerier, resolution string, sample *HistorySample) error {
+	_, err := q.Exec("INSERT INTO history (resolution, "+pgHistoryColumns+
+		") VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (resolution, "+
+		"scope, id, time) DO UPDATE SET hashrate = EXCLUDED.hashrate, "+
+		"accepted = EXCLUDED.accepted, rejected = EXCLUDED.rejected",
+		resolution, sample.Scope, sample.ID, sample.Time, sample.HashRate,
+		int64(sample.Accepted), int64(sample.Rejected))
+	return err
+}
+
+// queryPgHistory fetches the history samples of the provided query.
+func queryPgHistory(q pgQuerier, query string, args ...interface{}) ([]*HistorySample, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	samples := make([]*HistorySample, 0)
+	for rows.Next() {
+		var sample HistorySample
+		var accepted, rejected int64
+		err := rows.Scan(&sample.Scope, &sample.ID, &sample.Time,
+			&sample.HashRate, &accepted, &rejected)
+		if err != nil {
+			return nil, err
+		}
+		sample.Accepted = uint64(accepted)
+		sample.Rejected = uint64(rejected)
+		samples = append(samples, &sample)
+	}
+	return samples, rows.Err()
+}
+
+// PersistHistorySamples saves the provided samples at the provided
+// resolution.
+func (db *PostgresDB) PersistHistorySamples(resolution string, samples []*HistorySample) error {
+	_, err := historyBucket(resolution)
+	if err != nil {
+		return err
+	}
+	return db.update(func(tx *sql.Tx) error {
+		for _, sample := range samples {
+			err := putPgHistorySample(tx, resolution, sample)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchHistory fetches the samples of the provided scope and id at the
+// provided resolution taken at or after the provided time.
+func (db *PostgresDB) FetchHistory(resolution string, scope string, id string, since int64) ([]*HistorySample, error) {
+	_, err := historyBucket(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return queryPgHistory(db, "SELECT "+pgHistoryColumns+" FROM history "+
+		"WHERE resolution = $1 AND scope = $2 AND id = $3 AND time >= $4 "+
+		"ORDER BY time", resolution, scope, id, since)
+}
+
+// FetchHistoryRange fetches the samples of all series at the provided
+// resolution taken within the provided start inclusive and end exclusive
+// times.
+func (db *PostgresDB) FetchHistoryRange(resolution string, start int64, end int64) ([]*HistorySample, error) {
+	_, err := historyBucket(resolution)
+	if err != nil {
+		return nil, err
+	}
+	return queryPgHistory(db, "SELECT "+pgHistoryColumns+" FROM history "+
+		"WHERE resolution = $1 AND time >= $2 AND time < $3 "+
+		"ORDER BY scope, id, time", resolution, start, end)
+}
+
+// PruneHistory removes all samples at the provided resolution taken before
+// the provided time.
+func (db *PostgresDB) PruneHistory(resolution string, before int64) error {
+	_, err := historyBucket(resolution)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM history WHERE resolution = $1 AND "+
+		"time < $2", resolution, before)
+	return err
+}
+
+// FetchWorkers fetches all workers.
+func (db *PostgresDB) FetchWorkers() ([]*Worker, error) {
+	rows, err := db.Query("SELECT data FROM workers ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	workers := make([]*Worker, 0)
+	for rows.Next() {
+		var v []byte
+		err := rows.Scan(&v)
+		if err != nil {
+			return nil, err
+		}
+		var worker Worker
+		err = json.Unmarshal(v, &worker)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, &worker)
+	}
+	return workers, rows.Err()
+}
+
+// putPgWorker saves the provided worker.
+func putPgWorker(q pgQuerier, worker *Worker) error {
+	v, err := json.Marshal(worker)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("INSERT INTO workers (id, data) VALUES ($1, $2) "+
+		"ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data",
+		WorkerID(worker.AccountID, worker.Name), string(v))
+	return err
+}
+
+// PersistWorkers saves the provided workers.
+func (db *PostgresDB) PersistWorkers(workers []*Worker) error {
+	return db.update(func(tx *sql.Tx) error {
+		for _, worker := range workers {
+			err := putPgWorker(tx, worker)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteWorkers removes the workers referenced by the provided ids.
+func (db *PostgresDB) DeleteWorkers(ids []string) error {
+	return db.update(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			_, err := tx.Exec("DELETE FROM workers WHERE id = $1", id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// queryPgWebhooks fetches the webhooks of the provided query.
+func queryPgWebhooks(q pgQuerier, query string, args ...interface{}) ([]*Webhook, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := make([]*Webhook, 0)
+	for rows.Next() {
+		var v []byte
+		err := rows.Scan(&v)
+		if err != nil {
+			return nil, err
+		}
+		var webhook Webhook
+		err = json.Unmarshal(v, &webhook)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// putPgWebhook saves the provided webhook.
+func putPgWebhook(q pgQuerier, webhook *Webhook) error {
+	v, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("INSERT INTO webhooks (uuid, accountid, data) "+
+		"VALUES ($1, $2, $3) ON CONFLICT (uuid) DO UPDATE SET "+
+		"accountid = EXCLUDED.accountid, data = EXCLUDED.data",
+		webhook.UUID, webhook.AccountID, string(v))
+	return err
+}
+
+// FetchWebhook fetches the webhook referenced by the provided id.
+func (db *PostgresDB) FetchWebhook(id string) (*Webhook, error) {
+	webhooks, err := queryPgWebhooks(db, "SELECT data FROM webhooks "+
+		"WHERE uuid = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		desc := fmt.Sprintf("no webhook found for id %s", id)
+		return nil, MakeError(ErrValueNotFound, desc, nil)
+	}
+	return webhooks[0], nil
+}
+
+// PersistWebhook saves the provided webhook.
+func (db *PostgresDB) PersistWebhook(webhook *Webhook) error {
+	return db.update(func(tx *sql.Tx) error {
+		registered, err := queryPgWebhooks(tx, "SELECT data FROM webhooks "+
+			"WHERE accountid = $1 ORDER BY uuid FOR UPDATE",
+			webhook.AccountID)
+		if err != nil {
+			return err
+		}
+		err = webhook.checkLimit(registered)
+		if err != nil {
+			return err
+		}
+		return putPgWebhook(tx, webhook)
+	})
+}
+
+// DeleteWebhook removes the webhook referenced by the provided id.
+func (db *PostgresDB) DeleteWebhook(id string) error {
+	_, err := db.Exec("DELETE FROM webhooks WHERE uuid = $1", id)
+	return err
+}
+
+// FetchAccountWebhooks fetches all webhooks registered by the provided
+// account.
+func (db *PostgresDB) FetchAccountWebhooks(accountID string) ([]*Webhook, error) {
+	return queryPgWebhooks(db, "SELECT data FROM webhooks "+
+		"WHERE accountid = $1 ORDER BY uuid", accountID)
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// forEachBoltEntry calls the provided function for each entry of the
+// provided bucket nested within the pool bucket.
+func forEachBoltEntry(tx *bolt.Tx, bucket []byte, f func(k, v []byte) error) error {
+	bkt, err := fetchBucket(tx, bucket)
+	if err != nil {
+		return err
+	}
+	return bkt.ForEach(f)
+}
+
+// importBoltEntries imports all json encoded entries of the provided bolt
+// bucket using the provided import function.
+func importBoltEntries(tx *bolt.Tx, bucket []byte, importEntry func(v []byte) error) error {
+	var count int
+	err := forEachBoltEntry(tx, bucket, func(k, v []byte) error {
+		count++
+		return importEntry(v)
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Imported %d %s entries.", count, string(bucket))
+	return nil
+}
+
+// importBoltMetadata imports the pool metadata of the provided bolt
+// database.
+func importBoltMetadata(src *BoltDB, q pgQuerier) error {
+	mode, err := src.FetchPoolMode()
+	if err != nil && !IsError(err, ErrValueNotFound) {
+		return err
+	}
+	if err == nil {
+		err := putMetadata(q, soloPool, strconv.FormatUint(uint64(mode), 10))
+		if err != nil {
+			return err
+		}
+	}
+	secret, err := src.FetchCSRFSecret()
+	if err != nil && !IsError(err, ErrValueNotFound) {
+		return err
+	}
+	if err == nil {
+		err := putMetadata(q, csrfSecret, hex.EncodeToString(secret))
+		if err != nil {
+			return err
+		}
+	}
+	state, err := src.FetchPaymentState()
+	if err != nil {
+		return err
+	}
+	err = putPgPaymentState(q, state)
+	if err != nil {
+		return err
+	}
+	work, err := src.FetchRoundWork()
+	if err != nil {
+		return err
+	}
+	return putMetadata(q, roundWork, work.String())
+}
+
+// importBoltBuckets imports all entries of the pool buckets of the provided
+// bolt transaction.
+func importBoltBuckets(tx *bolt.Tx, q pgQuerier) error {
+	err := importBoltEntries(tx, accountBkt, func(v []byte) error {
+		var account Account
+		err := json.Unmarshal(v, &account)
+		if err != nil {
+			return err
+		}
+		return putPgAccount(q, &account)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, jobBkt, func(v []byte) error {
+		var job Job
+		err := json.Unmarshal(v, &job)
+		if err != nil {
+			return err
+		}
+		return putPgJob(q, &job)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, shareBkt, func(v []byte) error {
+		var share Share
+		err := json.Unmarshal(v, &share)
+		if err != nil {
+			return err
+		}
+		return putPgShare(q, &share)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, roundBkt, func(v []byte) error {
+		var round Round
+		err := json.Unmarshal(v, &round)
+		if err != nil {
+			return err
+		}
+		return putPgRound(q, &round)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, workBkt, func(v []byte) error {
+		var work AcceptedWork
+		err := json.Unmarshal(v, &work)
+		if err != nil {
+			return err
+		}
+		return putPgWork(q, &work)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, paymentBkt, func(v []byte) error {
+		var payment Payment
+		err := json.Unmarshal(v, &payment)
+		if err != nil {
+			return err
+		}
+		return putPgPayment(q, pgPayments, &payment)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, paymentArchiveBkt, func(v []byte) error {
+		var payment Payment
+		err := json.Unmarshal(v, &payment)
+		if err != nil {
+			return err
+		}
+		return putPgPayment(q, pgArchivedPayments, &payment)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, payoutBkt, func(v []byte) error {
+		var attempt PayoutAttempt
+		err := json.Unmarshal(v, &attempt)
+		if err != nil {
+			return err
+		}
+		return putPgPayoutAttempt(q, &attempt)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, workerBkt, func(v []byte) error {
+		var worker Worker
+		err := json.Unmarshal(v, &worker)
+		if err != nil {
+			return err
+		}
+		return putPgWorker(q, &worker)
+	})
+	if err != nil {
+		return err
+	}
+	err = importBoltEntries(tx, webhookBkt, func(v []byte) error {
+		var webhook Webhook
+		err := json.Unmarshal(v, &webhook)
+		if err != nil {
+			return err
+		}
+		return putPgWebhook(q, &webhook)
+	})
+	if err != nil {
+		return err
+	}
+	for _, resolution := range []string{MinuteResolution, HourResolution} {
+		bucket, err := historyBucket(resolution)
+		if err != nil {
+			return err
+		}
+		resolution := resolution
+		err = importBoltEntries(tx, bucket, func(v []byte) error {
+			var sample HistorySample
+			err := json.Unmarshal(v, &sample)
+			if err != nil {
+				return err
+			}
+			return putPgHistorySample(q, resolution, &sample)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportBoltDB imports all data of the provided bolt database file into
+// the postgres database atomically. The bolt database is upgraded to the
+// latest version before being imported. Only empty postgres databases can
+// be imported into.
+func (db *PostgresDB) ImportBoltDB(dbFile string) error {
+	if _, err := os.Stat(dbFile); err != nil {
+		desc := fmt.Sprintf("unable to access db file %s", dbFile)
+		return MakeError(ErrDBOpen, desc, err)
+	}
+
+	var populated bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM metadata) OR " +
+		"EXISTS (SELECT 1 FROM accounts)").Scan(&populated)
+	if err != nil {
+		return err
+	}
+	if populated {
+		desc := "postgres db is not empty"
+		return MakeError(ErrOther, desc, nil)
+	}
+
+	src, err := openDB(dbFile)
+	if err != nil {
+		return MakeError(ErrDBOpen, "unable to open db file", err)
+	}
+	defer src.Close()
+	err = createBuckets(src)
+	if err != nil {
+		return err
+	}
+	err = upgradeDB(src)
+	if err != nil {
+		return err
+	}
+
+	return db.update(func(tx *sql.Tx) error {
+		err := importBoltMetadata(src, tx)
+		if err != nil {
+			return err
+		}
+		return src.View(func(btx *bolt.Tx) error {
+			return importBoltBuckets(btx, tx)
+		})
+	})
+}
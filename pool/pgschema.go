@@ -0,0 +1,182 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	// pgInitialSchema is the first version of the postgres schema. It
+	// creates the tables of all pool data.
+	pgInitialSchema = 1
+
+	// PostgresSchemaVersion is the latest version of the postgres schema
+	// that is understood by the program. Databases with recorded versions
+	// higher than this will fail to open.
+	PostgresSchemaVersion = pgInitialSchema
+)
+
+// pgMigrations maps between old postgres schema versions and the statements
+// migrating the schema to the next version.
+var pgMigrations = [...][]string{
+	pgInitialSchema - 1: {
+		`CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+		`CREATE TABLE accounts (
+			uuid TEXT PRIMARY KEY,
+			address TEXT NOT NULL,
+			createdon BIGINT NOT NULL
+		)`,
+		`CREATE TABLE jobs (
+			uuid TEXT PRIMARY KEY,
+			height BIGINT NOT NULL,
+			header TEXT NOT NULL
+		)`,
+		`CREATE INDEX jobs_height_idx ON jobs (height)`,
+		`CREATE TABLE shares (
+			createdon BIGINT PRIMARY KEY,
+			account TEXT NOT NULL,
+			weight TEXT,
+			difficulty TEXT
+		)`,
+		`CREATE INDEX shares_account_idx ON shares (account)`,
+		`CREATE TABLE rounds (
+			height BIGINT PRIMARY KEY,
+			lastpaymentcreatedon BIGINT NOT NULL,
+			createdon BIGINT NOT NULL,
+			shares JSONB NOT NULL
+		)`,
+		`CREATE TABLE acceptedwork (
+			uuid TEXT PRIMARY KEY,
+			blockhash TEXT NOT NULL,
+			prevhash TEXT NOT NULL,
+			height BIGINT NOT NULL,
+			minedby TEXT NOT NULL,
+			miner TEXT NOT NULL,
+			createdon BIGINT NOT NULL,
+			status TEXT NOT NULL,
+			submittedon BIGINT NOT NULL,
+			acceptedon BIGINT NOT NULL,
+			confirmedon BIGINT NOT NULL,
+			orphanedon BIGINT NOT NULL,
+			maturedon BIGINT NOT NULL,
+			reward BIGINT NOT NULL,
+			networkdifficulty DOUBLE PRECISION NOT NULL,
+			effort DOUBLE PRECISION NOT NULL
+		)`,
+		`CREATE INDEX acceptedwork_height_idx ON acceptedwork (height)`,
+		`CREATE TABLE payments (
+			uuid TEXT PRIMARY KEY,
+			account TEXT NOT NULL,
+			estimatedmaturity BIGINT NOT NULL,
+			height BIGINT NOT NULL,
+			amount BIGINT NOT NULL,
+			createdon BIGINT NOT NULL,
+			paidonheight BIGINT NOT NULL,
+			transactionid TEXT NOT NULL
+		)`,
+		`CREATE TABLE archivedpayments (LIKE payments INCLUDING ALL)`,
+		`CREATE INDEX archivedpayments_account_idx ON archivedpayments (account)`,
+		`CREATE TABLE payouts (
+			uuid TEXT PRIMARY KEY,
+			data JSONB NOT NULL
+		)`,
+		`CREATE TABLE history (
+			resolution TEXT NOT NULL,
+			scope TEXT NOT NULL,
+			id TEXT NOT NULL,
+			time BIGINT NOT NULL,
+			hashrate DOUBLE PRECISION NOT NULL,
+			accepted BIGINT NOT NULL,
+			rejected BIGINT NOT NULL,
+			PRIMARY KEY (resolution, scope, id, time)
+		)`,
+		`CREATE INDEX history_time_idx ON history (resolution, time)`,
+		`CREATE TABLE workers (
+			id TEXT PRIMARY KEY,
+			data JSONB NOT NULL
+		)`,
+		`CREATE TABLE webhooks (
+			uuid TEXT PRIMARY KEY,
+			accountid TEXT NOT NULL,
+			data JSONB NOT NULL
+		)`,
+		`CREATE INDEX webhooks_accountid_idx ON webhooks (accountid)`,
+	},
+}
+
+// fetchPgSchemaVersion fetches the schema version of the postgres database,
+// it defaults to zero for databases never migrated.
+func fetchPgSchemaVersion(q pgQuerier) (uint32, error) {
+	var version int64
+	err := q.QueryRow("SELECT version FROM schemaversion").Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint32(version), nil
+}
+
+// migratePostgresDB checks whether any schema migrations are necessary
+// before the postgres database is ready for application usage. If any are,
+// they are performed atomically.
+func migratePostgresDB(db *PostgresDB) error {
+	return db.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec("CREATE TABLE IF NOT EXISTS schemaversion " +
+			"(version BIGINT NOT NULL)")
+		if err != nil {
+			return err
+		}
+
+		// Serialize migrations of concurrently started pool instances.
+		_, err = tx.Exec("LOCK TABLE schemaversion IN EXCLUSIVE MODE")
+		if err != nil {
+			return err
+		}
+
+		version, err := fetchPgSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if version > PostgresSchemaVersion {
+			desc := fmt.Sprintf("postgres schema version %d is newer "+
+				"than the supported version %d", version,
+				PostgresSchemaVersion)
+			return MakeError(ErrDBUpgrade, desc, nil)
+		}
+		if version == PostgresSchemaVersion {
+			// No migrations necessary.
+			return nil
+		}
+
+		log.Infof("Migrating postgres schema from version %d to %d",
+			version, PostgresSchemaVersion)
+
+		// Execute all necessary migrations in order.
+		for _, migration := range pgMigrations[version:] {
+			for _, stmt := range migration {
+				_, err := tx.Exec(stmt)
+				if err != nil {
+					desc := "unable to migrate postgres schema"
+					return MakeError(ErrDBUpgrade, desc, err)
+				}
+			}
+		}
+
+		_, err = tx.Exec("DELETE FROM schemaversion")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO schemaversion (version) VALUES ($1)",
+			int64(PostgresSchemaVersion))
+		return err
+	})
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// restoreFile is the file name of a database restore staged to be
+	// swapped in once the hub is stopped.
+	restoreFile = "restore.kv"
+	// gzipMagic are the leading bytes of gzip compressed data.
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// prepareRestore validates the provided database file to be restored. Its
+// version must be supported by the program, older databases are upgraded
+// to the latest version. The pool mode of the database must match the
+// provided pool mode.
+func prepareRestore(dbFile string, isSoloPool bool) error {
+	db, err := openDB(dbFile)
+	if err != nil {
+		return MakeError(ErrDBOpen, "unable to open restore db file", err)
+	}
+	defer db.Close()
+
+	var version uint32
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(poolBkt) == nil {
+			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+			return MakeError(ErrBucketNotFound, desc, nil)
+		}
+		var err error
+		version, err = fetchDBVersion(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if version > DBVersion {
+		desc := fmt.Sprintf("restore db version %d is newer than the "+
+			"supported version %d", version, DBVersion)
+		return MakeError(ErrDBUpgrade, desc, nil)
+	}
+	err = createBuckets(db)
+	if err != nil {
+		return err
+	}
+	err = upgradeDB(db)
+	if err != nil {
+		return err
+	}
+
+	mode, err := db.FetchPoolMode()
+	if err != nil {
+		if IsError(err, ErrValueNotFound) {
+			return nil
+		}
+		return err
+	}
+	if isSoloPool != (mode == 1) {
+		desc := "restore db pool mode does not match the configured " +
+			"pool mode"
+		return MakeError(ErrOther, desc, nil)
+	}
+	return nil
+}
+
+// stageRestore writes the provided database, gzip compressed or not, to the
+// provided file and validates it for restoration. The file is removed if
+// it cannot be restored.
+func stageRestore(src io.Reader, path string, isSoloPool bool) error {
+	br := bufio.NewReader(src)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var r io.Reader = br
+	if bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			desc := "unable to decompress restore db"
+			return MakeError(ErrDecode, desc, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), tmpPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = prepareRestore(tmp.Name(), isSoloPool)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// backupDBFile persists a copy of the provided database file, if it exists,
+// to the backup file of its directory. The database must not be open.
+func backupDBFile(dbFile string) error {
+	_, err := os.Stat(dbFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	db, err := openDB(dbFile)
+	if err != nil {
+		return MakeError(ErrDBOpen, "unable to open db file", err)
+	}
+	defer db.Close()
+	return db.Backup(backupFile)
+}
+
+// RestoreDB replaces the provided database file with the provided database
+// snapshot or backup, gzip compressed or not. The snapshot is validated,
+// upgraded if older and swapped in atomically, the replaced database is
+// backed up first. The database must not be open.
+func RestoreDB(dbFile string, snapshot string, isSoloPool bool) error {
+	f, err := os.Open(snapshot)
+	if err != nil {
+		desc := fmt.Sprintf("unable to open restore db file %s", snapshot)
+		return MakeError(ErrDBOpen, desc, err)
+	}
+	defer f.Close()
+	path := filepath.Join(filepath.Dir(dbFile), restoreFile)
+	err = stageRestore(f, path, isSoloPool)
+	if err != nil {
+		return err
+	}
+	err = backupDBFile(dbFile)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	err = os.Rename(path, dbFile)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	log.Infof("Database restored from %s.", snapshot)
+	return nil
+}
+
+// RestoreDB validates the provided database snapshot or backup, gzip
+// compressed or not, and stops the pool. The database is replaced by the
+// snapshot once the hub is stopped, the pool has to be restarted afterwards.
+func (h *Hub) RestoreDB(src io.Reader) error {
+	db, ok := h.db.(*BoltDB)
+	if !ok {
+		desc := "restore only supported by the bolt database"
+		return MakeError(ErrNotSupported, desc, nil)
+	}
+
+	h.restoreMtx.Lock()
+	defer h.restoreMtx.Unlock()
+	if h.restorePath != "" {
+		desc := "a database restore is already pending"
+		return MakeError(ErrOther, desc, nil)
+	}
+	dbFile := db.Path()
+	path := filepath.Join(filepath.Dir(dbFile), restoreFile)
+	err := stageRestore(src, path, h.cfg.SoloPool)
+	if err != nil {
+		return err
+	}
+	h.restorePath = path
+	h.restoreDBFile = dbFile
+
+	log.Infof("Database restore staged, stopping the pool.")
+	h.cancel()
+	return nil
+}
+
+// applyRestore swaps in a staged database restore. It must only be called
+// once the database is closed.
+func (h *Hub) applyRestore() {
+	h.restoreMtx.Lock()
+	defer h.restoreMtx.Unlock()
+	if h.restorePath == "" {
+		return
+	}
+	err := os.Rename(h.restorePath, h.restoreDBFile)
+	if err != nil {
+		log.Errorf("unable to restore db: %v", err)
+		return
+	}
+	log.Infof("Database restored, restart the pool to resume mining.")
+}
@@ -0,0 +1,111 @@
+package pool
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func testRestore(t *testing.T, db *BoltDB) {
+	dir, err := ioutil.TempDir("", "dcrpool_test_restore")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Ensure uncompressed and compressed snapshots are restored.
+	dbFile := filepath.Join(dir, "restored.kv")
+	for _, compress := range []bool{false, true} {
+		snapshot, err := db.Snapshot(dir, compress)
+		if err != nil {
+			t.Fatalf("Snapshot error: %v", err)
+		}
+		err = RestoreDB(dbFile, snapshot, false)
+		if err != nil {
+			t.Fatalf("[RestoreDB] compress %v: unexpected error: %v",
+				compress, err)
+		}
+		restored, err := OpenBoltDB(dbFile, true)
+		if err != nil {
+			t.Fatalf("OpenBoltDB error: %v", err)
+		}
+		_, err = restored.FetchAccount(xID)
+		restored.Close()
+		if err != nil {
+			t.Fatalf("[RestoreDB] compress %v: expected account %s to be "+
+				"restored, got %v", compress, xID, err)
+		}
+	}
+
+	// Ensure the replaced database was backed up.
+	backup, err := OpenBoltDB(filepath.Join(dir, backupFile), true)
+	if err != nil {
+		t.Fatalf("expected the replaced db to be backed up, got %v", err)
+	}
+	_, err = backup.FetchAccount(xID)
+	backup.Close()
+	if err != nil {
+		t.Fatalf("expected account %s to be backed up, got %v", xID, err)
+	}
+
+	// Ensure snapshots of a different pool mode are not restored.
+	soloFile := filepath.Join(dir, "solo.kv")
+	soloDB, err := InitDB(soloFile, true)
+	if err != nil {
+		t.Fatalf("InitDB error: %v", err)
+	}
+	err = soloDB.PersistPoolMode(1)
+	if err != nil {
+		t.Fatalf("PersistPoolMode error: %v", err)
+	}
+	soloDB.Close()
+	err = RestoreDB(dbFile, soloFile, false)
+	if err == nil {
+		t.Fatal("expected a pool mode mismatch error")
+	}
+
+	// Ensure snapshots newer than the supported version are not restored.
+	newerFile := filepath.Join(dir, "newer.kv")
+	newerDB, err := InitDB(newerFile, false)
+	if err != nil {
+		t.Fatalf("InitDB error: %v", err)
+	}
+	err = newerDB.Update(func(tx *bolt.Tx) error {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, DBVersion+1)
+		return tx.Bucket(poolBkt).Put(versionK, b)
+	})
+	if err != nil {
+		t.Fatalf("unable to set db version: %v", err)
+	}
+	newerDB.Close()
+	err = RestoreDB(dbFile, newerFile, false)
+	if !IsError(err, ErrDBUpgrade) {
+		t.Fatalf("expected a db upgrade error, got %v", err)
+	}
+
+	// Ensure the restored database was left untouched by failed restores
+	// and no temporary files are left behind.
+	restored, err := OpenBoltDB(dbFile, true)
+	if err != nil {
+		t.Fatalf("OpenBoltDB error: %v", err)
+	}
+	_, err = restored.FetchAccount(xID)
+	restored.Close()
+	if err != nil {
+		t.Fatalf("expected account %s to remain, got %v", xID, err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	for _, f := range files {
+		if filepath.Ext(f.Name()) == "" || f.Name()[0] == '.' {
+			t.Fatalf("unexpected temporary file %s", f.Name())
+		}
+	}
+}
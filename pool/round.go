@@ -0,0 +1,46 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"time"
+)
+
+// Round represents the shares pruned when generating payments for a block
+// mined by the pool. Rounds are archived so pruned shares can be restored
+// when the mined block is invalidated by a chain reorganization.
+type Round struct {
+	Height uint32   `json:"height"`
+	Shares []*Share `json:"shares"`
+	// LastPaymentCreatedOn is the last payment created on time prior to
+	// the payments of the round.
+	LastPaymentCreatedOn uint64 `json:"lastpaymentcreatedon"`
+	CreatedOn            int64  `json:"createdon"`
+}
+
+// RoundReversal details the ledger changes made reverting the payments of
+// an invalidated block mined by the pool.
+type RoundReversal struct {
+	Height          uint32
+	RemovedPayments []*Payment
+	PaidPayments    []*Payment
+	RestoredShares  int
+}
+
+// pruneRounds removes all archived rounds with heights less than the
+// provided height.
+func pruneRounds(db Database, height uint32) error {
+	return db.PruneRounds(height)
+}
+
+// newRound creates a round of the provided height. The shares of the round
+// are sourced when it is archived.
+func newRound(height uint32, lastPaymentCreatedOn uint64) *Round {
+	return &Round{
+		Height:               height,
+		LastPaymentCreatedOn: lastPaymentCreatedOn,
+		CreatedOn:            time.Now().UnixNano(),
+	}
+}
@@ -0,0 +1,181 @@
+package pool
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+)
+
+func testRounds(t *testing.T, db Database) {
+	activeNet := chaincfg.SimNetParams()
+	pCfg := &PaymentMgrConfig{
+		DB:               db,
+		ActiveNet:        activeNet,
+		PoolFee:          0.1,
+		LastNPeriod:      time.Second * 120,
+		PaymentMethod:    PROP,
+		PoolFeeAddrs:     []dcrutil.Address{poolFeeAddrs},
+		RecordPaymentRun: func(time.Duration, error) {},
+		Notify:           func(*Event) {},
+	}
+	mgr, err := NewPaymentMgr(pCfg)
+	if err != nil {
+		t.Fatalf("[NewPaymentMgr] unexpected error: %v", err)
+	}
+
+	now := time.Now().UnixNano()
+	for i, account := range []string{xID, xID, xID, yID} {
+		share := NewShare(account, new(big.Rat).SetInt64(1))
+		share.CreatedOn = now + int64(i)
+		err := share.Create(db)
+		if err != nil {
+			t.Fatalf("unable to persist share: %v", err)
+		}
+	}
+
+	// Ensure generating payments archives the pruned shares of the round.
+	height := uint32(20)
+	coinbase := dcrutil.Amount(1e8)
+	err = mgr.generatePayments(height, coinbase)
+	if err != nil {
+		t.Fatalf("unable to generate payments: %v", err)
+	}
+	shares, err := PPSEligibleShares(db, 0, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("[PPSEligibleShares] unexpected error: %v", err)
+	}
+	if len(shares) != 0 {
+		t.Fatalf("expected round shares to be pruned, got %d", len(shares))
+	}
+	round, err := db.FetchRound(height)
+	if err != nil {
+		t.Fatalf("unable to fetch round: %v", err)
+	}
+	if len(round.Shares) != 4 {
+		t.Fatalf("expected 4 archived shares, got %d", len(round.Shares))
+	}
+	pmts, err := fetchPendingPaymentsAtHeight(db, height)
+	if err != nil {
+		t.Fatalf("[fetchPendingPaymentsAtHeight] unexpected error: %v", err)
+	}
+	if len(pmts) != 3 {
+		t.Fatalf("expected 3 pending payments, got %d", len(pmts))
+	}
+
+	// Ensure reverting the round removes its pending payments and restores
+	// its shares to be credited with the next round.
+	reversal, err := mgr.revertRound(height)
+	if err != nil {
+		t.Fatalf("unable to revert round: %v", err)
+	}
+	if len(reversal.RemovedPayments) != 3 {
+		t.Fatalf("expected 3 removed payments, got %d",
+			len(reversal.RemovedPayments))
+	}
+	if reversal.RestoredShares != 4 {
+		t.Fatalf("expected 4 restored shares, got %d",
+			reversal.RestoredShares)
+	}
+	pmts, err = fetchPendingPaymentsAtHeight(db, height)
+	if err != nil {
+		t.Fatalf("[fetchPendingPaymentsAtHeight] unexpected error: %v", err)
+	}
+	if len(pmts) != 0 {
+		t.Fatalf("expected no pending payments, got %d", len(pmts))
+	}
+	if mgr.fetchLastPaymentCreatedOn() != 0 {
+		t.Fatalf("expected the last payment created on time to be "+
+			"reverted, got %d", mgr.fetchLastPaymentCreatedOn())
+	}
+	percentages, err := mgr.sharePercentages()
+	if err != nil {
+		t.Fatalf("unable to fetch share percentages: %v", err)
+	}
+	if percentages[xID].Cmp(big.NewRat(3, 4)) != 0 {
+		t.Fatalf("expected a share percentage of 3/4 for account x, "+
+			"got %v", percentages[xID])
+	}
+	_, err = db.FetchRound(height)
+	if !IsError(err, ErrValueNotFound) {
+		t.Fatalf("expected the reverted round to be removed, got %v", err)
+	}
+
+	// Ensure reverting a round without payments or archived shares is a
+	// no-op.
+	reversal, err = mgr.revertRound(height)
+	if err != nil {
+		t.Fatalf("unable to revert round: %v", err)
+	}
+	if len(reversal.RemovedPayments) != 0 || reversal.RestoredShares != 0 {
+		t.Fatal("expected an empty round reversal")
+	}
+
+	// Ensure paid payments of a reverted round are reported.
+	err = mgr.generatePayments(height, coinbase)
+	if err != nil {
+		t.Fatalf("unable to generate payments: %v", err)
+	}
+	pmts, err = fetchPendingPaymentsAtHeight(db, height)
+	if err != nil {
+		t.Fatalf("[fetchPendingPaymentsAtHeight] unexpected error: %v", err)
+	}
+	paid := pmts[0]
+	paid.PaidOnHeight = height + 20
+	err = paid.Update(db)
+	if err != nil {
+		t.Fatalf("unable to update payment: %v", err)
+	}
+	reversal, err = mgr.revertRound(height)
+	if err != nil {
+		t.Fatalf("unable to revert round: %v", err)
+	}
+	if len(reversal.RemovedPayments) != 2 || len(reversal.PaidPayments) != 1 {
+		t.Fatalf("expected 2 removed and 1 paid payments, got %d and %d",
+			len(reversal.RemovedPayments), len(reversal.PaidPayments))
+	}
+
+	// Ensure rounds are pruned once their payments mature.
+	for _, h := range []uint32{5, 50} {
+		err := db.ArchiveRound(&Round{Height: h}, 0, mgr.paymentState())
+		if err != nil {
+			t.Fatalf("unable to archive round: %v", err)
+		}
+	}
+	err = mgr.pruneRounds(50)
+	if err != nil {
+		t.Fatalf("unable to prune rounds: %v", err)
+	}
+	_, err = db.FetchRound(5)
+	if !IsError(err, ErrValueNotFound) {
+		t.Fatalf("expected round at height 5 to be pruned, got %v", err)
+	}
+	_, err = db.FetchRound(50)
+	if err != nil {
+		t.Fatalf("expected round at height 50 to be retained, got %v", err)
+	}
+
+	// Empty the share, payment and round buckets.
+	err = emptyBucket(db, shareBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, paymentBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+	err = emptyBucket(db, roundBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+
+	// Reset backed up values to their defaults.
+	mgr.setLastPaymentCreatedOn(0)
+	err = mgr.persistState()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
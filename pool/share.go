@@ -5,38 +5,30 @@
 package pool
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"math"
 	"math/big"
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/dcrutil/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 var (
+	// PROP represents the proportional payment method.
+	PROP = "prop"
+
 	// PPS represents the pay per share payment method.
 	PPS = "pps"
 
+	// FPPS represents the full pay per share payment method.
+	FPPS = "fpps"
+
 	// PPLNS represents the pay per last n shares payment method.
 	PPLNS = "pplns"
-)
 
-// ShareWeights reprsents the associated weights for each known DCR miner.
-// With the share weight of the lowest hash DCR miner (LHM) being 1, the
-// rest were calculated as :
-// 				(Hash of Miner X * Weight of LHM)/ Hash of LHM
-var ShareWeights = map[string]*big.Rat{
-	CPU:           new(big.Rat).SetFloat64(1.0), // Reserved for testing.
-	ObeliskDCR1:   new(big.Rat).SetFloat64(1.0),
-	InnosiliconD9: new(big.Rat).SetFloat64(2.182),
-	AntminerDR3:   new(big.Rat).SetFloat64(7.091),
-	AntminerDR5:   new(big.Rat).SetFloat64(31.181),
-	WhatsminerD1:  new(big.Rat).SetFloat64(43.636),
-}
+	// PaymentMethods represents the payment methods supported by the pool.
+	PaymentMethods = []string{PROP, PPS, FPPS, PPLNS}
+)
 
 // calculatePoolDifficulty determines the difficulty at which the provided
 // hashrate can generate a pool share by the provided target time.
@@ -90,146 +82,128 @@ func calculatePoolTarget(net *chaincfg.Params, hashRate *big.Int, targetTimeSecs
 	return target, difficulty, err
 }
 
-// Share represents verifiable work performed by a pool client.
+// Share represents verifiable work performed by a pool client. The work of
+// a share is the difficulty it was credited at. Shares recorded before
+// share difficulties were credited carry a miner type weight instead.
 type Share struct {
-	Account   string   `json:"account"`
-	Weight    *big.Rat `json:"weight"`
-	CreatedOn int64    `json:"createdOn"`
+	Account    string   `json:"account"`
+	Weight     *big.Rat `json:"weight,omitempty"`
+	Difficulty *big.Rat `json:"difficulty,omitempty"`
+	CreatedOn  int64    `json:"createdOn"`
 }
 
-// NewShare creates a share with the provided account and weight.
-func NewShare(account string, weight *big.Rat) *Share {
+// NewShare creates a share with the provided account and credited
+// difficulty.
+func NewShare(account string, difficulty *big.Rat) *Share {
 	return &Share{
-		Account:   account,
-		Weight:    weight,
-		CreatedOn: time.Now().UnixNano(),
+		Account:    account,
+		Difficulty: difficulty,
+		CreatedOn:  time.Now().UnixNano(),
 	}
 }
 
-// fetchShareBucket is a helper function for getting the share bucket.
-func fetchShareBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
-	pbkt := tx.Bucket(poolBkt)
-	if pbkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-	bkt := pbkt.Bucket(shareBkt)
-	if bkt == nil {
-		desc := fmt.Sprintf("bucket %s not found", string(shareBkt))
-		return nil, MakeError(ErrBucketNotFound, desc, nil)
-	}
-
-	return bkt, nil
-}
-
 // Create persists a share to the database.
-func (s *Share) Create(db *bolt.DB) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		bkt, err := fetchShareBucket(tx)
-		if err != nil {
-			return err
-		}
-		sBytes, err := json.Marshal(s)
-		if err != nil {
-			return err
-		}
-		err = bkt.Put(nanoToBigEndianBytes(s.CreatedOn), sBytes)
-		return err
-	})
-	return err
+func (s *Share) Create(db Database) error {
+	return db.PersistShare(s)
 }
 
 // Update is not supported for shares.
-func (s *Share) Update(db *bolt.DB) error {
+func (s *Share) Update(db Database) error {
 	desc := "share update not supported"
 	return MakeError(ErrNotSupported, desc, nil)
 }
 
 // Delete is not supported for shares.
-func (s *Share) Delete(db *bolt.DB) error {
+func (s *Share) Delete(db Database) error {
 	desc := "share deletion not supported"
 	return MakeError(ErrNotSupported, desc, nil)
 }
 
-// PPSEligibleShares fetches all shares within the provided inclusive bounds.
-func PPSEligibleShares(db *bolt.DB, min []byte, max []byte) ([]*Share, error) {
-	eligibleShares := make([]*Share, 0)
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchShareBucket(tx)
-		if err != nil {
-			return err
-		}
-		c := bkt.Cursor()
-		if min == nil {
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				var share Share
-				err := json.Unmarshal(v, &share)
-				if err != nil {
-					return err
-				}
-				eligibleShares = append(eligibleShares, &share)
-			}
-		}
-		if min != nil {
-			for k, v := c.Seek(min); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
-				var share Share
-				err := json.Unmarshal(v, &share)
-				if err != nil {
-					return err
-				}
-				eligibleShares = append(eligibleShares, &share)
-			}
-		}
-		return nil
-	})
+// PPSEligibleShares fetches all shares created within the provided inclusive
+// bounds.
+func PPSEligibleShares(db Database, min int64, max int64) ([]*Share, error) {
+	return db.FetchShares(min, max)
+}
+
+// PPLNSEligibleShares fetches all shares created after the provided
+// minimum. Shares are returned from the most recent.
+func PPLNSEligibleShares(db Database, min int64) ([]*Share, error) {
+	shares, err := db.FetchShares(min+1, math.MaxInt64)
 	if err != nil {
 		return nil, err
 	}
-	return eligibleShares, err
+	for i, j := 0, len(shares)-1; i < j; i, j = i+1, j-1 {
+		shares[i], shares[j] = shares[j], shares[i]
+	}
+	return shares, nil
 }
 
-// PPLNSEligibleShares fetches all shares keyed greater than the provided
-// minimum.
-func PPLNSEligibleShares(db *bolt.DB, min []byte) ([]*Share, error) {
-	eligibleShares := make([]*Share, 0)
-	err := db.View(func(tx *bolt.Tx) error {
-		bkt, err := fetchShareBucket(tx)
-		if err != nil {
-			return err
-		}
-		c := bkt.Cursor()
-		for k, v := c.Last(); k != nil && bytes.Compare(k, min) > 0; k, v = c.Prev() {
-			var share Share
-			err := json.Unmarshal(v, &share)
-			if err != nil {
-				return err
-			}
-			eligibleShares = append(eligibleShares, &share)
+// PPLNSLastNShares fetches the last n shares.
+func PPLNSLastNShares(db Database, n uint32) ([]*Share, error) {
+	if n == 0 {
+		return make([]*Share, 0), nil
+	}
+	var count uint32
+	return db.FetchLastShares(func(*Share) bool {
+		count++
+		return count >= n
+	})
+}
+
+// PPLNSLastNWork fetches the most recent shares with a combined difficulty
+// of at least the provided work. Shares recorded without a difficulty do not
+// contribute work. All shares are fetched if the provided work is nil.
+func PPLNSLastNWork(db Database, work *big.Rat) ([]*Share, error) {
+	total := new(big.Rat)
+	return db.FetchLastShares(func(share *Share) bool {
+		if work == nil || share.Difficulty == nil {
+			return false
 		}
-		return nil
+		total.Add(total, share.Difficulty)
+		return total.Cmp(work) >= 0
 	})
-	if err != nil {
-		return nil, err
-	}
-	return eligibleShares, err
 }
 
 // sharePercentages calculates the percentages due each account
-// according to their weighted shares.
+// according to the summed difficulty of their shares. Shares recorded
+// without a difficulty only carry a miner weight not comparable to share
+// difficulties. They are excluded, as they are by PPLNSLastNWork, unless
+// none of the shares carry a difficulty, the percentages are then
+// calculated from the share weights.
 func sharePercentages(shares []*Share) (map[string]*big.Rat, error) {
 	totalShares := new(big.Rat)
 	tally := make(map[string]*big.Rat)
 	percentages := make(map[string]*big.Rat)
 
-	// Tally all share weights for each participation account.
+	var legacy int
+	for _, share := range shares {
+		if share.Difficulty == nil {
+			legacy++
+		}
+	}
+	weighted := legacy == len(shares)
+	if legacy > 0 && !weighted {
+		log.Warnf("excluding %d of %d shares recorded without a "+
+			"difficulty from share percentages", legacy, len(shares))
+	}
+
+	// Tally all share difficulties, or weights, for each participation
+	// account.
 	for _, share := range shares {
-		totalShares = totalShares.Add(totalShares, share.Weight)
+		work := share.Difficulty
+		if weighted {
+			work = share.Weight
+		}
+		if work == nil {
+			continue
+		}
+		totalShares = totalShares.Add(totalShares, work)
 		if _, ok := tally[share.Account]; ok {
 			tally[share.Account] = tally[share.Account].
-				Add(tally[share.Account], share.Weight)
+				Add(tally[share.Account], work)
 			continue
 		}
-		tally[share.Account] = share.Weight
+		tally[share.Account] = new(big.Rat).Set(work)
 	}
 
 	// Calculate each participating account percentage to be claimed.
@@ -262,26 +236,3 @@ func CalculatePayments(percentages map[string]*big.Rat, total dcrutil.Amount,
 	payments = append(payments, NewPayment(poolFeesK, fee, height, estMaturity))
 	return payments, nil
 }
-
-// pruneShares removes invalidated shares from the db.
-func pruneShares(tx *bolt.Tx, minNano int64) error {
-	minBytes := nanoToBigEndianBytes(minNano)
-	bkt, err := fetchShareBucket(tx)
-	if err != nil {
-		return err
-	}
-	toDelete := [][]byte{}
-	cursor := bkt.Cursor()
-	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
-		if bytes.Compare(minBytes, k) > 0 {
-			toDelete = append(toDelete, k)
-		}
-	}
-	for _, entry := range toDelete {
-		err := bkt.Delete(entry)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
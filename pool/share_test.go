@@ -11,17 +11,17 @@ import (
 	"time"
 
 	"github.com/decred/dcrd/chaincfg/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
 // persistShare creates a persisted share with the provided account, share
-// weight and creation time.
-func persistShare(db *bolt.DB, account string, weight *big.Rat,
+// weight and creation time. The weight is also used as the share difficulty.
+func persistShare(db Database, account string, weight *big.Rat,
 	createdOnNano int64) error {
 	share := &Share{
-		Account:   account,
-		Weight:    weight,
-		CreatedOn: createdOnNano,
+		Account:    account,
+		Weight:     weight,
+		Difficulty: weight,
+		CreatedOn:  createdOnNano,
 	}
 
 	err := share.Create(db)
@@ -32,7 +32,7 @@ func persistShare(db *bolt.DB, account string, weight *big.Rat,
 	return nil
 }
 
-func testShares(t *testing.T, db *bolt.DB) {
+func testShares(t *testing.T, db Database) {
 	now := time.Now()
 	minimumTime := now.Add(-(time.Second * 60)).UnixNano()
 	maximumTime := now.UnixNano()
@@ -67,11 +67,8 @@ func testShares(t *testing.T, db *bolt.DB) {
 		t.Fatal(err)
 	}
 
-	minimumTimeBytes := nanoToBigEndianBytes(minimumTime)
-	maximumTimeBytes := nanoToBigEndianBytes(maximumTime)
-
 	// Fetch eligible shares using the minimum and maximum time range.
-	shares, err := PPSEligibleShares(db, minimumTimeBytes, maximumTimeBytes)
+	shares, err := PPSEligibleShares(db, minimumTime, maximumTime)
 	if err != nil {
 		t.Error(err)
 	}
@@ -154,7 +151,7 @@ func testShares(t *testing.T, db *bolt.DB) {
 		t.Fatal(err)
 	}
 
-	shares, err = PPLNSEligibleShares(db, minimumTimeBytes)
+	shares, err = PPLNSEligibleShares(db, minimumTime)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -241,6 +238,43 @@ func testSharePercentages(t *testing.T) {
 			},
 			err: nil,
 		},
+		"summed difficulties": {
+			input: []*Share{
+				NewShare("a", new(big.Rat).SetFrac64(1, 2)),
+				NewShare("a", new(big.Rat).SetInt64(4)),
+				NewShare("b", new(big.Rat).SetInt64(4)),
+				NewShare("a", new(big.Rat).SetFrac64(1, 2)),
+			},
+			output: map[string]*big.Rat{
+				"a": new(big.Rat).SetFrac64(5, 9),
+				"b": new(big.Rat).SetFrac64(4, 9),
+			},
+			err: nil,
+		},
+		"weighted legacy shares": {
+			input: []*Share{
+				{Account: "a", Weight: new(big.Rat).SetInt64(1)},
+				{Account: "b", Weight: new(big.Rat).SetInt64(3)},
+			},
+			output: map[string]*big.Rat{
+				"a": new(big.Rat).SetFrac64(1, 4),
+				"b": new(big.Rat).SetFrac64(3, 4),
+			},
+			err: nil,
+		},
+		"excluded legacy shares": {
+			input: []*Share{
+				{Account: "a", Weight: new(big.Rat).SetInt64(1)},
+				NewShare("a", new(big.Rat).SetInt64(1)),
+				NewShare("b", new(big.Rat).SetInt64(4)),
+				{Account: "c", Weight: new(big.Rat).SetInt64(3)},
+			},
+			output: map[string]*big.Rat{
+				"a": new(big.Rat).SetFrac64(1, 5),
+				"b": new(big.Rat).SetFrac64(4, 5),
+			},
+			err: nil,
+		},
 		"zero shares": {
 			input: []*Share{
 				NewShare("a", new(big.Rat)),
@@ -280,6 +314,10 @@ func testSharePercentages(t *testing.T) {
 			}
 		}
 
+		if len(actual) != len(test.output) {
+			t.Fatalf("%s: expected %d account dividends, got %d", name,
+				len(test.output), len(actual))
+		}
 		for account, dividend := range test.output {
 			if actual[account].Cmp(dividend) != 0 {
 				t.Fatalf("%s: account %v dividend was %v, "+
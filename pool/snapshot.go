@@ -0,0 +1,250 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// snapshotPrefix is the file name prefix of database snapshots.
+	snapshotPrefix = "snapshot-"
+	// snapshotTimeFormat is the UTC timestamp format of database snapshot
+	// file names, sorting lexically in chronological order.
+	snapshotTimeFormat = "20060102T150405Z"
+	// snapshotExt is the file extension of database snapshots.
+	snapshotExt = ".kv"
+	// gzipExt is the file extension of gzip compressed database snapshots.
+	gzipExt = ".gz"
+	// tmpPrefix is the file name prefix of snapshots being written.
+	tmpPrefix = ".tmp-"
+)
+
+// snapshotName returns the snapshot file name of the provided time.
+func snapshotName(t time.Time, compress bool) string {
+	name := snapshotPrefix + t.UTC().Format(snapshotTimeFormat) + snapshotExt
+	if compress {
+		name += gzipExt
+	}
+	return name
+}
+
+// isSnapshot returns whether the provided file name is a snapshot name.
+func isSnapshot(name string) bool {
+	return strings.HasPrefix(name, snapshotPrefix) &&
+		(strings.HasSuffix(name, snapshotExt) ||
+			strings.HasSuffix(name, snapshotExt+gzipExt))
+}
+
+// writeSnapshot writes a consistent copy of the database to the provided
+// file, gzip compressed if set.
+func (db *BoltDB) writeSnapshot(path string, compress bool) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if !compress {
+			_, err := tx.WriteTo(f)
+			return err
+		}
+		gz := gzip.NewWriter(f)
+		_, err := tx.WriteTo(gz)
+		if err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Snapshot writes a timestamped copy of the database to the provided
+// directory while the database remains online, gzip compressed if set.
+// The snapshot is verified before being made visible under its final name,
+// the path of the snapshot is returned.
+func (db *BoltDB) Snapshot(dir string, compress bool) (string, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	name := snapshotName(time.Now(), compress)
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		desc := fmt.Sprintf("snapshot %s already exists", path)
+		return "", MakeError(ErrOther, desc, nil)
+	}
+	tmpPath := filepath.Join(dir, tmpPrefix+name)
+	err = db.writeSnapshot(tmpPath, compress)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	err = verifySnapshot(tmpPath, compress)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return path, nil
+}
+
+// decompressSnapshot decompresses the provided gzip compressed snapshot to
+// a temporary file in the directory of the snapshot. The path of the
+// temporary file is returned, it is the responsibility of the caller to
+// remove it.
+func decompressSnapshot(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		desc := fmt.Sprintf("unable to decompress snapshot %s", path)
+		return "", MakeError(ErrDecode, desc, err)
+	}
+	defer gz.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(path), tmpPrefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, gz)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		desc := fmt.Sprintf("unable to decompress snapshot %s", path)
+		return "", MakeError(ErrDecode, desc, err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// verifySnapshot reopens the provided snapshot read-only and ensures its
+// version is set and supported and all pool buckets exist.
+func verifySnapshot(path string, compressed bool) error {
+	if compressed {
+		tmpPath, err := decompressSnapshot(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmpPath)
+		path = tmpPath
+	}
+	db, err := OpenBoltDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range poolBuckets {
+			_, err := fetchBucket(tx, bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// VerifySnapshot ensures the provided database snapshot, gzip compressed
+// if named with a .gz extension, can be opened and has a supported version
+// and all pool buckets.
+func VerifySnapshot(path string) error {
+	return verifySnapshot(path, strings.HasSuffix(path, gzipExt))
+}
+
+// pruneSnapshots removes the oldest snapshots of the provided directory
+// exceeding the provided retention count. All snapshots are retained when
+// the count is zero.
+func pruneSnapshots(dir string, retain uint32) error {
+	if retain == 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() && isSnapshot(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	if uint32(len(names)) <= retain {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names[:uint32(len(names))-retain] {
+		err := os.Remove(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		log.Tracef("Pruned db snapshot %s.", name)
+	}
+	return nil
+}
+
+// snapshot takes a database snapshot and prunes the snapshots exceeding the
+// configured retention count.
+func (h *Hub) snapshot() error {
+	path, err := h.db.Snapshot(h.cfg.SnapshotDir, h.cfg.SnapshotCompress)
+	if err != nil {
+		return err
+	}
+	log.Infof("Database snapshot written to %s.", path)
+	return pruneSnapshots(h.cfg.SnapshotDir, h.cfg.SnapshotRetention)
+}
+
+// snapshotMonitor periodically takes database snapshots.
+func (h *Hub) snapshotMonitor(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.SnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.wg.Done()
+			return
+
+		case <-ticker.C:
+			err := h.snapshot()
+			if err != nil {
+				if IsError(err, ErrNotSupported) {
+					log.Warnf("Database snapshots disabled: %v", err)
+					h.wg.Done()
+					return
+				}
+				log.Errorf("unable to snapshot db: %v", err)
+			}
+		}
+	}
+}
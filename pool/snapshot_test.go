@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testSnapshot(t *testing.T, db *BoltDB) {
+	dir, err := ioutil.TempDir("", "dcrpool_test_snapshot")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Ensure uncompressed and compressed snapshots are written and verified.
+	for _, compress := range []bool{false, true} {
+		path, err := db.Snapshot(dir, compress)
+		if err != nil {
+			t.Fatalf("[Snapshot] compress %v: unexpected error: %v",
+				compress, err)
+		}
+		if !isSnapshot(filepath.Base(path)) {
+			t.Fatalf("[Snapshot] compress %v: unexpected snapshot name %s",
+				compress, path)
+		}
+		err = VerifySnapshot(path)
+		if err != nil {
+			t.Fatalf("[VerifySnapshot] compress %v: unexpected error: %v",
+				compress, err)
+		}
+	}
+
+	// Ensure no temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 snapshot files, got %d", len(files))
+	}
+
+	// Ensure corrupt snapshots fail verification.
+	corrupt := filepath.Join(dir, snapshotName(time.Unix(0, 0), false))
+	err = ioutil.WriteFile(corrupt, []byte("corrupt"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	err = VerifySnapshot(corrupt)
+	if err == nil {
+		t.Fatal("expected a corrupt snapshot verification error")
+	}
+	corruptGzip := filepath.Join(dir, snapshotName(time.Unix(1, 0), true))
+	err = ioutil.WriteFile(corruptGzip, []byte("corrupt"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	err = VerifySnapshot(corruptGzip)
+	if !IsError(err, ErrDecode) {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+
+	// Ensure pruning retains the most recent snapshots and ignores
+	// unrelated files.
+	unrelated := filepath.Join(dir, backupFile)
+	err = ioutil.WriteFile(unrelated, []byte("backup"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	err = pruneSnapshots(dir, 2)
+	if err != nil {
+		t.Fatalf("pruneSnapshots error: %v", err)
+	}
+	files, err = ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != backupFile ||
+		names[1] == filepath.Base(corrupt) ||
+		names[1] == filepath.Base(corruptGzip) {
+		t.Fatalf("expected the backup and the 2 most recent snapshots "+
+			"to remain, got %v", names)
+	}
+}
@@ -15,16 +15,21 @@ const (
 	// transactionId field to the payments struct for payment tracking purposes.
 	transactionIDVersion = 1
 
+	// acceptedWorkStatusVersion is the third version of the database. It
+	// replaces the confirmed flag of accepted work with its lifecycle status.
+	acceptedWorkStatusVersion = 2
+
 	// DBVersion is the latest version of the database that is understood by the
 	// program. Databases with recorded versions higher than this will fail to
 	// open (meaning any upgrades prevent reverting to older software).
-	DBVersion = transactionIDVersion
+	DBVersion = acceptedWorkStatusVersion
 )
 
 // upgrades maps between old database versions and the upgrade function to
 // upgrade the database to the next version.
 var upgrades = [...]func(tx *bolt.Tx) error{
-	transactionIDVersion - 1: transactionIDUpgrade,
+	transactionIDVersion - 1:      transactionIDUpgrade,
+	acceptedWorkStatusVersion - 1: acceptedWorkStatusUpgrade,
 }
 
 func fetchDBVersion(tx *bolt.Tx) (uint32, error) {
@@ -136,9 +141,77 @@ func transactionIDUpgrade(tx *bolt.Tx) error {
 	return setDBVersion(tx, newVersion)
 }
 
+func acceptedWorkStatusUpgrade(tx *bolt.Tx) error {
+	const oldVersion = 1
+	const newVersion = 2
+
+	dbVersion, err := fetchDBVersion(tx)
+	if err != nil {
+		return err
+	}
+
+	if dbVersion != oldVersion {
+		desc := "acceptedWorkStatusUpgrade inappropriately called"
+		return MakeError(ErrDBUpgrade, desc, nil)
+	}
+
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	// Update all entries in the work bucket.
+	//
+	// Confirmed work before the upgrade is marked confirmed and all other
+	// work accepted, both accepted at their creation time.
+
+	wbkt := pbkt.Bucket(workBkt)
+	if wbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(workBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	wCursor := wbkt.Cursor()
+	for k, v := wCursor.First(); k != nil; k, v = wCursor.Next() {
+		var legacy struct {
+			Confirmed bool `json:"confirmed"`
+		}
+		err := json.Unmarshal(v, &legacy)
+		if err != nil {
+			return err
+		}
+
+		var work AcceptedWork
+		err = json.Unmarshal(v, &work)
+		if err != nil {
+			return err
+		}
+
+		work.Status = WorkAccepted
+		if legacy.Confirmed {
+			work.Status = WorkConfirmed
+		}
+		work.SubmittedOn = work.CreatedOn
+		work.AcceptedOn = work.CreatedOn
+
+		wBytes, err := json.Marshal(work)
+		if err != nil {
+			return err
+		}
+
+		err = wbkt.Put(k, wBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return setDBVersion(tx, newVersion)
+}
+
 // upgradeDB checks whether the any upgrades are necessary before the database is
 // ready for application usage.  If any are, they are performed.
-func upgradeDB(db *bolt.DB) error {
+func upgradeDB(db *BoltDB) error {
 	var version uint32
 	err := db.View(func(tx *bolt.Tx) error {
 		pbkt := tx.Bucket(poolBkt)
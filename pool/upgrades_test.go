@@ -2,6 +2,7 @@ package pool
 
 import (
 	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,7 +14,7 @@ import (
 )
 
 var dbUpgradeTests = [...]struct {
-	verify   func(*testing.T, *bolt.DB)
+	verify   func(*testing.T, *BoltDB)
 	filename string // in testdata directory
 }{
 	// No upgrade test for V1, it is a backwards-compatible upgrade
@@ -68,3 +69,83 @@ func TestUpgrades(t *testing.T) {
 
 	os.RemoveAll(d)
 }
+
+func testAcceptedWorkStatusUpgrade(t *testing.T, db *BoltDB) {
+	// Persist legacy confirmed and unconfirmed accepted work at the
+	// previous database version.
+	legacy := map[string]bool{
+		"0000a1b2confirmed":   true,
+		"0000a1b3unconfirmed": false,
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		bkt, err := fetchBucket(tx, workBkt)
+		if err != nil {
+			return err
+		}
+		for id, confirmed := range legacy {
+			v, err := json.Marshal(map[string]interface{}{
+				"uuid":      id,
+				"height":    uint32(41394),
+				"createdon": int64(1590000000),
+				"confirmed": confirmed,
+			})
+			if err != nil {
+				return err
+			}
+			err = bkt.Put([]byte(id), v)
+			if err != nil {
+				return err
+			}
+		}
+		return setDBVersion(tx, acceptedWorkStatusVersion-1)
+	})
+	if err != nil {
+		t.Fatalf("unable to persist legacy work: %v", err)
+	}
+
+	err = upgradeDB(db)
+	if err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+
+	// Ensure legacy work is converted to its lifecycle status.
+	for id, confirmed := range legacy {
+		work, err := FetchAcceptedWork(db, []byte(id))
+		if err != nil {
+			t.Fatalf("FetchAcceptedWork error: %v", err)
+		}
+		expected := WorkAccepted
+		if confirmed {
+			expected = WorkConfirmed
+		}
+		if work.Status != expected {
+			t.Fatalf("expected work %s to be %s, got %s", id, expected,
+				work.Status)
+		}
+		if work.SubmittedOn != work.CreatedOn ||
+			work.AcceptedOn != work.CreatedOn {
+			t.Fatalf("expected work %s to be accepted at its creation "+
+				"time, got %d", id, work.AcceptedOn)
+		}
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		version, err := fetchDBVersion(tx)
+		if err != nil {
+			return err
+		}
+		if version != DBVersion {
+			return fmt.Errorf("expected db version %d, got %d",
+				DBVersion, version)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty the work bucket.
+	err = emptyBucket(db, workBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}
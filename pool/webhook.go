@@ -0,0 +1,289 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+const (
+	// MaxAccountWebhooks is the maximum number of webhooks an account can
+	// register.
+	MaxAccountWebhooks = 5
+
+	// signedMessagePrefix is the prefix of messages signed by decred
+	// wallets.
+	signedMessagePrefix = "Decred Signed Message:\n"
+)
+
+var (
+	// internalNets are the loopback, private, shared, link-local and
+	// unique local networks webhooks may not deliver to.
+	internalNets = parseCIDRs("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10",
+		"127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+		"::1/128", "fc00::/7", "fe80::/10")
+
+	// lookupIP resolves the addresses of webhook hosts.
+	lookupIP = net.LookupIP
+)
+
+// parseCIDRs parses the provided CIDR networks, it panics on invalid
+// networks.
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isInternalIP returns whether the provided address is not publicly
+// routable.
+func isInternalIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range internalNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhookHost asserts the provided webhook host resolves to publicly
+// routable addresses only.
+func checkWebhookHost(host string) error {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		ips, err = lookupIP(host)
+		if err != nil {
+			desc := fmt.Sprintf("unable to resolve webhook host %s", host)
+			return MakeError(ErrOther, desc, err)
+		}
+	}
+	for _, ip := range ips {
+		if isInternalIP(ip) {
+			desc := fmt.Sprintf("webhook host %s resolves to the internal "+
+				"address %s", host, ip)
+			return MakeError(ErrOther, desc, nil)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl rejects webhook delivery connections to internal
+// addresses, guarding against hosts resolving differently at delivery than
+// at registration.
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isInternalIP(ip) {
+		return fmt.Errorf("webhook delivery to internal address %s "+
+			"rejected", host)
+	}
+	return nil
+}
+
+// WebhookMessage returns the message the address of an account has to sign
+// to register the provided webhook url.
+func WebhookMessage(rawURL string) string {
+	return "dcrpool webhook " + rawURL
+}
+
+// verifyMessage asserts the provided base64 encoded compact signature of
+// the provided message was created by the key of the provided address.
+func verifyMessage(address string, signature string, message string, params *chaincfg.Params) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		desc := "invalid message signature encoding"
+		return MakeError(ErrInvalidSignature, desc, err)
+	}
+	var buf bytes.Buffer
+	err = wire.WriteVarString(&buf, 0, signedMessagePrefix)
+	if err != nil {
+		return err
+	}
+	err = wire.WriteVarString(&buf, 0, message)
+	if err != nil {
+		return err
+	}
+	pubKey, compressed, err := secp256k1.RecoverCompact(sig,
+		chainhash.HashB(buf.Bytes()))
+	if err != nil {
+		desc := "invalid message signature"
+		return MakeError(ErrInvalidSignature, desc, err)
+	}
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	addr, err := dcrutil.NewAddressSecpPubKey(serialized, params)
+	if err != nil {
+		desc := "invalid message signature"
+		return MakeError(ErrInvalidSignature, desc, err)
+	}
+	if addr.AddressPubKeyHash().EncodeAddress() != address {
+		desc := fmt.Sprintf("message not signed by address %s", address)
+		return MakeError(ErrInvalidSignature, desc, nil)
+	}
+	return nil
+}
+
+// Webhook represents a url registered by an account to receive notification
+// events of the account. Deliveries are signed with the webhook secret.
+type Webhook struct {
+	UUID      string `json:"uuid"`
+	AccountID string `json:"accountid"`
+	URL       string `json:"url"`
+	Secret    string `json:"secret"`
+	CreatedOn int64  `json:"createdon"`
+}
+
+// randomHex returns a hex encoded string of the provided number of random
+// bytes.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewWebhook creates a webhook of the provided account delivering to the
+// provided url. Only absolute http and https urls of hosts resolving to
+// publicly routable addresses are accepted.
+func NewWebhook(accountID string, rawURL string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Hostname() == "" {
+		desc := fmt.Sprintf("invalid webhook url %s", rawURL)
+		return nil, MakeError(ErrOther, desc, err)
+	}
+	err = checkWebhookHost(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{
+		UUID:      id,
+		AccountID: accountID,
+		URL:       u.String(),
+		Secret:    secret,
+		CreatedOn: time.Now().UnixNano(),
+	}, nil
+}
+
+// checkLimit asserts the account of the webhook can register it given the
+// provided webhooks already registered by the account.
+func (webhook *Webhook) checkLimit(registered []*Webhook) error {
+	if len(registered) >= MaxAccountWebhooks {
+		desc := fmt.Sprintf("account %s has reached the maximum of "+
+			"%d webhooks", webhook.AccountID, MaxAccountWebhooks)
+		return MakeError(ErrOther, desc, nil)
+	}
+	return nil
+}
+
+// FetchWebhook fetches the webhook referenced by the provided id.
+func FetchWebhook(db Database, id string) (*Webhook, error) {
+	return db.FetchWebhook(id)
+}
+
+// Create persists a webhook to the database. An account can register at
+// most MaxAccountWebhooks webhooks.
+func (webhook *Webhook) Create(db Database) error {
+	return db.PersistWebhook(webhook)
+}
+
+// Delete removes the associated webhook from the database.
+func (webhook *Webhook) Delete(db Database) error {
+	return db.DeleteWebhook(webhook.UUID)
+}
+
+// fetchAccountWebhooks fetches all webhooks registered by the provided
+// account.
+func fetchAccountWebhooks(db Database, accountID string) ([]*Webhook, error) {
+	return db.FetchAccountWebhooks(accountID)
+}
+
+// checkWebhookOwner asserts the provided base64 encoded signature of the
+// webhook message of the provided url was created by the address of the
+// provided account.
+func checkWebhookOwner(db Database, params *chaincfg.Params, accountID string, rawURL string, signature string) error {
+	account, err := FetchAccount(db, []byte(accountID))
+	if err != nil {
+		return err
+	}
+	return verifyMessage(account.Address, signature, WebhookMessage(rawURL),
+		params)
+}
+
+// AddWebhook registers a webhook delivering notification events of the
+// provided account to the provided url. The webhook message of the url has
+// to be signed by the address of the account, proving ownership of the
+// account.
+func (h *Hub) AddWebhook(accountID string, rawURL string, signature string) (*Webhook, error) {
+	err := checkWebhookOwner(h.db, h.cfg.ActiveNet, accountID, rawURL,
+		signature)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := NewWebhook(accountID, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	err = webhook.Create(h.db)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// RemoveWebhook removes the webhook of the provided account referenced by
+// the provided id. The webhook secret is required to remove it.
+func (h *Hub) RemoveWebhook(accountID string, id string, secret string) error {
+	webhook, err := FetchWebhook(h.db, id)
+	if err != nil {
+		return err
+	}
+	if webhook.AccountID != accountID || subtle.ConstantTimeCompare(
+		[]byte(webhook.Secret), []byte(secret)) != 1 {
+		desc := fmt.Sprintf("no webhook found for id %s", id)
+		return MakeError(ErrValueNotFound, desc, nil)
+	}
+	return webhook.Delete(h.db)
+}
@@ -0,0 +1,370 @@
+// Copyright (c) 2020 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// workerFlushInterval is the interval between persisting updated
+	// worker statistics.
+	workerFlushInterval = time.Second * 30
+
+	// maxWorkerNameLength is the maximum length of a worker name.
+	maxWorkerNameLength = 64
+
+	// maxAccountWorkers is the maximum number of workers tracked for an
+	// account.
+	maxAccountWorkers = 256
+
+	// workerPruneAge is the period after which offline workers are pruned.
+	// History samples are only taken of connected workers, so pruned
+	// workers have no history samples left after the hour history
+	// retention period.
+	workerPruneAge = hourHistoryRetention
+)
+
+// Worker represents a named mining worker of an account. Worker statistics
+// are tracked across all connections of the worker and survive reconnects.
+type Worker struct {
+	AccountID   string            `json:"accountid"`
+	Name        string            `json:"name"`
+	Miner       string            `json:"miner"`
+	FirstSeen   int64             `json:"firstseen"`
+	LastSeen    int64             `json:"lastseen"`
+	LastShare   int64             `json:"lastshare"`
+	HashRate    float64           `json:"hashrate"`
+	Accepted    uint64            `json:"accepted"`
+	Rejected    uint64            `json:"rejected"`
+	Rejections  map[string]uint64 `json:"rejections"`
+	Connections uint32            `json:"connections"`
+	Online      bool              `json:"online"`
+
+	// OfflineNotified indicates the account has been notified of the
+	// worker being offline since it last disconnected.
+	OfflineNotified bool `json:"offlinenotified"`
+}
+
+// copy returns a copy of the worker.
+func (w *Worker) copy() *Worker {
+	worker := *w
+	worker.Rejections = make(map[string]uint64, len(w.Rejections))
+	for reason, count := range w.Rejections {
+		worker.Rejections[reason] = count
+	}
+	return &worker
+}
+
+// workerRegistry tracks the statistics of all workers of the pool. Updates
+// are kept in memory and persisted periodically.
+type workerRegistry struct {
+	db       Database
+	workers  map[string]*Worker
+	accounts map[string]int
+	dirty    map[string]struct{}
+	mtx      sync.Mutex
+}
+
+// newWorkerRegistry creates a worker registry loaded with all persisted
+// workers. Loaded workers are marked offline since they have no connections
+// yet, workers online before the restart are considered last seen at the
+// restart.
+func newWorkerRegistry(db Database) (*workerRegistry, error) {
+	r := &workerRegistry{
+		db:       db,
+		workers:  make(map[string]*Worker),
+		accounts: make(map[string]int),
+		dirty:    make(map[string]struct{}),
+	}
+	workers, err := db.FetchWorkers()
+	if err != nil {
+		return nil, err
+	}
+	for _, worker := range workers {
+		id := WorkerID(worker.AccountID, worker.Name)
+		if worker.Online {
+			worker.Online = false
+			worker.Connections = 0
+			worker.HashRate = 0
+			worker.LastSeen = time.Now().UnixNano()
+			r.dirty[id] = struct{}{}
+		}
+		r.workers[id] = worker
+		r.accounts[worker.AccountID]++
+	}
+	return r, nil
+}
+
+// fetchWorker returns the registered worker of the provided account and
+// name, marking it updated. This must be called with the registry mutex
+// held.
+func (r *workerRegistry) fetchWorker(accountID string, name string) (*Worker, bool) {
+	id := WorkerID(accountID, name)
+	worker, ok := r.workers[id]
+	if !ok {
+		return nil, false
+	}
+	r.dirty[id] = struct{}{}
+	return worker, true
+}
+
+// connect records a new connection of the provided worker, registering the
+// worker if it does not exist. An error is returned if the account of the
+// worker has reached the maximum number of workers.
+func (r *workerRegistry) connect(accountID string, name string, miner string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	now := time.Now().UnixNano()
+	worker, ok := r.fetchWorker(accountID, name)
+	if !ok {
+		if r.accounts[accountID] >= maxAccountWorkers {
+			desc := fmt.Sprintf("account %s has reached the maximum "+
+				"of %d workers", accountID, maxAccountWorkers)
+			return MakeError(ErrOther, desc, nil)
+		}
+		worker = &Worker{
+			AccountID:  accountID,
+			Name:       name,
+			FirstSeen:  now,
+			Rejections: make(map[string]uint64),
+		}
+		id := WorkerID(accountID, name)
+		r.workers[id] = worker
+		r.accounts[accountID]++
+		r.dirty[id] = struct{}{}
+	}
+	worker.Miner = miner
+	worker.Connections++
+	worker.Online = true
+	worker.OfflineNotified = false
+	worker.LastSeen = now
+	return nil
+}
+
+// disconnect records a terminated connection of the provided worker. The
+// worker is marked offline once all of its connections are terminated.
+func (r *workerRegistry) disconnect(accountID string, name string) {
+	r.mtx.Lock()
+	worker, ok := r.fetchWorker(accountID, name)
+	if !ok {
+		r.mtx.Unlock()
+		return
+	}
+	if worker.Connections > 0 {
+		worker.Connections--
+	}
+	if worker.Connections == 0 {
+		worker.Online = false
+		worker.HashRate = 0
+	}
+	worker.LastSeen = time.Now().UnixNano()
+	r.mtx.Unlock()
+}
+
+// recordShare records a share submission of the provided worker with the
+// provided status and rejection reason.
+func (r *workerRegistry) recordShare(accountID string, name string, status string, reason string) {
+	r.mtx.Lock()
+	worker, ok := r.fetchWorker(accountID, name)
+	if !ok {
+		r.mtx.Unlock()
+		return
+	}
+	now := time.Now().UnixNano()
+	worker.LastSeen = now
+	if status == shareAccepted {
+		worker.Accepted++
+		worker.LastShare = now
+		r.mtx.Unlock()
+		return
+	}
+	worker.Rejected++
+	if reason == "" {
+		reason = status
+	}
+	worker.Rejections[reason]++
+	r.mtx.Unlock()
+}
+
+// setHashRates updates the hash rates of all workers from the provided
+// connected clients.
+func (r *workerRegistry) setHashRates(clients []*Client) {
+	rates := make(map[string]*big.Rat)
+	for _, c := range clients {
+		accountID := c.FetchAccountID()
+		if accountID == "" {
+			continue
+		}
+		id := WorkerID(accountID, c.FetchWorkerName())
+		rate, ok := rates[id]
+		if !ok {
+			rate = new(big.Rat)
+			rates[id] = rate
+		}
+		rate.Add(rate, c.FetchHashRate())
+	}
+
+	r.mtx.Lock()
+	for id, worker := range r.workers {
+		var hashRate float64
+		if rate, ok := rates[id]; ok {
+			hashRate, _ = rate.Float64()
+		}
+		if worker.HashRate != hashRate {
+			worker.HashRate = hashRate
+			r.dirty[id] = struct{}{}
+		}
+	}
+	r.mtx.Unlock()
+}
+
+// flush persists all updated workers.
+func (r *workerRegistry) flush() error {
+	r.mtx.Lock()
+	updates := make([]*Worker, 0, len(r.dirty))
+	for id := range r.dirty {
+		updates = append(updates, r.workers[id].copy())
+	}
+	r.dirty = make(map[string]struct{})
+	r.mtx.Unlock()
+
+	if len(updates) == 0 {
+		return nil
+	}
+	return r.db.PersistWorkers(updates)
+}
+
+// prune removes all workers offline for longer than the provided age.
+func (r *workerRegistry) prune(age time.Duration, now time.Time) error {
+	cutoff := now.Add(-age).UnixNano()
+	ids := make([]string, 0)
+	r.mtx.Lock()
+	for id, worker := range r.workers {
+		if worker.Online || worker.LastSeen > cutoff {
+			continue
+		}
+		delete(r.workers, id)
+		delete(r.dirty, id)
+		r.accounts[worker.AccountID]--
+		if r.accounts[worker.AccountID] == 0 {
+			delete(r.accounts, worker.AccountID)
+		}
+		ids = append(ids, id)
+	}
+	r.mtx.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.DeleteWorkers(ids)
+}
+
+// fetchOfflineWorkers returns copies of all workers offline for longer than
+// the provided threshold that are yet to be notified of. Returned workers
+// are marked notified.
+func (r *workerRegistry) fetchOfflineWorkers(threshold time.Duration, now time.Time) []*Worker {
+	cutoff := now.Add(-threshold).UnixNano()
+	workers := make([]*Worker, 0)
+	r.mtx.Lock()
+	for id, worker := range r.workers {
+		if worker.Online || worker.OfflineNotified ||
+			worker.LastSeen > cutoff {
+			continue
+		}
+		worker.OfflineNotified = true
+		r.dirty[id] = struct{}{}
+		workers = append(workers, worker.copy())
+	}
+	r.mtx.Unlock()
+	return workers
+}
+
+// fetchWorkers returns copies of all workers of the provided account.
+// Offline workers come first, followed by online workers, each ordered by
+// name.
+func (r *workerRegistry) fetchWorkers(accountID string) []*Worker {
+	r.mtx.Lock()
+	workers := make([]*Worker, 0)
+	for _, worker := range r.workers {
+		if worker.AccountID == accountID {
+			workers = append(workers, worker.copy())
+		}
+	}
+	r.mtx.Unlock()
+
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].Online != workers[j].Online {
+			return !workers[i].Online
+		}
+		return workers[i].Name < workers[j].Name
+	})
+	return workers
+}
+
+// addWorker records a new connection of the provided worker.
+func (h *Hub) addWorker(accountID string, name string, miner string) error {
+	return h.workers.connect(accountID, name, miner)
+}
+
+// removeWorker records a terminated connection of the provided worker.
+func (h *Hub) removeWorker(accountID string, name string) {
+	h.workers.disconnect(accountID, name)
+}
+
+// recordWorkerShare records a share submission of the provided worker.
+func (h *Hub) recordWorkerShare(accountID string, name string, status string, reason string) {
+	h.workers.recordShare(accountID, name, status, reason)
+}
+
+// workerMonitor periodically updates worker hash rates, prunes long offline
+// workers and persists updated worker statistics along with the round work.
+// It must be run as a goroutine.
+func (h *Hub) workerMonitor(ctx context.Context) {
+	ticker := time.NewTicker(workerFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err := h.workers.flush()
+			if err != nil {
+				log.Errorf("unable to persist workers: %v", err)
+			}
+			err = h.roundEffort.persist()
+			if err != nil {
+				log.Errorf("unable to persist round work: %v", err)
+			}
+			h.wg.Done()
+			return
+
+		case now := <-ticker.C:
+			h.workers.setHashRates(h.FetchClients())
+			h.notifyOfflineWorkers(now)
+			err := h.workers.prune(workerPruneAge, now)
+			if err != nil {
+				log.Errorf("unable to prune workers: %v", err)
+			}
+			err = h.workers.flush()
+			if err != nil {
+				log.Errorf("unable to persist workers: %v", err)
+			}
+			err = h.roundEffort.persist()
+			if err != nil {
+				log.Errorf("unable to persist round work: %v", err)
+			}
+		}
+	}
+}
+
+// FetchWorkers returns all workers of the provided account. Offline workers
+// come first, followed by online workers, each ordered by name.
+func (h *Hub) FetchWorkers(accountID string) []*Worker {
+	return h.workers.fetchWorkers(accountID)
+}
@@ -0,0 +1,168 @@
+package pool
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testWorkers(t *testing.T, db Database) {
+	workers, err := newWorkerRegistry(db)
+	if err != nil {
+		t.Fatalf("[newWorkerRegistry] unexpected error: %v", err)
+	}
+
+	// Ensure worker connections and share submissions are tracked.
+	for _, name := range []string{"b", "a", "a"} {
+		err = workers.connect(xID, name, CPU)
+		if err != nil {
+			t.Fatalf("[connect] unexpected error: %v", err)
+		}
+	}
+	err = workers.connect(yID, "a", CPU)
+	if err != nil {
+		t.Fatalf("[connect] unexpected error: %v", err)
+	}
+	workers.recordShare(xID, "a", shareAccepted, "")
+	workers.recordShare(xID, "a", shareRejected, reasonDuplicate)
+	workers.recordShare(xID, "a", shareStale, "")
+	workers.disconnect(xID, "a")
+	workers.disconnect(xID, "b")
+
+	xWorkers := workers.fetchWorkers(xID)
+	if len(xWorkers) != 2 {
+		t.Fatalf("expected 2 workers for account %s, got %d", xID,
+			len(xWorkers))
+	}
+	if xWorkers[0].Name != "b" || xWorkers[0].Online {
+		t.Fatalf("expected offline worker b first, got %+v", xWorkers[0])
+	}
+	worker := xWorkers[1]
+	if worker.Name != "a" || !worker.Online || worker.Connections != 1 {
+		t.Fatalf("expected online worker a with 1 connection, got %+v",
+			worker)
+	}
+	if worker.Accepted != 1 || worker.Rejected != 2 || worker.LastShare == 0 {
+		t.Fatalf("expected 1 accepted and 2 rejected shares, got %+v",
+			worker)
+	}
+	if worker.Rejections[reasonDuplicate] != 1 ||
+		worker.Rejections[shareStale] != 1 {
+		t.Fatalf("unexpected worker rejections: %v", worker.Rejections)
+	}
+
+	// Ensure workers offline beyond the threshold are reported once.
+	offline := workers.fetchOfflineWorkers(time.Minute, time.Now())
+	if len(offline) != 0 {
+		t.Fatalf("expected no offline workers, got %d", len(offline))
+	}
+	later := time.Now().Add(time.Hour)
+	offline = workers.fetchOfflineWorkers(time.Minute, later)
+	if len(offline) != 1 || offline[0].Name != "b" {
+		t.Fatalf("expected offline worker b, got %v", offline)
+	}
+	offline = workers.fetchOfflineWorkers(time.Minute, later)
+	if len(offline) != 0 {
+		t.Fatalf("expected offline workers to be reported once, got %d",
+			len(offline))
+	}
+
+	// Ensure worker hash rates are set from connected clients.
+	clients := []*Client{
+		{account: xID, name: "a", hashRate: big.NewRat(100, 1)},
+		{account: xID, name: "a", hashRate: big.NewRat(50, 1)},
+		{name: "solo", hashRate: big.NewRat(5, 1)},
+	}
+	workers.setHashRates(clients)
+	xWorkers = workers.fetchWorkers(xID)
+	if xWorkers[1].HashRate != 150 {
+		t.Fatalf("expected a worker hash rate of 150, got %v",
+			xWorkers[1].HashRate)
+	}
+
+	// Ensure persisted workers survive a restart and are marked offline.
+	err = workers.flush()
+	if err != nil {
+		t.Fatalf("[flush] unexpected error: %v", err)
+	}
+	workers, err = newWorkerRegistry(db)
+	if err != nil {
+		t.Fatalf("[newWorkerRegistry] unexpected error: %v", err)
+	}
+	xWorkers = workers.fetchWorkers(xID)
+	if len(xWorkers) != 2 {
+		t.Fatalf("expected 2 persisted workers for account %s, got %d",
+			xID, len(xWorkers))
+	}
+	worker = xWorkers[1]
+	if worker.Online || worker.Connections != 0 || worker.HashRate != 0 {
+		t.Fatalf("expected reloaded worker to be offline, got %+v", worker)
+	}
+	if worker.Accepted != 1 || worker.Rejections[reasonDuplicate] != 1 {
+		t.Fatalf("expected reloaded worker statistics, got %+v", worker)
+	}
+	yWorkers := workers.fetchWorkers(yID)
+	if len(yWorkers) != 1 {
+		t.Fatalf("expected 1 persisted worker for account %s, got %d",
+			yID, len(yWorkers))
+	}
+
+	// Ensure unregistered workers are not registered by share submissions.
+	workers.recordShare(yID, "unknown", shareAccepted, "")
+	yWorkers = workers.fetchWorkers(yID)
+	if len(yWorkers) != 1 {
+		t.Fatalf("expected 1 worker for account %s, got %d", yID,
+			len(yWorkers))
+	}
+
+	// Ensure an account cannot register more than the maximum number of
+	// workers, while existing workers can still connect.
+	for i := 1; i < maxAccountWorkers; i++ {
+		err = workers.connect(yID, fmt.Sprintf("w%d", i), CPU)
+		if err != nil {
+			t.Fatalf("[connect] unexpected error: %v", err)
+		}
+	}
+	err = workers.connect(yID, "excess", CPU)
+	if err == nil {
+		t.Fatal("[connect] expected a worker limit error")
+	}
+	err = workers.connect(yID, "a", CPU)
+	if err != nil {
+		t.Fatalf("[connect] unexpected error: %v", err)
+	}
+
+	// Ensure long offline workers are pruned from the registry and the
+	// database, while online workers are kept.
+	err = workers.prune(workerPruneAge, time.Now().Add(workerPruneAge*2))
+	if err != nil {
+		t.Fatalf("[prune] unexpected error: %v", err)
+	}
+	xWorkers = workers.fetchWorkers(xID)
+	if len(xWorkers) != 0 {
+		t.Fatalf("expected no workers for account %s, got %d", xID,
+			len(xWorkers))
+	}
+	yWorkers = workers.fetchWorkers(yID)
+	if len(yWorkers) != maxAccountWorkers {
+		t.Fatalf("expected %d online workers for account %s, got %d",
+			maxAccountWorkers, yID, len(yWorkers))
+	}
+	persisted, err := db.FetchWorkers()
+	if err != nil {
+		t.Fatalf("[FetchWorkers] unexpected error: %v", err)
+	}
+	for _, worker := range persisted {
+		if worker.AccountID == xID {
+			t.Fatalf("expected pruned worker %s to be deleted",
+				worker.Name)
+		}
+	}
+
+	// Empty the worker bucket.
+	err = emptyBucket(db, workerBkt)
+	if err != nil {
+		t.Fatalf("emptyBucket error: %v", err)
+	}
+}